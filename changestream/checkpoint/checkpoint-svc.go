@@ -1,5 +1,7 @@
 package checkpoint
 
+import "time"
+
 type ResumeTokenCheckpointSvc interface {
 	Retrieve(string) (ResumeToken, error)
 	StoreIdle(tokenId string, token ResumeToken) error
@@ -8,3 +10,10 @@ type ResumeTokenCheckpointSvc interface {
 	Clear(tokenId string) error
 	OnHistoryLost(tokenId string) error
 }
+
+// RewindableCheckpointSvc is implemented by the services that keep a history of the tokens: RewindTo makes active the newest token at or
+// before the given time so that the next start of the watcher reprocesses the events from there.
+type RewindableCheckpointSvc interface {
+	ResumeTokenCheckpointSvc
+	RewindTo(watcherId string, at time.Time) (ResumeToken, error)
+}
@@ -0,0 +1,112 @@
+package composite
+
+import (
+	"errors"
+
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/changestream/checkpoint"
+	"github.com/rs/zerolog/log"
+)
+
+// CheckpointSvc writes the checkpoints to a primary and a secondary service and retrieves the newest of the two tokens. Commits and
+// retrievals fail only if both services fail, so that a secondary local file keeps the consumer going while the primary cluster is
+// unavailable. Clear and OnHistoryLost must succeed on both: a token left on one side would be picked up by the next Retrieve.
+type CheckpointSvc struct {
+	primary   checkpoint.ResumeTokenCheckpointSvc
+	secondary checkpoint.ResumeTokenCheckpointSvc
+}
+
+func NewCheckpointSvc(primary, secondary checkpoint.ResumeTokenCheckpointSvc) (*CheckpointSvc, error) {
+	const semLogContext = "composite-checkpoint-svc::new"
+
+	if primary == nil || secondary == nil {
+		err := errors.New("composite checkpoint svc requires a primary and a secondary service")
+		log.Error().Err(err).Msg(semLogContext)
+		return nil, err
+	}
+
+	return &CheckpointSvc{primary: primary, secondary: secondary}, nil
+}
+
+func (svc *CheckpointSvc) Retrieve(watcherId string) (checkpoint.ResumeToken, error) {
+	const semLogContext = "composite-checkpoint::retrieve"
+
+	primaryToken, primaryErr := svc.primary.Retrieve(watcherId)
+	secondaryToken, secondaryErr := svc.secondary.Retrieve(watcherId)
+
+	switch {
+	case primaryErr != nil && secondaryErr != nil:
+		err := errors.Join(primaryErr, secondaryErr)
+		log.Error().Err(err).Str("watcher-id", watcherId).Msg(semLogContext)
+		return checkpoint.ResumeToken{}, err
+	case primaryErr != nil:
+		log.Warn().Err(primaryErr).Str("watcher-id", watcherId).Msg(semLogContext + " - primary unavailable, using secondary")
+		return secondaryToken, nil
+	case secondaryErr != nil:
+		log.Warn().Err(secondaryErr).Str("watcher-id", watcherId).Msg(semLogContext + " - secondary unavailable, using primary")
+		return primaryToken, nil
+	}
+
+	if secondaryToken.Compare(primaryToken) > 0 {
+		log.Info().Str("watcher-id", watcherId).Str("primary", primaryToken.Value).Str("secondary", secondaryToken.Value).Msg(semLogContext + " - secondary is newer")
+		return secondaryToken, nil
+	}
+
+	return primaryToken, nil
+}
+
+func (svc *CheckpointSvc) StoreIdle(watcherId string, token checkpoint.ResumeToken) error {
+	return svc.both("composite-checkpoint::store-idle", watcherId, func(s checkpoint.ResumeTokenCheckpointSvc) error {
+		return s.StoreIdle(watcherId, token)
+	})
+}
+
+func (svc *CheckpointSvc) ClearIdle() {
+	svc.primary.ClearIdle()
+	svc.secondary.ClearIdle()
+}
+
+func (svc *CheckpointSvc) CommitAt(watcherId string, token checkpoint.ResumeToken, syncRequired bool) error {
+	return svc.both("composite-checkpoint::commit-at", watcherId, func(s checkpoint.ResumeTokenCheckpointSvc) error {
+		return s.CommitAt(watcherId, token, syncRequired)
+	})
+}
+
+func (svc *CheckpointSvc) Clear(watcherId string) error {
+	return svc.all("composite-checkpoint::clear", watcherId, func(s checkpoint.ResumeTokenCheckpointSvc) error {
+		return s.Clear(watcherId)
+	})
+}
+
+func (svc *CheckpointSvc) OnHistoryLost(watcherId string) error {
+	return svc.all("composite-checkpoint::on-history-lost", watcherId, func(s checkpoint.ResumeTokenCheckpointSvc) error {
+		return s.OnHistoryLost(watcherId)
+	})
+}
+
+func (svc *CheckpointSvc) both(semLogContext string, watcherId string, op func(s checkpoint.ResumeTokenCheckpointSvc) error) error {
+	primaryErr := op(svc.primary)
+	secondaryErr := op(svc.secondary)
+
+	switch {
+	case primaryErr != nil && secondaryErr != nil:
+		err := errors.Join(primaryErr, secondaryErr)
+		log.Error().Err(err).Str("watcher-id", watcherId).Msg(semLogContext)
+		return err
+	case primaryErr != nil:
+		log.Warn().Err(primaryErr).Str("watcher-id", watcherId).Msg(semLogContext + " - primary failed")
+	case secondaryErr != nil:
+		log.Warn().Err(secondaryErr).Str("watcher-id", watcherId).Msg(semLogContext + " - secondary failed")
+	}
+
+	return nil
+}
+
+// all applies the operation to both services and fails if any of them fails.
+func (svc *CheckpointSvc) all(semLogContext string, watcherId string, op func(s checkpoint.ResumeTokenCheckpointSvc) error) error {
+	err := errors.Join(op(svc.primary), op(svc.secondary))
+	if err != nil {
+		log.Error().Err(err).Str("watcher-id", watcherId).Msg(semLogContext)
+	}
+
+	return err
+}
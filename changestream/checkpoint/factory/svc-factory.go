@@ -2,26 +2,49 @@ package factory
 
 import (
 	"fmt"
+	"sync"
+
 	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/changestream/checkpoint"
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/changestream/checkpoint/composite"
 	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/changestream/checkpoint/file"
 	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/changestream/checkpoint/mdb"
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/changestream/checkpoint/memory"
 	"github.com/rs/zerolog/log"
 )
 
 const (
-	SvcProviderMongo = "mongo"
-	SvcProviderFile  = "file"
+	SvcProviderMongo     = "mongo"
+	SvcProviderFile      = "file"
+	SvcProviderMemory    = "memory"
+	SvcProviderComposite = "composite"
 )
 
 type Config struct {
-	Typ                string `yaml:"type,omitempty" mapstructure:"type,omitempty" json:"type,omitempty"`
-	Fn                 string `yaml:"file-name,omitempty" mapstructure:"file-name,omitempty" json:"file-name,omitempty"`
-	Stride             int    `yaml:"stride,omitempty" mapstructure:"stride,omitempty" json:"stride,omitempty"`
-	MongoInstance      string `yaml:"mongo-db-instance,omitempty" mapstructure:"mongo-db-instance,omitempty" json:"mongo-db-instance,omitempty"`
-	MongoCollectionId  string `yaml:"mongo-db-collection-id,omitempty" mapstructure:"mongo-db-collection-id,omitempty" json:"mongo-db-collection-id,omitempty"`
-	ClearOnHistoryLost bool   `yaml:"clear-on-history-lost,omitempty" mapstructure:"clear-on-history-lost,omitempty" json:"clear-on-history-lost,omitempty"`
+	Typ                string            `yaml:"type,omitempty" mapstructure:"type,omitempty" json:"type,omitempty"`
+	Fn                 string            `yaml:"file-name,omitempty" mapstructure:"file-name,omitempty" json:"file-name,omitempty"`
+	Dir                string            `yaml:"dir-name,omitempty" mapstructure:"dir-name,omitempty" json:"dir-name,omitempty"`
+	Stride             int               `yaml:"stride,omitempty" mapstructure:"stride,omitempty" json:"stride,omitempty"`
+	MongoInstance      string            `yaml:"mongo-db-instance,omitempty" mapstructure:"mongo-db-instance,omitempty" json:"mongo-db-instance,omitempty"`
+	MongoCollectionId  string            `yaml:"mongo-db-collection-id,omitempty" mapstructure:"mongo-db-collection-id,omitempty" json:"mongo-db-collection-id,omitempty"`
+	MongoHistory       mdb.HistoryConfig `yaml:"mongo-db-history,omitempty" mapstructure:"mongo-db-history,omitempty" json:"mongo-db-history,omitempty"`
+	ClearOnHistoryLost bool              `yaml:"clear-on-history-lost,omitempty" mapstructure:"clear-on-history-lost,omitempty" json:"clear-on-history-lost,omitempty"`
+
+	// Primary and Secondary are the services of a composite provider.
+	Primary   *Config `yaml:"primary,omitempty" mapstructure:"primary,omitempty" json:"primary,omitempty"`
+	Secondary *Config `yaml:"secondary,omitempty" mapstructure:"secondary,omitempty" json:"secondary,omitempty"`
+}
+
+// memorySvcs are the memory services created by the factory, by configuration: a consumer restarted in the same process (i.e. the
+// rewind of a producer) gets the service, and the tokens, of its previous instance.
+var memorySvcs sync.Map
+
+func memoryCheckPointSvc(cfg memory.CheckpointSvcConfig) *memory.CheckpointSvc {
+	svc, _ := memorySvcs.LoadOrStore(cfg, memory.NewCheckpointSvc(cfg))
+	return svc.(*memory.CheckpointSvc)
 }
 
+// NewCheckPointSvc returns the service of the configured type. The memory services are process wide: the same configuration always
+// returns the same service.
 func NewCheckPointSvc(config Config) (checkpoint.ResumeTokenCheckpointSvc, error) {
 	const semLogContext = "checkpoint-svc::new"
 	var err error
@@ -32,15 +55,23 @@ func NewCheckPointSvc(config Config) (checkpoint.ResumeTokenCheckpointSvc, error
 		svc, err = mdb.NewCheckpointSvc(mdb.CheckpointSvcConfig{
 			Instance:           config.MongoInstance,
 			CollectionId:       config.MongoCollectionId,
+			History:            config.MongoHistory,
 			Stride:             config.Stride,
 			ClearOnHistoryLost: config.ClearOnHistoryLost,
 		})
 	case SvcProviderFile:
 		svc = file.NewCheckpointSvc(file.CheckpointSvcConfig{
 			Fn:                 config.Fn,
+			Dir:                config.Dir,
 			Stride:             config.Stride,
 			ClearOnHistoryLost: config.ClearOnHistoryLost,
 		})
+	case SvcProviderMemory:
+		svc = memoryCheckPointSvc(memory.CheckpointSvcConfig{
+			ClearOnHistoryLost: config.ClearOnHistoryLost,
+		})
+	case SvcProviderComposite:
+		svc, err = newCompositeCheckPointSvc(config)
 	default:
 		err = fmt.Errorf("unknown checkpoint service type: %s", config.Typ)
 		log.Error().Err(err).Msg(semLogContext)
@@ -48,3 +79,30 @@ func NewCheckPointSvc(config Config) (checkpoint.ResumeTokenCheckpointSvc, error
 
 	return svc, err
 }
+
+func newCompositeCheckPointSvc(config Config) (checkpoint.ResumeTokenCheckpointSvc, error) {
+	const semLogContext = "checkpoint-svc::new-composite"
+
+	if config.Primary == nil || config.Secondary == nil {
+		err := fmt.Errorf("checkpoint service type %s requires a primary and a secondary", config.Typ)
+		log.Error().Err(err).Msg(semLogContext)
+		return nil, err
+	}
+
+	primary, err := NewCheckPointSvc(*config.Primary)
+	if err != nil {
+		return nil, err
+	}
+
+	secondary, err := NewCheckPointSvc(*config.Secondary)
+	if err != nil {
+		return nil, err
+	}
+
+	svc, err := composite.NewCheckpointSvc(primary, secondary)
+	if err != nil {
+		return nil, err
+	}
+
+	return svc, nil
+}
@@ -0,0 +1,114 @@
+package factory_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/changestream/checkpoint"
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/changestream/checkpoint/composite"
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/changestream/checkpoint/factory"
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/changestream/checkpoint/memory"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testToken1 = "8267C867A3000000012B0429296E1404"
+	testToken2 = "8267C867B3000000022B0429296E1404"
+)
+
+func TestMemoryCheckpointSvc(t *testing.T) {
+	svc, err := factory.NewCheckPointSvc(factory.Config{Typ: factory.SvcProviderMemory, ClearOnHistoryLost: true})
+	require.NoError(t, err)
+
+	require.NoError(t, svc.CommitAt("w1", checkpoint.ResumeToken{Value: testToken1}, false))
+	tok, err := svc.Retrieve("w1")
+	require.NoError(t, err)
+	require.Equal(t, testToken1, tok.Value)
+
+	// a second service with the same configuration, i.e. after the restart of the consumer, finds the token.
+	svc2, err := factory.NewCheckPointSvc(factory.Config{Typ: factory.SvcProviderMemory, ClearOnHistoryLost: true})
+	require.NoError(t, err)
+	tok, err = svc2.Retrieve("w1")
+	require.NoError(t, err)
+	require.Equal(t, testToken1, tok.Value)
+
+	require.NoError(t, svc.OnHistoryLost("w1"))
+	tok, err = svc2.Retrieve("w1")
+	require.NoError(t, err)
+	require.True(t, tok.IsZero())
+}
+
+func TestCompositeCheckpointSvc(t *testing.T) {
+	cfg := factory.Config{
+		Typ:       factory.SvcProviderComposite,
+		Primary:   &factory.Config{Typ: factory.SvcProviderMemory},
+		Secondary: &factory.Config{Typ: factory.SvcProviderFile, Fn: filepath.Join(t.TempDir(), "checkpoints.json")},
+	}
+
+	svc, err := factory.NewCheckPointSvc(cfg)
+	require.NoError(t, err)
+
+	require.NoError(t, svc.CommitAt("w1", checkpoint.ResumeToken{Value: testToken1}, true))
+	tok, err := svc.Retrieve("w1")
+	require.NoError(t, err)
+	require.Equal(t, testToken1, tok.Value)
+
+	_, err = factory.NewCheckPointSvc(factory.Config{Typ: factory.SvcProviderComposite, Primary: cfg.Primary})
+	require.Error(t, err)
+}
+
+type unavailableSvc struct {
+	checkpoint.ResumeTokenCheckpointSvc
+}
+
+var errUnavailable = errors.New("unavailable")
+
+func (unavailableSvc) Retrieve(string) (checkpoint.ResumeToken, error) {
+	return checkpoint.ResumeToken{}, errUnavailable
+}
+
+func (unavailableSvc) CommitAt(string, checkpoint.ResumeToken, bool) error {
+	return errUnavailable
+}
+
+func (unavailableSvc) Clear(string) error {
+	return errUnavailable
+}
+
+func (unavailableSvc) OnHistoryLost(string) error {
+	return errUnavailable
+}
+
+func TestCompositeCheckpointSvcNewest(t *testing.T) {
+	primary := memory.NewCheckpointSvc(memory.CheckpointSvcConfig{})
+	secondary := memory.NewCheckpointSvc(memory.CheckpointSvcConfig{})
+
+	svc, err := composite.NewCheckpointSvc(primary, secondary)
+	require.NoError(t, err)
+
+	require.NoError(t, primary.CommitAt("w1", checkpoint.ResumeToken{Value: testToken1}, true))
+	require.NoError(t, secondary.CommitAt("w1", checkpoint.ResumeToken{Value: testToken2}, true))
+	tok, err := svc.Retrieve("w1")
+	require.NoError(t, err)
+	require.Equal(t, testToken2, tok.Value)
+
+	// a failing primary does not stop the commits nor the retrieval.
+	svc, err = composite.NewCheckpointSvc(unavailableSvc{}, secondary)
+	require.NoError(t, err)
+	require.NoError(t, svc.CommitAt("w2", checkpoint.ResumeToken{Value: testToken1}, true))
+	tok, err = svc.Retrieve("w2")
+	require.NoError(t, err)
+	require.Equal(t, testToken1, tok.Value)
+
+	// a clear must reach both services, the one that succeeded is cleared anyway.
+	require.ErrorIs(t, svc.Clear("w2"), errUnavailable)
+	require.ErrorIs(t, svc.OnHistoryLost("w2"), errUnavailable)
+	tok, err = secondary.Retrieve("w2")
+	require.NoError(t, err)
+	require.True(t, tok.IsZero())
+
+	svc, err = composite.NewCheckpointSvc(unavailableSvc{}, unavailableSvc{})
+	require.NoError(t, err)
+	require.ErrorIs(t, svc.CommitAt("w2", checkpoint.ResumeToken{Value: testToken1}, true), errUnavailable)
+}
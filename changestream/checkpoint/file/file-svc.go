@@ -3,66 +3,124 @@ package file
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
 	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-common/util/fileutil"
 	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/changestream/checkpoint"
 	"github.com/rs/zerolog/log"
-	"os"
-	"time"
+)
+
+const (
+	CheckPointStatusActive  = "active"
+	CheckPointStatusCleared = "cleared"
+
+	LastIdleStoreInterval = 5 * time.Minute
+
+	checkpointFilePerm = 0o644
+	checkpointDirPerm  = 0o755
+	checkpointFileExt  = ".json"
 )
 
 type Document struct {
-	Bid         string `json:"_bid,omitempty" bson:"_bid,omitempty" yaml:"_bid,omitempty"`
-	Et          string `json:"_et,omitempty" bson:"_et,omitempty" yaml:"_et,omitempty"`
-	ResumeToken string `json:"resume_token,omitempty" bson:"resume_token,omitempty" yaml:"resume_token,omitempty"`
-	At          string `json:"at,omitempty" bson:"at,omitempty" yaml:"at,omitempty"`
-	ShortToken  string `json:"short_token,omitempty" bson:"short_token,omitempty" yaml:"short_token,omitempty"`
-	TxnOpnIndex string `json:"txn_opn_index,omitempty" bson:"txn_opn_index,omitempty" yaml:"txn_opn_index,omitempty"`
+	Bid              string `json:"_bid,omitempty" bson:"_bid,omitempty" yaml:"_bid,omitempty"`
+	Et               string `json:"_et,omitempty" bson:"_et,omitempty" yaml:"_et,omitempty"`
+	ResumeToken      string `json:"resume_token,omitempty" bson:"resume_token,omitempty" yaml:"resume_token,omitempty"`
+	At               string `json:"at,omitempty" bson:"at,omitempty" yaml:"at,omitempty"`
+	ShortToken       string `json:"short_token,omitempty" bson:"short_token,omitempty" yaml:"short_token,omitempty"`
+	TxnOpnIndex      string `json:"txn_opn_index,omitempty" bson:"txn_opn_index,omitempty" yaml:"txn_opn_index,omitempty"`
+	Status           string `json:"status,omitempty" bson:"status,omitempty" yaml:"status,omitempty"`
+	OpCount          int    `json:"op_count,omitempty" bson:"op_count,omitempty" yaml:"op_count,omitempty"`
+	SavedAt          string `json:"saved_at,omitempty" bson:"saved_at,omitempty" yaml:"saved_at,omitempty"`
+	HistoryLostCount int    `json:"history_lost_count,omitempty" bson:"history_lost_count,omitempty" yaml:"history_lost_count,omitempty"`
+	HistoryLostAt    string `json:"history_lost_at,omitempty" bson:"history_lost_at,omitempty" yaml:"history_lost_at,omitempty"`
+}
+
+func (d Document) IsZero() bool {
+	return d.Bid == "" && d.ResumeToken == ""
+}
+
+// File is the content of the single file of a CheckpointSvc configured with a file name: the documents of the watchers by id.
+type File struct {
+	Checkpoints map[string]Document `json:"checkpoints,omitempty" yaml:"checkpoints,omitempty"`
 }
 
+// CheckpointSvcConfig: with a file name the checkpoints of all the watchers share a single file, with a directory every watcher gets
+// its own file named after the (escaped) watcher id. The directory takes precedence.
 type CheckpointSvcConfig struct {
 	Fn                 string `yaml:"file-name,omitempty" mapstructure:"file-name,omitempty" json:"file-name,omitempty"`
+	Dir                string `yaml:"dir-name,omitempty" mapstructure:"dir-name,omitempty" json:"dir-name,omitempty"`
 	Stride             int    `yaml:"stride,omitempty" mapstructure:"stride,omitempty" json:"stride,omitempty"`
 	ClearOnHistoryLost bool   `yaml:"clear-on-history-lost,omitempty" mapstructure:"clear-on-history-lost,omitempty" json:"clear-on-history-lost,omitempty"`
 }
 
+type watcherState struct {
+	lastCommitted checkpoint.ResumeToken
+	lastSaved     checkpoint.ResumeToken
+	lastIdle      checkpoint.ResumeToken
+	numberOfTicks int
+}
+
+// CheckpointSvc keeps the state of its watchers under its own mutex. The read-modify-write of a file is serialized by a lock shared by
+// all the services of the process (see lockFile), so that two services configured with the same file do not lose each other's tokens.
+// Services of different processes must not share a file.
 type CheckpointSvc struct {
-	cfg           CheckpointSvcConfig
-	LastCommitted checkpoint.ResumeToken
-	LastSaved     checkpoint.ResumeToken
-	NumberOfTicks int
+	cfg      CheckpointSvcConfig
+	mu       sync.Mutex
+	watchers map[string]*watcherState
+}
+
+// fileLocks holds a mutex for each checkpoint file, by cleaned absolute path.
+var fileLocks sync.Map
+
+// lockFile locks the file for the services of the process and returns the function that unlocks it.
+func lockFile(fn string) func() {
+	if abs, err := filepath.Abs(fn); err == nil {
+		fn = abs
+	}
+
+	mu, _ := fileLocks.LoadOrStore(filepath.Clean(fn), &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+	return mu.(*sync.Mutex).Unlock
 }
 
 func NewCheckpointSvc(cfg CheckpointSvcConfig) checkpoint.ResumeTokenCheckpointSvc {
 	return &CheckpointSvc{
-		cfg:           cfg,
-		NumberOfTicks: -1,
+		cfg:      cfg,
+		watchers: make(map[string]*watcherState),
+	}
+}
+
+func (f *CheckpointSvc) state(watcherId string) *watcherState {
+	s, ok := f.watchers[watcherId]
+	if !ok {
+		s = &watcherState{numberOfTicks: -1}
+		f.watchers[watcherId] = s
 	}
 
+	return s
 }
 
 func (f *CheckpointSvc) Retrieve(watcherId string) (checkpoint.ResumeToken, error) {
 	const semLogContext = "file-checkpoint::retrieve"
 
-	var err error
-	var token checkpoint.ResumeToken
-
-	if !fileutil.FileExists(f.cfg.Fn) {
-		err = errors.New("file does not exist")
-		log.Warn().Err(err).Str("fn", f.cfg.Fn).Msg(semLogContext)
-		return token, nil
-	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
 
-	b, err := os.ReadFile(f.cfg.Fn)
+	var token checkpoint.ResumeToken
+	d, err := f.load(watcherId)
 	if err != nil {
-		log.Error().Err(err).Msg(semLogContext)
+		log.Error().Err(err).Str("watcher-id", watcherId).Msg(semLogContext)
 		return token, err
 	}
 
-	d := Document{}
-	err = json.Unmarshal(b, &d)
-	if err != nil {
-		log.Error().Err(err).Msg(semLogContext)
-		return token, err
+	if d.IsZero() || d.Status == CheckPointStatusCleared {
+		log.Info().Str("watcher-id", watcherId).Msg(semLogContext + " - no active checkpoint")
+		return token, nil
 	}
 
 	token.At = d.At
@@ -70,64 +128,80 @@ func (f *CheckpointSvc) Retrieve(watcherId string) (checkpoint.ResumeToken, erro
 	return token, nil
 }
 
-//func (f *CheckpointSvc) CommitAt2(watcherId string, rt checkpoint.ResumeToken, syncRequired bool) error {
-//	const semLogContext = "file-checkpoint::synch"
-//	var err error
-//
-//	// level is warn since this is called in cases when you want to force the update of synchpoint in case of errors
-//	if !rt.IsZero() {
-//		log.Warn().Str("rt", rt.String()).Msg(semLogContext + " synch on last-delivered")
-//		err = f.save(watcherId, rt)
-//	} else {
-//		if !f.LastCommitted.IsZero() {
-//			log.Warn().Str("rt", f.LastCommitted.String()).Msg(semLogContext + " synch on last-committed")
-//			err = f.save(watcherId, f.LastCommitted)
-//		}
-//	}
-//
-//	if err != nil {
-//		log.Error().Err(err).Msg(semLogContext)
-//		return err
-//	}
-//
-//	return nil
-//}
-
 func (f *CheckpointSvc) StoreIdle(watcherId string, token checkpoint.ResumeToken) error {
-	return errors.New("still not implemented for file checkpoint svc")
+	const semLogContext = "file-checkpoint::store-idle"
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	s := f.state(watcherId)
+	if s.lastIdle.IsZero() {
+		s.lastIdle = token
+		return nil
+	}
+
+	elapsed := LastIdleStoreInterval + time.Second
+	if lastIdleTm, err := time.Parse(time.RFC3339Nano, s.lastIdle.At); err == nil {
+		elapsed = time.Since(lastIdleTm)
+	} else {
+		log.Error().Err(err).Str("watcher-id", watcherId).Msg(semLogContext)
+	}
+
+	if elapsed <= LastIdleStoreInterval {
+		return nil
+	}
+
+	err := f.save(watcherId, token)
+	if err == nil {
+		s.numberOfTicks = 0
+		s.lastIdle = token
+		s.lastSaved = token
+	}
+
+	return err
 }
 
 func (f *CheckpointSvc) ClearIdle() {
-	const semLogContext = "file-checkpoint::clear-idle"
-	err := errors.New("still not implemented for file checkpoint svc")
-	log.Error().Err(err).Msg(semLogContext)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, s := range f.watchers {
+		s.lastIdle = checkpoint.ResumeToken{}
+	}
 }
 
 func (f *CheckpointSvc) CommitAt(watcherId string, token checkpoint.ResumeToken, syncRequired bool) error {
-	var err error
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	s := f.state(watcherId)
 
 	// last committed contains the last token that's been stored or not.
 	if !token.IsZero() {
-		f.LastCommitted = token
+		s.lastCommitted = token
+	}
+
+	if s.lastCommitted.IsZero() {
+		return nil
 	}
 
 	doSave := syncRequired
-	if f.NumberOfTicks < 0 {
+	if s.numberOfTicks < 0 {
+		doSave = true
+		s.numberOfTicks = 0
+	} else if (s.numberOfTicks + 1) >= f.cfg.Stride {
 		doSave = true
-		f.NumberOfTicks = 0
-	} else {
-		if (f.NumberOfTicks + 1) >= f.cfg.Stride {
-			doSave = true
-		}
 	}
 
-	f.NumberOfTicks++
-	if doSave {
-		err = f.save(watcherId, token)
-		if err == nil {
-			f.NumberOfTicks = 0
-			f.LastSaved = token
-		}
+	s.numberOfTicks++
+	if !doSave {
+		return nil
+	}
+
+	err := f.save(watcherId, s.lastCommitted)
+	if err == nil {
+		s.numberOfTicks = 0
+		s.lastSaved = s.lastCommitted
 	}
 
 	return err
@@ -136,48 +210,233 @@ func (f *CheckpointSvc) CommitAt(watcherId string, token checkpoint.ResumeToken,
 func (f *CheckpointSvc) save(watcherId string, token checkpoint.ResumeToken) error {
 	const semLogContext = "file-checkpoint::save"
 
-	log.Trace().Str("fn", f.cfg.Fn).Msg(semLogContext)
-
 	info, err := token.Parse()
 	if err != nil {
+		log.Error().Err(err).Str("watcher-id", watcherId).Msg(semLogContext)
 		return err
 	}
 
-	d := Document{
-		Bid:         watcherId,
-		Et:          "",
-		ResumeToken: token.Value,
-		At:          time.Now().Format(time.RFC3339),
-		ShortToken:  token.ShortVersion(),
-		TxnOpnIndex: info.TxnOpIndex,
-	}
+	return f.update(watcherId, func(d *Document) {
+		at := token.At
+		if at == "" {
+			at = time.Now().Format(time.RFC3339Nano)
+		}
+
+		d.ResumeToken = token.Value
+		d.At = at
+		d.ShortToken = token.ShortVersion()
+		d.TxnOpnIndex = info.TxnOpIndex
+		d.Status = CheckPointStatusActive
+		d.OpCount++
+	})
+}
+
+func (f *CheckpointSvc) Clear(watcherId string) error {
+	const semLogContext = "file-checkpoint::clear"
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delete(f.watchers, watcherId)
+	return f.update(watcherId, func(d *Document) {
+		if d.ResumeToken != "" {
+			log.Warn().Str("watcher-id", watcherId).Str("resume-token", d.ResumeToken).Str("at", d.At).Msg(semLogContext + " checkpoint to clear")
+		}
+		d.Status = CheckPointStatusCleared
+	})
+}
+
+// OnHistoryLost records the event in the document of the watcher and, if so configured, clears its checkpoint.
+func (f *CheckpointSvc) OnHistoryLost(watcherId string) error {
+	const semLogContext = "file-checkpoint::on-history-lost"
+
+	f.mu.Lock()
+	err := f.update(watcherId, func(d *Document) {
+		d.HistoryLostCount++
+		d.HistoryLostAt = time.Now().Format(time.RFC3339Nano)
+	})
+	f.mu.Unlock()
 
-	b, err := json.Marshal(d)
 	if err != nil {
+		log.Error().Err(err).Str("watcher-id", watcherId).Msg(semLogContext)
+		return err
+	}
+
+	if f.cfg.ClearOnHistoryLost {
+		return f.Clear(watcherId)
+	}
+
+	return nil
+}
+
+// update applies a change to the document of the watcher and writes it back. The caller holds the lock of the service, update takes
+// the one of the file.
+func (f *CheckpointSvc) update(watcherId string, apply func(d *Document)) error {
+	const semLogContext = "file-checkpoint::update"
+
+	if f.cfg.Dir != "" {
+		fn := f.watcherFileName(watcherId)
+		defer lockFile(fn)()
+
+		d, err := readDocument(fn)
+		if err != nil {
+			log.Error().Err(err).Str("fn", fn).Msg(semLogContext)
+			return err
+		}
+
+		d.Bid = watcherId
+		apply(&d)
+		d.SavedAt = time.Now().Format(time.RFC3339Nano)
+		return writeJsonAtomic(fn, d)
+	}
+
+	if f.cfg.Fn == "" {
+		err := errors.New("file checkpoint svc has neither a file nor a directory configured")
 		log.Error().Err(err).Msg(semLogContext)
 		return err
 	}
 
-	err = os.WriteFile(f.cfg.Fn, b, os.ModePerm)
+	defer lockFile(f.cfg.Fn)()
+
+	content, err := readFile(f.cfg.Fn)
 	if err != nil {
-		log.Error().Err(err).Msg(semLogContext)
+		log.Error().Err(err).Str("fn", f.cfg.Fn).Msg(semLogContext)
 		return err
 	}
 
-	return nil
+	d := content.Checkpoints[watcherId]
+	d.Bid = watcherId
+	apply(&d)
+	d.SavedAt = time.Now().Format(time.RFC3339Nano)
+	content.Checkpoints[watcherId] = d
+	return writeJsonAtomic(f.cfg.Fn, content)
 }
 
-func (f *CheckpointSvc) Clear(watcherId string) error {
-	const semLogContext = "file-checkpoint::clear"
-	err := errors.New("clearing not implemented on file checkpoint svc")
-	log.Warn().Err(err).Msg(semLogContext)
-	return nil
+func (f *CheckpointSvc) load(watcherId string) (Document, error) {
+	if f.cfg.Dir != "" {
+		return readDocument(f.watcherFileName(watcherId))
+	}
+
+	content, err := readFile(f.cfg.Fn)
+	if err != nil {
+		return Document{}, err
+	}
+
+	return content.Checkpoints[watcherId], nil
 }
 
-func (f *CheckpointSvc) OnHistoryLost(watcherId string) error {
-	if f.cfg.ClearOnHistoryLost {
-		return f.Clear(watcherId)
+func (f *CheckpointSvc) watcherFileName(watcherId string) string {
+	return filepath.Join(f.cfg.Dir, url.PathEscape(watcherId)+checkpointFileExt)
+}
+
+func readDocument(fn string) (Document, error) {
+	var d Document
+	if !fileutil.FileExists(fn) {
+		return d, nil
+	}
+
+	b, err := os.ReadFile(fn)
+	if err != nil {
+		return d, err
+	}
+
+	err = json.Unmarshal(b, &d)
+	return d, err
+}
+
+// readFile reads the checkpoints of a shared file. A file in the former single document format is read as the checkpoint of its _bid.
+func readFile(fn string) (File, error) {
+	var content File
+	if !fileutil.FileExists(fn) {
+		content.Checkpoints = make(map[string]Document)
+		return content, nil
+	}
+
+	b, err := os.ReadFile(fn)
+	if err != nil {
+		return content, err
+	}
+
+	if len(b) == 0 {
+		content.Checkpoints = make(map[string]Document)
+		return content, nil
+	}
+
+	if err = json.Unmarshal(b, &content); err != nil {
+		return content, err
+	}
+
+	if content.Checkpoints == nil {
+		content.Checkpoints = make(map[string]Document)
+		var d Document
+		if err = json.Unmarshal(b, &d); err == nil && !d.IsZero() {
+			if d.Status == "" {
+				d.Status = CheckPointStatusActive
+			}
+			content.Checkpoints[d.Bid] = d
+		}
+	}
+
+	return content, nil
+}
+
+// writeJsonAtomic replaces the file with a temporary file written and synced in the same directory: a crash leaves either the old
+// or the new content, never a truncated one.
+func writeJsonAtomic(fn string, v interface{}) error {
+	const semLogContext = "file-checkpoint::write"
+
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		log.Error().Err(err).Msg(semLogContext)
+		return err
+	}
+
+	dir := filepath.Dir(fn)
+	if err = os.MkdirAll(dir, checkpointDirPerm); err != nil {
+		log.Error().Err(err).Str("dir", dir).Msg(semLogContext)
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(fn)+".*.tmp")
+	if err != nil {
+		log.Error().Err(err).Str("fn", fn).Msg(semLogContext)
+		return err
+	}
+
+	tmpName := tmp.Name()
+	err = writeAndSync(tmp, b)
+	if err == nil {
+		err = os.Rename(tmpName, fn)
+	}
+
+	if err != nil {
+		_ = os.Remove(tmpName)
+		log.Error().Err(err).Str("fn", fn).Msg(semLogContext)
+		return err
+	}
+
+	// the rename is durable once the directory entry is synced, not every platform supports it.
+	if d, err := os.Open(dir); err == nil {
+		_ = d.Sync()
+		_ = d.Close()
 	}
 
 	return nil
 }
+
+func writeAndSync(tmp *os.File, b []byte) error {
+	_, err := tmp.Write(b)
+	if err == nil {
+		err = tmp.Sync()
+	}
+
+	if err == nil {
+		err = tmp.Chmod(checkpointFilePerm)
+	}
+
+	if errClose := tmp.Close(); err == nil && errClose != nil {
+		err = fmt.Errorf("closing %s: %w", tmp.Name(), errClose)
+	}
+
+	return err
+}
@@ -0,0 +1,135 @@
+package file_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/changestream/checkpoint"
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/changestream/checkpoint/file"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testToken1 = "8267C867A3000000012B0429296E1404"
+	testToken2 = "8267C867B3000000022B0429296E1404"
+)
+
+func TestSharedFileCheckpointSvc(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "checkpoints.json")
+	svc := file.NewCheckpointSvc(file.CheckpointSvcConfig{Fn: fn, Stride: 2})
+
+	require.NoError(t, svc.CommitAt("w1", checkpoint.ResumeToken{Value: testToken1}, false))
+	require.NoError(t, svc.CommitAt("w2", checkpoint.ResumeToken{Value: testToken2}, false))
+
+	// within the stride the token is not saved unless a sync is required.
+	require.NoError(t, svc.CommitAt("w1", checkpoint.ResumeToken{Value: testToken2}, false))
+	tok, err := svc.Retrieve("w1")
+	require.NoError(t, err)
+	require.Equal(t, testToken1, tok.Value)
+
+	require.NoError(t, svc.CommitAt("w1", checkpoint.ResumeToken{}, true))
+	tok, err = svc.Retrieve("w1")
+	require.NoError(t, err)
+	require.Equal(t, testToken2, tok.Value)
+
+	tok, err = svc.Retrieve("w2")
+	require.NoError(t, err)
+	require.Equal(t, testToken2, tok.Value)
+
+	fi, err := os.Stat(fn)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o644), fi.Mode().Perm())
+
+	entries, err := os.ReadDir(filepath.Dir(fn))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	require.NoError(t, svc.Clear("w1"))
+	tok, err = svc.Retrieve("w1")
+	require.NoError(t, err)
+	require.True(t, tok.IsZero())
+
+	tok, err = svc.Retrieve("w2")
+	require.NoError(t, err)
+	require.Equal(t, testToken2, tok.Value)
+}
+
+func TestSharedFileConcurrentSvcs(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "checkpoints.json")
+
+	// two services on the same file, the second one with a relative path: every watcher must find its own token.
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	rel, err := filepath.Rel(wd, fn)
+	require.NoError(t, err)
+	svcs := []checkpoint.ResumeTokenCheckpointSvc{
+		file.NewCheckpointSvc(file.CheckpointSvcConfig{Fn: fn, Stride: 1}),
+		file.NewCheckpointSvc(file.CheckpointSvcConfig{Fn: rel, Stride: 1}),
+	}
+
+	const numberOfWatchers = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, numberOfWatchers*5)
+	for i := 0; i < numberOfWatchers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 5; j++ {
+				errs <- svcs[i%2].CommitAt(fmt.Sprintf("w%d", i), checkpoint.ResumeToken{Value: testToken1}, true)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	for i := 0; i < numberOfWatchers; i++ {
+		tok, err := svcs[(i+1)%2].Retrieve(fmt.Sprintf("w%d", i))
+		require.NoError(t, err)
+		require.Equal(t, testToken1, tok.Value, "w%d", i)
+	}
+}
+
+func TestDirCheckpointSvcHistoryLost(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "checkpoints")
+	svc := file.NewCheckpointSvc(file.CheckpointSvcConfig{Dir: dir, ClearOnHistoryLost: true})
+
+	require.NoError(t, svc.CommitAt("ns/watcher", checkpoint.ResumeToken{Value: testToken1}, true))
+	require.NoError(t, svc.OnHistoryLost("ns/watcher"))
+
+	tok, err := svc.Retrieve("ns/watcher")
+	require.NoError(t, err)
+	require.True(t, tok.IsZero())
+
+	b, err := os.ReadFile(filepath.Join(dir, "ns%2Fwatcher.json"))
+	require.NoError(t, err)
+
+	var d file.Document
+	require.NoError(t, json.Unmarshal(b, &d))
+	require.Equal(t, "ns/watcher", d.Bid)
+	require.Equal(t, file.CheckPointStatusCleared, d.Status)
+	require.Equal(t, 1, d.HistoryLostCount)
+	require.NotEmpty(t, d.HistoryLostAt)
+}
+
+func TestLegacyFileCheckpointSvc(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "checkpoint.json")
+	require.NoError(t, os.WriteFile(fn, []byte(`{"_bid":"w1","resume_token":"`+testToken1+`","at":"2024-01-01T00:00:00Z"}`), 0o644))
+
+	svc := file.NewCheckpointSvc(file.CheckpointSvcConfig{Fn: fn})
+	tok, err := svc.Retrieve("w1")
+	require.NoError(t, err)
+	require.Equal(t, testToken1, tok.Value)
+
+	require.NoError(t, svc.CommitAt("w2", checkpoint.ResumeToken{Value: testToken2}, true))
+	tok, err = svc.Retrieve("w1")
+	require.NoError(t, err)
+	require.Equal(t, testToken1, tok.Value)
+}
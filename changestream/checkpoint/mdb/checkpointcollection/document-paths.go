@@ -19,4 +19,8 @@ const (
 )
 
 // @tpm-schematics:start-region("bottom-file-section")
+const (
+	ClusterTimeFieldName = "cluster_time"
+)
+
 // @tpm-schematics:end-region("bottom-file-section")
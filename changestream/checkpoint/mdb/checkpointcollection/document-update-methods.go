@@ -512,4 +512,33 @@ func UpdateWithIncrementOp_count(p int32) UpdateOption {
 // @tpm-schematics:end-region("op-count-field-update-section")
 
 // @tpm-schematics:start-region("bottom-file-section")
+
+// SetCluster_time No Remarks
+func (ud *UpdateDocument) SetCluster_time(p bson.Timestamp) *UpdateDocument {
+	mName := fmt.Sprintf(ClusterTimeFieldName)
+	ud.Set().Add(func() bson.E {
+		return bson.E{Key: mName, Value: p}
+	})
+	return ud
+}
+
+// UnsetCluster_time No Remarks
+func (ud *UpdateDocument) UnsetCluster_time() *UpdateDocument {
+	mName := fmt.Sprintf(ClusterTimeFieldName)
+	ud.Unset().Add(func() bson.E {
+		return bson.E{Key: mName, Value: ""}
+	})
+	return ud
+}
+
+func UpdateWithCluster_time(p bson.Timestamp) UpdateOption {
+	return func(ud *UpdateDocument) {
+		if !p.IsZero() {
+			ud.SetCluster_time(p)
+		} else {
+			ud.UnsetCluster_time()
+		}
+	}
+}
+
 // @tpm-schematics:end-region("bottom-file-section")
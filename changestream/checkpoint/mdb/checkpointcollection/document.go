@@ -1,9 +1,12 @@
 package checkpointcollection
 
+import "go.mongodb.org/mongo-driver/v2/bson"
+
 // @tpm-schematics:start-region("top-file-section")
 const (
 	CheckPointStatusActive  = "active"
 	CheckPointStatusCleared = "cleared"
+	CheckPointStatusHistory = "history"
 )
 
 // @tpm-schematics:end-region("top-file-section")
@@ -19,6 +22,7 @@ type Document struct {
 	OpCount     int32  `json:"op_count,omitempty" bson:"op_count,omitempty" yaml:"op_count,omitempty"`
 
 	// @tpm-schematics:start-region("struct-section")
+	ClusterTime bson.Timestamp `json:"cluster_time,omitempty" bson:"cluster_time,omitempty" yaml:"cluster_time,omitempty"`
 	// @tpm-schematics:end-region("struct-section")
 }
 
@@ -5,6 +5,7 @@ import (
 	"errors"
 
 	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
 )
@@ -29,3 +30,67 @@ func FindOneByBidAndStatus(coll *mongo.Collection, watcherId string, status stri
 	log.Info().Err(err).Interface("chk", doc).Msg(semLogContext)
 	return &doc, nil
 }
+
+// FindLatestHistoryAtOrBefore returns the newest history entry of the watcher with a cluster time not after ts, nil if there is none.
+func FindLatestHistoryAtOrBefore(coll *mongo.Collection, watcherId string, ts bson.Timestamp) (*Document, error) {
+	const semLogContext = "checkpoint-collection::find-latest-history-at-or-before"
+	var doc Document
+
+	f := historyFilter(watcherId)
+	f = append(f, bson.E{Key: ClusterTimeFieldName, Value: bson.D{{Key: "$lte", Value: ts}}})
+	opts := options.FindOne().SetSort(bson.D{{Key: ClusterTimeFieldName, Value: -1}})
+	err := coll.FindOne(context.Background(), f, opts).Decode(&doc)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			log.Info().Str("watcher-id", watcherId).Msg(semLogContext + " - no history entry found")
+			return nil, nil
+		}
+
+		log.Error().Err(err).Str("watcher-id", watcherId).Msg(semLogContext)
+		return nil, err
+	}
+
+	return &doc, nil
+}
+
+// PruneHistory removes the history entries of the watcher with a cluster time before ts, if not zero, and the ones older than the newest
+// maxEntries, if positive. It takes at most two round trips: the lookup of the oldest entry to keep and a single deletion.
+func PruneHistory(coll *mongo.Collection, watcherId string, ts bson.Timestamp, maxEntries int) (int64, error) {
+	const semLogContext = "checkpoint-collection::prune-history"
+
+	cutOff := ts
+	if maxEntries > 0 {
+		var doc Document
+		opts := options.FindOne().
+			SetSort(bson.D{{Key: ClusterTimeFieldName, Value: -1}}).
+			SetSkip(int64(maxEntries - 1)).
+			SetProjection(bson.D{{Key: ClusterTimeFieldName, Value: 1}})
+		err := coll.FindOne(context.Background(), historyFilter(watcherId), opts).Decode(&doc)
+		switch {
+		case errors.Is(err, mongo.ErrNoDocuments):
+		case err != nil:
+			log.Error().Err(err).Str("watcher-id", watcherId).Msg(semLogContext)
+			return 0, err
+		case doc.ClusterTime.Compare(cutOff) > 0:
+			cutOff = doc.ClusterTime
+		}
+	}
+
+	if cutOff.IsZero() {
+		return 0, nil
+	}
+
+	f := historyFilter(watcherId)
+	f = append(f, bson.E{Key: ClusterTimeFieldName, Value: bson.D{{Key: "$lt", Value: cutOff}}})
+	resp, err := coll.DeleteMany(context.Background(), f)
+	if err != nil {
+		log.Error().Err(err).Str("watcher-id", watcherId).Msg(semLogContext)
+		return 0, err
+	}
+
+	return resp.DeletedCount, nil
+}
+
+func historyFilter(watcherId string) bson.D {
+	return bson.D{{Key: BidFieldName, Value: watcherId}, {Key: StatusFieldName, Value: CheckPointStatusHistory}}
+}
@@ -2,12 +2,17 @@ package mdb
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"math"
 	"time"
 
 	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/changestream/checkpoint"
 	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/changestream/checkpoint/mdb/checkpointcollection"
 	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/mongolks"
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/util"
 	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
 )
@@ -17,8 +22,31 @@ type CheckpointSvcConfig struct {
 	CollectionId       string `yaml:"collection-id,omitempty" mapstructure:"collection-id,omitempty" json:"collection-id,omitempty"`
 	Stride             int    `yaml:"stride,omitempty" mapstructure:"stride,omitempty" json:"stride,omitempty"`
 	ClearOnHistoryLost bool   `yaml:"clear-on-history-lost,omitempty" mapstructure:"clear-on-history-lost,omitempty" json:"clear-on-history-lost,omitempty"`
+
+	// Retry bounds the attempts of a save, zero values take the defaults of util.DefaultRetryPolicy.
+	Retry util.RetryPolicy `yaml:"retry,omitempty" mapstructure:"retry,omitempty" json:"retry,omitempty"`
+
+	// History keeps the saved tokens next to the active checkpoint so that the watcher can be rewound, see RewindTo.
+	History HistoryConfig `yaml:"history,omitempty" mapstructure:"history,omitempty" json:"history,omitempty"`
+}
+
+const DefaultHistoryPruneInterval = time.Minute
+
+// HistoryConfig bounds the history by number of entries, by age of their cluster time or both. The history is off when neither is set.
+// The entries out of bounds are pruned at most once per prune-interval (DefaultHistoryPruneInterval if not set): between two prunes the
+// history may exceed its bounds.
+type HistoryConfig struct {
+	MaxEntries    int           `yaml:"max-entries,omitempty" mapstructure:"max-entries,omitempty" json:"max-entries,omitempty"`
+	Retention     time.Duration `yaml:"retention,omitempty" mapstructure:"retention,omitempty" json:"retention,omitempty"`
+	PruneInterval time.Duration `yaml:"prune-interval,omitempty" mapstructure:"prune-interval,omitempty" json:"prune-interval,omitempty"`
 }
 
+func (h HistoryConfig) Enabled() bool {
+	return h.MaxEntries > 0 || h.Retention > 0
+}
+
+var ErrNoHistoryCheckpoint = errors.New("no checkpoint in history at or before the requested time")
+
 type CheckpointSvc struct {
 	cfg           CheckpointSvcConfig
 	LastSaved     checkpoint.ResumeToken
@@ -26,7 +54,8 @@ type CheckpointSvc struct {
 	LastIdle      checkpoint.ResumeToken
 	NumberOfTicks int
 
-	coll *mongo.Collection
+	coll       *mongo.Collection
+	lastPruned map[string]time.Time
 }
 
 func NewCheckpointSvc(cfg CheckpointSvcConfig) (checkpoint.ResumeTokenCheckpointSvc, error) {
@@ -42,6 +71,7 @@ func NewCheckpointSvc(cfg CheckpointSvcConfig) (checkpoint.ResumeTokenCheckpoint
 		cfg:           cfg,
 		NumberOfTicks: -1,
 		coll:          coll,
+		lastPruned:    make(map[string]time.Time),
 	}, nil
 
 }
@@ -234,25 +264,165 @@ func (svc *CheckpointSvc) save(watcherId string, token checkpoint.ResumeToken) e
 	f.Or().AndBidEqTo(watcherId).AndStatusEqTo(checkpointcollection.CheckPointStatusActive)
 	opts := options.UpdateOne().SetUpsert(true)
 
-	ud := checkpointcollection.GetUpdateDocumentFromOptions(
+	updOpts := []checkpointcollection.UpdateOption{
 		checkpointcollection.UpdateWith_bid(watcherId),
 		checkpointcollection.UpdateWithResume_token(token.Value),
 		checkpointcollection.UpdateWithAt(token.At),
 		checkpointcollection.UpdateWithShort_token(token.ShortVersion()),
 		checkpointcollection.UpdateWithTxn_opn_index(info.TxnOpIndex),
 		checkpointcollection.UpdateWithStatus(checkpointcollection.CheckPointStatusActive),
-		checkpointcollection.UpdateWithIncrementOp_count(1),
-	)
+	}
 
-	resp, err := svc.coll.UpdateOne(context.Background(), f.Build(), ud.Build(), opts)
+	// the cluster time is only needed to look up the history.
+	var clusterTime bson.Timestamp
+	if svc.cfg.History.Enabled() {
+		clusterTime, err = token.ClusterTime()
+		if err != nil {
+			log.Warn().Err(err).Str("token", token.Value).Msg(semLogContext + " - cluster time not available")
+		}
+		updOpts = append(updOpts, checkpointcollection.UpdateWithCluster_time(clusterTime))
+	}
+
+	// the failed attempt may have been applied by the server with its reply lost: the retries set the same values but do not
+	// increment the op count again, at the cost of missing an increment when the failed attempt was not applied.
+	ud := checkpointcollection.GetUpdateDocumentFromOptions(append(updOpts, checkpointcollection.UpdateWithIncrementOp_count(1))...)
+	udRetry := checkpointcollection.GetUpdateDocumentFromOptions(updOpts...)
+
+	var resp *mongo.UpdateResult
+	attempt := 0
+	err = util.Retry(context.Background(), svc.retryPolicy(), func(ctx context.Context) error {
+		attempt++
+		upd := ud
+		if attempt > 1 {
+			upd = udRetry
+		}
+
+		var errUpd error
+		resp, errUpd = svc.coll.UpdateOne(ctx, f.Build(), upd.Build(), opts)
+		return errUpd
+	})
 	if err != nil {
 		return err
 	}
 
 	log.Info().Interface("resp", resp).Str("token", token.Value).Msg(semLogContext)
+	if svc.cfg.History.Enabled() {
+		svc.appendHistory(watcherId, token, info, clusterTime)
+	}
+
 	return nil
 }
 
+// appendHistory adds the saved token to the history of the watcher and prunes the entries out of bounds. A failure only affects
+// rewinding, so it is logged and not reported to the caller of the save.
+func (svc *CheckpointSvc) appendHistory(watcherId string, token checkpoint.ResumeToken, info checkpoint.ResumeTokenInfo, clusterTime bson.Timestamp) {
+	const semLogContext = "mongodb-checkpoint::append-history"
+
+	if clusterTime.IsZero() {
+		log.Warn().Str("watcher-id", watcherId).Str("token", token.Value).Msg(semLogContext + " - token without cluster time not kept in history")
+		return
+	}
+
+	doc := checkpointcollection.Document{
+		Bid:         watcherId,
+		ResumeToken: token.Value,
+		At:          token.At,
+		ShortToken:  token.ShortVersion(),
+		TxnOpnIndex: info.TxnOpIndex,
+		Status:      checkpointcollection.CheckPointStatusHistory,
+		ClusterTime: clusterTime,
+	}
+
+	if _, err := svc.coll.InsertOne(context.Background(), doc); err != nil {
+		log.Error().Err(err).Str("watcher-id", watcherId).Msg(semLogContext)
+		return
+	}
+
+	pruneInterval := svc.cfg.History.PruneInterval
+	if pruneInterval <= 0 {
+		pruneInterval = DefaultHistoryPruneInterval
+	}
+
+	if time.Since(svc.lastPruned[watcherId]) < pruneInterval {
+		return
+	}
+
+	var cutOff bson.Timestamp
+	if svc.cfg.History.Retention > 0 {
+		cutOff = bson.Timestamp{T: uint32(time.Now().Add(-svc.cfg.History.Retention).Unix())}
+	}
+
+	deleted, err := checkpointcollection.PruneHistory(svc.coll, watcherId, cutOff, svc.cfg.History.MaxEntries)
+	if err != nil {
+		log.Error().Err(err).Str("watcher-id", watcherId).Msg(semLogContext)
+		return
+	}
+
+	svc.lastPruned[watcherId] = time.Now()
+	log.Trace().Str("watcher-id", watcherId).Int64("pruned", deleted).Msg(semLogContext)
+}
+
+// RewindTo makes active the newest token of the history with a cluster time at or before the given time. The watcher picks it up
+// on its next start, a running one keeps committing its own position over it.
+func (svc *CheckpointSvc) RewindTo(watcherId string, at time.Time) (checkpoint.ResumeToken, error) {
+	const semLogContext = "mongodb-checkpoint::rewind-to"
+
+	var token checkpoint.ResumeToken
+	if !svc.cfg.History.Enabled() {
+		err := errors.New("checkpoint history is not enabled")
+		log.Error().Err(err).Str("watcher-id", watcherId).Msg(semLogContext)
+		return token, err
+	}
+
+	doc, err := checkpointcollection.FindLatestHistoryAtOrBefore(svc.coll, watcherId, bson.Timestamp{T: uint32(at.Unix()), I: math.MaxUint32})
+	if err != nil {
+		return token, err
+	}
+
+	if doc == nil {
+		err = fmt.Errorf("%w: watcher %s, time %s", ErrNoHistoryCheckpoint, watcherId, at.Format(time.RFC3339))
+		log.Error().Err(err).Msg(semLogContext)
+		return token, err
+	}
+
+	token = checkpoint.ResumeToken{Value: doc.ResumeToken, At: doc.At}
+
+	f := checkpointcollection.Filter{}
+	f.Or().AndBidEqTo(watcherId).AndStatusEqTo(checkpointcollection.CheckPointStatusActive)
+	ud := checkpointcollection.GetUpdateDocumentFromOptions(
+		checkpointcollection.UpdateWith_bid(watcherId),
+		checkpointcollection.UpdateWithResume_token(doc.ResumeToken),
+		checkpointcollection.UpdateWithAt(doc.At),
+		checkpointcollection.UpdateWithShort_token(doc.ShortToken),
+		checkpointcollection.UpdateWithTxn_opn_index(doc.TxnOpnIndex),
+		checkpointcollection.UpdateWithStatus(checkpointcollection.CheckPointStatusActive),
+		checkpointcollection.UpdateWithIncrementOp_count(1),
+		checkpointcollection.UpdateWithCluster_time(doc.ClusterTime),
+	)
+
+	resp, err := svc.coll.UpdateOne(context.Background(), f.Build(), ud.Build(), options.UpdateOne().SetUpsert(true))
+	if err != nil {
+		log.Error().Err(err).Str("watcher-id", watcherId).Msg(semLogContext)
+		return token, err
+	}
+
+	svc.LastCommitted = token
+	svc.LastSaved = token
+	svc.NumberOfTicks = 0
+
+	log.Warn().Interface("resp", resp).Str("watcher-id", watcherId).Str("token", token.Value).Time("cluster-time", time.Unix(int64(doc.ClusterTime.T), 0)).Msg(semLogContext)
+	return token, nil
+}
+
+func (svc *CheckpointSvc) retryPolicy() util.RetryPolicy {
+	p := svc.cfg.Retry
+	if p.Name == "" {
+		p.Name = "checkpoint-save"
+	}
+
+	return p
+}
+
 func (svc *CheckpointSvc) Clear(watcherId string) error {
 	const semLogContext = "mongodb-checkpoint::clear"
 	var err error
@@ -0,0 +1,84 @@
+package mdb_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/changestream/checkpoint"
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/changestream/checkpoint/mdb"
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/changestream/checkpoint/mdb/checkpointcollection"
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/mongolks"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+const (
+	testLksName               = "default"
+	checkpointCollectionId    = "checkpoints"
+	checkpointCollectionName  = "tpm_mongo_common_checkpoints"
+	historyWatcherId          = "history-watcher"
+	resumeTokenFormat         = "82%08X%08X2B0429296E1404"
+	historyTokensIntervalSecs = 10
+)
+
+func newTestCheckpointSvc(t *testing.T, history mdb.HistoryConfig) checkpoint.RewindableCheckpointSvc {
+	_, err := mongolks.Initialize([]mongolks.Config{{
+		Name:         testLksName,
+		Host:         "mongodb://localhost:27017",
+		DbName:       "tpm_morphia",
+		WriteConcern: "majority",
+		Pool:         mongolks.PoolConfig{MinConn: 1, MaxConn: 5, ConnectTimeout: 1000, MaxConnectionIdleTime: 30000},
+		Collections:  []mongolks.CollectionCfg{{Id: checkpointCollectionId, Name: checkpointCollectionName}},
+	}})
+	require.NoError(t, err)
+
+	svc, err := mdb.NewCheckpointSvc(mdb.CheckpointSvcConfig{Instance: testLksName, CollectionId: checkpointCollectionId, History: history})
+	require.NoError(t, err)
+
+	rsvc, ok := svc.(checkpoint.RewindableCheckpointSvc)
+	require.True(t, ok)
+	return rsvc
+}
+
+func TestCheckpointHistory(t *testing.T) {
+	svc := newTestCheckpointSvc(t, mdb.HistoryConfig{MaxEntries: 2, PruneInterval: time.Nanosecond})
+
+	coll, err := mongolks.GetCollection(context.Background(), testLksName, checkpointCollectionId)
+	require.NoError(t, err)
+	_, err = coll.DeleteMany(context.Background(), bson.D{{Key: checkpointcollection.BidFieldName, Value: historyWatcherId}})
+	require.NoError(t, err)
+
+	// three saves, ten seconds apart: the oldest entry exceeds the history and gets pruned.
+	base := time.Now().Add(-time.Minute).Truncate(time.Second)
+	var tokens []string
+	for i := 0; i < 3; i++ {
+		tm := base.Add(time.Duration(i*historyTokensIntervalSecs) * time.Second)
+		tokens = append(tokens, fmt.Sprintf(resumeTokenFormat, tm.Unix(), 1))
+		require.NoError(t, svc.CommitAt(historyWatcherId, checkpoint.ResumeToken{Value: tokens[i], At: tm.Format(time.RFC3339Nano)}, true))
+	}
+
+	n, err := coll.CountDocuments(context.Background(), bson.D{
+		{Key: checkpointcollection.BidFieldName, Value: historyWatcherId},
+		{Key: checkpointcollection.StatusFieldName, Value: checkpointcollection.CheckPointStatusHistory},
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(2), n)
+
+	tok, err := svc.RewindTo(historyWatcherId, base.Add(15*time.Second))
+	require.NoError(t, err)
+	require.Equal(t, tokens[1], tok.Value)
+
+	tok, err = svc.Retrieve(historyWatcherId)
+	require.NoError(t, err)
+	require.Equal(t, tokens[1], tok.Value)
+
+	// the pruned entry is no longer available.
+	_, err = svc.RewindTo(historyWatcherId, base.Add(5*time.Second))
+	require.ErrorIs(t, err, mdb.ErrNoHistoryCheckpoint)
+
+	svc = newTestCheckpointSvc(t, mdb.HistoryConfig{})
+	_, err = svc.RewindTo(historyWatcherId, base)
+	require.Error(t, err)
+}
@@ -0,0 +1,128 @@
+package memory
+
+import (
+	"sync"
+	"time"
+
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/changestream/checkpoint"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	CheckPointStatusActive  = "active"
+	CheckPointStatusCleared = "cleared"
+)
+
+// Checkpoint is the state kept for a watcher, Checkpoints returns a copy of them.
+type Checkpoint struct {
+	WatcherId        string                 `yaml:"watcher-id,omitempty" mapstructure:"watcher-id,omitempty" json:"watcher-id,omitempty"`
+	Token            checkpoint.ResumeToken `yaml:"token,omitempty" mapstructure:"token,omitempty" json:"token,omitempty"`
+	Status           string                 `yaml:"status,omitempty" mapstructure:"status,omitempty" json:"status,omitempty"`
+	OpCount          int                    `yaml:"op-count,omitempty" mapstructure:"op-count,omitempty" json:"op-count,omitempty"`
+	HistoryLostCount int                    `yaml:"history-lost-count,omitempty" mapstructure:"history-lost-count,omitempty" json:"history-lost-count,omitempty"`
+}
+
+type CheckpointSvcConfig struct {
+	ClearOnHistoryLost bool `yaml:"clear-on-history-lost,omitempty" mapstructure:"clear-on-history-lost,omitempty" json:"clear-on-history-lost,omitempty"`
+}
+
+// CheckpointSvc keeps the checkpoints in the process: every commit is stored and nothing survives the end of the process, the factory
+// shares a service among the consumers with the same configuration. It fits tests and consumers that always start from the current
+// position of the stream.
+type CheckpointSvc struct {
+	cfg         CheckpointSvcConfig
+	mu          sync.Mutex
+	checkpoints map[string]Checkpoint
+}
+
+func NewCheckpointSvc(cfg CheckpointSvcConfig) *CheckpointSvc {
+	return &CheckpointSvc{
+		cfg:         cfg,
+		checkpoints: make(map[string]Checkpoint),
+	}
+}
+
+func (svc *CheckpointSvc) Retrieve(watcherId string) (checkpoint.ResumeToken, error) {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+
+	chk, ok := svc.checkpoints[watcherId]
+	if !ok || chk.Status != CheckPointStatusActive {
+		return checkpoint.ResumeToken{}, nil
+	}
+
+	return chk.Token, nil
+}
+
+func (svc *CheckpointSvc) StoreIdle(watcherId string, token checkpoint.ResumeToken) error {
+	return svc.CommitAt(watcherId, token, true)
+}
+
+func (svc *CheckpointSvc) ClearIdle() {
+}
+
+func (svc *CheckpointSvc) CommitAt(watcherId string, token checkpoint.ResumeToken, syncRequired bool) error {
+	if token.IsZero() {
+		return nil
+	}
+
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+
+	if token.At == "" {
+		token.At = time.Now().Format(time.RFC3339Nano)
+	}
+
+	chk := svc.checkpoints[watcherId]
+	chk.WatcherId = watcherId
+	chk.Token = token
+	chk.Status = CheckPointStatusActive
+	chk.OpCount++
+	svc.checkpoints[watcherId] = chk
+	return nil
+}
+
+func (svc *CheckpointSvc) Clear(watcherId string) error {
+	const semLogContext = "memory-checkpoint::clear"
+
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+
+	chk, ok := svc.checkpoints[watcherId]
+	if !ok {
+		return nil
+	}
+
+	log.Warn().Str("watcher-id", watcherId).Str("resume-token", chk.Token.Value).Msg(semLogContext + " checkpoint to clear")
+	chk.Status = CheckPointStatusCleared
+	svc.checkpoints[watcherId] = chk
+	return nil
+}
+
+func (svc *CheckpointSvc) OnHistoryLost(watcherId string) error {
+	svc.mu.Lock()
+	chk := svc.checkpoints[watcherId]
+	chk.WatcherId = watcherId
+	chk.HistoryLostCount++
+	svc.checkpoints[watcherId] = chk
+	svc.mu.Unlock()
+
+	if svc.cfg.ClearOnHistoryLost {
+		return svc.Clear(watcherId)
+	}
+
+	return nil
+}
+
+// Checkpoints returns a snapshot of the checkpoints by watcher id.
+func (svc *CheckpointSvc) Checkpoints() map[string]Checkpoint {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+
+	m := make(map[string]Checkpoint, len(svc.checkpoints))
+	for k, v := range svc.checkpoints {
+		m[k] = v
+	}
+
+	return m
+}
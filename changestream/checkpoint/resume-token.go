@@ -8,6 +8,7 @@ import (
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"go.mongodb.org/mongo-driver/v2/bson"
+	"strings"
 	"time"
 )
 
@@ -161,6 +162,32 @@ func (rt ResumeToken) IsZero() bool {
 	return rt.Value == ""
 }
 
+// ClusterTime returns the cluster time of the event the token refers to, the first value of its key string.
+func (rt ResumeToken) ClusterTime() (bson.Timestamp, error) {
+	ks, err := keystring.NewKeyStringFromString(keystring.KeyStringVersionV1, rt.Value)
+	if err != nil {
+		return bson.Timestamp{}, err
+	}
+
+	val, err := ks.ToSingleValueBsonPartial()
+	if err != nil {
+		return bson.Timestamp{}, err
+	}
+
+	ts, ok := val.(bson.Timestamp)
+	if !ok {
+		return bson.Timestamp{}, fmt.Errorf("resume token does not start with a timestamp: %v", val)
+	}
+
+	return ts, nil
+}
+
+// Compare orders two tokens by their position in the change stream: the key string encoding of a resume token sorts as its bytes do,
+// so the hex values compare as strings. A zero token sorts first.
+func (rt ResumeToken) Compare(other ResumeToken) int {
+	return strings.Compare(strings.ToUpper(rt.Value), strings.ToUpper(other.Value))
+}
+
 func (rt ResumeToken) String() string {
 	const semLogContext = "resume-token::string"
 
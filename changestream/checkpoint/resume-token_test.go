@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/changestream/checkpoint"
 	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/v2/bson"
 	"testing"
 )
 
@@ -36,3 +37,14 @@ func TestParse(t *testing.T) {
 	}
 
 }
+
+func TestClusterTime(t *testing.T) {
+	rt1 := checkpoint.ResumeToken{Value: "8267C867A3000000012B0429296E1404"}
+	ts, err := rt1.ClusterTime()
+	require.NoError(t, err)
+	require.Equal(t, bson.Timestamp{T: 0x67C867A3, I: 1}, ts)
+
+	rt2 := checkpoint.ResumeToken{Value: "8267C867B3000000022B0429296E1404"}
+	require.Equal(t, -1, rt1.Compare(rt2))
+	require.Equal(t, 1, rt2.Compare(checkpoint.ResumeToken{}))
+}
@@ -242,8 +242,8 @@ func (s *Consumer) Poll() (*events.ChangeEvent, error) {
 		}
 
 		if s.chgStream.Err() != nil {
-			ec := util.MongoErrorCode(s.chgStream.Err(), s.ServerVersion)
-			if ec == util.MongoErrChangeStreamHistoryLost {
+			ec := util.Classify(s.chgStream.Err(), util.WithServerVersion(s.ServerVersion))
+			if errors.Is(ec, util.ErrHistoryLost) {
 				s.statsInfo.IncHistoryLost()
 				if s.cfg.CheckPointSvc != nil {
 					errHl := s.cfg.CheckPointSvc.OnHistoryLost(s.cfg.Id)
@@ -252,7 +252,7 @@ func (s *Consumer) Poll() (*events.ChangeEvent, error) {
 					}
 				}
 			} else {
-				log.Error().Err(s.chgStream.Err()).Int32("error-code", ec).Interface("error", s.chgStream.Err()).Msg(semLogContext)
+				log.Error().Err(s.chgStream.Err()).Int32("error-code", ec.Code).Str("error-name", ec.CodeName).Str("category", string(ec.Category)).Bool("retryable", ec.Retryable).Msg(semLogContext)
 			}
 
 			return nil, s.chgStream.Err()
@@ -365,9 +365,8 @@ func (s *Consumer) newChangeStream() (*mongo.ChangeStream, error) {
 	log.Info().Err(err).Int("retry-num", counter).Msg(semLogContext)
 	collStream, err := coll.Watch(context.TODO(), pipeline, opts)
 	for err != nil && counter < s.cfg.RetryCount {
-		mongoCode := util.MongoErrorCode(err, s.ServerVersion)
 		// TODO add logic to retry with the start after time depending on config
-		if mongoCode == util.MongoErrChangeStreamHistoryLost {
+		if errors.Is(util.Classify(err, util.WithServerVersion(s.ServerVersion)), util.ErrHistoryLost) {
 			s.statsInfo.IncHistoryLost()
 
 			if s.cfg.CheckPointSvc != nil {
@@ -57,7 +57,8 @@ func (e *EchoConsumerProducer) ProcessMessage(evt *events.ChangeEvent) error {
 	const semLogContext = "echo-producer::process-message"
 
 	e.numEvents++
-	log.Info().Interface("evt", evt).Int("num-evts", e.numEvents).Msg(semLogContext)
+	// the documents of the event are not logged, they may carry personal data.
+	log.Info().Str("op-type", evt.OpType).Str("db", evt.Ns.Db).Str("coll", evt.Ns.Coll).Str("cluster-time", evt.ClusterTimeAsString()).Int("num-evts", e.numEvents).Msg(semLogContext)
 	if e.numEvents%4 == 0 {
 		return errors.New("msg error")
 	}
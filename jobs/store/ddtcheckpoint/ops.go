@@ -48,7 +48,7 @@ func UpdateDueDate(coll *mongo.Collection, bid string, dueDate string) error {
 		return err
 	}
 
-	log.Info().Str("bid", bid).Str("due_date", dueDate).Str("op", util.MustToExtendedJsonString(updDoc, false, false)).Interface("update-resp", resp).Msg(semLogContext)
+	log.Info().Str("bid", bid).Str("due_date", dueDate).Str("op", util.RedactedExtendedJsonString(updDoc, false, false)).Interface("update-resp", resp).Msg(semLogContext)
 	if resp.MatchedCount > 1 {
 		log.Warn().Msg(semLogContext + " - more than one trigger found")
 	}
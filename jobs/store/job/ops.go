@@ -128,7 +128,7 @@ func UpdateManyStatus(jobsColl *mongo.Collection, f *Filter, st string) (int64,
 	}
 
 	filterDocument := f.Build()
-	log.Info().Str("filter", util.MustToExtendedJsonString(filterDocument, false, false)).Msg(semLogContext)
+	log.Info().Str("filter", util.RedactedExtendedJsonString(filterDocument, false, false)).Msg(semLogContext)
 
 	updDoc := GetUpdateDocumentFromOptions(updOpts...)
 	resp, err := jobsColl.UpdateMany(context.Background(), filterDocument, updDoc.Build())
@@ -226,8 +226,8 @@ func (j Job) Restart(retryOnly bool) error {
 		tFd := tFilter.Build()
 
 		log.Info().
-			Str("update-filter", util.MustToExtendedJsonString(tFd, false, false)).
-			Str("update-document", util.MustToExtendedJsonString(tUd, false, false)).
+			Str("update-filter", util.RedactedExtendedJsonString(tFd, false, false)).
+			Str("update-document", util.RedactedExtendedJsonString(tUd, false, false)).
 			Msg(semLogContext)
 
 		resp, err := taskCollection.UpdateOne(context.Background(), tFd, tUd, options.UpdateOne())
@@ -254,8 +254,8 @@ func (j Job) Restart(retryOnly bool) error {
 	jFd := jFilter.Build()
 
 	log.Info().
-		Str("update-filter", util.MustToExtendedJsonString(jFd, false, false)).
-		Str("update-document", util.MustToExtendedJsonString(jUd, false, false)).
+		Str("update-filter", util.RedactedExtendedJsonString(jFd, false, false)).
+		Str("update-document", util.RedactedExtendedJsonString(jUd, false, false)).
 		Msg(semLogContext)
 
 	jResp, err := jobCollection.UpdateOne(context.Background(), jFd, jUd, options.UpdateOne())
@@ -314,7 +314,7 @@ func Delete(coll *mongo.Collection, filter *Filter, limit int) (int, error) {
 		f1 := tasklog.Filter{}
 		f1.Or().AndDomainEqTo(domain).AndSiteEqTo(site).AndJobIdIn(jobIds).AndEtEqTo(tasklog.EType)
 		f1d := f1.Build()
-		log.Info().Str("filter", util.MustToExtendedJsonString(f1d, false, false)).Msg(semLogContext + " - deleting task-logs")
+		log.Info().Str("filter", util.RedactedExtendedJsonString(f1d, false, false)).Msg(semLogContext + " - deleting task-logs")
 		resp, err := coll.DeleteMany(context.Background(), f1d, options.DeleteMany())
 		if err != nil {
 			log.Error().Err(err).Msg(semLogContext)
@@ -327,7 +327,7 @@ func Delete(coll *mongo.Collection, filter *Filter, limit int) (int, error) {
 		f2 := tasklog.Filter{}
 		f2.Or().AndDomainEqTo(domain).AndSiteEqTo(site).AndJobIdIn(jobIds).AndEtEqTo(task.EType)
 		f2d := f2.Build()
-		log.Info().Str("filter", util.MustToExtendedJsonString(f2d, false, false)).Msg(semLogContext + " - deleting tasks")
+		log.Info().Str("filter", util.RedactedExtendedJsonString(f2d, false, false)).Msg(semLogContext + " - deleting tasks")
 		resp, err = coll.DeleteMany(context.Background(), f2d, options.DeleteMany())
 		if err != nil {
 			log.Error().Err(err).Msg(semLogContext)
@@ -340,7 +340,7 @@ func Delete(coll *mongo.Collection, filter *Filter, limit int) (int, error) {
 		f3 := Filter{}
 		f3.Or().AndDomainEqTo(domain).AndSiteEqTo(site).AndBidIn(jobIds).AndEtEqTo(EType)
 		f3d := f3.Build()
-		log.Info().Str("filter", util.MustToExtendedJsonString(f3d, false, false)).Msg(semLogContext + " - deleting jobs")
+		log.Info().Str("filter", util.RedactedExtendedJsonString(f3d, false, false)).Msg(semLogContext + " - deleting jobs")
 		resp, err = coll.DeleteMany(context.Background(), f3d, options.DeleteMany())
 		if err != nil {
 			log.Error().Err(err).Msg(semLogContext)
@@ -353,7 +353,7 @@ func Delete(coll *mongo.Collection, filter *Filter, limit int) (int, error) {
 		f4 := lease.Filter{}
 		f4.Or().AndGidIn(leaseGids).AndEtIn([]string{lease.EntityType})
 		f4d := f4.Build()
-		log.Info().Str("filter", util.MustToExtendedJsonString(f4d, false, false)).Msg(semLogContext + " - deleting task leases")
+		log.Info().Str("filter", util.RedactedExtendedJsonString(f4d, false, false)).Msg(semLogContext + " - deleting task leases")
 		resp, err = coll.DeleteMany(context.Background(), f4d, options.DeleteMany())
 		if err != nil {
 			log.Error().Err(err).Msg(semLogContext)
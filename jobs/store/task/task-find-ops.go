@@ -36,7 +36,7 @@ func FindByPk(collection *mongo.Collection, domain, site, bidJob, bidTask string
 	f.Or().AndDomainEqTo(domain).AndSiteEqTo(site).AndEtEqTo(EType).AndJobIdEqTo(bidJob).AndBidEqTo(bidTask)
 	// @tpm-schematics:end-region("filter-section")
 	fd := f.Build()
-	evtTraceLog = evtTraceLog.Str("filter", util.MustToExtendedJsonString(fd, false, false))
+	evtTraceLog = evtTraceLog.Str("filter", util.RedactedExtendedJsonString(fd, false, false))
 	err := collection.FindOne(ctx, fd, findOptions).Decode(&ent)
 	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
 		evtErrLog.Err(err).Msg(semLogContext)
@@ -61,8 +61,8 @@ func FindByPk(collection *mongo.Collection, domain, site, bidJob, bidTask string
 func Find(collection *mongo.Collection, f *Filter, withCount bool, findOptions *options.FindOptionsBuilder) (QueryResult, error) {
 	const semLogContext = "task::find"
 	fd := f.Build()
-	evtTraceLog := log.Trace().Str("filter", util.MustToExtendedJsonString(fd, false, false))
-	evtErrLog := log.Error().Str("filter", util.MustToExtendedJsonString(fd, false, false))
+	evtTraceLog := log.Trace().Str("filter", util.RedactedExtendedJsonString(fd, false, false))
+	evtErrLog := log.Error().Str("filter", util.RedactedExtendedJsonString(fd, false, false))
 	evtTraceLog.Msg(semLogContext)
 
 	qr := QueryResult{}
@@ -112,7 +112,7 @@ func CountByStatus(collection *mongo.Collection, f *Filter, findOptions *options
 
 	pipeline := mongo.Pipeline{}
 	fd := f.Build()
-	log.Trace().Str("filter", util.MustToExtendedJsonString(fd, false, false)).Msg(semLogContext)
+	log.Trace().Str("filter", util.RedactedExtendedJsonString(fd, false, false)).Msg(semLogContext)
 
 	pipeline = append(pipeline, bson.D{{"$match", fd}})
 	if findOptions != nil {
@@ -168,7 +168,7 @@ func CountByStatus(collection *mongo.Collection, f *Filter, findOptions *options
 	}
 
 	for _, stage := range pipeline {
-		log.Trace().Str("filter", util.MustToExtendedJsonString(stage, true, true)).Msg(semLogContext)
+		log.Trace().Str("filter", util.RedactedExtendedJsonString(stage, true, true)).Msg(semLogContext)
 	}
 
 	for cur.Next(context.Background()) {
@@ -34,7 +34,7 @@ func FindByPk(collection *mongo.Collection /* pk params, */, mustFind bool, find
 	// f.Or().And...
 	// @tpm-schematics:end-region("filter-section")
 	fd := f.Build()
-	evtTraceLog = evtTraceLog.Str("filter", util.MustToExtendedJsonString(fd, false, false))
+	evtTraceLog = evtTraceLog.Str("filter", util.RedactedExtendedJsonString(fd, false, false))
 	err := collection.FindOne(ctx, fd, findOptions).Decode(&ent)
 	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
 		evtErrLog.Err(err).Msg(semLogContext)
@@ -59,8 +59,8 @@ func FindByPk(collection *mongo.Collection /* pk params, */, mustFind bool, find
 func Find(collection *mongo.Collection, f *Filter, withCount bool, findOptions *options.FindOptionsBuilder) (QueryResult, error) {
 	const semLogContext = "task-log::find"
 	fd := f.Build()
-	evtTraceLog := log.Trace().Str("filter", util.MustToExtendedJsonString(fd, false, false))
-	evtErrLog := log.Error().Str("filter", util.MustToExtendedJsonString(fd, false, false))
+	evtTraceLog := log.Trace().Str("filter", util.RedactedExtendedJsonString(fd, false, false))
+	evtErrLog := log.Error().Str("filter", util.RedactedExtendedJsonString(fd, false, false))
 	evtTraceLog.Msg(semLogContext)
 
 	qr := QueryResult{}
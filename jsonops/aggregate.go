@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/util"
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/util/mdboptions"
 
 	"net/http"
 
@@ -22,12 +23,14 @@ const (
 	MongoActivityAggregateOneOpProperty       MongoJsonOperationStatementPart = "$op"
 	MongoActivityAggregateOnePipelineProperty MongoJsonOperationStatementPart = "$pipeline"
 	MongoActivityAggregateOneOptsProperty     MongoJsonOperationStatementPart = "$opts"
+	MongoActivityAggregateOneOutputProperty   MongoJsonOperationStatementPart = "$output"
 )
 
 type AggregateOneOperation struct {
 	Filter   []byte `yaml:"filter,omitempty" json:"filter,omitempty" mapstructure:"filter,omitempty"`
 	Pipeline []byte `yaml:"pipeline,omitempty" json:"pipeline,omitempty" mapstructure:"pipelineP,omitempty"`
 	Options  []byte `yaml:"options,omitempty" json:"options,omitempty" mapstructure:"options,omitempty"`
+	Output   []byte `yaml:"output,omitempty" json:"output,omitempty" mapstructure:"output,omitempty"`
 }
 
 func (op *AggregateOneOperation) OpType() MongoJsonOperationType {
@@ -52,6 +55,14 @@ func (op *AggregateOneOperation) ToString() string {
 		sb.WriteString(fmt.Sprintf("\"%s\": ", MongoActivityAggregateOneOptsProperty))
 		sb.WriteString(string(op.Options))
 	}
+	if len(op.Output) > 0 {
+		if numberOfElements > 0 {
+			sb.WriteString(",")
+		}
+		numberOfElements++
+		sb.WriteString(fmt.Sprintf("\"%s\": ", MongoActivityAggregateOneOutputProperty))
+		sb.WriteString(string(op.Output))
+	}
 
 	sb.WriteString("}")
 	return sb.String()
@@ -71,6 +82,10 @@ func NewAggregateOneOperation(m map[MongoJsonOperationStatementPart][]byte) (*Ag
 		foStmt.Options = data
 	}
 
+	if data, ok := m[MongoActivityAggregateOneOutputProperty]; ok {
+		foStmt.Output = data
+	}
+
 	return &foStmt, nil
 }
 
@@ -89,19 +104,36 @@ func NewAggregateOneStatementConfigFromJson(data []byte) (AggregateOneOperation,
 	fo := AggregateOneOperation{
 		Pipeline: m[MongoActivityAggregateOnePipelineProperty],
 		Options:  m[MongoActivityAggregateOneOptsProperty],
+		Output:   m[MongoActivityAggregateOneOutputProperty],
 	}
 
 	return fo, nil
 }
 
 func (op *AggregateOneOperation) Execute(lks *mongolks.LinkedService, collectionId string) (OperationResult, []byte, error) {
-	sc, resp, err := AggregateOne(lks, collectionId, op.Pipeline, op.Options)
+	return op.ExecuteContext(context.Background(), lks, collectionId)
+}
+
+func (op *AggregateOneOperation) ExecuteContext(ctx context.Context, lks *mongolks.LinkedService, collectionId string) (res OperationResult, resp []byte, err error) {
+	ctx, endSpan := startOperationSpan(ctx, lks, op.OpType(), collectionId)
+	defer func() { endSpan(res, resp, err) }()
+
+	oo, err := NewOutputOptionsFromJson(op.Output)
+	if err != nil {
+		return OperationResultFromStatementError(err), nil, err
+	}
+
+	sc, resp, err := AggregateOneContext(ctx, lks, collectionId, op.Pipeline, op.Options, oo)
 	return sc, resp, err
 }
 
-func AggregateOne(lks *mongolks.LinkedService, collectionId string, pipeline []byte, opts []byte) (OperationResult, []byte, error) {
+func AggregateOne(lks *mongolks.LinkedService, collectionId string, pipeline []byte, opts []byte, output ...OutputOptions) (OperationResult, []byte, error) {
+	return AggregateOneContext(context.Background(), lks, collectionId, pipeline, opts, output...)
+}
+
+func AggregateOneContext(ctx context.Context, lks *mongolks.LinkedService, collectionId string, pipeline []byte, opts []byte, output ...OutputOptions) (OperationResult, []byte, error) {
 	const semLogContext = "json-ops::aggregate-one"
-	sc, items, err := Aggregate(lks, collectionId, pipeline, opts)
+	sc, items, err := AggregateContext(ctx, lks, collectionId, pipeline, opts, output...)
 	if err != nil {
 		return sc, nil, err
 	}
@@ -121,26 +153,46 @@ func AggregateOne(lks *mongolks.LinkedService, collectionId string, pipeline []b
 	return OperationResult{StatusCode: http.StatusOK}, items[0], nil
 }
 
-func Aggregate(lks *mongolks.LinkedService, collectionId string, pipeline []byte, opts []byte) (OperationResult, [][]byte, error) {
+func Aggregate(lks *mongolks.LinkedService, collectionId string, pipeline []byte, opts []byte, output ...OutputOptions) (OperationResult, [][]byte, error) {
+	return AggregateContext(context.Background(), lks, collectionId, pipeline, opts, output...)
+}
+
+func AggregateContext(ctx context.Context, lks *mongolks.LinkedService, collectionId string, pipeline []byte, opts []byte, output ...OutputOptions) (OperationResult, [][]byte, error) {
 	const semLogContext = "json-ops::aggregate"
 	var err error
 
+	if err := checkPolicy(lks, collectionId, &AggregateOneOperation{Pipeline: pipeline, Options: opts}); err != nil {
+		return OperationResultFromError(err), nil, err
+	}
+
 	c := lks.GetCollection(collectionId, "")
 	if c == nil {
 		err = errors.New("cannot find requested collection")
 		log.Error().Err(err).Str("collection", collectionId).Msg(semLogContext)
-		return OperationResult{StatusCode: http.StatusInternalServerError}, nil, err
+		return OperationResultFromError(err), nil, err
 	}
 
 	statementQuery, err := util.UnmarshalJson2ArrayOfBsonD(pipeline, true)
 	if err != nil {
-		return OperationResult{StatusCode: http.StatusInternalServerError}, nil, err
+		return OperationResultFromStatementError(err), nil, err
 	}
 
-	fo := options.Aggregate()
-	sc, resp, err := executeAggregateOp(c, statementQuery, fo)
+	fo, err := mdboptions.AggregateOptionsFromJson(opts)
 	if err != nil {
-		return OperationResult{StatusCode: http.StatusInternalServerError}, nil, err
+		log.Error().Err(err).Msg(semLogContext)
+		return OperationResultFromStatementError(err), nil, err
+	}
+
+	ctx, cancel, err := newOperationContext(ctx, lks, collectionId, AggregateOneOperationType, opts)
+	if err != nil {
+		log.Error().Err(err).Msg(semLogContext)
+		return OperationResultFromStatementError(err), nil, err
+	}
+	defer cancel()
+
+	sc, resp, err := executeAggregateOp(ctx, c, statementQuery, fo, outputOptionsOf(output))
+	if err != nil {
+		return sc, nil, err
 	}
 
 	if sc.StatusCode == http.StatusOK {
@@ -156,29 +208,24 @@ func Aggregate(lks *mongolks.LinkedService, collectionId string, pipeline []byte
 	return sc, nil, nil
 }
 
-func executeAggregateOp(c *mongo.Collection, pipeline interface{}, fo options.Lister[options.AggregateOptions] /* fo *options.AggregateOptions*/) (OperationResult, [][]byte, error) {
+func executeAggregateOp(ctx context.Context, c *mongo.Collection, pipeline interface{}, fo options.Lister[options.AggregateOptions] /* fo *options.AggregateOptions*/, oo OutputOptions) (OperationResult, [][]byte, error) {
 	const semLogContext = "mongo-operation::execute-aggregate-op"
 
-	crs, err := c.Aggregate(context.Background(), pipeline, fo)
+	crs, err := c.Aggregate(ctx, pipeline, fo)
 	if err != nil {
-		mongoErrorCode := util.MongoErrorCode(err, util.MongoDbVersion{})
-		log.Error().Err(err).Int32("mongo-error", mongoErrorCode).Msg(semLogContext)
-		return OperationResult{StatusCode: int(-mongoErrorCode)}, nil, err
+		res := OperationResultFromError(err)
+		log.Error().Err(err).Int("status-code", res.StatusCode).Msg(semLogContext)
+		return res, nil, err
 	}
 
-	var resp [][]byte
-	for crs.Next(context.TODO()) {
-		var el bson.M
-		err = crs.Decode(&el)
-		if err = crs.Decode(&el); err != nil {
-			return OperationResult{StatusCode: http.StatusInternalServerError}, nil, err
-		}
+	defer crs.Close(ctx)
 
-		var b []byte
-		b, err := json.Marshal(el)
+	var resp [][]byte
+	for crs.Next(ctx) {
+		b, err := oo.MarshalDocument(crs.Current)
 		if err != nil {
 			log.Error().Err(err).Msg(semLogContext)
-			return OperationResult{StatusCode: http.StatusInternalServerError}, nil, err
+			return OperationResultFromError(err), nil, err
 		}
 
 		resp = append(resp, b)
@@ -186,7 +233,7 @@ func executeAggregateOp(c *mongo.Collection, pipeline interface{}, fo options.Li
 
 	if err = crs.Err(); err != nil {
 		log.Error().Err(err).Msg(semLogContext)
-		return OperationResult{StatusCode: http.StatusInternalServerError}, nil, err
+		return OperationResultFromError(err), nil, err
 	}
 
 	return OperationResult{StatusCode: http.StatusOK}, resp, nil
@@ -195,3 +242,19 @@ func executeAggregateOp(c *mongo.Collection, pipeline interface{}, fo options.Li
 func (op *AggregateOneOperation) NewWriteModel() (mongo.WriteModel, error) {
 	panic("new write model not supported in aggregation queries operations")
 }
+
+func (op *AggregateOneOperation) explainCommand(collectionName string) (bson.D, []byte, error) {
+	statementPipeline, err := util.UnmarshalJson2ArrayOfBsonD(op.Pipeline, true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	jo, err := mdboptions.ParseJsonOptions(op.Options, mdboptions.AggregateOptionNames)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cmd := bson.D{{Key: "aggregate", Value: collectionName}, {Key: "pipeline", Value: statementPipeline}, {Key: "cursor", Value: bson.D{}}}
+	cmd = explainStatementOptions(cmd, jo)
+	return explainCommandOptions(cmd, jo), op.Options, nil
+}
@@ -0,0 +1,478 @@
+package jsonops
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/mongolks"
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	CatalogueParameterProperty = "$param"
+
+	CatalogueParamTypeString   = "string"
+	CatalogueParamTypeNumber   = "number"
+	CatalogueParamTypeInteger  = "integer"
+	CatalogueParamTypeBoolean  = "boolean"
+	CatalogueParamTypeObject   = "object"
+	CatalogueParamTypeArray    = "array"
+	CatalogueParamTypeObjectId = "object-id"
+	CatalogueParamTypeDate     = "date"
+)
+
+var ErrCatalogueEntryNotFound = errors.New("catalogue entry not found")
+
+type CatalogueParameter struct {
+	Name     string      `yaml:"name,omitempty" mapstructure:"name,omitempty" json:"name,omitempty"`
+	Type     string      `yaml:"type,omitempty" mapstructure:"type,omitempty" json:"type,omitempty"`
+	Required bool        `yaml:"required,omitempty" mapstructure:"required,omitempty" json:"required,omitempty"`
+	Default  interface{} `yaml:"default,omitempty" mapstructure:"default,omitempty" json:"default,omitempty"`
+}
+
+// CatalogueEntry is a named operation definition. The statement parts are written as plain yaml or json and can contain
+// placeholders in the form { "$param": "name" } that are replaced by the parameter values at execution time.
+type CatalogueEntry struct {
+	Name         string                                              `yaml:"name,omitempty" mapstructure:"name,omitempty" json:"name,omitempty"`
+	OpType       MongoJsonOperationType                              `yaml:"op-type,omitempty" mapstructure:"op-type,omitempty" json:"op-type,omitempty"`
+	Lks          string                                              `yaml:"lks,omitempty" mapstructure:"lks,omitempty" json:"lks,omitempty"`
+	CollectionId string                                              `yaml:"collection-id,omitempty" mapstructure:"collection-id,omitempty" json:"collection-id,omitempty"`
+	Parameters   []CatalogueParameter                                `yaml:"parameters,omitempty" mapstructure:"parameters,omitempty" json:"parameters,omitempty"`
+	Statement    map[MongoJsonOperationStatementPart]json.RawMessage `yaml:"-" mapstructure:"-" json:"statement,omitempty"`
+}
+
+// Catalogue is safe for concurrent use: entries can be added while the handler executes the others.
+type Catalogue struct {
+	mu      sync.RWMutex
+	entries map[string]CatalogueEntry
+}
+
+func NewCatalogue() *Catalogue {
+	return &Catalogue{entries: make(map[string]CatalogueEntry)}
+}
+
+// NewCatalogueFromDir loads the .yml, .yaml and .json files of the directory. A file holds a single definition, named after
+// the file if the name is missing, or a list of definitions.
+func NewCatalogueFromDir(dir string) (*Catalogue, error) {
+	const semLogContext = "json-ops::new-catalogue-from-dir"
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		log.Error().Err(err).Str("dir", dir).Msg(semLogContext)
+		return nil, err
+	}
+
+	c := NewCatalogue()
+	for _, f := range files {
+		ext := strings.ToLower(filepath.Ext(f.Name()))
+		if f.IsDir() || (ext != ".yml" && ext != ".yaml" && ext != ".json") {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			log.Error().Err(err).Str("file", f.Name()).Msg(semLogContext)
+			return nil, err
+		}
+
+		entries, err := NewCatalogueEntriesFromYaml(data, strings.TrimSuffix(f.Name(), filepath.Ext(f.Name())))
+		if err != nil {
+			log.Error().Err(err).Str("file", f.Name()).Msg(semLogContext)
+			return nil, err
+		}
+
+		for _, e := range entries {
+			if err = c.Add(e); err != nil {
+				log.Error().Err(err).Str("file", f.Name()).Msg(semLogContext)
+				return nil, err
+			}
+		}
+	}
+
+	log.Info().Str("dir", dir).Int("num-entries", len(c.Names())).Msg(semLogContext)
+	return c, nil
+}
+
+// NewCatalogueEntriesFromYaml parses a yaml (or json) document. The statement parts are converted to json preserving the key order.
+func NewCatalogueEntriesFromYaml(data []byte, defaultName string) ([]CatalogueEntry, error) {
+	var doc yaml.Node
+	err := yaml.Unmarshal(data, &doc)
+	if err != nil {
+		return nil, err
+	}
+
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return nil, errors.New("empty catalogue file")
+	}
+
+	root := doc.Content[0]
+	switch root.Kind {
+	case yaml.MappingNode:
+		e, err := newCatalogueEntryFromYamlNode(root)
+		if err != nil {
+			return nil, err
+		}
+		if e.Name == "" {
+			e.Name = defaultName
+		}
+		return []CatalogueEntry{e}, nil
+	case yaml.SequenceNode:
+		entries := make([]CatalogueEntry, 0, len(root.Content))
+		for _, n := range root.Content {
+			e, err := newCatalogueEntryFromYamlNode(n)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, e)
+		}
+		return entries, nil
+	}
+
+	return nil, errors.New("catalogue file must hold a definition or a list of definitions")
+}
+
+func newCatalogueEntryFromYamlNode(n *yaml.Node) (CatalogueEntry, error) {
+	var e CatalogueEntry
+	err := n.Decode(&e)
+	if err != nil {
+		return e, err
+	}
+
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value != "statement" {
+			continue
+		}
+
+		stmt := n.Content[i+1]
+		if stmt.Kind != yaml.MappingNode {
+			return e, fmt.Errorf("catalogue entry %s: statement must be a mapping", e.Name)
+		}
+
+		e.Statement = make(map[MongoJsonOperationStatementPart]json.RawMessage)
+		for j := 0; j+1 < len(stmt.Content); j += 2 {
+			var buf bytes.Buffer
+			if err = yamlNode2Json(stmt.Content[j+1], &buf); err != nil {
+				return e, fmt.Errorf("catalogue entry %s: %w", e.Name, err)
+			}
+			e.Statement[MongoJsonOperationStatementPart(stmt.Content[j].Value)] = buf.Bytes()
+		}
+	}
+
+	return e, nil
+}
+
+func yamlNode2Json(n *yaml.Node, buf *bytes.Buffer) error {
+	switch n.Kind {
+	case yaml.AliasNode:
+		return yamlNode2Json(n.Alias, buf)
+	case yaml.MappingNode:
+		buf.WriteByte('{')
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			kb, _ := json.Marshal(n.Content[i].Value)
+			buf.Write(kb)
+			buf.WriteByte(':')
+			if err := yamlNode2Json(n.Content[i+1], buf); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	case yaml.SequenceNode:
+		buf.WriteByte('[')
+		for i, c := range n.Content {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := yamlNode2Json(c, buf); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case yaml.ScalarNode:
+		var v interface{}
+		if err := n.Decode(&v); err != nil {
+			return err
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		buf.Write(b)
+	default:
+		return fmt.Errorf("unsupported yaml node at line %d", n.Line)
+	}
+
+	return nil
+}
+
+// Add validates the entry, with the parameters set to their defaults or to a placeholder of the declared type, and adds it to the catalogue.
+func (c *Catalogue) Add(e CatalogueEntry) error {
+	if e.Name == "" {
+		return errors.New("catalogue entry has no name")
+	}
+
+	if e.CollectionId == "" {
+		return fmt.Errorf("catalogue entry %s has no collection-id", e.Name)
+	}
+
+	for _, p := range e.Parameters {
+		if p.Name == "" {
+			return fmt.Errorf("catalogue entry %s has a parameter with no name", e.Name)
+		}
+		if _, err := p.placeholder(); err != nil {
+			return fmt.Errorf("catalogue entry %s: %w", e.Name, err)
+		}
+	}
+
+	op, err := e.newOperation(nil, true)
+	if err != nil {
+		return fmt.Errorf("catalogue entry %s: %w", e.Name, err)
+	}
+
+	switch ex := op.(type) {
+	case explainer:
+		_, _, err = ex.explainCommand(e.CollectionId)
+	case multiExplainer:
+		_, _, err = ex.explainCommands(e.CollectionId)
+	}
+
+	if err != nil {
+		return fmt.Errorf("catalogue entry %s: %w", e.Name, err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[e.Name]; ok {
+		return fmt.Errorf("catalogue entry %s is duplicated", e.Name)
+	}
+
+	c.entries[e.Name] = e
+	return nil
+}
+
+func (c *Catalogue) Names() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	names := make([]string, 0, len(c.entries))
+	for n := range c.entries {
+		names = append(names, n)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+func (c *Catalogue) Entry(name string) (CatalogueEntry, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	e, ok := c.entries[name]
+	return e, ok
+}
+
+// NewOperation returns the operation of the named entry with the parameters in place.
+func (c *Catalogue) NewOperation(name string, params map[string]interface{}) (Operation, error) {
+	e, ok := c.Entry(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrCatalogueEntryNotFound, name)
+	}
+
+	return e.newOperation(params, false)
+}
+
+func (c *Catalogue) Execute(name string, params map[string]interface{}) (OperationResult, []byte, error) {
+	return c.ExecuteContext(context.Background(), name, params)
+}
+
+func (c *Catalogue) ExecuteContext(ctx context.Context, name string, params map[string]interface{}) (OperationResult, []byte, error) {
+	const semLogContext = "json-ops::execute-catalogue-entry"
+
+	e, ok := c.Entry(name)
+	if !ok {
+		err := fmt.Errorf("%w: %s", ErrCatalogueEntryNotFound, name)
+		log.Error().Err(err).Msg(semLogContext)
+		return OperationResult{StatusCode: http.StatusNotFound, Problem: NewProblem(http.StatusNotFound, err)}, nil, err
+	}
+
+	op, err := e.newOperation(params, false)
+	if err != nil {
+		log.Error().Err(err).Str("name", name).Msg(semLogContext)
+		return OperationResultFromStatementError(err), nil, err
+	}
+
+	lksName := e.Lks
+	if lksName == "" {
+		lksName = mongolks.MongoDbDefaultInstanceName
+	}
+
+	lks, err := mongolks.GetLinkedService(ctx, lksName)
+	if err != nil {
+		log.Error().Err(err).Str("name", name).Str("lks", lksName).Msg(semLogContext)
+		return OperationResultFromError(err), nil, err
+	}
+
+	return op.ExecuteContext(ctx, lks, e.CollectionId)
+}
+
+func (e *CatalogueEntry) newOperation(params map[string]interface{}, validate bool) (Operation, error) {
+	declared := make(map[string]CatalogueParameter)
+	for _, p := range e.Parameters {
+		declared[p.Name] = p
+	}
+
+	for n := range params {
+		if _, ok := declared[n]; !ok {
+			return nil, fmt.Errorf("unknown parameter %s", n)
+		}
+	}
+
+	resolver := func(name string) ([]byte, error) {
+		p, ok := declared[name]
+		if !ok {
+			return nil, fmt.Errorf("undeclared parameter %s", name)
+		}
+
+		if validate {
+			return p.placeholder()
+		}
+
+		v, ok := params[name]
+		if !ok {
+			if p.Required {
+				return nil, fmt.Errorf("missing required parameter %s", name)
+			}
+			v = p.Default
+		}
+
+		return p.marshalValue(v)
+	}
+
+	m := make(map[MongoJsonOperationStatementPart][]byte)
+	for part, data := range e.Statement {
+		resolved, err := rewriteReferences(data, CatalogueParameterProperty, resolver)
+		if err != nil {
+			return nil, err
+		}
+		m[part] = resolved
+	}
+
+	return NewOperation(e.OpType, m)
+}
+
+func (p CatalogueParameter) placeholder() ([]byte, error) {
+	if p.Default != nil {
+		return p.marshalValue(p.Default)
+	}
+
+	switch p.Type {
+	case "", CatalogueParamTypeString:
+		return []byte(`""`), nil
+	case CatalogueParamTypeNumber, CatalogueParamTypeInteger:
+		return []byte(`0`), nil
+	case CatalogueParamTypeBoolean:
+		return []byte(`false`), nil
+	case CatalogueParamTypeObject:
+		return []byte(`{}`), nil
+	case CatalogueParamTypeArray:
+		return []byte(`[]`), nil
+	case CatalogueParamTypeObjectId:
+		return []byte(`{"$oid":"000000000000000000000000"}`), nil
+	case CatalogueParamTypeDate:
+		return []byte(`{"$date":"1970-01-01T00:00:00Z"}`), nil
+	}
+
+	return nil, fmt.Errorf("parameter %s has unsupported type %s", p.Name, p.Type)
+}
+
+// marshalValue checks the value against the declared type and renders it as (extended) json.
+func (p CatalogueParameter) marshalValue(v interface{}) ([]byte, error) {
+	if v == nil {
+		return []byte("null"), nil
+	}
+
+	invalid := fmt.Errorf("parameter %s: value of type %T is not a valid %s", p.Name, v, p.Type)
+	switch p.Type {
+	case "", CatalogueParamTypeString:
+		if _, ok := v.(string); !ok {
+			return nil, invalid
+		}
+	case CatalogueParamTypeNumber:
+		switch v.(type) {
+		case float64, float32, int, int32, int64, json.Number:
+		default:
+			return nil, invalid
+		}
+	case CatalogueParamTypeInteger:
+		switch tv := v.(type) {
+		case int, int32, int64:
+		case float64:
+			if tv != math.Trunc(tv) {
+				return nil, invalid
+			}
+			v = int64(tv)
+		case json.Number:
+			if _, err := tv.Int64(); err != nil {
+				return nil, invalid
+			}
+		default:
+			return nil, invalid
+		}
+	case CatalogueParamTypeBoolean:
+		if _, ok := v.(bool); !ok {
+			return nil, invalid
+		}
+	case CatalogueParamTypeObject:
+		if _, ok := v.(map[string]interface{}); !ok {
+			return nil, invalid
+		}
+	case CatalogueParamTypeArray:
+		if _, ok := v.([]interface{}); !ok {
+			return nil, invalid
+		}
+	case CatalogueParamTypeObjectId:
+		switch tv := v.(type) {
+		case bson.ObjectID:
+			v = bson.M{"$oid": tv.Hex()}
+		case string:
+			if _, err := bson.ObjectIDFromHex(tv); err != nil {
+				return nil, invalid
+			}
+			v = bson.M{"$oid": tv}
+		default:
+			return nil, invalid
+		}
+	case CatalogueParamTypeDate:
+		switch tv := v.(type) {
+		case time.Time:
+			v = bson.M{"$date": tv.UTC().Format(time.RFC3339Nano)}
+		case string:
+			if _, err := time.Parse(time.RFC3339Nano, tv); err != nil {
+				return nil, invalid
+			}
+			v = bson.M{"$date": tv}
+		default:
+			return nil, invalid
+		}
+	default:
+		return nil, fmt.Errorf("parameter %s has unsupported type %s", p.Name, p.Type)
+	}
+
+	return json.Marshal(v)
+}
@@ -0,0 +1,95 @@
+package jsonops_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/jsonops"
+	"github.com/stretchr/testify/require"
+)
+
+const catalogueYamlDefinition = `
+op-type: find
+collection-id: tpm-mongo-common
+parameters:
+  - name: year
+    type: integer
+    required: true
+  - name: limit
+    type: integer
+    default: 10
+statement:
+  $query:
+    year: { $param: year }
+  $sort:
+    title: 1
+    year: -1
+  $opts:
+    limit: { $param: limit }
+`
+
+const catalogueJsonDefinitions = `[
+  { "name": "movie-by-id", "op-type": "find-one", "collection-id": "tpm-mongo-common",
+    "parameters": [ { "name": "id", "type": "object-id", "required": true } ],
+    "statement": { "$query": { "_id": { "$param": "id" } } } }
+]`
+
+func TestCatalogue(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "movies-by-year.yml"), []byte(catalogueYamlDefinition), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "movies.json"), []byte(catalogueJsonDefinitions), 0644))
+
+	c, err := jsonops.NewCatalogueFromDir(dir)
+	require.NoError(t, err)
+	require.Equal(t, []string{"movie-by-id", "movies-by-year"}, c.Names())
+
+	op, err := c.NewOperation("movies-by-year", map[string]interface{}{"year": 1939})
+	require.NoError(t, err)
+	fo, ok := op.(*jsonops.FindOperation)
+	require.True(t, ok)
+	require.Equal(t, `{"year":1939}`, string(fo.Query))
+	require.Equal(t, `{"title":1,"year":-1}`, string(fo.Sort))
+	require.Equal(t, `{"limit":10}`, string(fo.Options))
+
+	_, err = c.NewOperation("movies-by-year", map[string]interface{}{})
+	require.Error(t, err, "missing required parameter")
+
+	_, err = c.NewOperation("movies-by-year", map[string]interface{}{"year": "1939"})
+	require.Error(t, err, "invalid parameter type")
+
+	op, err = c.NewOperation("movie-by-id", map[string]interface{}{"id": "5a934e000102030405000000"})
+	require.NoError(t, err)
+	require.Equal(t, `{"_id":{"$oid":"5a934e000102030405000000"}}`, string(op.(*jsonops.FindOneOperation).Query))
+
+	_, err = c.NewOperation("unknown", nil)
+	require.ErrorIs(t, err, jsonops.ErrCatalogueEntryNotFound)
+
+	require.Error(t, c.Add(jsonops.CatalogueEntry{Name: "bad", OpType: jsonops.FindManyOperationType, CollectionId: "tpm-mongo-common",
+		Statement: map[jsonops.MongoJsonOperationStatementPart]json.RawMessage{"$query": json.RawMessage(`{ "year": { "$param": "undeclared" } }`)}}))
+}
+
+func TestCatalogueConcurrentAdd(t *testing.T) {
+	c := jsonops.NewCatalogue()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			require.NoError(t, c.Add(jsonops.CatalogueEntry{Name: fmt.Sprintf("entry-%d", i), OpType: jsonops.FindOneOperationType, CollectionId: "tpm-mongo-common",
+				Statement: map[jsonops.MongoJsonOperationStatementPart]json.RawMessage{"$query": json.RawMessage(`{ "year": 1939 }`)}}))
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			_, _ = c.NewOperation(fmt.Sprintf("entry-%d", i), nil)
+			_ = c.Names()
+		}(i)
+	}
+
+	wg.Wait()
+	require.Len(t, c.Names(), 8)
+}
@@ -0,0 +1,30 @@
+package jsonops_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/jsonops"
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/mongolks"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecuteContext(t *testing.T) {
+	lks, err := mongolks.GetLinkedService(context.Background(), "default")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, _, err = jsonops.FindContext(ctx, lks, CollectionId, []byte(`{ "year": 1939 }`), nil, nil, nil)
+	require.True(t, errors.Is(err, context.Canceled), err)
+
+	op, err := jsonops.NewOperation(jsonops.FindOneOperationType, map[jsonops.MongoJsonOperationStatementPart][]byte{
+		jsonops.MongoActivityFindOneQueryProperty: []byte(`{ "year": 1939 }`),
+	})
+	require.NoError(t, err)
+
+	_, _, err = op.ExecuteContext(ctx, lks, CollectionId)
+	require.True(t, errors.Is(err, context.Canceled), err)
+}
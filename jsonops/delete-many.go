@@ -5,13 +5,13 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"net/http"
 	"strings"
 
 	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/mongolks"
 	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/util"
 	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/util/mdboptions"
 	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 )
 
@@ -19,11 +19,13 @@ const (
 	MongoActivityDeleteManyOpProperty     MongoJsonOperationStatementPart = "$op"
 	MongoActivityDeleteManyFilterProperty MongoJsonOperationStatementPart = "$filter"
 	MongoActivityDeleteManyOptsProperty   MongoJsonOperationStatementPart = "$opts"
+	MongoActivityDeleteManyOutputProperty MongoJsonOperationStatementPart = "$output"
 )
 
 type DeleteManyOperation struct {
 	Filter  []byte `yaml:"filter,omitempty" json:"filter,omitempty" mapstructure:"filter,omitempty"`
 	Options []byte `yaml:"options,omitempty" json:"options,omitempty" mapstructure:"options,omitempty"`
+	Output  []byte `yaml:"output,omitempty" json:"output,omitempty" mapstructure:"output,omitempty"`
 }
 
 func (op *DeleteManyOperation) OpType() MongoJsonOperationType {
@@ -48,6 +50,14 @@ func (op *DeleteManyOperation) ToString() string {
 		sb.WriteString(fmt.Sprintf("\"%s\": ", MongoActivityDeleteManyOptsProperty))
 		sb.WriteString(string(op.Options))
 	}
+	if len(op.Output) > 0 {
+		if numberOfElements > 0 {
+			sb.WriteString(",")
+		}
+		numberOfElements++
+		sb.WriteString(fmt.Sprintf("\"%s\": ", MongoActivityDeleteManyOutputProperty))
+		sb.WriteString(string(op.Output))
+	}
 
 	sb.WriteString("}")
 	return sb.String()
@@ -67,6 +77,10 @@ func NewDeleteManyOperation(m map[MongoJsonOperationStatementPart][]byte) (*Dele
 		foStmt.Options = data
 	}
 
+	if data, ok := m[MongoActivityDeleteManyOutputProperty]; ok {
+		foStmt.Output = data
+	}
+
 	return &foStmt, nil
 }
 
@@ -85,31 +99,52 @@ func NewDeleteManyStatementConfigFromJson(data []byte) (DeleteManyOperation, err
 	fo := DeleteManyOperation{
 		Filter:  m[MongoActivityDeleteManyFilterProperty],
 		Options: m[MongoActivityDeleteManyOptsProperty],
+		Output:  m[MongoActivityDeleteManyOutputProperty],
 	}
 
 	return fo, nil
 }
 
 func (op *DeleteManyOperation) Execute(lks *mongolks.LinkedService, collectionId string) (OperationResult, []byte, error) {
-	sc, resp, err := DeleteMany(lks, collectionId, op.Filter, op.Options)
+	return op.ExecuteContext(context.Background(), lks, collectionId)
+}
+
+func (op *DeleteManyOperation) ExecuteContext(ctx context.Context, lks *mongolks.LinkedService, collectionId string) (res OperationResult, resp []byte, err error) {
+	ctx, endSpan := startOperationSpan(ctx, lks, op.OpType(), collectionId)
+	defer func() { endSpan(res, resp, err) }()
+
+	oo, err := NewOutputOptionsFromJson(op.Output)
+	if err != nil {
+		return OperationResultFromStatementError(err), nil, err
+	}
+
+	sc, resp, err := DeleteManyContext(ctx, lks, collectionId, op.Filter, op.Options, oo)
 	return sc, resp, err
 }
 
-func DeleteMany(lks *mongolks.LinkedService, collectionId string, filter []byte, opts []byte) (OperationResult, []byte, error) {
+func DeleteMany(lks *mongolks.LinkedService, collectionId string, filter []byte, opts []byte, output ...OutputOptions) (OperationResult, []byte, error) {
+	return DeleteManyContext(context.Background(), lks, collectionId, filter, opts, output...)
+}
+
+func DeleteManyContext(ctx context.Context, lks *mongolks.LinkedService, collectionId string, filter []byte, opts []byte, output ...OutputOptions) (OperationResult, []byte, error) {
 	const semLogContext = "json-ops::delete-one"
 	var err error
 
+	if err := checkPolicy(lks, collectionId, &DeleteManyOperation{Filter: filter, Options: opts}); err != nil {
+		return OperationResultFromError(err), nil, err
+	}
+
 	c := lks.GetCollection(collectionId, "")
 	if c == nil {
 		err = errors.New("cannot find requested collection")
 		log.Error().Err(err).Str("collection", collectionId).Msg(semLogContext)
-		return OperationResult{StatusCode: http.StatusInternalServerError}, nil, err
+		return OperationResultFromError(err), nil, err
 	}
 
 	opFilter, err := util.UnmarshalJson2BsonD(filter, true)
 	if err != nil {
 		log.Error().Err(err).Msg(semLogContext)
-		return OperationResult{StatusCode: http.StatusInternalServerError}, nil, err
+		return OperationResultFromStatementError(err), nil, err
 	}
 	//if opFilter == nil {
 	//	opFilter = bson.D{}
@@ -118,20 +153,28 @@ func DeleteMany(lks *mongolks.LinkedService, collectionId string, filter []byte,
 	uo, err := mdboptions.DeleteManyOptionsFromJson(opts)
 	if err != nil {
 		log.Error().Err(err).Msg(semLogContext)
-		return OperationResult{StatusCode: http.StatusInternalServerError}, nil, err
+		return OperationResultFromStatementError(err), nil, err
 	}
-	res, err := c.DeleteMany(context.Background(), opFilter, uo)
+
+	ctx, cancel, err := newOperationContext(ctx, lks, collectionId, DeleteManyOperationType, opts)
+	if err != nil {
+		log.Error().Err(err).Msg(semLogContext)
+		return OperationResultFromStatementError(err), nil, err
+	}
+	defer cancel()
+
+	res, err := c.DeleteMany(ctx, opFilter, uo)
 	if err != nil {
-		mongoErrorCode := util.MongoErrorCode(err, util.MongoDbVersion{})
-		log.Error().Err(err).Int32("mongo-error", mongoErrorCode).Msg(semLogContext)
-		return OperationResult{StatusCode: int(-mongoErrorCode)}, nil, err
+		res := OperationResultFromError(err)
+		log.Error().Err(err).Int("status-code", res.StatusCode).Msg(semLogContext)
+		return res, nil, err
 	}
 
 	var b []byte
-	b, err = json.Marshal(res)
+	b, err = outputOptionsOf(output).MarshalWriteResult(res)
 	if err != nil {
 		log.Error().Err(err).Msg(semLogContext)
-		return OperationResult{StatusCode: http.StatusInternalServerError}, nil, err
+		return OperationResultFromError(err), nil, err
 	}
 
 	return OperationResultFromDeleteResult(res), b, nil
@@ -146,11 +189,35 @@ func (op *DeleteManyOperation) NewWriteModel() (mongo.WriteModel, error) {
 		return nil, err
 	}
 
-	_, err = mdboptions.DeleteManyOptionsFromJson(op.Options)
+	jo, err := mdboptions.ParseJsonOptions(op.Options, mdboptions.DeleteOptionNames)
 	if err != nil {
 		log.Error().Err(err).Msg(semLogContext)
 		return nil, err
 	}
 
-	return mongo.NewDeleteManyModel().SetFilter(statementFilter), nil
+	wm := mongo.NewDeleteManyModel().SetFilter(statementFilter)
+	if jo.Hint != nil {
+		wm.SetHint(jo.Hint)
+	}
+	if jo.Collation != nil {
+		wm.SetCollation(jo.Collation)
+	}
+
+	return wm, nil
+}
+
+func (op *DeleteManyOperation) explainCommand(collectionName string) (bson.D, []byte, error) {
+	statementFilter, err := util.UnmarshalJson2BsonD(op.Filter, true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	jo, err := mdboptions.ParseJsonOptions(op.Options, mdboptions.DeleteOptionNames)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stmt := bson.D{{Key: "q", Value: statementFilter}, {Key: "limit", Value: int32(0)}}
+	cmd := bson.D{{Key: "delete", Value: collectionName}, {Key: "deletes", Value: bson.A{explainStatementOptions(stmt, jo)}}}
+	return explainCommandOptions(cmd, jo), op.Options, nil
 }
@@ -5,13 +5,13 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"net/http"
 	"strings"
 
 	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/mongolks"
 	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/util"
 	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/util/mdboptions"
 	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 )
 
@@ -19,11 +19,13 @@ const (
 	MongoActivityDeleteOneOpProperty     MongoJsonOperationStatementPart = "$op"
 	MongoActivityDeleteOneFilterProperty MongoJsonOperationStatementPart = "$filter"
 	MongoActivityDeleteOneOptsProperty   MongoJsonOperationStatementPart = "$opts"
+	MongoActivityDeleteOneOutputProperty MongoJsonOperationStatementPart = "$output"
 )
 
 type DeleteOneOperation struct {
 	Filter  []byte `yaml:"filter,omitempty" json:"filter,omitempty" mapstructure:"filter,omitempty"`
 	Options []byte `yaml:"options,omitempty" json:"options,omitempty" mapstructure:"options,omitempty"`
+	Output  []byte `yaml:"output,omitempty" json:"output,omitempty" mapstructure:"output,omitempty"`
 }
 
 func (op *DeleteOneOperation) OpType() MongoJsonOperationType {
@@ -48,6 +50,14 @@ func (op *DeleteOneOperation) ToString() string {
 		sb.WriteString(fmt.Sprintf("\"%s\": ", MongoActivityDeleteOneOptsProperty))
 		sb.WriteString(string(op.Options))
 	}
+	if len(op.Output) > 0 {
+		if numberOfElements > 0 {
+			sb.WriteString(",")
+		}
+		numberOfElements++
+		sb.WriteString(fmt.Sprintf("\"%s\": ", MongoActivityDeleteOneOutputProperty))
+		sb.WriteString(string(op.Output))
+	}
 
 	sb.WriteString("}")
 	return sb.String()
@@ -67,6 +77,10 @@ func NewDeleteOneOperation(m map[MongoJsonOperationStatementPart][]byte) (*Delet
 		foStmt.Options = data
 	}
 
+	if data, ok := m[MongoActivityDeleteOneOutputProperty]; ok {
+		foStmt.Output = data
+	}
+
 	return &foStmt, nil
 }
 
@@ -85,51 +99,79 @@ func NewDeleteOneStatementConfigFromJson(data []byte) (DeleteOneOperation, error
 	fo := DeleteOneOperation{
 		Filter:  m[MongoActivityDeleteOneFilterProperty],
 		Options: m[MongoActivityDeleteOneOptsProperty],
+		Output:  m[MongoActivityDeleteOneOutputProperty],
 	}
 
 	return fo, nil
 }
 
 func (op *DeleteOneOperation) Execute(lks *mongolks.LinkedService, collectionId string) (OperationResult, []byte, error) {
-	sc, resp, err := DeleteOne(lks, collectionId, op.Filter, op.Options)
+	return op.ExecuteContext(context.Background(), lks, collectionId)
+}
+
+func (op *DeleteOneOperation) ExecuteContext(ctx context.Context, lks *mongolks.LinkedService, collectionId string) (res OperationResult, resp []byte, err error) {
+	ctx, endSpan := startOperationSpan(ctx, lks, op.OpType(), collectionId)
+	defer func() { endSpan(res, resp, err) }()
+
+	oo, err := NewOutputOptionsFromJson(op.Output)
+	if err != nil {
+		return OperationResultFromStatementError(err), nil, err
+	}
+
+	sc, resp, err := DeleteOneContext(ctx, lks, collectionId, op.Filter, op.Options, oo)
 	return sc, resp, err
 }
 
-func DeleteOne(lks *mongolks.LinkedService, collectionId string, filter []byte, opts []byte) (OperationResult, []byte, error) {
+func DeleteOne(lks *mongolks.LinkedService, collectionId string, filter []byte, opts []byte, output ...OutputOptions) (OperationResult, []byte, error) {
+	return DeleteOneContext(context.Background(), lks, collectionId, filter, opts, output...)
+}
+
+func DeleteOneContext(ctx context.Context, lks *mongolks.LinkedService, collectionId string, filter []byte, opts []byte, output ...OutputOptions) (OperationResult, []byte, error) {
 	const semLogContext = "json-ops::delete-one"
 	var err error
 
+	if err := checkPolicy(lks, collectionId, &DeleteOneOperation{Filter: filter, Options: opts}); err != nil {
+		return OperationResultFromError(err), nil, err
+	}
+
 	c := lks.GetCollection(collectionId, "")
 	if c == nil {
 		err = errors.New("cannot find requested collection")
 		log.Error().Err(err).Str("collection", collectionId).Msg(semLogContext)
-		return OperationResult{StatusCode: http.StatusInternalServerError}, nil, err
+		return OperationResultFromError(err), nil, err
 	}
 
 	opFilter, err := util.UnmarshalJson2BsonD(filter, true)
 	if err != nil {
 		log.Error().Err(err).Msg(semLogContext)
-		return OperationResult{StatusCode: http.StatusInternalServerError}, nil, err
+		return OperationResultFromStatementError(err), nil, err
 	}
 
 	uo, err := mdboptions.DeleteOneOptionsFromJson(opts)
 	if err != nil {
 		log.Error().Err(err).Msg(semLogContext)
-		return OperationResult{StatusCode: http.StatusInternalServerError}, nil, err
+		return OperationResultFromStatementError(err), nil, err
 	}
 
-	res, err := c.DeleteOne(context.Background(), opFilter, uo)
+	ctx, cancel, err := newOperationContext(ctx, lks, collectionId, DeleteOneOperationType, opts)
 	if err != nil {
-		mongoErrorCode := util.MongoErrorCode(err, util.MongoDbVersion{})
-		log.Error().Err(err).Int32("mongo-error", mongoErrorCode).Msg(semLogContext)
-		return OperationResult{StatusCode: int(-mongoErrorCode)}, nil, err
+		log.Error().Err(err).Msg(semLogContext)
+		return OperationResultFromStatementError(err), nil, err
+	}
+	defer cancel()
+
+	res, err := c.DeleteOne(ctx, opFilter, uo)
+	if err != nil {
+		res := OperationResultFromError(err)
+		log.Error().Err(err).Int("status-code", res.StatusCode).Msg(semLogContext)
+		return res, nil, err
 	}
 
 	var b []byte
-	b, err = json.Marshal(res)
+	b, err = outputOptionsOf(output).MarshalWriteResult(res)
 	if err != nil {
 		log.Error().Err(err).Msg(semLogContext)
-		return OperationResult{StatusCode: http.StatusInternalServerError}, nil, err
+		return OperationResultFromError(err), nil, err
 	}
 
 	return OperationResultFromDeleteResult(res), b, nil
@@ -144,11 +186,35 @@ func (op *DeleteOneOperation) NewWriteModel() (mongo.WriteModel, error) {
 		return nil, err
 	}
 
-	_, err = mdboptions.DeleteOneOptionsFromJson(op.Options)
+	jo, err := mdboptions.ParseJsonOptions(op.Options, mdboptions.DeleteOptionNames)
 	if err != nil {
 		log.Error().Err(err).Msg(semLogContext)
 		return nil, err
 	}
 
-	return mongo.NewDeleteOneModel().SetFilter(statementFilter), nil
+	wm := mongo.NewDeleteOneModel().SetFilter(statementFilter)
+	if jo.Hint != nil {
+		wm.SetHint(jo.Hint)
+	}
+	if jo.Collation != nil {
+		wm.SetCollation(jo.Collation)
+	}
+
+	return wm, nil
+}
+
+func (op *DeleteOneOperation) explainCommand(collectionName string) (bson.D, []byte, error) {
+	statementFilter, err := util.UnmarshalJson2BsonD(op.Filter, true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	jo, err := mdboptions.ParseJsonOptions(op.Options, mdboptions.DeleteOptionNames)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stmt := bson.D{{Key: "q", Value: statementFilter}, {Key: "limit", Value: int32(1)}}
+	cmd := bson.D{{Key: "delete", Value: collectionName}, {Key: "deletes", Value: bson.A{explainStatementOptions(stmt, jo)}}}
+	return explainCommandOptions(cmd, jo), op.Options, nil
 }
@@ -0,0 +1,372 @@
+package jsonops
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/mongolks"
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/util"
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/util/mdboptions"
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+const (
+	ExplainVerbosityExecutionStats = "executionStats"
+
+	ExplainStageCollScan = "COLLSCAN"
+	ExplainStageIxScan   = "IXSCAN"
+	ExplainStageIdHack   = "IDHACK"
+	ExplainStageInsert   = "INSERT"
+
+	explainIdIndexName = "_id_"
+)
+
+// ExplainResult is the normalized summary of the plan chosen by the server for an operation. Writes are explained
+// with the executionStats verbosity that does not apply the modifications.
+type ExplainResult struct {
+	OpType              MongoJsonOperationType `yaml:"op-type,omitempty" json:"opType,omitempty" mapstructure:"op-type,omitempty"`
+	Collection          string                 `yaml:"collection,omitempty" json:"collection,omitempty" mapstructure:"collection,omitempty"`
+	Stage               string                 `yaml:"stage,omitempty" json:"stage,omitempty" mapstructure:"stage,omitempty"`
+	Stages              []string               `yaml:"stages,omitempty" json:"stages,omitempty" mapstructure:"stages,omitempty"`
+	Index               string                 `yaml:"index,omitempty" json:"index,omitempty" mapstructure:"index,omitempty"`
+	IsCollScan          bool                   `yaml:"is-coll-scan,omitempty" json:"isCollScan,omitempty" mapstructure:"is-coll-scan,omitempty"`
+	DocsExamined        int64                  `yaml:"docs-examined,omitempty" json:"docsExamined,omitempty" mapstructure:"docs-examined,omitempty"`
+	KeysExamined        int64                  `yaml:"keys-examined,omitempty" json:"keysExamined,omitempty" mapstructure:"keys-examined,omitempty"`
+	NReturned           int64                  `yaml:"n-returned,omitempty" json:"nReturned,omitempty" mapstructure:"n-returned,omitempty"`
+	ExecutionTimeMillis int64                  `yaml:"execution-time-millis,omitempty" json:"executionTimeMillis,omitempty" mapstructure:"execution-time-millis,omitempty"`
+	Statements          []ExplainResult        `yaml:"statements,omitempty" json:"statements,omitempty" mapstructure:"statements,omitempty"`
+}
+
+// explainer is implemented by the operations executed with a single command: it returns the database command to be explained and the
+// options of the statement. A nil command means the operation has no plan (i.e. insert-one).
+type explainer interface {
+	explainCommand(collectionName string) (bson.D, []byte, error)
+}
+
+// multiExplainer is implemented by the operations executed with several commands (i.e. sync-many). Watch implements neither: a change
+// stream has no plan to explain.
+type multiExplainer interface {
+	explainCommands(collectionName string) ([]bson.D, []byte, error)
+}
+
+// Explain runs the operation in explain mode and returns the summary of the winning plan. Nothing is written on the database.
+func Explain(lks *mongolks.LinkedService, collectionId string, op Operation) (OperationResult, *ExplainResult, error) {
+	return ExplainContext(context.Background(), lks, collectionId, op)
+}
+
+func ExplainContext(ctx context.Context, lks *mongolks.LinkedService, collectionId string, op Operation) (OperationResult, *ExplainResult, error) {
+	const semLogContext = "json-ops::explain"
+	var err error
+
+	c := lks.GetCollection(collectionId, "")
+	if c == nil {
+		err = errors.New("cannot find requested collection")
+		log.Error().Err(err).Str("collection", collectionId).Msg(semLogContext)
+		return OperationResultFromError(err), nil, err
+	}
+
+	// explain runs the plan with executionStats, the statement is subject to the same policy of its execution.
+	if err = checkPolicy(lks, collectionId, op); err != nil {
+		return OperationResultFromError(err), nil, err
+	}
+
+	var cmds []bson.D
+	var opts []byte
+	switch ex := op.(type) {
+	case explainer:
+		var cmd bson.D
+		if cmd, opts, err = ex.explainCommand(c.Name()); err == nil && cmd == nil {
+			return OperationResult{StatusCode: http.StatusOK}, &ExplainResult{OpType: op.OpType(), Collection: c.Name(), Stage: ExplainStageInsert, Stages: []string{ExplainStageInsert}}, nil
+		}
+		cmds = append(cmds, cmd)
+	case multiExplainer:
+		cmds, opts, err = ex.explainCommands(c.Name())
+	default:
+		err = errors.New("explain not supported by op-type " + string(op.OpType()))
+	}
+
+	if err != nil {
+		log.Error().Err(err).Msg(semLogContext)
+		return OperationResultFromStatementError(err), nil, err
+	}
+
+	// i.e. a sync-many with no documents: nothing is executed.
+	if len(cmds) == 0 {
+		return OperationResult{StatusCode: http.StatusOK}, &ExplainResult{OpType: op.OpType(), Collection: c.Name()}, nil
+	}
+
+	ctx, cancel, err := newOperationContext(ctx, lks, collectionId, op.OpType(), opts)
+	if err != nil {
+		log.Error().Err(err).Msg(semLogContext)
+		return OperationResultFromStatementError(err), nil, err
+	}
+	defer cancel()
+
+	var results []ExplainResult
+	for _, cmd := range cmds {
+		raw, err := c.Database().RunCommand(ctx, bson.D{{Key: "explain", Value: cmd}, {Key: "verbosity", Value: ExplainVerbosityExecutionStats}}).Raw()
+		if err != nil {
+			res := OperationResultFromError(err)
+			log.Error().Err(err).Int("status-code", res.StatusCode).Msg(semLogContext)
+			return res, nil, err
+		}
+
+		res, err := NewExplainResult(raw)
+		if err != nil {
+			log.Error().Err(err).Msg(semLogContext)
+			return OperationResultFromError(err), nil, err
+		}
+
+		results = append(results, res)
+	}
+
+	res := results[0]
+	if len(results) > 1 {
+		res = summarizeExplainResults(results)
+	}
+
+	res.OpType = op.OpType()
+	res.Collection = c.Name()
+	return OperationResult{StatusCode: http.StatusOK}, &res, nil
+}
+
+// summarizeExplainResults reports the plan of the first statement that scans the collection, if any, and the sum of the counters.
+// The plans of every statement are in Statements.
+func summarizeExplainResults(results []ExplainResult) ExplainResult {
+	summary := results[0]
+	for _, r := range results {
+		if r.IsCollScan {
+			summary = r
+			break
+		}
+	}
+
+	summary.DocsExamined, summary.KeysExamined, summary.NReturned, summary.ExecutionTimeMillis = 0, 0, 0, 0
+	for _, r := range results {
+		summary.DocsExamined += r.DocsExamined
+		summary.KeysExamined += r.KeysExamined
+		summary.NReturned += r.NReturned
+		summary.ExecutionTimeMillis += r.ExecutionTimeMillis
+	}
+
+	summary.Statements = results
+	return summary
+}
+
+// NewExplainResult normalizes the output of the explain command. The plan is looked up at the top level and, for aggregations,
+// in the $cursor of the first stage. Slot based plans nest the classic tree in the queryPlan field, sharded plans report the first shard.
+func NewExplainResult(raw bson.Raw) (ExplainResult, error) {
+	res := ExplainResult{}
+
+	planner, stats, ok := explainSections(raw)
+	if !ok {
+		return res, errors.New("explain output has no query planner section")
+	}
+
+	if plan, ok := planner.Lookup("winningPlan").DocumentOK(); ok {
+		res.walkPlan(explainClassicPlan(plan))
+	}
+
+	if stats != nil {
+		res.NReturned = explainInt64(stats, "nReturned")
+		res.DocsExamined = explainInt64(stats, "totalDocsExamined")
+		res.KeysExamined = explainInt64(stats, "totalKeysExamined")
+		res.ExecutionTimeMillis = explainInt64(stats, "executionTimeMillis")
+	}
+
+	return res, nil
+}
+
+func explainSections(raw bson.Raw) (bson.Raw, bson.Raw, bool) {
+	if planner, ok := raw.Lookup("queryPlanner").DocumentOK(); ok {
+		stats, _ := raw.Lookup("executionStats").DocumentOK()
+		return planner, stats, true
+	}
+
+	if stages, ok := raw.Lookup("stages").ArrayOK(); ok {
+		values, _ := stages.Values()
+		if len(values) > 0 {
+			if first, ok := values[0].DocumentOK(); ok {
+				if cursor, ok := first.Lookup("$cursor").DocumentOK(); ok {
+					return explainSections(cursor)
+				}
+			}
+		}
+	}
+
+	if shards, ok := raw.Lookup("shards").DocumentOK(); ok {
+		elems, _ := shards.Elements()
+		if len(elems) > 0 {
+			if shard, ok := elems[0].Value().DocumentOK(); ok {
+				return explainSections(shard)
+			}
+		}
+	}
+
+	return nil, nil, false
+}
+
+func explainClassicPlan(plan bson.Raw) bson.Raw {
+	if qp, ok := plan.Lookup("queryPlan").DocumentOK(); ok {
+		return qp
+	}
+
+	if shards, ok := plan.Lookup("shards").ArrayOK(); ok {
+		values, _ := shards.Values()
+		if len(values) > 0 {
+			if shard, ok := values[0].DocumentOK(); ok {
+				if wp, ok := shard.Lookup("winningPlan").DocumentOK(); ok {
+					return explainClassicPlan(wp)
+				}
+			}
+		}
+	}
+
+	return plan
+}
+
+func (res *ExplainResult) walkPlan(plan bson.Raw) {
+	stage, _ := plan.Lookup("stage").StringValueOK()
+	if stage != "" {
+		if res.Stage == "" {
+			res.Stage = stage
+		}
+		res.Stages = append(res.Stages, stage)
+	}
+
+	switch stage {
+	case ExplainStageCollScan:
+		res.IsCollScan = true
+	case ExplainStageIdHack:
+		if res.Index == "" {
+			res.Index = explainIdIndexName
+		}
+	default:
+		if indexName, ok := plan.Lookup("indexName").StringValueOK(); ok && res.Index == "" {
+			res.Index = indexName
+		}
+	}
+
+	if input, ok := plan.Lookup("inputStage").DocumentOK(); ok {
+		res.walkPlan(input)
+	}
+
+	if inputs, ok := plan.Lookup("inputStages").ArrayOK(); ok {
+		values, _ := inputs.Values()
+		for _, v := range values {
+			if input, ok := v.DocumentOK(); ok {
+				res.walkPlan(input)
+			}
+		}
+	}
+}
+
+func explainInt64(doc bson.Raw, key string) int64 {
+	n, _ := doc.Lookup(key).AsInt64OK()
+	return n
+}
+
+// explainStatementOptions returns the options that go into the single statement of update and delete commands.
+func explainStatementOptions(stmt bson.D, jo mdboptions.JsonOptions) bson.D {
+	if jo.Hint != nil {
+		stmt = append(stmt, bson.E{Key: "hint", Value: jo.Hint})
+	}
+	if jo.Collation != nil {
+		stmt = append(stmt, bson.E{Key: "collation", Value: explainCollation(jo.Collation)})
+	}
+	if jo.ArrayFilters != nil {
+		stmt = append(stmt, bson.E{Key: "arrayFilters", Value: jo.ArrayFilters})
+	}
+
+	return stmt
+}
+
+// explainCommandOptions returns the options that go at the top level of the command.
+func explainCommandOptions(cmd bson.D, jo mdboptions.JsonOptions) bson.D {
+	if jo.Let != nil {
+		cmd = append(cmd, bson.E{Key: "let", Value: jo.Let})
+	}
+	if jo.Comment != nil {
+		cmd = append(cmd, bson.E{Key: "comment", Value: jo.Comment})
+	}
+	if jo.BypassDocumentValidation != nil {
+		cmd = append(cmd, bson.E{Key: "bypassDocumentValidation", Value: *jo.BypassDocumentValidation})
+	}
+	if jo.AllowDiskUse != nil {
+		cmd = append(cmd, bson.E{Key: "allowDiskUse", Value: *jo.AllowDiskUse})
+	}
+
+	return cmd
+}
+
+func explainFindCommand(collectionName string, query []byte, sort []byte, projection []byte, opts []byte, supported []string, single bool) (bson.D, error) {
+	statementQuery, err := util.UnmarshalJson2BsonD(query, true)
+	if err != nil {
+		return nil, err
+	}
+
+	jo, err := mdboptions.ParseJsonOptions(opts, supported)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := bson.D{{Key: "find", Value: collectionName}, {Key: "filter", Value: statementQuery}}
+	if srt, err := util.UnmarshalJson2BsonD(sort, false); err != nil {
+		return nil, err
+	} else if len(srt) > 0 {
+		cmd = append(cmd, bson.E{Key: "sort", Value: srt})
+	}
+
+	if prj, err := util.UnmarshalJson2BsonD(projection, false); err != nil {
+		return nil, err
+	} else if len(prj) > 0 {
+		cmd = append(cmd, bson.E{Key: "projection", Value: prj})
+	}
+
+	switch {
+	case single:
+		cmd = append(cmd, bson.E{Key: "limit", Value: int64(1)}, bson.E{Key: "singleBatch", Value: true})
+	case jo.Limit != nil:
+		cmd = append(cmd, bson.E{Key: "limit", Value: *jo.Limit})
+	}
+
+	if jo.Skip != nil {
+		cmd = append(cmd, bson.E{Key: "skip", Value: *jo.Skip})
+	}
+
+	cmd = explainStatementOptions(cmd, jo)
+	return explainCommandOptions(cmd, jo), nil
+}
+
+func explainCollation(co *options.Collation) bson.D {
+	d := bson.D{{Key: "locale", Value: co.Locale}}
+	if co.CaseLevel {
+		d = append(d, bson.E{Key: "caseLevel", Value: true})
+	}
+	if co.CaseFirst != "" {
+		d = append(d, bson.E{Key: "caseFirst", Value: co.CaseFirst})
+	}
+	if co.Strength != 0 {
+		d = append(d, bson.E{Key: "strength", Value: int32(co.Strength)})
+	}
+	if co.NumericOrdering {
+		d = append(d, bson.E{Key: "numericOrdering", Value: true})
+	}
+	if co.Alternate != "" {
+		d = append(d, bson.E{Key: "alternate", Value: co.Alternate})
+	}
+	if co.MaxVariable != "" {
+		d = append(d, bson.E{Key: "maxVariable", Value: co.MaxVariable})
+	}
+	if co.Normalization {
+		d = append(d, bson.E{Key: "normalization", Value: true})
+	}
+	if co.Backwards {
+		d = append(d, bson.E{Key: "backwards", Value: true})
+	}
+
+	return d
+}
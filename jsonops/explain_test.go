@@ -0,0 +1,73 @@
+package jsonops_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/jsonops"
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/mongolks"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestNewExplainResult(t *testing.T) {
+	explainOutputs := []struct {
+		output   string
+		expected jsonops.ExplainResult
+	}{
+		{
+			output: `{ "queryPlanner": { "namespace": "test.orders", "winningPlan": { "stage": "FETCH", "inputStage": { "stage": "IXSCAN", "indexName": "code_1" } } },
+                       "executionStats": { "nReturned": 1, "executionTimeMillis": 2, "totalKeysExamined": 1, "totalDocsExamined": 1 }, "ok": 1 }`,
+			expected: jsonops.ExplainResult{Stage: "FETCH", Stages: []string{"FETCH", "IXSCAN"}, Index: "code_1", NReturned: 1, ExecutionTimeMillis: 2, KeysExamined: 1, DocsExamined: 1},
+		},
+		{
+			output: `{ "queryPlanner": { "winningPlan": { "queryPlan": { "stage": "UPDATE", "inputStage": { "stage": "COLLSCAN" } }, "slotBasedPlan": {} } },
+                       "executionStats": { "nReturned": 0, "totalKeysExamined": 0, "totalDocsExamined": 120 }, "ok": 1 }`,
+			expected: jsonops.ExplainResult{Stage: "UPDATE", Stages: []string{"UPDATE", "COLLSCAN"}, IsCollScan: true, DocsExamined: 120},
+		},
+		{
+			output: `{ "stages": [ { "$cursor": { "queryPlanner": { "winningPlan": { "stage": "IDHACK" } }, "executionStats": { "nReturned": 1, "totalKeysExamined": 1, "totalDocsExamined": 1 } } },
+                       { "$group": {} } ], "ok": 1 }`,
+			expected: jsonops.ExplainResult{Stage: "IDHACK", Stages: []string{"IDHACK"}, Index: "_id_", NReturned: 1, KeysExamined: 1, DocsExamined: 1},
+		},
+	}
+
+	for _, eo := range explainOutputs {
+		var raw bson.Raw
+		err := bson.UnmarshalExtJSON([]byte(eo.output), false, &raw)
+		require.NoError(t, err)
+
+		res, err := jsonops.NewExplainResult(raw)
+		require.NoError(t, err)
+		require.Equal(t, eo.expected, res)
+	}
+
+	raw, err := bson.Marshal(bson.D{{Key: "ok", Value: 1}})
+	require.NoError(t, err)
+	_, err = jsonops.NewExplainResult(raw)
+	require.Error(t, err)
+}
+
+func TestExplainSyncMany(t *testing.T) {
+	lks, err := mongolks.GetLinkedService(context.Background(), "default")
+	require.NoError(t, err)
+
+	op, err := jsonops.NewOperation(jsonops.SyncManyOperationType, map[jsonops.MongoJsonOperationStatementPart][]byte{
+		jsonops.MongoActivitySyncManyDocumentsProperty:     []byte(`[ { "code": "explain-1", "year": 2041 }, { "code": "explain-2", "year": 2041 } ]`),
+		jsonops.MongoActivitySyncManyKeyProperty:           []byte(`"code"`),
+		jsonops.MongoActivitySyncManyScopeProperty:         []byte(`{ "year": 2041 }`),
+		jsonops.MongoActivitySyncManyDeleteMissingProperty: []byte(`true`),
+	})
+	require.NoError(t, err)
+
+	// an update for each key and the delete of the missing ones.
+	_, res, err := jsonops.Explain(lks, CollectionId, op)
+	require.NoError(t, err)
+	require.Equal(t, jsonops.SyncManyOperationType, res.OpType)
+	require.Len(t, res.Statements, 3)
+
+	watchOp, err := jsonops.NewOperation(jsonops.WatchOperationType, map[jsonops.MongoJsonOperationStatementPart][]byte{})
+	require.NoError(t, err)
+	_, _, err = jsonops.Explain(lks, CollectionId, watchOp)
+	require.Error(t, err)
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/mongolks"
 	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/util"
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/util/mdboptions"
 	"github.com/rs/zerolog/log"
 	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
@@ -22,6 +23,7 @@ const (
 	MongoActivityFindOneSortProperty       MongoJsonOperationStatementPart = "$sort"
 	MongoActivityFindOneProjectionProperty MongoJsonOperationStatementPart = "$projection"
 	MongoActivityFindOneOptsProperty       MongoJsonOperationStatementPart = "$opts"
+	MongoActivityFindOneOutputProperty     MongoJsonOperationStatementPart = "$output"
 )
 
 type FindOneOperation struct {
@@ -29,6 +31,7 @@ type FindOneOperation struct {
 	Sort       []byte `yaml:"sort,omitempty" json:"sort,omitempty" mapstructure:"sort,omitempty"`
 	Projection []byte `yaml:"projection,omitempty" json:"projection,omitempty" mapstructure:"projection,omitempty"`
 	Options    []byte `yaml:"options,omitempty" json:"options,omitempty" mapstructure:"options,omitempty"`
+	Output     []byte `yaml:"output,omitempty" json:"output,omitempty" mapstructure:"output,omitempty"`
 }
 
 func (op *FindOneOperation) OpType() MongoJsonOperationType {
@@ -68,6 +71,14 @@ func (op *FindOneOperation) ToString() string {
 		sb.WriteString(fmt.Sprintf("\"%s\": ", MongoActivityFindOneOptsProperty))
 		sb.WriteString(string(op.Options))
 	}
+	if len(op.Output) > 0 {
+		if numberOfElements > 0 {
+			sb.WriteString(",")
+		}
+		numberOfElements++
+		sb.WriteString(fmt.Sprintf("\"%s\": ", MongoActivityFindOneOutputProperty))
+		sb.WriteString(string(op.Output))
+	}
 
 	sb.WriteString("}")
 	return sb.String()
@@ -95,6 +106,10 @@ func NewFindOneOperation(m map[MongoJsonOperationStatementPart][]byte) (*FindOne
 		foStmt.Options = data
 	}
 
+	if data, ok := m[MongoActivityFindOneOutputProperty]; ok {
+		foStmt.Output = data
+	}
+
 	return &foStmt, nil
 }
 
@@ -115,64 +130,77 @@ func NewFindOneStatementConfigFromJson(data []byte) (FindOneOperation, error) {
 		Sort:       m[MongoActivityFindOneSortProperty],
 		Projection: m[MongoActivityFindOneProjectionProperty],
 		Options:    m[MongoActivityFindOneOptsProperty],
+		Output:     m[MongoActivityFindOneOutputProperty],
 	}
 
 	return fo, nil
 }
 
 func (op *FindOneOperation) Execute(lks *mongolks.LinkedService, collectionId string) (OperationResult, []byte, error) {
-	sc, resp, err := FindOne(lks, collectionId, op.Query, op.Projection, op.Sort, op.Options)
+	return op.ExecuteContext(context.Background(), lks, collectionId)
+}
+
+func (op *FindOneOperation) ExecuteContext(ctx context.Context, lks *mongolks.LinkedService, collectionId string) (res OperationResult, resp []byte, err error) {
+	ctx, endSpan := startOperationSpan(ctx, lks, op.OpType(), collectionId)
+	defer func() { endSpan(res, resp, err) }()
+
+	oo, err := NewOutputOptionsFromJson(op.Output)
+	if err != nil {
+		return OperationResultFromStatementError(err), nil, err
+	}
+
+	sc, resp, err := FindOneContext(ctx, lks, collectionId, op.Query, op.Projection, op.Sort, op.Options, oo)
 	return sc, resp, err
 }
 
-func FindOne(lks *mongolks.LinkedService, collectionId string, query []byte, projection []byte, sort []byte, opts []byte) (OperationResult, []byte, error) {
+func FindOne(lks *mongolks.LinkedService, collectionId string, query []byte, projection []byte, sort []byte, opts []byte, output ...OutputOptions) (OperationResult, []byte, error) {
+	return FindOneContext(context.Background(), lks, collectionId, query, projection, sort, opts, output...)
+}
+
+func FindOneContext(ctx context.Context, lks *mongolks.LinkedService, collectionId string, query []byte, projection []byte, sort []byte, opts []byte, output ...OutputOptions) (OperationResult, []byte, error) {
 	const semLogContext = "json-ops::find-one"
 	var err error
 
+	if err := checkPolicy(lks, collectionId, &FindOneOperation{Query: query, Projection: projection, Sort: sort, Options: opts}); err != nil {
+		return OperationResultFromError(err), nil, err
+	}
+
 	c := lks.GetCollection(collectionId, "")
 	if c == nil {
 		err = errors.New("cannot find requested collection")
 		log.Error().Err(err).Str("collection", collectionId).Msg(semLogContext)
-		return OperationResult{StatusCode: http.StatusInternalServerError}, nil, err
+		return OperationResultFromError(err), nil, err
 	}
 
 	statementQuery, err := util.UnmarshalJson2BsonD(query, true)
 	if err != nil {
 		log.Error().Err(err).Msg(semLogContext)
-		return OperationResult{StatusCode: http.StatusInternalServerError}, nil, err
+		return OperationResultFromStatementError(err), nil, err
 	}
 
-	fo := options.FindOne()
-	srt, err := util.UnmarshalJson2BsonD(sort, false)
+	fo, err := mdboptions.FindOneOptionsFromJson(opts, sort, projection)
 	if err != nil {
 		log.Error().Err(err).Msg(semLogContext)
-		return OperationResult{StatusCode: http.StatusInternalServerError}, nil, err
+		return OperationResultFromStatementError(err), nil, err
 	}
 
-	if len(srt) > 0 {
-		fo.SetSort(srt)
-	}
-
-	prj, err := util.UnmarshalJson2BsonD(projection, false)
+	ctx, cancel, err := newOperationContext(ctx, lks, collectionId, FindOneOperationType, opts)
 	if err != nil {
 		log.Error().Err(err).Msg(semLogContext)
-		return OperationResult{StatusCode: http.StatusInternalServerError}, nil, err
-	}
-
-	if len(prj) > 0 {
-		fo.SetProjection(prj)
+		return OperationResultFromStatementError(err), nil, err
 	}
+	defer cancel()
 
-	sc, body, err := executeFindOneOp(c, statementQuery, fo)
+	sc, body, err := executeFindOneOp(ctx, c, statementQuery, fo)
 	if err != nil {
-		return OperationResult{StatusCode: http.StatusInternalServerError}, nil, err
+		return sc, nil, err
 	}
 
 	if sc.StatusCode == http.StatusOK {
-		b, err := json.Marshal(body)
+		b, err := outputOptionsOf(output).MarshalDocument(body)
 		if err != nil {
 			log.Error().Err(err).Msg(semLogContext)
-			return OperationResult{StatusCode: http.StatusInternalServerError}, nil, err
+			return OperationResultFromError(err), nil, err
 		}
 
 		return sc, b, nil
@@ -181,26 +209,25 @@ func FindOne(lks *mongolks.LinkedService, collectionId string, query []byte, pro
 	return sc, nil, nil
 }
 
-func executeFindOneOp(c *mongo.Collection, query bson.D, fo options.Lister[options.FindOneOptions]) (OperationResult, bson.M, error) {
+func executeFindOneOp(ctx context.Context, c *mongo.Collection, query bson.D, fo options.Lister[options.FindOneOptions]) (OperationResult, bson.Raw, error) {
 	const semLogContext = "mongo-operation::execute-find-one-op"
 
-	result := c.FindOne(context.Background(), query, fo)
+	result := c.FindOne(ctx, query, fo)
 	if errors.Is(result.Err(), mongo.ErrNoDocuments) {
 		return OperationResult{StatusCode: http.StatusNotFound}, nil, nil
 	}
 
 	if result.Err() != nil {
 		err := result.Err()
-		mongoErrorCode := util.MongoErrorCode(err, util.MongoDbVersion{})
-		log.Error().Err(err).Int32("mongo-error", mongoErrorCode).Msg(semLogContext)
-		return OperationResult{StatusCode: int(-mongoErrorCode)}, nil, err
+		res := OperationResultFromError(err)
+		log.Error().Err(err).Int("status-code", res.StatusCode).Msg(semLogContext)
+		return res, nil, err
 	}
 
-	var body bson.M
-	err := result.Decode(&body)
+	body, err := result.Raw()
 	if err != nil {
 		log.Error().Err(err).Msg(semLogContext)
-		return OperationResult{StatusCode: http.StatusInternalServerError}, nil, err
+		return OperationResultFromError(err), nil, err
 	}
 
 	return OperationResult{StatusCode: http.StatusOK}, body, nil
@@ -209,3 +236,8 @@ func executeFindOneOp(c *mongo.Collection, query bson.D, fo options.Lister[optio
 func (op *FindOneOperation) NewWriteModel() (mongo.WriteModel, error) {
 	panic("new write model not supported in find operations")
 }
+
+func (op *FindOneOperation) explainCommand(collectionName string) (bson.D, []byte, error) {
+	cmd, err := explainFindCommand(collectionName, op.Query, op.Sort, op.Projection, op.Options, mdboptions.FindOneOptionNames, true)
+	return cmd, op.Options, err
+}
@@ -24,6 +24,7 @@ const (
 	MongoActivityFindSortProperty       MongoJsonOperationStatementPart = "$sort"
 	MongoActivityFindProjectionProperty MongoJsonOperationStatementPart = "$projection"
 	MongoActivityFindOptsProperty       MongoJsonOperationStatementPart = "$opts"
+	MongoActivityFindOutputProperty     MongoJsonOperationStatementPart = "$output"
 )
 
 type FindOperation struct {
@@ -31,6 +32,7 @@ type FindOperation struct {
 	Sort       []byte `yaml:"sort,omitempty" json:"sort,omitempty" mapstructure:"sort,omitempty"`
 	Projection []byte `yaml:"projection,omitempty" json:"projection,omitempty" mapstructure:"projection,omitempty"`
 	Options    []byte `yaml:"options,omitempty" json:"options,omitempty" mapstructure:"options,omitempty"`
+	Output     []byte `yaml:"output,omitempty" json:"output,omitempty" mapstructure:"output,omitempty"`
 }
 
 func (op *FindOperation) OpType() MongoJsonOperationType {
@@ -70,6 +72,14 @@ func (op *FindOperation) ToString() string {
 		sb.WriteString(fmt.Sprintf("\"%s\": ", MongoActivityFindOptsProperty))
 		sb.WriteString(string(op.Options))
 	}
+	if len(op.Output) > 0 {
+		if numberOfElements > 0 {
+			sb.WriteString(",")
+		}
+		numberOfElements++
+		sb.WriteString(fmt.Sprintf("\"%s\": ", MongoActivityFindOutputProperty))
+		sb.WriteString(string(op.Output))
+	}
 
 	sb.WriteString("}")
 	return sb.String()
@@ -97,6 +107,10 @@ func NewFindOperation(m map[MongoJsonOperationStatementPart][]byte) (*FindOperat
 		foStmt.Options = data
 	}
 
+	if data, ok := m[MongoActivityFindOutputProperty]; ok {
+		foStmt.Output = data
+	}
+
 	return &foStmt, nil
 }
 
@@ -117,37 +131,58 @@ func NewFindStatementConfigFromJson(data []byte) (FindOperation, error) {
 		Sort:       m[MongoActivityFindSortProperty],
 		Projection: m[MongoActivityFindProjectionProperty],
 		Options:    m[MongoActivityFindOptsProperty],
+		Output:     m[MongoActivityFindOutputProperty],
 	}
 
 	return fo, nil
 }
 
 func (op *FindOperation) Execute(lks *mongolks.LinkedService, collectionId string) (OperationResult, []byte, error) {
-	sc, resp, err := Find(lks, collectionId, op.Query, op.Projection, op.Sort, op.Options)
+	return op.ExecuteContext(context.Background(), lks, collectionId)
+}
+
+func (op *FindOperation) ExecuteContext(ctx context.Context, lks *mongolks.LinkedService, collectionId string) (res OperationResult, resp []byte, err error) {
+	ctx, endSpan := startOperationSpan(ctx, lks, op.OpType(), collectionId)
+	defer func() { endSpan(res, resp, err) }()
+
+	oo, err := NewOutputOptionsFromJson(op.Output)
+	if err != nil {
+		return OperationResultFromStatementError(err), nil, err
+	}
+
+	sc, resp, err := FindContext(ctx, lks, collectionId, op.Query, op.Projection, op.Sort, op.Options, oo)
 	return sc, resp, err
 }
 
-func Find(lks *mongolks.LinkedService, collectionId string, query []byte, projection []byte, sort []byte, opts []byte) (OperationResult, []byte, error) {
+func Find(lks *mongolks.LinkedService, collectionId string, query []byte, projection []byte, sort []byte, opts []byte, output ...OutputOptions) (OperationResult, []byte, error) {
+	return FindContext(context.Background(), lks, collectionId, query, projection, sort, opts, output...)
+}
+
+func FindContext(ctx context.Context, lks *mongolks.LinkedService, collectionId string, query []byte, projection []byte, sort []byte, opts []byte, output ...OutputOptions) (OperationResult, []byte, error) {
 	const semLogContext = "json-ops::find-one"
 	var err error
 
+	if err := checkPolicy(lks, collectionId, &FindOperation{Query: query, Projection: projection, Sort: sort, Options: opts}); err != nil {
+		return OperationResultFromError(err), nil, err
+	}
+
 	c := lks.GetCollection(collectionId, "")
 	if c == nil {
 		err = errors.New("cannot find requested collection")
 		log.Error().Err(err).Str("collection", collectionId).Msg(semLogContext)
-		return OperationResult{StatusCode: http.StatusInternalServerError}, nil, err
+		return OperationResultFromError(err), nil, err
 	}
 
 	statementQuery, err := util.UnmarshalJson2BsonD(query, true)
 	if err != nil {
 		log.Error().Err(err).Msg(semLogContext)
-		return OperationResult{StatusCode: http.StatusInternalServerError}, nil, err
+		return OperationResultFromStatementError(err), nil, err
 	}
 
 	fo, err := mdboptions.FindOptionsFromJson(opts, sort, projection)
 	if err != nil {
 		log.Error().Err(err).Msg(semLogContext)
-		return OperationResult{StatusCode: http.StatusInternalServerError}, nil, err
+		return OperationResultFromStatementError(err), nil, err
 	}
 
 	//srt, err := util.UnmarshalJson2BsonD(sort, false)
@@ -170,9 +205,16 @@ func Find(lks *mongolks.LinkedService, collectionId string, query []byte, projec
 	//	fo.SetProjection(prj)
 	//}
 
-	sc, body, err := executeFindOp(c, statementQuery, fo)
+	ctx, cancel, err := newOperationContext(ctx, lks, collectionId, FindManyOperationType, opts)
 	if err != nil {
-		return OperationResult{StatusCode: http.StatusInternalServerError}, nil, err
+		log.Error().Err(err).Msg(semLogContext)
+		return OperationResultFromStatementError(err), nil, err
+	}
+	defer cancel()
+
+	sc, body, err := executeFindOp(ctx, c, statementQuery, fo, outputOptionsOf(output))
+	if err != nil {
+		return sc, nil, err
 	}
 
 	if sc.StatusCode == http.StatusOK {
@@ -226,29 +268,24 @@ func Find(lks *mongolks.LinkedService, collectionId string, query []byte, projec
 //	return fo, nil
 //}
 
-func executeFindOp(c *mongo.Collection, query bson.D, fo options.Lister[options.FindOptions]) (OperationResult, [][]byte, error) {
+func executeFindOp(ctx context.Context, c *mongo.Collection, query bson.D, fo options.Lister[options.FindOptions], oo OutputOptions) (OperationResult, [][]byte, error) {
 	const semLogContext = "mongo-operation::execute-find-op"
 
-	crs, err := c.Find(context.Background(), query, fo)
+	crs, err := c.Find(ctx, query, fo)
 	if err != nil {
-		mongoErrorCode := util.MongoErrorCode(err, util.MongoDbVersion{})
-		log.Error().Err(err).Int32("mongo-error", mongoErrorCode).Msg(semLogContext)
-		return OperationResult{StatusCode: int(-mongoErrorCode)}, nil, err
+		res := OperationResultFromError(err)
+		log.Error().Err(err).Int("status-code", res.StatusCode).Msg(semLogContext)
+		return res, nil, err
 	}
 
-	var resp [][]byte
-	for crs.Next(context.TODO()) {
-		var el bson.M
-		err = crs.Decode(&el)
-		if err = crs.Decode(&el); err != nil {
-			return OperationResult{StatusCode: http.StatusInternalServerError}, nil, err
-		}
+	defer crs.Close(ctx)
 
-		var b []byte
-		b, err := json.Marshal(el)
+	var resp [][]byte
+	for crs.Next(ctx) {
+		b, err := oo.MarshalDocument(crs.Current)
 		if err != nil {
 			log.Error().Err(err).Msg(semLogContext)
-			return OperationResult{StatusCode: http.StatusInternalServerError}, nil, err
+			return OperationResultFromError(err), nil, err
 		}
 
 		resp = append(resp, b)
@@ -256,7 +293,7 @@ func executeFindOp(c *mongo.Collection, query bson.D, fo options.Lister[options.
 
 	if err = crs.Err(); err != nil {
 		log.Error().Err(err).Msg(semLogContext)
-		return OperationResult{StatusCode: http.StatusInternalServerError}, nil, err
+		return OperationResultFromError(err), nil, err
 	}
 
 	return OperationResult{StatusCode: http.StatusOK, MatchedCount: int64(len(resp))}, resp, nil
@@ -265,3 +302,8 @@ func executeFindOp(c *mongo.Collection, query bson.D, fo options.Lister[options.
 func (op *FindOperation) NewWriteModel() (mongo.WriteModel, error) {
 	panic("new write model not supported in find operations")
 }
+
+func (op *FindOperation) explainCommand(collectionName string) (bson.D, []byte, error) {
+	cmd, err := explainFindCommand(collectionName, op.Query, op.Sort, op.Projection, op.Options, mdboptions.FindOptionNames, false)
+	return cmd, op.Options, err
+}
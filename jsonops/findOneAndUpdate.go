@@ -24,6 +24,7 @@ const (
 	MongoActivityFindOneAndUpdateSortProperty       MongoJsonOperationStatementPart = "$sort"
 	MongoActivityFindOneAndUpdateProjectionProperty MongoJsonOperationStatementPart = "$projection"
 	MongoActivityFindOneAndUpdateOptsProperty       MongoJsonOperationStatementPart = "$opts"
+	MongoActivityFindOneAndUpdateOutputProperty     MongoJsonOperationStatementPart = "$output"
 )
 
 type FindOneAndUpdateOperation struct {
@@ -32,6 +33,7 @@ type FindOneAndUpdateOperation struct {
 	Projection []byte `yaml:"projection,omitempty" json:"projection,omitempty" mapstructure:"projection,omitempty"`
 	Update     []byte `yaml:"update,omitempty" json:"update,omitempty" mapstructure:"update,omitempty"`
 	Options    []byte `yaml:"options,omitempty" json:"options,omitempty" mapstructure:"options,omitempty"`
+	Output     []byte `yaml:"output,omitempty" json:"output,omitempty" mapstructure:"output,omitempty"`
 }
 
 func (op *FindOneAndUpdateOperation) OpType() MongoJsonOperationType {
@@ -79,6 +81,14 @@ func (op *FindOneAndUpdateOperation) ToString() string {
 		sb.WriteString(fmt.Sprintf("\"%s\": ", MongoActivityFindOneAndUpdateOptsProperty))
 		sb.WriteString(string(op.Options))
 	}
+	if len(op.Output) > 0 {
+		if numberOfElements > 0 {
+			sb.WriteString(",")
+		}
+		numberOfElements++
+		sb.WriteString(fmt.Sprintf("\"%s\": ", MongoActivityFindOneAndUpdateOutputProperty))
+		sb.WriteString(string(op.Output))
+	}
 
 	sb.WriteString("}")
 	return sb.String()
@@ -106,6 +116,10 @@ func NewFindOneAndUpdateOperation(m map[MongoJsonOperationStatementPart][]byte)
 		foStmt.Options = data
 	}
 
+	if data, ok := m[MongoActivityFindOneAndUpdateOutputProperty]; ok {
+		foStmt.Output = data
+	}
+
 	if data, ok := m[MongoActivityFindOneAndUpdateUpdateProperty]; ok {
 		foStmt.Update = data
 	}
@@ -130,6 +144,7 @@ func NewFindOneAndUpdateStatementConfigFromJson(data []byte) (FindOneAndUpdateOp
 		Sort:       m[MongoActivityFindOneAndUpdateSortProperty],
 		Projection: m[MongoActivityFindOneAndUpdateProjectionProperty],
 		Options:    m[MongoActivityFindOneAndUpdateOptsProperty],
+		Output:     m[MongoActivityFindOneAndUpdateOutputProperty],
 		Update:     m[MongoActivityFindOneAndUpdateUpdateProperty],
 	}
 
@@ -137,7 +152,19 @@ func NewFindOneAndUpdateStatementConfigFromJson(data []byte) (FindOneAndUpdateOp
 }
 
 func (op *FindOneAndUpdateOperation) Execute(lks *mongolks.LinkedService, collectionId string) (OperationResult, []byte, error) {
-	sc, resp, err := FindOneAndUpdate(lks, collectionId, op.Query, op.Projection, op.Sort, op.Update, op.Options)
+	return op.ExecuteContext(context.Background(), lks, collectionId)
+}
+
+func (op *FindOneAndUpdateOperation) ExecuteContext(ctx context.Context, lks *mongolks.LinkedService, collectionId string) (res OperationResult, resp []byte, err error) {
+	ctx, endSpan := startOperationSpan(ctx, lks, op.OpType(), collectionId)
+	defer func() { endSpan(res, resp, err) }()
+
+	oo, err := NewOutputOptionsFromJson(op.Output)
+	if err != nil {
+		return OperationResultFromStatementError(err), nil, err
+	}
+
+	sc, resp, err := FindOneAndUpdateContext(ctx, lks, collectionId, op.Query, op.Projection, op.Sort, op.Update, op.Options, oo)
 	return sc, resp, err
 }
 
@@ -179,47 +206,62 @@ func (op *FindOneAndUpdateOperation) Execute(lks *mongolks.LinkedService, collec
 //	return fo, nil
 //}
 
-func FindOneAndUpdate(lks *mongolks.LinkedService, collectionId string, query []byte, projection []byte, sort []byte, update []byte, opts []byte) (OperationResult, []byte, error) {
+func FindOneAndUpdate(lks *mongolks.LinkedService, collectionId string, query []byte, projection []byte, sort []byte, update []byte, opts []byte, output ...OutputOptions) (OperationResult, []byte, error) {
+	return FindOneAndUpdateContext(context.Background(), lks, collectionId, query, projection, sort, update, opts, output...)
+}
+
+func FindOneAndUpdateContext(ctx context.Context, lks *mongolks.LinkedService, collectionId string, query []byte, projection []byte, sort []byte, update []byte, opts []byte, output ...OutputOptions) (OperationResult, []byte, error) {
 	const semLogContext = "json-ops::find-one-and-update"
 	var err error
 
+	if err := checkPolicy(lks, collectionId, &FindOneAndUpdateOperation{Query: query, Projection: projection, Sort: sort, Update: update, Options: opts}); err != nil {
+		return OperationResultFromError(err), nil, err
+	}
+
 	c := lks.GetCollection(collectionId, "")
 	if c == nil {
 		err = errors.New("cannot find requested collection")
 		log.Error().Err(err).Str("collection", collectionId).Msg(semLogContext)
-		return OperationResult{StatusCode: http.StatusInternalServerError}, nil, err
+		return OperationResultFromError(err), nil, err
 	}
 
 	statementQuery, err := util.UnmarshalJson2BsonD(query, true)
 	if err != nil {
 		log.Error().Err(err).Msg(semLogContext)
-		return OperationResult{StatusCode: http.StatusInternalServerError}, nil, err
+		return OperationResultFromStatementError(err), nil, err
 	}
 
 	statementUpdate, err := util.UnmarshalJson2Bson(update, true)
 	if err != nil {
 		log.Error().Err(err).Msg(semLogContext)
-		return OperationResult{StatusCode: http.StatusInternalServerError}, nil, err
+		return OperationResultFromStatementError(err), nil, err
 	}
 
 	fo, upsert, err := mdboptions.FindOneAndUpdateOptionsFromJson(opts, sort, projection)
 	if err != nil {
 		log.Error().Err(err).Msg(semLogContext)
-		return OperationResult{StatusCode: http.StatusInternalServerError}, nil, err
+		return OperationResultFromStatementError(err), nil, err
+	}
+
+	ctx, cancel, err := newOperationContext(ctx, lks, collectionId, FindOneAndUpdateOperationType, opts)
+	if err != nil {
+		log.Error().Err(err).Msg(semLogContext)
+		return OperationResultFromStatementError(err), nil, err
 	}
+	defer cancel()
 
-	sc, body, err := executeFindOneAndUpdateOp(c, statementQuery, statementUpdate, fo, upsert)
+	sc, body, err := executeFindOneAndUpdateOp(ctx, c, statementQuery, statementUpdate, fo, upsert)
 	if err != nil {
-		mongoErrorCode := util.MongoErrorCode(err, util.MongoDbVersion{})
-		log.Error().Err(err).Int32("mongo-error", mongoErrorCode).Msg(semLogContext)
-		return OperationResult{StatusCode: int(-mongoErrorCode)}, nil, err
+		res := OperationResultFromError(err)
+		log.Error().Err(err).Int("status-code", res.StatusCode).Msg(semLogContext)
+		return res, nil, err
 	}
 
 	if sc.StatusCode == http.StatusOK {
-		b, err := json.Marshal(body)
+		b, err := outputOptionsOf(output).MarshalDocument(body)
 		if err != nil {
 			log.Error().Err(err).Msg(semLogContext)
-			return OperationResult{StatusCode: http.StatusInternalServerError}, nil, err
+			return OperationResultFromError(err), nil, err
 		}
 
 		return sc, b, nil
@@ -228,10 +270,10 @@ func FindOneAndUpdate(lks *mongolks.LinkedService, collectionId string, query []
 	return sc, nil, nil
 }
 
-func executeFindOneAndUpdateOp(c *mongo.Collection, query bson.D, update any, fo options.Lister[options.FindOneAndUpdateOptions], isUpsert bool) (OperationResult, bson.M, error) {
+func executeFindOneAndUpdateOp(ctx context.Context, c *mongo.Collection, query bson.D, update any, fo options.Lister[options.FindOneAndUpdateOptions], isUpsert bool) (OperationResult, bson.Raw, error) {
 	const semLogContext = "mongo-operation::execute-find-one-and-update-op"
 
-	result := c.FindOneAndUpdate(context.Background(), query, update, fo)
+	result := c.FindOneAndUpdate(ctx, query, update, fo)
 	if errors.Is(result.Err(), mongo.ErrNoDocuments) {
 		if isUpsert {
 			return OperationResult{StatusCode: http.StatusNoContent}, nil, nil
@@ -241,16 +283,15 @@ func executeFindOneAndUpdateOp(c *mongo.Collection, query bson.D, update any, fo
 
 	if result.Err() != nil {
 		err := result.Err()
-		mongoErrorCode := util.MongoErrorCode(err, util.MongoDbVersion{})
-		log.Error().Err(err).Int32("mongo-error", mongoErrorCode).Msg(semLogContext)
-		return OperationResult{StatusCode: int(-mongoErrorCode)}, nil, err
+		res := OperationResultFromError(err)
+		log.Error().Err(err).Int("status-code", res.StatusCode).Msg(semLogContext)
+		return res, nil, err
 	}
 
-	var body bson.M
-	err := result.Decode(&body)
+	body, err := result.Raw()
 	if err != nil {
 		log.Error().Err(err).Msg(semLogContext)
-		return OperationResult{StatusCode: http.StatusInternalServerError}, nil, err
+		return OperationResultFromError(err), nil, err
 	}
 
 	return OperationResult{StatusCode: http.StatusOK}, body, nil
@@ -259,3 +300,37 @@ func executeFindOneAndUpdateOp(c *mongo.Collection, query bson.D, update any, fo
 func (op *FindOneAndUpdateOperation) NewWriteModel() (mongo.WriteModel, error) {
 	panic("new write model not supported in find operations")
 }
+
+func (op *FindOneAndUpdateOperation) explainCommand(collectionName string) (bson.D, []byte, error) {
+	statementQuery, err := util.UnmarshalJson2BsonD(op.Query, true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	statementUpdate, err := util.UnmarshalJson2Bson(op.Update, true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	jo, err := mdboptions.ParseJsonOptions(op.Options, mdboptions.FindOneAndUpdateOptionNames)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cmd := bson.D{{Key: "findAndModify", Value: collectionName}, {Key: "query", Value: statementQuery}, {Key: "update", Value: statementUpdate}}
+	if srt, err := util.UnmarshalJson2BsonD(op.Sort, false); err != nil {
+		return nil, nil, err
+	} else if len(srt) > 0 {
+		cmd = append(cmd, bson.E{Key: "sort", Value: srt})
+	}
+
+	if prj, err := util.UnmarshalJson2BsonD(op.Projection, false); err != nil {
+		return nil, nil, err
+	} else if len(prj) > 0 {
+		cmd = append(cmd, bson.E{Key: "fields", Value: prj})
+	}
+
+	cmd = append(cmd, bson.E{Key: "upsert", Value: jo.IsUpsert()}, bson.E{Key: "new", Value: jo.ReturnDocument != nil && *jo.ReturnDocument == options.After})
+	cmd = explainStatementOptions(cmd, jo)
+	return explainCommandOptions(cmd, jo), op.Options, nil
+}
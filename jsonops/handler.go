@@ -0,0 +1,186 @@
+package jsonops
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/mongolks"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	HandlerPattern      = "POST /{lks}/{collectionId}/{opType}"
+	HandlerExplainParam = "explain"
+
+	DefaultHandlerMaxBodySize int64 = 1 << 20
+)
+
+// Authorizer decides if the request can execute the operation. A returned *Problem is written as is, any other error maps to 403.
+type Authorizer func(r *http.Request, lks string, collectionId string, opType MongoJsonOperationType) error
+
+var ErrNoAuthorizer = errors.New("no authorizer configured, requests are denied")
+
+// AllowAll is the explicit opt-in to serve every request, i.e. behind a gateway that already authorizes them.
+func AllowAll(r *http.Request, lks string, collectionId string, opType MongoJsonOperationType) error {
+	return nil
+}
+
+type HandlerOptions struct {
+	MaxBodySize int64
+	Authorizer  Authorizer
+}
+
+type HandlerOption func(*HandlerOptions)
+
+func HandlerWithMaxBodySize(n int64) HandlerOption {
+	return func(o *HandlerOptions) {
+		o.MaxBodySize = n
+	}
+}
+
+func HandlerWithAuthorizer(a Authorizer) HandlerOption {
+	return func(o *HandlerOptions) {
+		o.Authorizer = a
+	}
+}
+
+// Handler exposes the operations as POST /{lks}/{collectionId}/{opType}: the body is a json object with the statement parts ($query, $filter, $opts, ...).
+// The query parameter explain=true returns the plan summary instead of executing the operation. Mount it under a prefix with http.StripPrefix.
+// Without an Authorizer every request is denied, HandlerWithAuthorizer(AllowAll) serves them all.
+type Handler struct {
+	opts HandlerOptions
+	mux  *http.ServeMux
+}
+
+func NewHandler(opts ...HandlerOption) *Handler {
+	hOptions := HandlerOptions{MaxBodySize: DefaultHandlerMaxBodySize}
+	for _, opt := range opts {
+		opt(&hOptions)
+	}
+
+	h := &Handler{opts: hOptions, mux: http.NewServeMux()}
+	h.mux.HandleFunc(HandlerPattern, h.serveOperation)
+	return h
+}
+
+func denyAll(r *http.Request, lks string, collectionId string, opType MongoJsonOperationType) error {
+	return ErrNoAuthorizer
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) serveOperation(w http.ResponseWriter, r *http.Request) {
+	const semLogContext = "json-ops::serve-operation"
+
+	lksName := r.PathValue("lks")
+	collectionId := r.PathValue("collectionId")
+	opType := MongoJsonOperationType(r.PathValue("opType"))
+
+	authorize := h.opts.Authorizer
+	if authorize == nil {
+		authorize = denyAll
+	}
+
+	if err := authorize(r, lksName, collectionId, opType); err != nil {
+		log.Error().Err(err).Str("lks", lksName).Str("collection-id", collectionId).Str("op-type", string(opType)).Msg(semLogContext)
+		var p *Problem
+		if !errors.As(err, &p) {
+			p = NewProblem(http.StatusForbidden, err)
+		}
+		writeProblem(w, r, p)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, h.opts.MaxBodySize))
+	if err != nil {
+		log.Error().Err(err).Msg(semLogContext)
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			writeProblem(w, r, NewProblem(http.StatusRequestEntityTooLarge, err))
+		} else {
+			writeProblem(w, r, NewProblem(http.StatusBadRequest, err))
+		}
+		return
+	}
+
+	var m map[MongoJsonOperationStatementPart]json.RawMessage
+	if err = json.Unmarshal(body, &m); err != nil {
+		log.Error().Err(err).Msg(semLogContext)
+		writeProblem(w, r, NewProblem(http.StatusBadRequest, err))
+		return
+	}
+
+	stmt := make(map[MongoJsonOperationStatementPart][]byte, len(m))
+	for part, data := range m {
+		stmt[part] = data
+	}
+
+	lks, err := mongolks.GetLinkedService(r.Context(), lksName)
+	if err != nil {
+		log.Error().Err(err).Str("lks", lksName).Msg(semLogContext)
+		// the registry reports unknown linked services with a plain error, connection failures keep their mapping.
+		sc := StatusCodeFromError(err)
+		if sc == http.StatusInternalServerError {
+			sc = http.StatusNotFound
+		}
+		writeProblem(w, r, NewProblem(sc, err))
+		return
+	}
+
+	op, err := NewOperation(opType, stmt)
+	if err != nil {
+		writeProblem(w, r, NewProblem(http.StatusBadRequest, err))
+		return
+	}
+
+	if explainMode, _ := strconv.ParseBool(r.URL.Query().Get(HandlerExplainParam)); explainMode {
+		res, plan, err := ExplainContext(r.Context(), lks, collectionId, op)
+		if err != nil {
+			writeProblem(w, r, res.Problem)
+			return
+		}
+
+		b, _ := json.Marshal(plan)
+		writeBody(w, res.StatusCode, b)
+		return
+	}
+
+	res, resp, err := op.ExecuteContext(r.Context(), lks, collectionId)
+	if res.Problem != nil {
+		writeProblem(w, r, res.Problem)
+		return
+	}
+
+	if err != nil || (res.StatusCode >= http.StatusBadRequest && len(resp) == 0) {
+		writeProblem(w, r, NewProblem(res.StatusCode, err))
+		return
+	}
+
+	writeBody(w, res.StatusCode, resp)
+}
+
+func writeBody(w http.ResponseWriter, statusCode int, body []byte) {
+	if len(body) > 0 {
+		w.Header().Set("Content-Type", "application/json")
+	}
+
+	w.WriteHeader(statusCode)
+	if len(body) > 0 {
+		_, _ = w.Write(body)
+	}
+}
+
+func writeProblem(w http.ResponseWriter, r *http.Request, p *Problem) {
+	if p.Instance == "" {
+		p.Instance = r.URL.Path
+	}
+
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(p.Status)
+	_, _ = w.Write(p.ToJson())
+}
@@ -0,0 +1,56 @@
+package jsonops_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/jsonops"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler(t *testing.T) {
+	h := jsonops.NewHandler(
+		jsonops.HandlerWithMaxBodySize(64),
+		jsonops.HandlerWithAuthorizer(func(r *http.Request, lks string, collectionId string, opType jsonops.MongoJsonOperationType) error {
+			if opType == jsonops.DeleteManyOperationType {
+				return errors.New("delete-many not allowed")
+			}
+			return nil
+		}),
+	)
+
+	handlerCases := []struct {
+		method     string
+		path       string
+		body       string
+		statusCode int
+	}{
+		{http.MethodGet, "/default/" + CollectionId + "/find", "", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/default/" + CollectionId + "/delete-many", `{ "$filter": {} }`, http.StatusForbidden},
+		{http.MethodPost, "/default/" + CollectionId + "/find", `{ "$query": { "title": "` + strings.Repeat("x", 64) + `" } }`, http.StatusRequestEntityTooLarge},
+		{http.MethodPost, "/default/" + CollectionId + "/find", `{ "$query": `, http.StatusBadRequest},
+		{http.MethodPost, "/unknown/" + CollectionId + "/find", `{ "$query": {} }`, http.StatusNotFound},
+	}
+
+	for i, c := range handlerCases {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(c.method, c.path, strings.NewReader(c.body)))
+		require.Equal(t, c.statusCode, rec.Code, "case #%d", i)
+		if c.statusCode != http.StatusMethodNotAllowed {
+			require.Equal(t, jsonops.ProblemContentType, rec.Header().Get("Content-Type"), "case #%d", i)
+		}
+	}
+}
+
+func TestHandlerDeniesByDefault(t *testing.T) {
+	rec := httptest.NewRecorder()
+	jsonops.NewHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/default/"+CollectionId+"/delete-many", strings.NewReader(`{ "$filter": {} }`)))
+	require.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = httptest.NewRecorder()
+	jsonops.NewHandler(jsonops.HandlerWithAuthorizer(jsonops.AllowAll)).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/unknown/"+CollectionId+"/find", strings.NewReader(`{ "$query": {} }`)))
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
@@ -5,26 +5,27 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"net/http"
 	"strings"
 
 	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/mongolks"
 	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/util"
 	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/util/mdboptions"
 	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
-	"go.mongodb.org/mongo-driver/v2/mongo/options"
 )
 
 const (
 	MongoActivityInsertOneOpProperty       MongoJsonOperationStatementPart = "$op"
 	MongoActivityInsertOneDocumentProperty MongoJsonOperationStatementPart = "$document"
 	MongoActivityInsertOneOptsProperty     MongoJsonOperationStatementPart = "$opts"
+	MongoActivityInsertOneOutputProperty   MongoJsonOperationStatementPart = "$output"
 )
 
 type InsertOneOperation struct {
 	Document []byte `yaml:"document,omitempty" json:"document,omitempty" mapstructure:"document,omitempty"`
 	Options  []byte `yaml:"options,omitempty" json:"options,omitempty" mapstructure:"options,omitempty"`
+	Output   []byte `yaml:"output,omitempty" json:"output,omitempty" mapstructure:"output,omitempty"`
 }
 
 func (op *InsertOneOperation) OpType() MongoJsonOperationType {
@@ -52,6 +53,14 @@ func (op *InsertOneOperation) ToString() string {
 		sb.WriteString(fmt.Sprintf("\"%s\": ", MongoActivityInsertOneOptsProperty))
 		sb.WriteString(string(op.Options))
 	}
+	if len(op.Output) > 0 {
+		if numberOfElements > 0 {
+			sb.WriteString(",")
+		}
+		numberOfElements++
+		sb.WriteString(fmt.Sprintf("\"%s\": ", MongoActivityInsertOneOutputProperty))
+		sb.WriteString(string(op.Output))
+	}
 
 	sb.WriteString("}")
 	return sb.String()
@@ -71,6 +80,10 @@ func NewInsertOneOperation(m map[MongoJsonOperationStatementPart][]byte) (*Inser
 		foStmt.Options = data
 	}
 
+	if data, ok := m[MongoActivityInsertOneOutputProperty]; ok {
+		foStmt.Output = data
+	}
+
 	return &foStmt, nil
 }
 
@@ -89,51 +102,79 @@ func NewInsertOneStatementConfigFromJson(data []byte) (InsertOneOperation, error
 	fo := InsertOneOperation{
 		Document: m[MongoActivityInsertOneDocumentProperty],
 		Options:  m[MongoActivityInsertOneOptsProperty],
+		Output:   m[MongoActivityInsertOneOutputProperty],
 	}
 
 	return fo, nil
 }
 
 func (op *InsertOneOperation) Execute(lks *mongolks.LinkedService, collectionId string) (OperationResult, []byte, error) {
-	sc, resp, err := InsertOne(lks, collectionId, op.Document, op.Options)
+	return op.ExecuteContext(context.Background(), lks, collectionId)
+}
+
+func (op *InsertOneOperation) ExecuteContext(ctx context.Context, lks *mongolks.LinkedService, collectionId string) (res OperationResult, resp []byte, err error) {
+	ctx, endSpan := startOperationSpan(ctx, lks, op.OpType(), collectionId)
+	defer func() { endSpan(res, resp, err) }()
+
+	oo, err := NewOutputOptionsFromJson(op.Output)
+	if err != nil {
+		return OperationResultFromStatementError(err), nil, err
+	}
+
+	sc, resp, err := InsertOneContext(ctx, lks, collectionId, op.Document, op.Options, oo)
 	return sc, resp, err
 }
 
-func InsertOne(lks *mongolks.LinkedService, collectionId string, document []byte, opts []byte) (OperationResult, []byte, error) {
+func InsertOne(lks *mongolks.LinkedService, collectionId string, document []byte, opts []byte, output ...OutputOptions) (OperationResult, []byte, error) {
+	return InsertOneContext(context.Background(), lks, collectionId, document, opts, output...)
+}
+
+func InsertOneContext(ctx context.Context, lks *mongolks.LinkedService, collectionId string, document []byte, opts []byte, output ...OutputOptions) (OperationResult, []byte, error) {
 	const semLogContext = "json-ops::insert-one"
 	var err error
 
+	if err := checkPolicy(lks, collectionId, &InsertOneOperation{Document: document, Options: opts}); err != nil {
+		return OperationResultFromError(err), nil, err
+	}
+
 	c := lks.GetCollection(collectionId, "")
 	if c == nil {
 		err = errors.New("cannot find requested collection")
 		log.Error().Err(err).Str("collection", collectionId).Msg(semLogContext)
-		return OperationResult{StatusCode: http.StatusInternalServerError}, nil, err
+		return OperationResultFromError(err), nil, err
 	}
 
 	opDocument, err := util.UnmarshalJson2BsonD(document, true)
 	if err != nil {
 		log.Error().Err(err).Msg(semLogContext)
-		return OperationResult{StatusCode: http.StatusInternalServerError}, nil, err
+		return OperationResultFromStatementError(err), nil, err
 	}
 
 	uo, err := mdboptions.InsertOneOptionsFromJson(opts)
 	if err != nil {
 		log.Error().Err(err).Msg(semLogContext)
-		return OperationResult{StatusCode: http.StatusInternalServerError}, nil, err
+		return OperationResultFromStatementError(err), nil, err
+	}
+
+	ctx, cancel, err := newOperationContext(ctx, lks, collectionId, InsertOneOperationType, opts)
+	if err != nil {
+		log.Error().Err(err).Msg(semLogContext)
+		return OperationResultFromStatementError(err), nil, err
 	}
+	defer cancel()
 
-	res, err := c.InsertOne(context.Background(), opDocument, uo)
+	res, err := c.InsertOne(ctx, opDocument, uo)
 	if err != nil {
-		mongoErrorCode := util.MongoErrorCode(err, util.MongoDbVersion{})
-		log.Error().Err(err).Int32("mongo-error", mongoErrorCode).Msg(semLogContext)
-		return OperationResult{StatusCode: int(-mongoErrorCode)}, nil, err
+		res := OperationResultFromError(err)
+		log.Error().Err(err).Int("status-code", res.StatusCode).Msg(semLogContext)
+		return res, nil, err
 	}
 
 	var b []byte
-	b, err = json.Marshal(res)
+	b, err = outputOptionsOf(output).MarshalWriteResult(res)
 	if err != nil {
 		log.Error().Err(err).Msg(semLogContext)
-		return OperationResult{StatusCode: http.StatusInternalServerError}, nil, err
+		return OperationResultFromError(err), nil, err
 	}
 
 	return OperationResultFromInsertOneResult(res), b, nil
@@ -148,14 +189,22 @@ func (op *InsertOneOperation) NewWriteModel() (mongo.WriteModel, error) {
 		return nil, err
 	}
 
-	uo := options.InsertOneOptions{}
-	if len(op.Options) > 0 {
-		err = json.Unmarshal(op.Options, &uo)
-		if err != nil {
-			log.Error().Err(err).Msg(semLogContext)
-			return nil, err
-		}
+	_, err = mdboptions.ParseJsonOptions(op.Options, mdboptions.InsertOneOptionNames)
+	if err != nil {
+		log.Error().Err(err).Msg(semLogContext)
+		return nil, err
 	}
 
 	return mongo.NewInsertOneModel().SetDocument(statementDocument), nil
 }
+
+// explainCommand validates the statement only: inserts have no query plan.
+func (op *InsertOneOperation) explainCommand(collectionName string) (bson.D, []byte, error) {
+	_, err := util.UnmarshalJson2BsonD(op.Document, true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	_, err = mdboptions.ParseJsonOptions(op.Options, mdboptions.InsertOneOptionNames)
+	return nil, op.Options, err
+}
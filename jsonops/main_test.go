@@ -9,10 +9,12 @@ import (
 )
 
 const (
-	CollectionId   = "tpm-mongo-common"
-	CollectionName = "tpm_mongo_common"
-	Host           = "mongodb://localhost:27017"
-	DbName         = "tpm_morphia"
+	CollectionId        = "tpm-mongo-common"
+	CollectionName      = "tpm_mongo_common"
+	WatchCollectionId   = "tpm-mongo-common-watch"
+	WatchCollectionName = "tpm_mongo_common_watch"
+	Host                = "mongodb://localhost:27017"
+	DbName              = "tpm_morphia"
 )
 
 var cfg = mongolks.Config{
@@ -39,6 +41,10 @@ var cfg = mongolks.Config{
 			Id:   CollectionId,
 			Name: CollectionName,
 		},
+		{
+			Id:   WatchCollectionId,
+			Name: WatchCollectionName,
+		},
 	},
 	SecurityProtocol: "PLAIN",
 	TLS:              mongolks.TLSConfig{SkipVerify: true},
@@ -0,0 +1,192 @@
+package jsonops
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/mongolks"
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+const (
+	MongoActivityMergePatchOneOpProperty      MongoJsonOperationStatementPart = "$op"
+	MongoActivityMergePatchOneFilterProperty  MongoJsonOperationStatementPart = "$filter"
+	MongoActivityMergePatchOnePatchProperty   MongoJsonOperationStatementPart = "$patch"
+	MongoActivityMergePatchOneVersionProperty MongoJsonOperationStatementPart = "$version"
+	MongoActivityMergePatchOneOptsProperty    MongoJsonOperationStatementPart = "$opts"
+	MongoActivityMergePatchOneOutputProperty  MongoJsonOperationStatementPart = "$output"
+)
+
+// MergePatchOneOperation applies a RFC 7396 json merge patch to the document selected by the filter. The optional version is the same
+// of PatchOneOperation.
+type MergePatchOneOperation struct {
+	Filter  []byte `yaml:"filter,omitempty" json:"filter,omitempty" mapstructure:"filter,omitempty"`
+	Patch   []byte `yaml:"patch,omitempty" json:"patch,omitempty" mapstructure:"patch,omitempty"`
+	Version []byte `yaml:"version,omitempty" json:"version,omitempty" mapstructure:"version,omitempty"`
+	Options []byte `yaml:"options,omitempty" json:"options,omitempty" mapstructure:"options,omitempty"`
+	Output  []byte `yaml:"output,omitempty" json:"output,omitempty" mapstructure:"output,omitempty"`
+}
+
+func (op *MergePatchOneOperation) OpType() MongoJsonOperationType {
+	return MergePatchOneOperationType
+}
+
+func (op *MergePatchOneOperation) ToString() string {
+	var sb strings.Builder
+	numberOfElements := 0
+	sb.WriteString("{")
+	if len(op.Filter) > 0 {
+		numberOfElements++
+		sb.WriteString(fmt.Sprintf("\"%s\": ", MongoActivityMergePatchOneFilterProperty))
+		sb.WriteString(string(op.Filter))
+	}
+	if len(op.Patch) > 0 {
+		if numberOfElements > 0 {
+			sb.WriteString(",")
+		}
+		numberOfElements++
+		sb.WriteString(fmt.Sprintf("\"%s\": ", MongoActivityMergePatchOnePatchProperty))
+		sb.WriteString(string(op.Patch))
+	}
+	if len(op.Version) > 0 {
+		if numberOfElements > 0 {
+			sb.WriteString(",")
+		}
+		numberOfElements++
+		sb.WriteString(fmt.Sprintf("\"%s\": ", MongoActivityMergePatchOneVersionProperty))
+		sb.WriteString(string(op.Version))
+	}
+	if len(op.Options) > 0 {
+		if numberOfElements > 0 {
+			sb.WriteString(",")
+		}
+		numberOfElements++
+		sb.WriteString(fmt.Sprintf("\"%s\": ", MongoActivityMergePatchOneOptsProperty))
+		sb.WriteString(string(op.Options))
+	}
+	if len(op.Output) > 0 {
+		if numberOfElements > 0 {
+			sb.WriteString(",")
+		}
+		numberOfElements++
+		sb.WriteString(fmt.Sprintf("\"%s\": ", MongoActivityMergePatchOneOutputProperty))
+		sb.WriteString(string(op.Output))
+	}
+
+	sb.WriteString("}")
+	return sb.String()
+}
+
+func NewMergePatchOneOperation(m map[MongoJsonOperationStatementPart][]byte) (*MergePatchOneOperation, error) {
+	foStmt, err := NewMergePatchOneStatementConfigFromJson(m[MongoActivityMergePatchOneOpProperty])
+	if err != nil {
+		return nil, err
+	}
+
+	if data, ok := m[MongoActivityMergePatchOneFilterProperty]; ok {
+		foStmt.Filter = data
+	}
+
+	if data, ok := m[MongoActivityMergePatchOnePatchProperty]; ok {
+		foStmt.Patch = data
+	}
+
+	if data, ok := m[MongoActivityMergePatchOneVersionProperty]; ok {
+		foStmt.Version = data
+	}
+
+	if data, ok := m[MongoActivityMergePatchOneOptsProperty]; ok {
+		foStmt.Options = data
+	}
+
+	if data, ok := m[MongoActivityMergePatchOneOutputProperty]; ok {
+		foStmt.Output = data
+	}
+
+	return &foStmt, nil
+}
+
+func NewMergePatchOneStatementConfigFromJson(data []byte) (MergePatchOneOperation, error) {
+
+	if len(data) == 0 {
+		return MergePatchOneOperation{}, nil
+	}
+
+	var m map[MongoJsonOperationStatementPart]json.RawMessage
+	err := json.Unmarshal(data, &m)
+	if err != nil {
+		return MergePatchOneOperation{}, err
+	}
+
+	fo := MergePatchOneOperation{
+		Filter:  m[MongoActivityMergePatchOneFilterProperty],
+		Patch:   m[MongoActivityMergePatchOnePatchProperty],
+		Version: m[MongoActivityMergePatchOneVersionProperty],
+		Options: m[MongoActivityMergePatchOneOptsProperty],
+		Output:  m[MongoActivityMergePatchOneOutputProperty],
+	}
+
+	return fo, nil
+}
+
+func (op *MergePatchOneOperation) Execute(lks *mongolks.LinkedService, collectionId string) (OperationResult, []byte, error) {
+	return op.ExecuteContext(context.Background(), lks, collectionId)
+}
+
+func (op *MergePatchOneOperation) ExecuteContext(ctx context.Context, lks *mongolks.LinkedService, collectionId string) (res OperationResult, resp []byte, err error) {
+	ctx, endSpan := startOperationSpan(ctx, lks, op.OpType(), collectionId)
+	defer func() { endSpan(res, resp, err) }()
+
+	oo, err := NewOutputOptionsFromJson(op.Output)
+	if err != nil {
+		return OperationResultFromStatementError(err), nil, err
+	}
+
+	sc, resp, err := MergePatchOneContext(ctx, lks, collectionId, op.Filter, op.Patch, op.Version, op.Options, oo)
+	return sc, resp, err
+}
+
+func MergePatchOne(lks *mongolks.LinkedService, collectionId string, filter []byte, patch []byte, version []byte, opts []byte, output ...OutputOptions) (OperationResult, []byte, error) {
+	return MergePatchOneContext(context.Background(), lks, collectionId, filter, patch, version, opts, output...)
+}
+
+func MergePatchOneContext(ctx context.Context, lks *mongolks.LinkedService, collectionId string, filter []byte, patch []byte, version []byte, opts []byte, output ...OutputOptions) (OperationResult, []byte, error) {
+	const semLogContext = "json-ops::merge-patch-one"
+
+	if err := checkPolicy(lks, collectionId, &MergePatchOneOperation{Filter: filter, Patch: patch, Version: version, Options: opts}); err != nil {
+		return OperationResultFromError(err), nil, err
+	}
+
+	pu, err := MergePatch2Update(patch)
+	if err != nil {
+		log.Error().Err(err).Msg(semLogContext)
+		return OperationResultFromStatementError(err), nil, err
+	}
+
+	return executePatchOne(ctx, lks, collectionId, MergePatchOneOperationType, filter, pu, version, opts, output...)
+}
+
+func (op *MergePatchOneOperation) NewWriteModel() (mongo.WriteModel, error) {
+	const semLogContext = "json-ops::new-merge-patch-one-model"
+
+	pu, err := MergePatch2Update(op.Patch)
+	if err != nil {
+		log.Error().Err(err).Msg(semLogContext)
+		return nil, err
+	}
+
+	return newPatchWriteModel(op.Filter, pu, op.Version, op.Options)
+}
+
+func (op *MergePatchOneOperation) explainCommand(collectionName string) (bson.D, []byte, error) {
+	pu, err := MergePatch2Update(op.Patch)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return explainPatchCommand(collectionName, op.Filter, pu, op.Version, op.Options)
+}
@@ -1,11 +1,14 @@
 package jsonops
 
 import (
+	"context"
 	"errors"
 	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/mongolks"
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/util/mdboptions"
 	"github.com/rs/zerolog/log"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 	"net/http"
+	"time"
 )
 
 type MongoJsonOperationType string
@@ -22,12 +25,17 @@ const (
 	UpdateManyOperationType       MongoJsonOperationType = "update-many"
 	DeleteManyOperationType       MongoJsonOperationType = "delete-many"
 	FindManyOperationType         MongoJsonOperationType = "find"
+	PatchOneOperationType         MongoJsonOperationType = "patch-one"
+	MergePatchOneOperationType    MongoJsonOperationType = "merge-patch-one"
+	SyncManyOperationType         MongoJsonOperationType = "sync-many"
+	WatchOperationType            MongoJsonOperationType = "watch"
 )
 
 type Operation interface {
 	OpType() MongoJsonOperationType
 	ToString() string
 	Execute(lks *mongolks.LinkedService, collectionId string) (OperationResult, []byte, error)
+	ExecuteContext(ctx context.Context, lks *mongolks.LinkedService, collectionId string) (OperationResult, []byte, error)
 	NewWriteModel() (mongo.WriteModel, error)
 }
 
@@ -57,6 +65,14 @@ func NewOperation(opType MongoJsonOperationType, m map[MongoJsonOperationStateme
 		op, err = NewUpdateManyOperation(m)
 	case DeleteManyOperationType:
 		op, err = NewDeleteManyOperation(m)
+	case PatchOneOperationType:
+		op, err = NewPatchOneOperation(m)
+	case MergePatchOneOperationType:
+		op, err = NewMergePatchOneOperation(m)
+	case SyncManyOperationType:
+		op, err = NewSyncManyOperation(m)
+	case WatchOperationType:
+		op, err = NewWatchOperation(m)
 	default:
 		err = errors.New("invalid op-type " + string(opType))
 	}
@@ -75,6 +91,7 @@ type OperationResult struct {
 	UpsertedCount int64 // The number of documents upserted by the operation.
 	DeletedCount  int64
 	ObjectID      interface{} // The _id field of the upserted document, or nil if no upsert was done.
+	Problem       *Problem    // The RFC 7807 description of the error, if any.
 }
 
 func OperationResultFromUpdateResult(ur *mongo.UpdateResult) OperationResult {
@@ -100,3 +117,34 @@ func OperationResultFromDeleteResult(ur *mongo.DeleteResult) OperationResult {
 		DeletedCount: ur.DeletedCount,
 	}
 }
+
+// newOperationContext bounds the operation with the maxTimeMS option, if present in the opts json, or with the default timeout configured
+// in the linked service for the op-type. The max-time-ms of the policy of the collection caps both, so that omitting the option does not
+// lift the bound. A deadline of the caller's context shorter than these is kept. The driver derives the server side maxTimeMS from the
+// resulting deadline.
+func newOperationContext(ctx context.Context, lks *mongolks.LinkedService, collectionId string, opType MongoJsonOperationType, opts []byte) (context.Context, context.CancelFunc, error) {
+	maxTime, err := mdboptions.MaxTimeFromJson(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if maxTime == 0 && lks != nil {
+		maxTime = lks.OperationTimeoutOf(string(opType))
+	}
+
+	if lks != nil {
+		if p, ok := PolicyFor(lks.Name(), collectionId); ok && p.MaxTimeMS > 0 {
+			if policyMaxTime := time.Duration(p.MaxTimeMS) * time.Millisecond; maxTime == 0 || maxTime > policyMaxTime {
+				maxTime = policyMaxTime
+			}
+		}
+	}
+
+	if maxTime > 0 {
+		opCtx, cancel := context.WithTimeout(ctx, maxTime)
+		return opCtx, cancel, nil
+	}
+
+	opCtx, cancel := context.WithCancel(ctx)
+	return opCtx, cancel, nil
+}
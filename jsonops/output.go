@@ -0,0 +1,203 @@
+package jsonops
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+type OutputFormat string
+
+const (
+	// OutputFormatDefault keeps the historical rendering: documents decoded as bson.M and marshalled with encoding/json.
+	OutputFormatDefault   OutputFormat = ""
+	OutputFormatRelaxed   OutputFormat = "relaxed"
+	OutputFormatCanonical OutputFormat = "canonical"
+	OutputFormatPlain     OutputFormat = "plain"
+
+	OutputObjectIdAsHex      = "hex"
+	OutputDateAsIso          = "iso"
+	OutputDateAsMillis       = "millis"
+	OutputInt64AsNumber      = "number"
+	OutputInt64AsString      = "string"
+	OutputDecimal128AsString = "string"
+	OutputDecimal128AsNumber = "number"
+	OutputAsExtended         = "extended"
+)
+
+// OutputOptions drive how documents and results are rendered in the response body. The ObjectId, Date, Int64 and Decimal128 conversions
+// apply to the plain format only: the extended json formats are lossless by definition.
+type OutputOptions struct {
+	Format     OutputFormat `yaml:"format,omitempty" json:"format,omitempty" mapstructure:"format,omitempty"`
+	ObjectId   string       `yaml:"objectId,omitempty" json:"objectId,omitempty" mapstructure:"objectId,omitempty"`
+	Date       string       `yaml:"date,omitempty" json:"date,omitempty" mapstructure:"date,omitempty"`
+	Int64      string       `yaml:"int64,omitempty" json:"int64,omitempty" mapstructure:"int64,omitempty"`
+	Decimal128 string       `yaml:"decimal128,omitempty" json:"decimal128,omitempty" mapstructure:"decimal128,omitempty"`
+}
+
+func NewOutputOptionsFromJson(data []byte) (OutputOptions, error) {
+	const semLogContext = "json-ops::new-output-options"
+
+	oo := OutputOptions{}
+	if len(data) == 0 {
+		return oo, nil
+	}
+
+	err := json.Unmarshal(data, &oo)
+	if err != nil {
+		log.Error().Err(err).Msg(semLogContext)
+		return oo, err
+	}
+
+	err = oo.validate()
+	if err != nil {
+		log.Error().Err(err).Msg(semLogContext)
+		return oo, err
+	}
+
+	return oo, nil
+}
+
+func (oo OutputOptions) validate() error {
+	switch oo.Format {
+	case OutputFormatDefault, OutputFormatRelaxed, OutputFormatCanonical, OutputFormatPlain:
+	default:
+		return fmt.Errorf("unrecognized output format %s", oo.Format)
+	}
+
+	switch oo.ObjectId {
+	case "", OutputObjectIdAsHex, OutputAsExtended:
+	default:
+		return fmt.Errorf("unrecognized objectId output conversion %s", oo.ObjectId)
+	}
+
+	switch oo.Date {
+	case "", OutputDateAsIso, OutputDateAsMillis, OutputAsExtended:
+	default:
+		return fmt.Errorf("unrecognized date output conversion %s", oo.Date)
+	}
+
+	switch oo.Int64 {
+	case "", OutputInt64AsNumber, OutputInt64AsString, OutputAsExtended:
+	default:
+		return fmt.Errorf("unrecognized int64 output conversion %s", oo.Int64)
+	}
+
+	switch oo.Decimal128 {
+	case "", OutputDecimal128AsString, OutputDecimal128AsNumber, OutputAsExtended:
+	default:
+		return fmt.Errorf("unrecognized decimal128 output conversion %s", oo.Decimal128)
+	}
+
+	return nil
+}
+
+func outputOptionsOf(output []OutputOptions) OutputOptions {
+	if len(output) > 0 {
+		return output[0]
+	}
+
+	return OutputOptions{}
+}
+
+func (oo OutputOptions) MarshalDocument(raw bson.Raw) ([]byte, error) {
+	switch oo.Format {
+	case OutputFormatRelaxed, OutputFormatCanonical:
+		return bson.MarshalExtJSON(raw, oo.Format == OutputFormatCanonical, false)
+	case OutputFormatPlain:
+		var d bson.D
+		err := bson.Unmarshal(raw, &d)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(oo.plainValue(d))
+	default:
+		var m bson.M
+		err := bson.Unmarshal(raw, &m)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(m)
+	}
+}
+
+// MarshalWriteResult renders the result of a write operation. The default format keeps the encoding/json rendering of the driver structs, the
+// other formats use the same field names but carry the type information of the inserted or upserted ids.
+func (oo OutputOptions) MarshalWriteResult(res interface{}) ([]byte, error) {
+	if oo.Format == OutputFormatDefault {
+		return json.Marshal(res)
+	}
+
+	var d bson.D
+	switch r := res.(type) {
+	case *mongo.InsertOneResult:
+		d = bson.D{{Key: "InsertedID", Value: r.InsertedID}, {Key: "Acknowledged", Value: r.Acknowledged}}
+	case *mongo.UpdateResult:
+		d = bson.D{{Key: "MatchedCount", Value: r.MatchedCount}, {Key: "ModifiedCount", Value: r.ModifiedCount}, {Key: "UpsertedCount", Value: r.UpsertedCount}, {Key: "UpsertedID", Value: r.UpsertedID}, {Key: "Acknowledged", Value: r.Acknowledged}}
+	case *mongo.DeleteResult:
+		d = bson.D{{Key: "DeletedCount", Value: r.DeletedCount}, {Key: "Acknowledged", Value: r.Acknowledged}}
+	default:
+		return json.Marshal(res)
+	}
+
+	if oo.Format == OutputFormatPlain {
+		return json.Marshal(oo.plainValue(d))
+	}
+
+	return bson.MarshalExtJSON(d, oo.Format == OutputFormatCanonical, false)
+}
+
+func (oo OutputOptions) plainValue(v interface{}) interface{} {
+	switch tv := v.(type) {
+	case bson.D:
+		d := make(bson.D, 0, len(tv))
+		for _, e := range tv {
+			d = append(d, bson.E{Key: e.Key, Value: oo.plainValue(e.Value)})
+		}
+		return d
+	case bson.A:
+		a := make(bson.A, 0, len(tv))
+		for _, e := range tv {
+			a = append(a, oo.plainValue(e))
+		}
+		return a
+	case bson.ObjectID:
+		if oo.ObjectId == OutputAsExtended {
+			return bson.D{{Key: "$oid", Value: tv.Hex()}}
+		}
+		return tv.Hex()
+	case bson.DateTime:
+		switch oo.Date {
+		case OutputDateAsMillis:
+			return int64(tv)
+		case OutputAsExtended:
+			return bson.D{{Key: "$date", Value: tv.Time().UTC().Format(time.RFC3339Nano)}}
+		}
+		return tv.Time().UTC().Format(time.RFC3339Nano)
+	case int64:
+		switch oo.Int64 {
+		case OutputInt64AsString:
+			return strconv.FormatInt(tv, 10)
+		case OutputAsExtended:
+			return bson.D{{Key: "$numberLong", Value: strconv.FormatInt(tv, 10)}}
+		}
+		return tv
+	case bson.Decimal128:
+		switch oo.Decimal128 {
+		case OutputDecimal128AsNumber:
+			if f, err := strconv.ParseFloat(tv.String(), 64); err == nil {
+				return f
+			}
+		case OutputAsExtended:
+			return bson.D{{Key: "$numberDecimal", Value: tv.String()}}
+		}
+		return tv.String()
+	}
+
+	return v
+}
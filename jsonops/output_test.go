@@ -0,0 +1,51 @@
+package jsonops_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/jsonops"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+func TestOutputOptions(t *testing.T) {
+	oid, err := bson.ObjectIDFromHex("5a934e000102030405000000")
+	require.NoError(t, err)
+	dec, err := bson.ParseDecimal128("1.5")
+	require.NoError(t, err)
+
+	raw, err := bson.Marshal(bson.D{
+		{Key: "_id", Value: oid},
+		{Key: "date", Value: bson.NewDateTimeFromTime(time.Date(2019, 8, 11, 17, 54, 14, 692000000, time.UTC))},
+		{Key: "amount", Value: dec},
+		{Key: "counter", Value: int64(64)},
+	})
+	require.NoError(t, err)
+
+	oo, err := jsonops.NewOutputOptionsFromJson([]byte(`{ "format": "canonical" }`))
+	require.NoError(t, err)
+	b, err := oo.MarshalDocument(raw)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"_id":{"$oid":"5a934e000102030405000000"},"date":{"$date":{"$numberLong":"1565546054692"}},"amount":{"$numberDecimal":"1.5"},"counter":{"$numberLong":"64"}}`, string(b))
+
+	oo, err = jsonops.NewOutputOptionsFromJson([]byte(`{ "format": "relaxed" }`))
+	require.NoError(t, err)
+	b, err = oo.MarshalDocument(raw)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"_id":{"$oid":"5a934e000102030405000000"},"date":{"$date":"2019-08-11T17:54:14.692Z"},"amount":{"$numberDecimal":"1.5"},"counter":64}`, string(b))
+
+	oo, err = jsonops.NewOutputOptionsFromJson([]byte(`{ "format": "plain", "date": "millis", "int64": "string", "decimal128": "number" }`))
+	require.NoError(t, err)
+	b, err = oo.MarshalDocument(raw)
+	require.NoError(t, err)
+	require.Equal(t, `{"_id":"5a934e000102030405000000","date":1565546054692,"amount":1.5,"counter":"64"}`, string(b))
+
+	b, err = oo.MarshalWriteResult(&mongo.InsertOneResult{InsertedID: oid, Acknowledged: true})
+	require.NoError(t, err)
+	require.Equal(t, `{"InsertedID":"5a934e000102030405000000","Acknowledged":true}`, string(b))
+
+	_, err = jsonops.NewOutputOptionsFromJson([]byte(`{ "format": "xml" }`))
+	require.Error(t, err)
+}
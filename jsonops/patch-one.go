@@ -0,0 +1,337 @@
+package jsonops
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/mongolks"
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/util"
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/util/mdboptions"
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+const (
+	MongoActivityPatchOneOpProperty      MongoJsonOperationStatementPart = "$op"
+	MongoActivityPatchOneFilterProperty  MongoJsonOperationStatementPart = "$filter"
+	MongoActivityPatchOnePatchProperty   MongoJsonOperationStatementPart = "$patch"
+	MongoActivityPatchOneVersionProperty MongoJsonOperationStatementPart = "$version"
+	MongoActivityPatchOneOptsProperty    MongoJsonOperationStatementPart = "$opts"
+	MongoActivityPatchOneOutputProperty  MongoJsonOperationStatementPart = "$output"
+)
+
+// PatchOneOperation applies a RFC 6902 json patch to the document selected by the filter. The optional version is in the form
+// { "field": "version", "expected": 3 }.
+type PatchOneOperation struct {
+	Filter  []byte `yaml:"filter,omitempty" json:"filter,omitempty" mapstructure:"filter,omitempty"`
+	Patch   []byte `yaml:"patch,omitempty" json:"patch,omitempty" mapstructure:"patch,omitempty"`
+	Version []byte `yaml:"version,omitempty" json:"version,omitempty" mapstructure:"version,omitempty"`
+	Options []byte `yaml:"options,omitempty" json:"options,omitempty" mapstructure:"options,omitempty"`
+	Output  []byte `yaml:"output,omitempty" json:"output,omitempty" mapstructure:"output,omitempty"`
+}
+
+func (op *PatchOneOperation) OpType() MongoJsonOperationType {
+	return PatchOneOperationType
+}
+
+func (op *PatchOneOperation) ToString() string {
+	var sb strings.Builder
+	numberOfElements := 0
+	sb.WriteString("{")
+	if len(op.Filter) > 0 {
+		numberOfElements++
+		sb.WriteString(fmt.Sprintf("\"%s\": ", MongoActivityPatchOneFilterProperty))
+		sb.WriteString(string(op.Filter))
+	}
+	if len(op.Patch) > 0 {
+		if numberOfElements > 0 {
+			sb.WriteString(",")
+		}
+		numberOfElements++
+		sb.WriteString(fmt.Sprintf("\"%s\": ", MongoActivityPatchOnePatchProperty))
+		sb.WriteString(string(op.Patch))
+	}
+	if len(op.Version) > 0 {
+		if numberOfElements > 0 {
+			sb.WriteString(",")
+		}
+		numberOfElements++
+		sb.WriteString(fmt.Sprintf("\"%s\": ", MongoActivityPatchOneVersionProperty))
+		sb.WriteString(string(op.Version))
+	}
+	if len(op.Options) > 0 {
+		if numberOfElements > 0 {
+			sb.WriteString(",")
+		}
+		numberOfElements++
+		sb.WriteString(fmt.Sprintf("\"%s\": ", MongoActivityPatchOneOptsProperty))
+		sb.WriteString(string(op.Options))
+	}
+	if len(op.Output) > 0 {
+		if numberOfElements > 0 {
+			sb.WriteString(",")
+		}
+		numberOfElements++
+		sb.WriteString(fmt.Sprintf("\"%s\": ", MongoActivityPatchOneOutputProperty))
+		sb.WriteString(string(op.Output))
+	}
+
+	sb.WriteString("}")
+	return sb.String()
+}
+
+func NewPatchOneOperation(m map[MongoJsonOperationStatementPart][]byte) (*PatchOneOperation, error) {
+	foStmt, err := NewPatchOneStatementConfigFromJson(m[MongoActivityPatchOneOpProperty])
+	if err != nil {
+		return nil, err
+	}
+
+	if data, ok := m[MongoActivityPatchOneFilterProperty]; ok {
+		foStmt.Filter = data
+	}
+
+	if data, ok := m[MongoActivityPatchOnePatchProperty]; ok {
+		foStmt.Patch = data
+	}
+
+	if data, ok := m[MongoActivityPatchOneVersionProperty]; ok {
+		foStmt.Version = data
+	}
+
+	if data, ok := m[MongoActivityPatchOneOptsProperty]; ok {
+		foStmt.Options = data
+	}
+
+	if data, ok := m[MongoActivityPatchOneOutputProperty]; ok {
+		foStmt.Output = data
+	}
+
+	return &foStmt, nil
+}
+
+func NewPatchOneStatementConfigFromJson(data []byte) (PatchOneOperation, error) {
+
+	if len(data) == 0 {
+		return PatchOneOperation{}, nil
+	}
+
+	var m map[MongoJsonOperationStatementPart]json.RawMessage
+	err := json.Unmarshal(data, &m)
+	if err != nil {
+		return PatchOneOperation{}, err
+	}
+
+	fo := PatchOneOperation{
+		Filter:  m[MongoActivityPatchOneFilterProperty],
+		Patch:   m[MongoActivityPatchOnePatchProperty],
+		Version: m[MongoActivityPatchOneVersionProperty],
+		Options: m[MongoActivityPatchOneOptsProperty],
+		Output:  m[MongoActivityPatchOneOutputProperty],
+	}
+
+	return fo, nil
+}
+
+func (op *PatchOneOperation) Execute(lks *mongolks.LinkedService, collectionId string) (OperationResult, []byte, error) {
+	return op.ExecuteContext(context.Background(), lks, collectionId)
+}
+
+func (op *PatchOneOperation) ExecuteContext(ctx context.Context, lks *mongolks.LinkedService, collectionId string) (res OperationResult, resp []byte, err error) {
+	ctx, endSpan := startOperationSpan(ctx, lks, op.OpType(), collectionId)
+	defer func() { endSpan(res, resp, err) }()
+
+	oo, err := NewOutputOptionsFromJson(op.Output)
+	if err != nil {
+		return OperationResultFromStatementError(err), nil, err
+	}
+
+	sc, resp, err := PatchOneContext(ctx, lks, collectionId, op.Filter, op.Patch, op.Version, op.Options, oo)
+	return sc, resp, err
+}
+
+func PatchOne(lks *mongolks.LinkedService, collectionId string, filter []byte, patch []byte, version []byte, opts []byte, output ...OutputOptions) (OperationResult, []byte, error) {
+	return PatchOneContext(context.Background(), lks, collectionId, filter, patch, version, opts, output...)
+}
+
+func PatchOneContext(ctx context.Context, lks *mongolks.LinkedService, collectionId string, filter []byte, patch []byte, version []byte, opts []byte, output ...OutputOptions) (OperationResult, []byte, error) {
+	const semLogContext = "json-ops::patch-one"
+
+	if err := checkPolicy(lks, collectionId, &PatchOneOperation{Filter: filter, Patch: patch, Version: version, Options: opts}); err != nil {
+		return OperationResultFromError(err), nil, err
+	}
+
+	pu, err := JsonPatch2Update(patch)
+	if err != nil {
+		log.Error().Err(err).Msg(semLogContext)
+		return OperationResultFromStatementError(err), nil, err
+	}
+
+	return executePatchOne(ctx, lks, collectionId, PatchOneOperationType, filter, pu, version, opts, output...)
+}
+
+// executePatchOne runs the translated patch. When nothing matches but the document selected by the filter exists, the preconditions
+// of the patch failed and the outcome is an ErrPatchConflict.
+func executePatchOne(ctx context.Context, lks *mongolks.LinkedService, collectionId string, opType MongoJsonOperationType, filter []byte, pu PatchUpdate, version []byte, opts []byte, output ...OutputOptions) (OperationResult, []byte, error) {
+	const semLogContext = "json-ops::execute-patch-one"
+	var err error
+
+	c := lks.GetCollection(collectionId, "")
+	if c == nil {
+		err = errors.New("cannot find requested collection")
+		log.Error().Err(err).Str("collection", collectionId).Msg(semLogContext)
+		return OperationResultFromError(err), nil, err
+	}
+
+	statementFilter, update, err := patchStatement(filter, pu, version)
+	if err != nil {
+		log.Error().Err(err).Msg(semLogContext)
+		return OperationResultFromStatementError(err), nil, err
+	}
+
+	uo, upsert, err := mdboptions.UpdateOneOptionsFromJson(opts)
+	if err != nil {
+		log.Error().Err(err).Msg(semLogContext)
+		return OperationResultFromStatementError(err), nil, err
+	}
+
+	if upsert && len(update.Filter) > 0 {
+		err = errors.New("upsert cannot be used with patch preconditions")
+		log.Error().Err(err).Msg(semLogContext)
+		return OperationResultFromStatementError(err), nil, err
+	}
+
+	ctx, cancel, err := newOperationContext(ctx, lks, collectionId, opType, opts)
+	if err != nil {
+		log.Error().Err(err).Msg(semLogContext)
+		return OperationResultFromStatementError(err), nil, err
+	}
+	defer cancel()
+
+	res, err := c.UpdateOne(ctx, update.ApplyTo(statementFilter), update.Update, uo)
+	if err != nil {
+		res := OperationResultFromError(err)
+		log.Error().Err(err).Int("status-code", res.StatusCode).Msg(semLogContext)
+		return res, nil, err
+	}
+
+	if res.MatchedCount == 0 && res.UpsertedCount == 0 && len(update.Filter) > 0 {
+		n, err := c.CountDocuments(ctx, statementFilter, options.Count().SetLimit(1))
+		if err != nil {
+			res := OperationResultFromError(err)
+			log.Error().Err(err).Int("status-code", res.StatusCode).Msg(semLogContext)
+			return res, nil, err
+		}
+
+		if n > 0 {
+			err = fmt.Errorf("%w: preconditions of the patch do not hold", ErrPatchConflict)
+			log.Error().Err(err).Msg(semLogContext)
+			return OperationResultFromError(err), nil, err
+		}
+	}
+
+	var b []byte
+	b, err = outputOptionsOf(output).MarshalWriteResult(res)
+	if err != nil {
+		log.Error().Err(err).Msg(semLogContext)
+		return OperationResultFromError(err), nil, err
+	}
+
+	return OperationResultFromUpdateResult(res), b, nil
+}
+
+// patchStatement parses the filter and applies the expected version, if any, to the translated patch.
+func patchStatement(filter []byte, pu PatchUpdate, version []byte) (bson.D, PatchUpdate, error) {
+	statementFilter, err := util.UnmarshalJson2BsonD(filter, true)
+	if err != nil {
+		return nil, pu, err
+	}
+
+	v, err := NewPatchVersionFromJson(version)
+	if err != nil {
+		return nil, pu, err
+	}
+
+	if v != nil {
+		expected, err := patchValue(v.Expected)
+		if err != nil {
+			return nil, pu, err
+		}
+
+		if err = pu.WithExpectedVersion(v.Field, expected); err != nil {
+			return nil, pu, err
+		}
+	}
+
+	return statementFilter, pu, nil
+}
+
+func (op *PatchOneOperation) NewWriteModel() (mongo.WriteModel, error) {
+	const semLogContext = "json-ops::new-patch-one-model"
+
+	pu, err := JsonPatch2Update(op.Patch)
+	if err != nil {
+		log.Error().Err(err).Msg(semLogContext)
+		return nil, err
+	}
+
+	return newPatchWriteModel(op.Filter, pu, op.Version, op.Options)
+}
+
+func newPatchWriteModel(filter []byte, pu PatchUpdate, version []byte, opts []byte) (mongo.WriteModel, error) {
+	const semLogContext = "json-ops::new-patch-model"
+
+	statementFilter, update, err := patchStatement(filter, pu, version)
+	if err != nil {
+		log.Error().Err(err).Msg(semLogContext)
+		return nil, err
+	}
+
+	jo, err := mdboptions.ParseJsonOptions(opts, mdboptions.UpdateOptionNames)
+	if err != nil {
+		log.Error().Err(err).Msg(semLogContext)
+		return nil, err
+	}
+
+	wm := mongo.NewUpdateOneModel().SetFilter(update.ApplyTo(statementFilter)).SetUpdate(update.Update).SetUpsert(jo.IsUpsert())
+	if jo.Hint != nil {
+		wm.SetHint(jo.Hint)
+	}
+	if jo.Collation != nil {
+		wm.SetCollation(jo.Collation)
+	}
+	if jo.ArrayFilters != nil {
+		wm.SetArrayFilters(jo.ArrayFilters)
+	}
+
+	return wm, nil
+}
+
+func (op *PatchOneOperation) explainCommand(collectionName string) (bson.D, []byte, error) {
+	pu, err := JsonPatch2Update(op.Patch)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return explainPatchCommand(collectionName, op.Filter, pu, op.Version, op.Options)
+}
+
+func explainPatchCommand(collectionName string, filter []byte, pu PatchUpdate, version []byte, opts []byte) (bson.D, []byte, error) {
+	statementFilter, update, err := patchStatement(filter, pu, version)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	jo, err := mdboptions.ParseJsonOptions(opts, mdboptions.UpdateOptionNames)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stmt := bson.D{{Key: "q", Value: update.ApplyTo(statementFilter)}, {Key: "u", Value: update.Update}, {Key: "multi", Value: false}, {Key: "upsert", Value: jo.IsUpsert()}}
+	cmd := bson.D{{Key: "update", Value: collectionName}, {Key: "updates", Value: bson.A{explainStatementOptions(stmt, jo)}}}
+	return explainCommandOptions(cmd, jo), opts, nil
+}
@@ -0,0 +1,599 @@
+package jsonops
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/util"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+const (
+	JsonPatchOpAdd     = "add"
+	JsonPatchOpRemove  = "remove"
+	JsonPatchOpReplace = "replace"
+	JsonPatchOpMove    = "move"
+	JsonPatchOpCopy    = "copy"
+	JsonPatchOpTest    = "test"
+
+	// patchTempField holds the value being moved or copied between the stages of a pipeline update.
+	patchTempField = "__jsonops_patch"
+)
+
+// ErrPatchConflict is returned when the document exists but the patch preconditions (test operations, existence of replaced paths,
+// expected version) do not hold.
+var ErrPatchConflict = errors.New("patch conflict")
+
+// PatchUpdate is the translation of a json patch or json merge patch. Filter holds the preconditions, one condition per element, to be
+// and-ed with the filter of the operation (see ApplyTo). Update is either a document of update operators or, when the patch cannot be
+// expressed with operators, a pipeline.
+type PatchUpdate struct {
+	Filter bson.D
+	Update interface{}
+
+	paths []string
+}
+
+// PatchVersion is the expected-version of an optimistic concurrency update: the document is patched only if Field has the Expected value
+// and, in that case, Field is incremented.
+type PatchVersion struct {
+	Field    string          `yaml:"field,omitempty" mapstructure:"field,omitempty" json:"field,omitempty"`
+	Expected json.RawMessage `yaml:"expected,omitempty" mapstructure:"expected,omitempty" json:"expected,omitempty"`
+}
+
+func NewPatchVersionFromJson(data []byte) (*PatchVersion, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	var v PatchVersion
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+
+	if v.Field == "" || len(v.Expected) == 0 {
+		return nil, errors.New("patch version requires field and expected value")
+	}
+
+	if err := checkPatchVersionField(v.Field); err != nil {
+		return nil, err
+	}
+
+	expected, err := patchValue(v.Expected)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = checkPatchVersionExpected(expected); err != nil {
+		return nil, err
+	}
+
+	return &v, nil
+}
+
+type jsonPatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+type patchActionKind int
+
+const (
+	patchActionSet patchActionKind = iota
+	patchActionUnset
+	patchActionPush
+	patchActionInsertAt
+	patchActionRemoveAt
+	patchActionReplaceAt
+)
+
+// patchAction is a single change to the document. For array actions path is the array and index the position of the element.
+// A nil value with a from path means the value is taken from the document itself.
+type patchAction struct {
+	kind  patchActionKind
+	path  []string
+	index int
+	value interface{}
+	from  []string
+	move  bool
+}
+
+// JsonPatch2Update translates a RFC 6902 json patch. The patch is converted to update operators when possible; remove of array elements,
+// move, copy and changes to overlapping paths require a pipeline update and, in that case, index segments are only allowed as the last
+// segment of a path. A numeric last segment of an add is an array insertion and the index cannot be past the end of the array.
+// The preconditions (test operations, existence of the removed, replaced and source paths, array bounds) are evaluated against the
+// document before the patch, so a precondition on a path changed by a previous operation of the same patch is rejected.
+func JsonPatch2Update(patch []byte) (PatchUpdate, error) {
+	var ops []jsonPatchOperation
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return PatchUpdate{}, err
+	}
+
+	if len(ops) == 0 {
+		return PatchUpdate{}, errors.New("json patch is empty")
+	}
+
+	var pu PatchUpdate
+	var actions []patchAction
+	var changed []string
+	precondition := func(i int, path []string, cond bson.E) error {
+		p := strings.Join(path, ".")
+		for _, c := range changed {
+			if patchPathsOverlap(p, c) {
+				return fmt.Errorf("json patch op #%d: precondition on %s changed by a previous op", i, p)
+			}
+		}
+
+		pu.Filter = append(pu.Filter, cond)
+		return nil
+	}
+
+	for i, op := range ops {
+		path, err := parseJsonPointer(op.Path)
+		if err != nil {
+			return PatchUpdate{}, fmt.Errorf("json patch op #%d: %w", i, err)
+		}
+
+		if path[len(path)-1] == "-" && op.Op != JsonPatchOpAdd && op.Op != JsonPatchOpMove && op.Op != JsonPatchOpCopy {
+			return PatchUpdate{}, fmt.Errorf("json patch op #%d: %s cannot target the end of an array", i, op.Op)
+		}
+
+		switch op.Op {
+		case JsonPatchOpAdd, JsonPatchOpReplace, JsonPatchOpTest:
+			v, err := patchValue(op.Value)
+			if err != nil {
+				return PatchUpdate{}, fmt.Errorf("json patch op #%d: %w", i, err)
+			}
+
+			switch op.Op {
+			case JsonPatchOpAdd:
+				a := newPatchAddAction(path, v)
+				if err = patchArrayBounds(a, func(path []string, cond bson.E) error { return precondition(i, path, cond) }); err != nil {
+					return PatchUpdate{}, err
+				}
+				actions = append(actions, a)
+			case JsonPatchOpReplace:
+				if err = precondition(i, path, patchExists(path)); err != nil {
+					return PatchUpdate{}, err
+				}
+				if ndx, ok := patchIndex(path[len(path)-1]); ok && len(path) > 1 {
+					actions = append(actions, patchAction{kind: patchActionReplaceAt, path: path[:len(path)-1], index: ndx, value: v})
+				} else {
+					actions = append(actions, patchAction{kind: patchActionSet, path: path, value: v})
+				}
+			case JsonPatchOpTest:
+				if err = checkPipelinePath(path); err != nil {
+					return PatchUpdate{}, fmt.Errorf("json patch op #%d: %w", i, err)
+				}
+
+				// the value is compared as an aggregation expression: no query operator in the value, no match of an array element.
+				// A missing field is not equal to null.
+				if v == nil {
+					if err = precondition(i, path, patchExists(path)); err != nil {
+						return PatchUpdate{}, err
+					}
+				}
+				if err = precondition(i, path, bson.E{Key: "$expr", Value: bson.D{{Key: "$eq", Value: bson.A{patchPipelineRef(path), bson.D{{Key: "$literal", Value: v}}}}}}); err != nil {
+					return PatchUpdate{}, err
+				}
+			}
+
+		case JsonPatchOpRemove:
+			if err = precondition(i, path, patchExists(path)); err != nil {
+				return PatchUpdate{}, err
+			}
+			actions = append(actions, newPatchRemoveAction(path))
+
+		case JsonPatchOpMove, JsonPatchOpCopy:
+			from, err := parseJsonPointer(op.From)
+			if err != nil {
+				return PatchUpdate{}, fmt.Errorf("json patch op #%d: %w", i, err)
+			}
+
+			if err = precondition(i, from, patchExists(from)); err != nil {
+				return PatchUpdate{}, err
+			}
+			a := newPatchAddAction(path, nil)
+			a.from = from
+			a.move = op.Op == JsonPatchOpMove
+			if err = patchArrayBounds(a, func(path []string, cond bson.E) error { return precondition(i, path, cond) }); err != nil {
+				return PatchUpdate{}, err
+			}
+			actions = append(actions, a)
+			if a.move {
+				changed = append(changed, strings.Join(from, "."))
+			}
+
+		default:
+			return PatchUpdate{}, fmt.Errorf("json patch op #%d: invalid op %s", i, op.Op)
+		}
+
+		if op.Op != JsonPatchOpTest {
+			changed = append(changed, strings.Join(actions[len(actions)-1].path, "."))
+		}
+	}
+
+	return pu.withActions(actions)
+}
+
+func patchExists(path []string) bson.E {
+	return bson.E{Key: strings.Join(path, "."), Value: bson.D{{Key: "$exists", Value: true}}}
+}
+
+// patchArrayBounds adds the preconditions of an insertion at an index: the array exists and has at least index elements.
+func patchArrayBounds(a patchAction, precondition func(path []string, cond bson.E) error) error {
+	if a.kind != patchActionInsertAt {
+		return nil
+	}
+
+	if err := precondition(a.path, bson.E{Key: strings.Join(a.path, "."), Value: bson.D{{Key: "$type", Value: "array"}}}); err != nil {
+		return err
+	}
+
+	if a.index > 0 {
+		return precondition(a.path, patchExists(append(append([]string{}, a.path...), strconv.Itoa(a.index-1))))
+	}
+
+	return nil
+}
+
+// MergePatch2Update translates a RFC 7396 json merge patch to $set and $unset operators: null removes a field, objects are merged
+// recursively and any other value, arrays included, replaces the field. Merging an object into a field holding a scalar is rejected
+// by the server and an empty object leaves the field untouched.
+func MergePatch2Update(patch []byte) (PatchUpdate, error) {
+	if trimmed := bytes.TrimSpace(patch); len(trimmed) == 0 || trimmed[0] != '{' {
+		return PatchUpdate{}, errors.New("json merge patch must be an object")
+	}
+
+	d, err := util.UnmarshalJson2BsonD(patch, false)
+	if err != nil {
+		return PatchUpdate{}, err
+	}
+
+	var actions []patchAction
+	if err = mergePatchActions(nil, d, &actions); err != nil {
+		return PatchUpdate{}, err
+	}
+
+	if len(actions) == 0 {
+		return PatchUpdate{}, errors.New("json merge patch is empty")
+	}
+
+	var pu PatchUpdate
+	return pu.withActions(actions)
+}
+
+func mergePatchActions(prefix []string, d bson.D, actions *[]patchAction) error {
+	for _, e := range d {
+		if err := checkPatchSegment(e.Key); err != nil {
+			return err
+		}
+
+		path := append(append([]string{}, prefix...), e.Key)
+		switch tv := e.Value.(type) {
+		case nil:
+			*actions = append(*actions, patchAction{kind: patchActionUnset, path: path})
+		case bson.D:
+			if err := mergePatchActions(path, tv, actions); err != nil {
+				return err
+			}
+		default:
+			*actions = append(*actions, patchAction{kind: patchActionSet, path: path, value: e.Value})
+		}
+	}
+
+	return nil
+}
+
+// WithExpectedVersion adds the version check to the filter and the increment of the version to the update.
+func (pu *PatchUpdate) WithExpectedVersion(field string, expected interface{}) error {
+	if err := checkPatchVersionField(field); err != nil {
+		return err
+	}
+
+	if err := checkPatchVersionExpected(expected); err != nil {
+		return err
+	}
+
+	for _, p := range pu.paths {
+		if patchPathsOverlap(p, field) {
+			return fmt.Errorf("patch cannot modify the version field %s", field)
+		}
+	}
+
+	pu.Filter = append(pu.Filter, bson.E{Key: field, Value: expected})
+	switch tu := pu.Update.(type) {
+	case bson.D:
+		pu.Update = append(tu, bson.E{Key: "$inc", Value: bson.D{{Key: field, Value: 1}}})
+	case bson.A:
+		pu.Update = append(tu, bson.D{{Key: "$set", Value: bson.D{{Key: field, Value: bson.D{{Key: "$add", Value: bson.A{bson.D{{Key: "$ifNull", Value: bson.A{"$" + field, 0}}}, 1}}}}}}})
+	}
+
+	pu.paths = append(pu.paths, field)
+	return nil
+}
+
+// ApplyTo returns the filter of the operation in and with the patch preconditions.
+func (pu PatchUpdate) ApplyTo(filter bson.D) bson.D {
+	if len(pu.Filter) == 0 {
+		return filter
+	}
+
+	and := bson.A{filter}
+	for _, e := range pu.Filter {
+		and = append(and, bson.D{e})
+	}
+
+	return bson.D{{Key: "$and", Value: and}}
+}
+
+func (pu PatchUpdate) withActions(actions []patchAction) (PatchUpdate, error) {
+	usePipeline := false
+	for _, a := range actions {
+		// update operators would store the keys starting with $ of a value as they are, the pipeline wraps the values in $literal.
+		if a.kind == patchActionRemoveAt || a.kind == patchActionReplaceAt || a.from != nil || patchHasOperatorKeys(a.value) {
+			usePipeline = true
+		}
+
+		p := strings.Join(a.path, ".")
+		for _, other := range pu.paths {
+			if patchPathsOverlap(p, other) {
+				usePipeline = true
+			}
+		}
+		pu.paths = append(pu.paths, p)
+	}
+
+	var err error
+	if usePipeline {
+		pu.Update, err = patchPipeline(actions)
+	} else {
+		pu.Update = patchOperators(actions)
+	}
+
+	return pu, err
+}
+
+func patchOperators(actions []patchAction) bson.D {
+	var set, unset, push bson.D
+	for _, a := range actions {
+		p := strings.Join(a.path, ".")
+		switch a.kind {
+		case patchActionSet:
+			set = append(set, bson.E{Key: p, Value: a.value})
+		case patchActionUnset:
+			unset = append(unset, bson.E{Key: p, Value: ""})
+		case patchActionPush:
+			push = append(push, bson.E{Key: p, Value: a.value})
+		case patchActionInsertAt:
+			push = append(push, bson.E{Key: p, Value: bson.D{{Key: "$each", Value: bson.A{a.value}}, {Key: "$position", Value: a.index}}})
+		}
+	}
+
+	var update bson.D
+	if len(set) > 0 {
+		update = append(update, bson.E{Key: "$set", Value: set})
+	}
+	if len(unset) > 0 {
+		update = append(update, bson.E{Key: "$unset", Value: unset})
+	}
+	if len(push) > 0 {
+		update = append(update, bson.E{Key: "$push", Value: push})
+	}
+
+	return update
+}
+
+func patchPipeline(actions []patchAction) (bson.A, error) {
+	var stages bson.A
+	for _, a := range actions {
+		if err := checkPipelinePath(a.path); err != nil {
+			return nil, err
+		}
+
+		var value interface{} = bson.D{{Key: "$literal", Value: a.value}}
+		if a.from != nil {
+			if err := checkPipelinePath(a.from); err != nil {
+				return nil, err
+			}
+
+			// the value is saved before removing the source, so that a move within the same array sees the shifted indexes as RFC 6902 does.
+			stages = append(stages, bson.D{{Key: "$set", Value: bson.D{{Key: patchTempField, Value: patchPipelineRef(a.from)}}}})
+			if a.move {
+				stages = append(stages, patchPipelineStage(newPatchRemoveAction(a.from), nil))
+			}
+			value = "$" + patchTempField
+		}
+
+		stages = append(stages, patchPipelineStage(a, value))
+		if a.from != nil {
+			stages = append(stages, bson.D{{Key: "$unset", Value: patchTempField}})
+		}
+	}
+
+	return stages, nil
+}
+
+func patchPipelineStage(a patchAction, value interface{}) bson.D {
+	p := strings.Join(a.path, ".")
+	if a.kind == patchActionUnset {
+		return bson.D{{Key: "$unset", Value: p}}
+	}
+
+	arr := bson.D{{Key: "$ifNull", Value: bson.A{"$" + p, bson.A{}}}}
+	head := func(n int) interface{} {
+		if n == 0 {
+			return bson.A{}
+		}
+		return bson.D{{Key: "$slice", Value: bson.A{arr, n}}}
+	}
+	tail := func(n int) interface{} {
+		return bson.D{{Key: "$slice", Value: bson.A{arr, n, bson.D{{Key: "$max", Value: bson.A{bson.D{{Key: "$size", Value: arr}}, 1}}}}}}
+	}
+
+	var expr interface{}
+	switch a.kind {
+	case patchActionPush:
+		expr = bson.D{{Key: "$concatArrays", Value: bson.A{arr, bson.A{value}}}}
+	case patchActionInsertAt:
+		expr = bson.D{{Key: "$concatArrays", Value: bson.A{head(a.index), bson.A{value}, tail(a.index)}}}
+	case patchActionReplaceAt:
+		expr = bson.D{{Key: "$concatArrays", Value: bson.A{head(a.index), bson.A{value}, tail(a.index + 1)}}}
+	case patchActionRemoveAt:
+		expr = bson.D{{Key: "$concatArrays", Value: bson.A{head(a.index), tail(a.index + 1)}}}
+	default:
+		expr = value
+	}
+
+	return bson.D{{Key: "$set", Value: bson.D{{Key: p, Value: expr}}}}
+}
+
+// patchPipelineRef is the aggregation expression of the value at path: a last index segment is resolved with $arrayElemAt.
+func patchPipelineRef(path []string) interface{} {
+	if ndx, ok := patchIndex(path[len(path)-1]); ok && len(path) > 1 {
+		return bson.D{{Key: "$arrayElemAt", Value: bson.A{"$" + strings.Join(path[:len(path)-1], "."), ndx}}}
+	}
+
+	return "$" + strings.Join(path, ".")
+}
+
+func newPatchAddAction(path []string, v interface{}) patchAction {
+	if len(path) > 1 {
+		parent := path[:len(path)-1]
+		last := path[len(path)-1]
+		if last == "-" {
+			return patchAction{kind: patchActionPush, path: parent, value: v}
+		}
+		if ndx, ok := patchIndex(last); ok {
+			return patchAction{kind: patchActionInsertAt, path: parent, index: ndx, value: v}
+		}
+	}
+
+	return patchAction{kind: patchActionSet, path: path, value: v}
+}
+
+func newPatchRemoveAction(path []string) patchAction {
+	if ndx, ok := patchIndex(path[len(path)-1]); ok && len(path) > 1 {
+		return patchAction{kind: patchActionRemoveAt, path: path[:len(path)-1], index: ndx}
+	}
+
+	return patchAction{kind: patchActionUnset, path: path}
+}
+
+// parseJsonPointer splits a RFC 6901 pointer in its unescaped segments. The pointer to the whole document is not supported.
+func parseJsonPointer(pointer string) ([]string, error) {
+	if !strings.HasPrefix(pointer, "/") || len(pointer) == 1 {
+		return nil, fmt.Errorf("invalid json pointer %q", pointer)
+	}
+
+	segments := strings.Split(pointer[1:], "/")
+	for i, seg := range segments {
+		seg = strings.ReplaceAll(strings.ReplaceAll(seg, "~1", "/"), "~0", "~")
+		if seg == "-" && i == len(segments)-1 {
+			continue
+		}
+
+		if err := checkPatchSegment(seg); err != nil {
+			return nil, err
+		}
+		segments[i] = seg
+	}
+
+	return segments, nil
+}
+
+func checkPatchSegment(seg string) error {
+	if seg == "" || strings.HasPrefix(seg, "$") || strings.ContainsAny(seg, ".\x00") {
+		return fmt.Errorf("field name %q cannot be used in a patch", seg)
+	}
+
+	return nil
+}
+
+// checkPatchVersionField applies to each segment of the dotted version field the rules of the patch paths: the field ends up as a key
+// of the filter and of the update.
+func checkPatchVersionField(field string) error {
+	for _, seg := range strings.Split(field, ".") {
+		if err := checkPatchSegment(seg); err != nil {
+			return fmt.Errorf("patch version field %s: %w", field, err)
+		}
+	}
+
+	return nil
+}
+
+// checkPatchVersionExpected rejects an expected value with keys starting with $: in the filter it would be a query operator (i.e. $ne)
+// and not the value to match.
+func checkPatchVersionExpected(expected interface{}) error {
+	if patchHasOperatorKeys(expected) {
+		return errors.New("patch version expected value cannot be an operator document")
+	}
+
+	return nil
+}
+
+func checkPipelinePath(path []string) error {
+	for _, seg := range path[:len(path)-1] {
+		if _, ok := patchIndex(seg); ok {
+			return fmt.Errorf("path %s cannot be used in a pipeline update", strings.Join(path, "."))
+		}
+	}
+
+	return nil
+}
+
+func patchIndex(seg string) (int, bool) {
+	if seg == "" || (len(seg) > 1 && seg[0] == '0') {
+		return 0, false
+	}
+
+	ndx, err := strconv.Atoi(seg)
+	if err != nil || ndx < 0 {
+		return 0, false
+	}
+
+	return ndx, true
+}
+
+func patchHasOperatorKeys(v interface{}) bool {
+	switch tv := v.(type) {
+	case bson.D:
+		for _, e := range tv {
+			if strings.HasPrefix(e.Key, "$") || patchHasOperatorKeys(e.Value) {
+				return true
+			}
+		}
+	case bson.A:
+		for _, e := range tv {
+			if patchHasOperatorKeys(e) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func patchPathsOverlap(a, b string) bool {
+	return a == b || strings.HasPrefix(a, b+".") || strings.HasPrefix(b, a+".")
+}
+
+// patchValue converts a json value, extended json included, to its bson counterpart.
+func patchValue(data json.RawMessage) (interface{}, error) {
+	if len(data) == 0 {
+		return nil, errors.New("json patch op requires a value")
+	}
+
+	d, err := util.UnmarshalJson2BsonD([]byte(`{"v":`+string(data)+`}`), false)
+	if err != nil {
+		return nil, err
+	}
+
+	return d[0].Value, nil
+}
@@ -0,0 +1,153 @@
+package jsonops_test
+
+import (
+	"testing"
+
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/jsonops"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestJsonPatch2Update(t *testing.T) {
+	pu, err := jsonops.JsonPatch2Update([]byte(`[
+		{ "op": "test", "path": "/status", "value": "draft" },
+		{ "op": "replace", "path": "/title", "value": "Gone with the wind" },
+		{ "op": "add", "path": "/tags/-", "value": "classic" },
+		{ "op": "add", "path": "/cast/0", "value": "Vivien Leigh" },
+		{ "op": "remove", "path": "/a~1b" }
+	]`))
+	require.NoError(t, err)
+	require.Equal(t, bson.D{
+		{Key: "$expr", Value: bson.D{{Key: "$eq", Value: bson.A{"$status", bson.D{{Key: "$literal", Value: "draft"}}}}}},
+		{Key: "title", Value: bson.D{{Key: "$exists", Value: true}}},
+		{Key: "cast", Value: bson.D{{Key: "$type", Value: "array"}}},
+		{Key: "a/b", Value: bson.D{{Key: "$exists", Value: true}}},
+	}, pu.Filter)
+	require.Equal(t, bson.D{
+		{Key: "$set", Value: bson.D{{Key: "title", Value: "Gone with the wind"}}},
+		{Key: "$unset", Value: bson.D{{Key: "a/b", Value: ""}}},
+		{Key: "$push", Value: bson.D{
+			{Key: "tags", Value: "classic"},
+			{Key: "cast", Value: bson.D{{Key: "$each", Value: bson.A{"Vivien Leigh"}}, {Key: "$position", Value: 0}}},
+		}},
+	}, pu.Update)
+
+	err = pu.WithExpectedVersion("version", int32(3))
+	require.NoError(t, err)
+	require.Equal(t, bson.E{Key: "version", Value: int32(3)}, pu.Filter[len(pu.Filter)-1])
+	require.Equal(t, bson.E{Key: "$inc", Value: bson.D{{Key: "version", Value: 1}}}, pu.Update.(bson.D)[3])
+
+	filter := pu.ApplyTo(bson.D{{Key: "_id", Value: 1}})
+	require.Equal(t, "$and", filter[0].Key)
+	require.Len(t, filter[0].Value, 6)
+
+	// removing an array element and moving values need a pipeline.
+	pu, err = jsonops.JsonPatch2Update([]byte(`[
+		{ "op": "remove", "path": "/tags/1" },
+		{ "op": "move", "from": "/old", "path": "/new" }
+	]`))
+	require.NoError(t, err)
+	stages, ok := pu.Update.(bson.A)
+	require.True(t, ok)
+	require.Len(t, stages, 5)
+	require.Equal(t, bson.D{{Key: "$set", Value: bson.D{{Key: "__jsonops_patch", Value: "$old"}}}}, stages[1])
+	require.Equal(t, bson.D{{Key: "$unset", Value: "old"}}, stages[2])
+	require.Equal(t, bson.D{{Key: "$set", Value: bson.D{{Key: "new", Value: "$__jsonops_patch"}}}}, stages[3])
+
+	err = pu.WithExpectedVersion("version", int32(3))
+	require.NoError(t, err)
+	require.Len(t, pu.Update, 6)
+
+	err = pu.WithExpectedVersion("new", int32(3))
+	require.Error(t, err)
+
+	_, err = jsonops.JsonPatch2Update([]byte(`[{ "op": "remove", "path": "/items/0/tags/1" }]`))
+	require.Error(t, err)
+
+	_, err = jsonops.JsonPatch2Update([]byte(`[{ "op": "add", "path": "/$where", "value": 1 }]`))
+	require.Error(t, err)
+
+	_, err = jsonops.JsonPatch2Update([]byte(`[{ "op": "replace", "path": "/tags/-", "value": 1 }]`))
+	require.Error(t, err)
+}
+
+func TestJsonPatch2UpdatePreconditions(t *testing.T) {
+	// a test of null requires the field, operators in the value are compared as literals.
+	pu, err := jsonops.JsonPatch2Update([]byte(`[
+		{ "op": "test", "path": "/deletedAt", "value": null },
+		{ "op": "test", "path": "/owner", "value": { "$ne": "x" } },
+		{ "op": "test", "path": "/tags/1", "value": "b" }
+	]`))
+	require.NoError(t, err)
+	require.Equal(t, bson.D{
+		{Key: "deletedAt", Value: bson.D{{Key: "$exists", Value: true}}},
+		{Key: "$expr", Value: bson.D{{Key: "$eq", Value: bson.A{"$deletedAt", bson.D{{Key: "$literal", Value: nil}}}}}},
+		{Key: "$expr", Value: bson.D{{Key: "$eq", Value: bson.A{"$owner", bson.D{{Key: "$literal", Value: bson.D{{Key: "$ne", Value: "x"}}}}}}}},
+		{Key: "$expr", Value: bson.D{{Key: "$eq", Value: bson.A{bson.D{{Key: "$arrayElemAt", Value: bson.A{"$tags", 1}}}, bson.D{{Key: "$literal", Value: "b"}}}}}},
+	}, pu.Filter)
+
+	// the insertion index cannot be past the end of the array.
+	pu, err = jsonops.JsonPatch2Update([]byte(`[{ "op": "add", "path": "/cast/3", "value": "Clark Gable" }]`))
+	require.NoError(t, err)
+	require.Equal(t, bson.D{
+		{Key: "cast", Value: bson.D{{Key: "$type", Value: "array"}}},
+		{Key: "cast.2", Value: bson.D{{Key: "$exists", Value: true}}},
+	}, pu.Filter)
+
+	// values with keys starting with $ are set through the pipeline as literals.
+	pu, err = jsonops.JsonPatch2Update([]byte(`[{ "op": "add", "path": "/meta", "value": { "$expr": 1 } }]`))
+	require.NoError(t, err)
+	require.Equal(t, bson.A{
+		bson.D{{Key: "$set", Value: bson.D{{Key: "meta", Value: bson.D{{Key: "$literal", Value: bson.D{{Key: "$expr", Value: int32(1)}}}}}}}},
+	}, pu.Update)
+
+	// the preconditions are evaluated before the patch: they cannot refer to paths changed by a previous op.
+	for _, patch := range []string{
+		`[{ "op": "replace", "path": "/status", "value": "a" }, { "op": "test", "path": "/status", "value": "a" }]`,
+		`[{ "op": "add", "path": "/author", "value": {} }, { "op": "remove", "path": "/author/name" }]`,
+		`[{ "op": "move", "from": "/old", "path": "/new" }, { "op": "copy", "from": "/old", "path": "/other" }]`,
+		`[{ "op": "add", "path": "/tags/0", "value": "a" }, { "op": "add", "path": "/tags/2", "value": "b" }]`,
+	} {
+		_, err = jsonops.JsonPatch2Update([]byte(patch))
+		require.Error(t, err, patch)
+	}
+}
+
+func TestMergePatch2Update(t *testing.T) {
+	pu, err := jsonops.MergePatch2Update([]byte(`{ "title": "Hello", "author": { "familyName": null, "givenName": "John" }, "tags": [ "a", "b" ] }`))
+	require.NoError(t, err)
+	require.Empty(t, pu.Filter)
+	require.Equal(t, bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "title", Value: "Hello"},
+			{Key: "author.givenName", Value: "John"},
+			{Key: "tags", Value: bson.A{"a", "b"}},
+		}},
+		{Key: "$unset", Value: bson.D{{Key: "author.familyName", Value: ""}}},
+	}, pu.Update)
+
+	_, err = jsonops.MergePatch2Update([]byte(`[ 1 ]`))
+	require.Error(t, err)
+}
+
+func TestNewPatchOneOperation(t *testing.T) {
+	op, err := jsonops.NewOperation(jsonops.PatchOneOperationType, map[jsonops.MongoJsonOperationStatementPart][]byte{
+		jsonops.MongoActivityPatchOneFilterProperty:  []byte(`{ "_id": { "$oid": "5f1b9b9b9b9b9b9b9b9b9b9b" } }`),
+		jsonops.MongoActivityPatchOnePatchProperty:   []byte(`[{ "op": "replace", "path": "/title", "value": "x" }]`),
+		jsonops.MongoActivityPatchOneVersionProperty: []byte(`{ "field": "version", "expected": 3 }`),
+	})
+	require.NoError(t, err)
+
+	wm, err := op.NewWriteModel()
+	require.NoError(t, err)
+	require.NotNil(t, wm)
+
+	op, err = jsonops.NewOperation(jsonops.MergePatchOneOperationType, map[jsonops.MongoJsonOperationStatementPart][]byte{
+		jsonops.MongoActivityMergePatchOnePatchProperty:   []byte(`{ "version": 4 }`),
+		jsonops.MongoActivityMergePatchOneVersionProperty: []byte(`{ "field": "version", "expected": 3 }`),
+	})
+	require.NoError(t, err)
+
+	_, err = op.NewWriteModel()
+	require.Error(t, err)
+}
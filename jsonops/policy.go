@@ -0,0 +1,314 @@
+package jsonops
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/mongolks"
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/util"
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/util/mdboptions"
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+const (
+	PolicyRuleForbiddenOperator = "forbidden-operator"
+	PolicyRuleMaxLimit          = "max-limit"
+	PolicyRuleMaxTimeMS         = "max-time-ms"
+	PolicyRuleRequireFilter     = "require-filter"
+)
+
+// DefaultForbiddenOperators are the operators and stages that run server side javascript or write to other collections.
+var DefaultForbiddenOperators = []string{"$where", "$function", "$accumulator", "$out", "$merge"}
+
+var ErrPolicyViolation = errors.New("policy violation")
+
+// ErrInvalidStatement is returned by the policy check for a statement that cannot be parsed, the request is at fault.
+var ErrInvalidStatement = errors.New("invalid statement")
+
+type PolicyViolation struct {
+	Rule   string
+	Detail string
+}
+
+func (v *PolicyViolation) Error() string {
+	return fmt.Sprintf("%s: %s: %s", ErrPolicyViolation.Error(), v.Rule, v.Detail)
+}
+
+func (v *PolicyViolation) Is(target error) bool {
+	return target == ErrPolicyViolation
+}
+
+// Policy is the set of guardrails checked on the parsed statement of an operation before its execution. The check is part of the
+// package level functions (FindContext, AggregateContext, ...) so that they cannot be used to bypass it. Zero values disable the
+// corresponding rule: a max-limit makes the limit option mandatory on finds and a terminal $limit stage mandatory on aggregations.
+type Policy struct {
+	ForbiddenOperators  []string `yaml:"forbidden-operators,omitempty" mapstructure:"forbidden-operators,omitempty" json:"forbidden-operators,omitempty"`
+	MaxLimit            int64    `yaml:"max-limit,omitempty" mapstructure:"max-limit,omitempty" json:"max-limit,omitempty"`
+	MaxTimeMS           int64    `yaml:"max-time-ms,omitempty" mapstructure:"max-time-ms,omitempty" json:"max-time-ms,omitempty"`
+	RequireFilterOnMany bool     `yaml:"require-filter-on-many,omitempty" mapstructure:"require-filter-on-many,omitempty" json:"require-filter-on-many,omitempty"`
+}
+
+// PolicyConfig binds a policy to a linked service and, optionally, to a collection of it. An empty lks applies to every linked service.
+type PolicyConfig struct {
+	Lks          string `yaml:"lks,omitempty" mapstructure:"lks,omitempty" json:"lks,omitempty"`
+	CollectionId string `yaml:"collection-id,omitempty" mapstructure:"collection-id,omitempty" json:"collection-id,omitempty"`
+	Policy       Policy `yaml:"policy,omitempty" mapstructure:"policy,omitempty" json:"policy,omitempty"`
+}
+
+func DefaultPolicy() Policy {
+	return Policy{ForbiddenOperators: DefaultForbiddenOperators, RequireFilterOnMany: true}
+}
+
+var policies sync.Map
+
+func policyKey(lksName, collectionId string) string {
+	return lksName + "/" + collectionId
+}
+
+func RegisterPolicies(cfgs []PolicyConfig) {
+	for _, cfg := range cfgs {
+		RegisterPolicy(cfg.Lks, cfg.CollectionId, cfg.Policy)
+	}
+}
+
+func RegisterPolicy(lksName, collectionId string, p Policy) {
+	const semLogContext = "json-ops::register-policy"
+	log.Info().Str("lks", lksName).Str("collection-id", collectionId).Msg(semLogContext)
+	policies.Store(policyKey(lksName, collectionId), p)
+}
+
+// PolicyFor returns the most specific policy registered: collection, linked service, global. No policy means no guardrails.
+func PolicyFor(lksName, collectionId string) (Policy, bool) {
+	for _, k := range []string{policyKey(lksName, collectionId), policyKey(lksName, ""), policyKey("", "")} {
+		if p, ok := policies.Load(k); ok {
+			return p.(Policy), true
+		}
+	}
+
+	return Policy{}, false
+}
+
+func checkPolicy(lks *mongolks.LinkedService, collectionId string, op Operation) error {
+	const semLogContext = "json-ops::check-policy"
+
+	p, ok := PolicyFor(lks.Name(), collectionId)
+	if !ok {
+		return nil
+	}
+
+	err := p.Check(op)
+	if err != nil {
+		log.Error().Err(err).Str("lks", lks.Name()).Str("collection-id", collectionId).Str("op-type", string(op.OpType())).Msg(semLogContext)
+	}
+
+	return err
+}
+
+// Check walks the statement parts of the operation. A statement that cannot be parsed fails the check with an ErrInvalidStatement.
+func (p Policy) Check(op Operation) error {
+	switch o := op.(type) {
+	case *FindOperation:
+		return p.checkParts(o.Options, mdboptions.FindOptionNames, true, o.Query, o.Sort, o.Projection)
+	case *FindOneOperation:
+		return p.checkParts(o.Options, mdboptions.FindOneOptionNames, false, o.Query, o.Sort, o.Projection)
+	case *FindOneAndUpdateOperation:
+		return p.checkParts(o.Options, mdboptions.FindOneAndUpdateOptionNames, false, o.Query, o.Sort, o.Projection, o.Update)
+	case *AggregateOneOperation:
+		if err := p.checkPipelineLimit(o.Pipeline); err != nil {
+			return err
+		}
+		return p.checkParts(o.Options, mdboptions.AggregateOptionNames, false, o.Pipeline)
+	case *ReplaceOneOperation:
+		return p.checkParts(o.Options, mdboptions.ReplaceOptionNames, false, o.Filter, o.Replacement)
+	case *UpdateOneOperation:
+		return p.checkParts(o.Options, mdboptions.UpdateOptionNames, false, o.Filter, o.Update)
+	case *UpdateManyOperation:
+		if err := p.checkFilter(UpdateManyOperationType, o.Filter); err != nil {
+			return err
+		}
+		return p.checkParts(o.Options, mdboptions.UpdateOptionNames, false, o.Filter, o.Update)
+	case *DeleteOneOperation:
+		return p.checkParts(o.Options, mdboptions.DeleteOptionNames, false, o.Filter)
+	case *DeleteManyOperation:
+		if err := p.checkFilter(DeleteManyOperationType, o.Filter); err != nil {
+			return err
+		}
+		return p.checkParts(o.Options, mdboptions.DeleteOptionNames, false, o.Filter)
+	case *InsertOneOperation:
+		return p.checkParts(o.Options, mdboptions.InsertOneOptionNames, false)
+	case *PatchOneOperation:
+		if _, err := NewPatchVersionFromJson(o.Version); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidStatement, err)
+		}
+		return p.checkParts(o.Options, mdboptions.UpdateOptionNames, false, o.Filter, o.Patch, o.Version)
+	case *MergePatchOneOperation:
+		if _, err := NewPatchVersionFromJson(o.Version); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidStatement, err)
+		}
+		return p.checkParts(o.Options, mdboptions.UpdateOptionNames, false, o.Filter, o.Patch, o.Version)
+	case *SyncManyOperation:
+		_, deleteMissing, err := o.syncParameters()
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidStatement, err)
+		}
+
+		if deleteMissing {
+			if err := p.checkFilter(SyncManyOperationType, o.Scope); err != nil {
+				return err
+			}
+		}
+		return p.checkParts(o.Options, mdboptions.BulkWriteOptionNames, false, o.Scope)
+	case *WatchOperation:
+		return p.checkParts(o.Options, mdboptions.WatchOptionNames, false, o.Pipeline)
+	}
+
+	return nil
+}
+
+func (p Policy) checkParts(opts []byte, supported []string, checkLimit bool, parts ...[]byte) error {
+	for _, part := range parts {
+		if len(part) == 0 {
+			continue
+		}
+
+		v, err := util.UnmarshalJson2Bson(part, false)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidStatement, err)
+		}
+
+		if err = p.checkOperators(v); err != nil {
+			return err
+		}
+	}
+
+	jo, err := mdboptions.ParseJsonOptions(opts, supported)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidStatement, err)
+	}
+
+	if checkLimit && p.MaxLimit > 0 {
+		if jo.Limit == nil || *jo.Limit <= 0 {
+			return &PolicyViolation{Rule: PolicyRuleMaxLimit, Detail: fmt.Sprintf("a limit not greater than %d is required", p.MaxLimit)}
+		}
+		if *jo.Limit > p.MaxLimit {
+			return &PolicyViolation{Rule: PolicyRuleMaxLimit, Detail: fmt.Sprintf("limit %d exceeds %d", *jo.Limit, p.MaxLimit)}
+		}
+	}
+
+	if p.MaxTimeMS > 0 && jo.MaxTime != nil && jo.MaxTime.Milliseconds() > p.MaxTimeMS {
+		return &PolicyViolation{Rule: PolicyRuleMaxTimeMS, Detail: fmt.Sprintf("maxTimeMS %d exceeds %d", jo.MaxTime.Milliseconds(), p.MaxTimeMS)}
+	}
+
+	return nil
+}
+
+// checkPipelineLimit requires the last stage of the pipeline to be a $limit not greater than the max-limit. A $limit in the middle
+// of the pipeline does not bound the number of documents returned (i.e. an $unwind can follow it).
+func (p Policy) checkPipelineLimit(pipeline []byte) error {
+	if p.MaxLimit <= 0 {
+		return nil
+	}
+
+	stages, err := util.UnmarshalJson2ArrayOfBsonD(pipeline, false)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidStatement, err)
+	}
+
+	if len(stages) == 0 || len(stages[len(stages)-1]) != 1 || stages[len(stages)-1][0].Key != "$limit" {
+		return &PolicyViolation{Rule: PolicyRuleMaxLimit, Detail: fmt.Sprintf("a terminal $limit stage not greater than %d is required", p.MaxLimit)}
+	}
+
+	var limit int64
+	switch v := stages[len(stages)-1][0].Value.(type) {
+	case int32:
+		limit = int64(v)
+	case int64:
+		limit = v
+	case float64:
+		if v != float64(int64(v)) {
+			return fmt.Errorf("%w: $limit value %v is not an integer", ErrInvalidStatement, v)
+		}
+		limit = int64(v)
+	default:
+		return fmt.Errorf("%w: $limit value of type %T is not a number", ErrInvalidStatement, v)
+	}
+
+	if limit <= 0 || limit > p.MaxLimit {
+		return &PolicyViolation{Rule: PolicyRuleMaxLimit, Detail: fmt.Sprintf("$limit %d exceeds %d", limit, p.MaxLimit)}
+	}
+
+	return nil
+}
+
+func (p Policy) checkFilter(opType MongoJsonOperationType, filter []byte) error {
+	if !p.RequireFilterOnMany {
+		return nil
+	}
+
+	d, err := util.UnmarshalJson2BsonD(filter, false)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidStatement, err)
+	}
+
+	if len(d) == 0 {
+		return &PolicyViolation{Rule: PolicyRuleRequireFilter, Detail: fmt.Sprintf("%s requires a non empty filter", opType)}
+	}
+
+	return nil
+}
+
+func (p Policy) checkOperators(v interface{}) error {
+	switch tv := v.(type) {
+	case bson.D:
+		for _, e := range tv {
+			if strings.HasPrefix(e.Key, "$") && p.isForbidden(e.Key) {
+				return &PolicyViolation{Rule: PolicyRuleForbiddenOperator, Detail: e.Key + " is not allowed"}
+			}
+			if err := p.checkOperators(e.Value); err != nil {
+				return err
+			}
+		}
+	case bson.M:
+		for k, e := range tv {
+			if strings.HasPrefix(k, "$") && p.isForbidden(k) {
+				return &PolicyViolation{Rule: PolicyRuleForbiddenOperator, Detail: k + " is not allowed"}
+			}
+			if err := p.checkOperators(e); err != nil {
+				return err
+			}
+		}
+	case bson.A:
+		for _, e := range tv {
+			if err := p.checkOperators(e); err != nil {
+				return err
+			}
+		}
+	case []bson.D:
+		for _, e := range tv {
+			if err := p.checkOperators(e); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, e := range tv {
+			if err := p.checkOperators(e); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (p Policy) isForbidden(op string) bool {
+	for _, f := range p.ForbiddenOperators {
+		if f == op {
+			return true
+		}
+	}
+
+	return false
+}
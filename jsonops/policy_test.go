@@ -0,0 +1,93 @@
+package jsonops_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/jsonops"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicy(t *testing.T) {
+	p := jsonops.DefaultPolicy()
+	p.MaxLimit = 100
+	p.MaxTimeMS = 5000
+
+	policyCases := []struct {
+		opType jsonops.MongoJsonOperationType
+		stmt   map[jsonops.MongoJsonOperationStatementPart][]byte
+		rule   string
+	}{
+		{jsonops.FindManyOperationType, map[jsonops.MongoJsonOperationStatementPart][]byte{"$query": []byte(`{ "code": "A01" }`), "$opts": []byte(`{ "limit": 10 }`)}, ""},
+		{jsonops.FindManyOperationType, map[jsonops.MongoJsonOperationStatementPart][]byte{"$query": []byte(`{ "code": "A01" }`)}, jsonops.PolicyRuleMaxLimit},
+		{jsonops.FindManyOperationType, map[jsonops.MongoJsonOperationStatementPart][]byte{"$query": []byte(`{ "code": "A01" }`), "$opts": []byte(`{ "limit": 1000 }`)}, jsonops.PolicyRuleMaxLimit},
+		{jsonops.FindOneOperationType, map[jsonops.MongoJsonOperationStatementPart][]byte{"$query": []byte(`{ "$and": [ { "$where": "this.a > 1" } ] }`)}, jsonops.PolicyRuleForbiddenOperator},
+		{jsonops.FindOneOperationType, map[jsonops.MongoJsonOperationStatementPart][]byte{"$query": []byte(`{ "code": "A01" }`), "$opts": []byte(`{ "maxTimeMS": 60000 }`)}, jsonops.PolicyRuleMaxTimeMS},
+		{jsonops.AggregateOneOperationType, map[jsonops.MongoJsonOperationStatementPart][]byte{"$pipeline": []byte(`[ { "$match": { "code": "A01" } }, { "$out": "other" }, { "$limit": 10 } ]`)}, jsonops.PolicyRuleForbiddenOperator},
+		{jsonops.AggregateOneOperationType, map[jsonops.MongoJsonOperationStatementPart][]byte{"$pipeline": []byte(`[ { "$match": { "code": "A01" } }, { "$limit": 10 } ]`)}, ""},
+		{jsonops.AggregateOneOperationType, map[jsonops.MongoJsonOperationStatementPart][]byte{"$pipeline": []byte(`[ { "$match": { "code": "A01" } } ]`)}, jsonops.PolicyRuleMaxLimit},
+		{jsonops.AggregateOneOperationType, map[jsonops.MongoJsonOperationStatementPart][]byte{"$pipeline": []byte(`[ { "$limit": 10 }, { "$unwind": "$items" } ]`)}, jsonops.PolicyRuleMaxLimit},
+		{jsonops.AggregateOneOperationType, map[jsonops.MongoJsonOperationStatementPart][]byte{"$pipeline": []byte(`[ { "$match": { "code": "A01" } }, { "$limit": 1000 } ]`)}, jsonops.PolicyRuleMaxLimit},
+		{jsonops.FindOneOperationType, map[jsonops.MongoJsonOperationStatementPart][]byte{"$query": []byte(`{ "code": "A01" }`), "$sort": []byte(`{ "score": { "$function": {} } }`)}, jsonops.PolicyRuleForbiddenOperator},
+		{jsonops.ReplaceOneOperationType, map[jsonops.MongoJsonOperationStatementPart][]byte{"$filter": []byte(`{ "code": "A01" }`), "$replacement": []byte(`{ "code": "A01", "a": { "$where": "1" } }`)}, jsonops.PolicyRuleForbiddenOperator},
+		{jsonops.UpdateManyOperationType, map[jsonops.MongoJsonOperationStatementPart][]byte{"$filter": []byte(`{}`), "$update": []byte(`{ "$set": { "status": "done" } }`)}, jsonops.PolicyRuleRequireFilter},
+		{jsonops.DeleteManyOperationType, map[jsonops.MongoJsonOperationStatementPart][]byte{"$filter": []byte(`{ "status": "done" }`)}, ""},
+		{jsonops.DeleteManyOperationType, map[jsonops.MongoJsonOperationStatementPart][]byte{}, jsonops.PolicyRuleRequireFilter},
+		{jsonops.SyncManyOperationType, map[jsonops.MongoJsonOperationStatementPart][]byte{"$key": []byte(`"code"`), "$delete-missing": []byte(`true`)}, jsonops.PolicyRuleRequireFilter},
+		{jsonops.SyncManyOperationType, map[jsonops.MongoJsonOperationStatementPart][]byte{"$key": []byte(`"code"`)}, ""},
+		{jsonops.PatchOneOperationType, map[jsonops.MongoJsonOperationStatementPart][]byte{"$filter": []byte(`{ "code": "A01" }`), "$patch": []byte(`[ { "op": "add", "path": "/a", "value": { "$function": {} } } ]`)}, jsonops.PolicyRuleForbiddenOperator},
+		{jsonops.MergePatchOneOperationType, map[jsonops.MongoJsonOperationStatementPart][]byte{"$filter": []byte(`{ "code": "A01" }`), "$patch": []byte(`{ "a": { "$where": "1" } }`)}, jsonops.PolicyRuleForbiddenOperator},
+		{jsonops.MergePatchOneOperationType, map[jsonops.MongoJsonOperationStatementPart][]byte{"$filter": []byte(`{ "code": "A01" }`), "$patch": []byte(`{ "a": 1 }`), "$version": []byte(`{ "field": "meta.version", "expected": { "$numberLong": "3" } }`)}, ""},
+	}
+
+	for i, c := range policyCases {
+		op, err := jsonops.NewOperation(c.opType, c.stmt)
+		require.NoError(t, err)
+
+		err = p.Check(op)
+		if c.rule == "" {
+			require.NoError(t, err, "case #%d", i)
+			continue
+		}
+
+		var violation *jsonops.PolicyViolation
+		require.True(t, errors.As(err, &violation), "case #%d", i)
+		require.Equal(t, c.rule, violation.Rule, "case #%d", i)
+		require.ErrorIs(t, err, jsonops.ErrPolicyViolation)
+		require.Equal(t, http.StatusForbidden, jsonops.StatusCodeFromError(err))
+	}
+
+	// statements that cannot be parsed do not pass the check.
+	invalidCases := []struct {
+		opType jsonops.MongoJsonOperationType
+		stmt   map[jsonops.MongoJsonOperationStatementPart][]byte
+	}{
+		{jsonops.FindManyOperationType, map[jsonops.MongoJsonOperationStatementPart][]byte{"$query": []byte(`{ "$where": `), "$opts": []byte(`{ "limit": 10 }`)}},
+		{jsonops.FindManyOperationType, map[jsonops.MongoJsonOperationStatementPart][]byte{"$query": []byte(`{ "code": "A01" }`), "$opts": []byte(`{ "limit": 10, "maxTimeMS": "never" }`)}},
+		{jsonops.DeleteManyOperationType, map[jsonops.MongoJsonOperationStatementPart][]byte{"$filter": []byte(`{ "code": `)}},
+		{jsonops.AggregateOneOperationType, map[jsonops.MongoJsonOperationStatementPart][]byte{"$pipeline": []byte(`[ { "$limit": "ten" } ]`)}},
+		{jsonops.PatchOneOperationType, map[jsonops.MongoJsonOperationStatementPart][]byte{"$filter": []byte(`{ "code": "A01" }`), "$patch": []byte(`[ { "op": "add", "path": "/a", "value": 1 } ]`), "$version": []byte(`{ "field": "$where", "expected": 1 }`)}},
+		{jsonops.PatchOneOperationType, map[jsonops.MongoJsonOperationStatementPart][]byte{"$filter": []byte(`{ "code": "A01" }`), "$patch": []byte(`[ { "op": "add", "path": "/a", "value": 1 } ]`), "$version": []byte(`{ "field": "meta..version", "expected": 1 }`)}},
+		{jsonops.PatchOneOperationType, map[jsonops.MongoJsonOperationStatementPart][]byte{"$filter": []byte(`{ "code": "A01" }`), "$patch": []byte(`[ { "op": "add", "path": "/a", "value": 1 } ]`), "$version": []byte(`{ "field": "version", "expected": { "$ne": 0 } }`)}},
+		{jsonops.MergePatchOneOperationType, map[jsonops.MongoJsonOperationStatementPart][]byte{"$filter": []byte(`{ "code": "A01" }`), "$patch": []byte(`{ "a": 1 }`), "$version": []byte(`{ "field": "meta.$version", "expected": 1 }`)}},
+		{jsonops.MergePatchOneOperationType, map[jsonops.MongoJsonOperationStatementPart][]byte{"$filter": []byte(`{ "code": "A01" }`), "$patch": []byte(`{ "a": 1 }`), "$version": []byte(`{ "field": "version\u0000", "expected": 1 }`)}},
+		{jsonops.MergePatchOneOperationType, map[jsonops.MongoJsonOperationStatementPart][]byte{"$filter": []byte(`{ "code": "A01" }`), "$patch": []byte(`{ "a": 1 }`), "$version": []byte(`{ "field": "version", "expected": { "$gt": 0 } }`)}},
+		{jsonops.SyncManyOperationType, map[jsonops.MongoJsonOperationStatementPart][]byte{"$key": []byte(`"code"`), "$delete-missing": []byte(`"yes"`)}},
+	}
+
+	for i, c := range invalidCases {
+		op, err := jsonops.NewOperation(c.opType, c.stmt)
+		require.NoError(t, err)
+
+		err = p.Check(op)
+		require.ErrorIs(t, err, jsonops.ErrInvalidStatement, "case #%d", i)
+		require.Equal(t, http.StatusBadRequest, jsonops.StatusCodeFromError(err), "case #%d", i)
+	}
+
+	jsonops.RegisterPolicy("policy-test", "", p)
+	_, ok := jsonops.PolicyFor("policy-test", "orders")
+	require.True(t, ok)
+	_, ok = jsonops.PolicyFor("other", "orders")
+	require.False(t, ok)
+}
@@ -0,0 +1,129 @@
+package jsonops
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/util"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+const (
+	ProblemContentType = "application/problem+json"
+	ProblemTypeDefault = "about:blank"
+)
+
+// Problem is the RFC 7807 representation of a failed operation. The mongo code and code name are carried as extension members.
+type Problem struct {
+	Type          string `yaml:"type,omitempty" json:"type,omitempty" mapstructure:"type,omitempty"`
+	Title         string `yaml:"title,omitempty" json:"title,omitempty" mapstructure:"title,omitempty"`
+	Status        int    `yaml:"status,omitempty" json:"status,omitempty" mapstructure:"status,omitempty"`
+	Detail        string `yaml:"detail,omitempty" json:"detail,omitempty" mapstructure:"detail,omitempty"`
+	Instance      string `yaml:"instance,omitempty" json:"instance,omitempty" mapstructure:"instance,omitempty"`
+	MongoCode     int32  `yaml:"mongo-code,omitempty" json:"mongoCode,omitempty" mapstructure:"mongo-code,omitempty"`
+	MongoCodeName string `yaml:"mongo-code-name,omitempty" json:"mongoCodeName,omitempty" mapstructure:"mongo-code-name,omitempty"`
+}
+
+func (p *Problem) Error() string {
+	if p.Detail != "" {
+		return p.Title + ": " + p.Detail
+	}
+	return p.Title
+}
+
+func (p *Problem) ToJson() []byte {
+	b, _ := json.Marshal(p)
+	return b
+}
+
+func NewProblem(status int, err error) *Problem {
+	p := &Problem{
+		Type:   ProblemTypeDefault,
+		Title:  http.StatusText(status),
+		Status: status,
+	}
+
+	if err != nil {
+		c := util.Classify(err)
+		p.MongoCode, p.MongoCodeName, p.Detail = c.Code, c.CodeName, c.Message
+	}
+
+	return p
+}
+
+// OperationResultFromError maps the error of an operation to an http status and a problem. Errors not coming from mongo map to 500.
+func OperationResultFromError(err error) OperationResult {
+	sc := StatusCodeFromError(err)
+	return OperationResult{StatusCode: sc, Problem: NewProblem(sc, err)}
+}
+
+// OperationResultFromStatementError is used when the statement of an operation cannot be parsed. The request is at fault and maps to 400.
+func OperationResultFromStatementError(err error) OperationResult {
+	return OperationResult{StatusCode: http.StatusBadRequest, Problem: NewProblem(http.StatusBadRequest, err)}
+}
+
+func StatusCodeFromError(err error) int {
+	if err == nil {
+		return http.StatusOK
+	}
+
+	var p *Problem
+	switch {
+	case errors.As(err, &p):
+		return p.Status
+	case errors.Is(err, ErrPolicyViolation):
+		return http.StatusForbidden
+	case errors.Is(err, ErrInvalidStatement):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrPatchConflict):
+		return http.StatusConflict
+	case errors.Is(err, mongo.ErrNoDocuments):
+		return http.StatusNotFound
+	}
+
+	c := util.Classify(err)
+	switch {
+	case errors.Is(c, util.ErrDuplicateKey):
+		return http.StatusConflict
+	case errors.Is(c, util.ErrValidation):
+		return http.StatusUnprocessableEntity
+	case errors.Is(c, util.ErrTimeout):
+		return http.StatusGatewayTimeout
+	case errors.Is(c, util.ErrNetwork), errors.Is(c, util.ErrNotPrimary):
+		return http.StatusServiceUnavailable
+	}
+
+	return StatusCodeFromMongoErrorCode(c.Code)
+}
+
+func StatusCodeFromMongoErrorCode(code int32) int {
+	switch code {
+	case 0:
+		return http.StatusInternalServerError
+	case util.MongoErrDuplicateKey:
+		return http.StatusConflict
+	case util.MongoErrDocumentValidationFailure:
+		return http.StatusUnprocessableEntity
+	case util.MongoErrNoMatchingDocument, util.MongoErrNamespaceNotFound:
+		return http.StatusNotFound
+	case util.MongoErrWriteConflict:
+		return http.StatusConflict
+	case util.MongoErrBadValue, util.MongoErrFailedToParse, util.MongoErrTypeMismatch, util.MongoErrInvalidOptions,
+		util.MongoErrInvalidIdField, util.MongoErrImmutableField, util.MongoErrInvalidLength, util.MongoErrOverflow:
+		return http.StatusBadRequest
+	case util.MongoErrUnauthorized:
+		return http.StatusForbidden
+	case util.MongoErrAuthenticationFailed:
+		return http.StatusUnauthorized
+	case util.MongoErrMaxTimeMSExpired, util.MongoErrExceededTimeLimit, util.MongoErrNetworkTimeout,
+		util.MongoErrNetworkInterfaceExceededTimeLimit, util.MongoErrLockTimeout:
+		return http.StatusGatewayTimeout
+	case util.MongoErrHostUnreachable, util.MongoErrHostNotFound, util.MongoErrSocketException, util.MongoErrShutdownInProgress,
+		util.MongoErrInterruptedAtShutdown, util.MongoErrNotWritablePrimary, util.MongoErrPrimarySteppedDown,
+		util.MongoErrNotPrimaryNoSecondaryOk, util.MongoErrNotPrimaryOrSecondary, util.MongoErrInterruptedDueToReplStateChange:
+		return http.StatusServiceUnavailable
+	}
+
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,40 @@
+package jsonops_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/jsonops"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+func TestOperationResultFromError(t *testing.T) {
+	dupErr := mongo.WriteException{WriteErrors: []mongo.WriteError{{Code: 11000, Message: "E11000 duplicate key error collection"}}}
+	res := jsonops.OperationResultFromError(dupErr)
+	require.Equal(t, http.StatusConflict, res.StatusCode)
+	require.Equal(t, int32(11000), res.Problem.MongoCode)
+	require.Equal(t, "DuplicateKey", res.Problem.MongoCodeName)
+	require.Equal(t, "E11000 duplicate key error collection", res.Problem.Detail)
+
+	res = jsonops.OperationResultFromError(mongo.CommandError{Code: 121, Name: "DocumentValidationFailure", Message: "Document failed validation"})
+	require.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+	require.Equal(t, "DocumentValidationFailure", res.Problem.MongoCodeName)
+
+	res = jsonops.OperationResultFromError(mongo.CommandError{Code: 50, Message: "operation exceeded time limit"})
+	require.Equal(t, http.StatusGatewayTimeout, res.StatusCode)
+	require.Equal(t, "MaxTimeMSExpired", res.Problem.MongoCodeName)
+
+	res = jsonops.OperationResultFromError(mongo.CommandError{Code: 10107, Name: "NotWritablePrimary"})
+	require.Equal(t, http.StatusServiceUnavailable, res.StatusCode)
+
+	require.Equal(t, http.StatusNotFound, jsonops.StatusCodeFromError(mongo.ErrNoDocuments))
+	require.Equal(t, http.StatusGatewayTimeout, jsonops.StatusCodeFromError(context.DeadlineExceeded))
+	require.Equal(t, http.StatusInternalServerError, jsonops.StatusCodeFromError(errors.New("cannot find requested collection")))
+
+	res = jsonops.OperationResultFromStatementError(errors.New("invalid character"))
+	require.Equal(t, http.StatusBadRequest, res.StatusCode)
+	require.JSONEq(t, `{"type":"about:blank","title":"Bad Request","status":400,"detail":"invalid character"}`, string(res.Problem.ToJson()))
+}
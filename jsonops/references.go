@@ -0,0 +1,117 @@
+package jsonops
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// rewriteReferences rewrites the json replacing the objects made of the single key property (i.e. { "$ref": "..." }) with the value
+// returned by the resolver. Key order is preserved.
+func rewriteReferences(data []byte, key string, resolver func(ref string) ([]byte, error)) ([]byte, error) {
+	if len(data) == 0 {
+		return data, nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var buf bytes.Buffer
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	err = rewriteReferenceValue(dec, tok, &buf, key, resolver)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = dec.Token(); !errors.Is(err, io.EOF) {
+		return nil, errors.New("invalid json: unexpected data after top-level value")
+	}
+
+	return buf.Bytes(), nil
+}
+
+func rewriteReferenceValue(dec *json.Decoder, tok json.Token, buf *bytes.Buffer, key string, resolver func(ref string) ([]byte, error)) error {
+	delim, isDelim := tok.(json.Delim)
+	if !isDelim {
+		b, err := json.Marshal(tok)
+		if err != nil {
+			return err
+		}
+		buf.Write(b)
+		return nil
+	}
+
+	switch delim {
+	case '[':
+		buf.WriteByte('[')
+		for i := 0; ; i++ {
+			tok, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			if d, ok := tok.(json.Delim); ok && d == ']' {
+				break
+			}
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err = rewriteReferenceValue(dec, tok, buf, key, resolver); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case '{':
+		for i := 0; ; i++ {
+			tok, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			if d, ok := tok.(json.Delim); ok && d == '}' {
+				if i == 0 {
+					buf.WriteByte('{')
+				}
+				break
+			}
+
+			name := tok.(string)
+			tok, err = dec.Token()
+			if err != nil {
+				return err
+			}
+
+			if i == 0 && name == key {
+				if ref, ok := tok.(string); ok && !dec.More() {
+					if _, err = dec.Token(); err != nil { // skip '}'
+						return err
+					}
+					resolved, err := resolver(ref)
+					if err != nil {
+						return err
+					}
+					buf.Write(resolved)
+					return nil
+				}
+			}
+
+			if i == 0 {
+				buf.WriteByte('{')
+			} else {
+				buf.WriteByte(',')
+			}
+			kb, _ := json.Marshal(name)
+			buf.Write(kb)
+			buf.WriteByte(':')
+			if err = rewriteReferenceValue(dec, tok, buf, key, resolver); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	}
+
+	return nil
+}
@@ -5,15 +5,14 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"net/http"
 	"strings"
 
 	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/mongolks"
 	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/util"
 	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/util/mdboptions"
 	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
-	"go.mongodb.org/mongo-driver/v2/mongo/options"
 )
 
 const (
@@ -21,12 +20,14 @@ const (
 	MongoActivityReplaceOneFilterProperty      MongoJsonOperationStatementPart = "$filter"
 	MongoActivityReplaceOneReplacementProperty MongoJsonOperationStatementPart = "$replacement"
 	MongoActivityReplaceOneOptsProperty        MongoJsonOperationStatementPart = "$opts"
+	MongoActivityReplaceOneOutputProperty      MongoJsonOperationStatementPart = "$output"
 )
 
 type ReplaceOneOperation struct {
 	Filter      []byte `yaml:"filter,omitempty" json:"filter,omitempty" mapstructure:"filter,omitempty"`
 	Replacement []byte `yaml:"replacement,omitempty" json:"replacement,omitempty" mapstructure:"replacement,omitempty"`
 	Options     []byte `yaml:"options,omitempty" json:"options,omitempty" mapstructure:"options,omitempty"`
+	Output      []byte `yaml:"output,omitempty" json:"output,omitempty" mapstructure:"output,omitempty"`
 }
 
 func (op *ReplaceOneOperation) OpType() MongoJsonOperationType {
@@ -58,6 +59,14 @@ func (op *ReplaceOneOperation) ToString() string {
 		sb.WriteString(fmt.Sprintf("\"%s\": ", MongoActivityReplaceOneOptsProperty))
 		sb.WriteString(string(op.Options))
 	}
+	if len(op.Output) > 0 {
+		if numberOfElements > 0 {
+			sb.WriteString(",")
+		}
+		numberOfElements++
+		sb.WriteString(fmt.Sprintf("\"%s\": ", MongoActivityReplaceOneOutputProperty))
+		sb.WriteString(string(op.Output))
+	}
 
 	sb.WriteString("}")
 	return sb.String()
@@ -81,6 +90,10 @@ func NewReplaceOneOperation(m map[MongoJsonOperationStatementPart][]byte) (*Repl
 		foStmt.Options = data
 	}
 
+	if data, ok := m[MongoActivityReplaceOneOutputProperty]; ok {
+		foStmt.Output = data
+	}
+
 	return &foStmt, nil
 }
 
@@ -100,57 +113,85 @@ func NewReplaceOneStatementConfigFromJson(data []byte) (ReplaceOneOperation, err
 		Filter:      m[MongoActivityReplaceOneFilterProperty],
 		Replacement: m[MongoActivityReplaceOneReplacementProperty],
 		Options:     m[MongoActivityReplaceOneOptsProperty],
+		Output:      m[MongoActivityReplaceOneOutputProperty],
 	}
 
 	return fo, nil
 }
 
 func (op *ReplaceOneOperation) Execute(lks *mongolks.LinkedService, collectionId string) (OperationResult, []byte, error) {
-	sc, resp, err := ReplaceOne(lks, collectionId, op.Filter, op.Replacement, op.Options)
+	return op.ExecuteContext(context.Background(), lks, collectionId)
+}
+
+func (op *ReplaceOneOperation) ExecuteContext(ctx context.Context, lks *mongolks.LinkedService, collectionId string) (res OperationResult, resp []byte, err error) {
+	ctx, endSpan := startOperationSpan(ctx, lks, op.OpType(), collectionId)
+	defer func() { endSpan(res, resp, err) }()
+
+	oo, err := NewOutputOptionsFromJson(op.Output)
+	if err != nil {
+		return OperationResultFromStatementError(err), nil, err
+	}
+
+	sc, resp, err := ReplaceOneContext(ctx, lks, collectionId, op.Filter, op.Replacement, op.Options, oo)
 	return sc, resp, err
 }
 
-func ReplaceOne(lks *mongolks.LinkedService, collectionId string, filter []byte, replacement []byte, opts []byte) (OperationResult, []byte, error) {
+func ReplaceOne(lks *mongolks.LinkedService, collectionId string, filter []byte, replacement []byte, opts []byte, output ...OutputOptions) (OperationResult, []byte, error) {
+	return ReplaceOneContext(context.Background(), lks, collectionId, filter, replacement, opts, output...)
+}
+
+func ReplaceOneContext(ctx context.Context, lks *mongolks.LinkedService, collectionId string, filter []byte, replacement []byte, opts []byte, output ...OutputOptions) (OperationResult, []byte, error) {
 	const semLogContext = "json-ops::replace-one"
 	var err error
 
+	if err := checkPolicy(lks, collectionId, &ReplaceOneOperation{Filter: filter, Replacement: replacement, Options: opts}); err != nil {
+		return OperationResultFromError(err), nil, err
+	}
+
 	c := lks.GetCollection(collectionId, "")
 	if c == nil {
 		err = errors.New("cannot find requested collection")
 		log.Error().Err(err).Str("collection", collectionId).Msg(semLogContext)
-		return OperationResult{StatusCode: http.StatusInternalServerError}, nil, err
+		return OperationResultFromError(err), nil, err
 	}
 
 	opFilter, err := util.UnmarshalJson2BsonD(filter, true)
 	if err != nil {
 		log.Error().Err(err).Msg(semLogContext)
-		return OperationResult{StatusCode: http.StatusInternalServerError}, nil, err
+		return OperationResultFromStatementError(err), nil, err
 	}
 
 	opReplacement, err := util.UnmarshalJson2BsonD(replacement, true)
 	if err != nil {
 		log.Error().Err(err).Msg(semLogContext)
-		return OperationResult{StatusCode: http.StatusInternalServerError}, nil, err
+		return OperationResultFromStatementError(err), nil, err
 	}
 
 	uo, err := mdboptions.ReplaceOptionsFromJson(opts)
 	if err != nil {
 		log.Error().Err(err).Msg(semLogContext)
-		return OperationResult{StatusCode: http.StatusInternalServerError}, nil, err
+		return OperationResultFromStatementError(err), nil, err
+	}
+
+	ctx, cancel, err := newOperationContext(ctx, lks, collectionId, ReplaceOneOperationType, opts)
+	if err != nil {
+		log.Error().Err(err).Msg(semLogContext)
+		return OperationResultFromStatementError(err), nil, err
 	}
+	defer cancel()
 
-	res, err := c.ReplaceOne(context.Background(), opFilter, opReplacement, uo)
+	res, err := c.ReplaceOne(ctx, opFilter, opReplacement, uo)
 	if err != nil {
-		mongoErrorCode := util.MongoErrorCode(err, util.MongoDbVersion{})
-		log.Error().Err(err).Int32("mongo-error", mongoErrorCode).Msg(semLogContext)
-		return OperationResult{StatusCode: int(-mongoErrorCode)}, nil, err
+		res := OperationResultFromError(err)
+		log.Error().Err(err).Int("status-code", res.StatusCode).Msg(semLogContext)
+		return res, nil, err
 	}
 
 	var b []byte
-	b, err = json.Marshal(res)
+	b, err = outputOptionsOf(output).MarshalWriteResult(res)
 	if err != nil {
 		log.Error().Err(err).Msg(semLogContext)
-		return OperationResult{StatusCode: http.StatusInternalServerError}, nil, err
+		return OperationResultFromError(err), nil, err
 	}
 
 	return OperationResultFromUpdateResult(res), b, nil
@@ -171,19 +212,40 @@ func (op *ReplaceOneOperation) NewWriteModel() (mongo.WriteModel, error) {
 		return nil, err
 	}
 
-	upsert := false
-	uo := options.ReplaceOptions{}
-	if len(op.Options) > 0 {
-		err = json.Unmarshal(op.Options, &uo)
-		if err != nil {
-			log.Error().Err(err).Msg(semLogContext)
-			return nil, err
-		}
+	jo, err := mdboptions.ParseJsonOptions(op.Options, mdboptions.ReplaceOptionNames)
+	if err != nil {
+		log.Error().Err(err).Msg(semLogContext)
+		return nil, err
+	}
 
-		if uo.Upsert != nil {
-			upsert = *uo.Upsert
-		}
+	wm := mongo.NewReplaceOneModel().SetFilter(statementFilter).SetReplacement(statementReplacement).SetUpsert(jo.IsUpsert())
+	if jo.Hint != nil {
+		wm.SetHint(jo.Hint)
+	}
+	if jo.Collation != nil {
+		wm.SetCollation(jo.Collation)
+	}
+
+	return wm, nil
+}
+
+func (op *ReplaceOneOperation) explainCommand(collectionName string) (bson.D, []byte, error) {
+	statementFilter, err := util.UnmarshalJson2BsonD(op.Filter, true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	statementReplacement, err := util.UnmarshalJson2Bson(op.Replacement, true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	jo, err := mdboptions.ParseJsonOptions(op.Options, mdboptions.ReplaceOptionNames)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return mongo.NewReplaceOneModel().SetFilter(statementFilter).SetReplacement(statementReplacement).SetUpsert(upsert), nil
+	stmt := bson.D{{Key: "q", Value: statementFilter}, {Key: "u", Value: statementReplacement}, {Key: "multi", Value: false}, {Key: "upsert", Value: jo.IsUpsert()}}
+	cmd := bson.D{{Key: "update", Value: collectionName}, {Key: "updates", Value: bson.A{explainStatementOptions(stmt, jo)}}}
+	return explainCommandOptions(cmd, jo), op.Options, nil
 }
@@ -0,0 +1,265 @@
+package jsonops
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/mongolks"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	ScriptOnNotFoundContinue = "continue"
+	ScriptOnNotFoundStop     = "stop"
+	ScriptOnNotFoundFail     = "fail"
+
+	ScriptReferenceProperty = "$ref"
+
+	scriptOutputProperty MongoJsonOperationStatementPart = "$output"
+)
+
+// scriptStepOutput is set on every step so that references to previous results keep the bson types (i.e. an _id is an ObjectID and not its hex string).
+var scriptStepOutput = []byte(`{ "format": "canonical" }`)
+
+var ErrScriptStepNotFound = errors.New("script step not found")
+
+var scriptConditionRegexp = regexp.MustCompile(`^(found|not-found)\(([^)]+)\)$`)
+
+// ScriptStep is a jsonops statement executed as part of a Script. The statement parts can reference values of previous results
+// with objects in the form { "$ref": "step-name.path.to.field" }.
+type ScriptStep struct {
+	Name         string                                              `yaml:"name,omitempty" mapstructure:"name,omitempty" json:"name,omitempty"`
+	OpType       MongoJsonOperationType                              `yaml:"op-type,omitempty" mapstructure:"op-type,omitempty" json:"op-type,omitempty"`
+	CollectionId string                                              `yaml:"collection-id,omitempty" mapstructure:"collection-id,omitempty" json:"collection-id,omitempty"`
+	When         string                                              `yaml:"when,omitempty" mapstructure:"when,omitempty" json:"when,omitempty"`
+	OnNotFound   string                                              `yaml:"on-not-found,omitempty" mapstructure:"on-not-found,omitempty" json:"on-not-found,omitempty"`
+	Statement    map[MongoJsonOperationStatementPart]json.RawMessage `yaml:"statement,omitempty" mapstructure:"statement,omitempty" json:"statement,omitempty"`
+}
+
+type Script struct {
+	Name        string       `yaml:"name,omitempty" mapstructure:"name,omitempty" json:"name,omitempty"`
+	Transaction bool         `yaml:"transaction,omitempty" mapstructure:"transaction,omitempty" json:"transaction,omitempty"`
+	Steps       []ScriptStep `yaml:"steps,omitempty" mapstructure:"steps,omitempty" json:"steps,omitempty"`
+}
+
+type ScriptStepResult struct {
+	Name    string
+	Skipped bool
+	Result  OperationResult
+	Body    []byte // the step output, always in canonical extended json
+}
+
+func NewScriptFromJson(data []byte) (*Script, error) {
+	const semLogContext = "json-ops::new-script"
+
+	var s Script
+	err := json.Unmarshal(data, &s)
+	if err != nil {
+		log.Error().Err(err).Msg(semLogContext)
+		return nil, err
+	}
+
+	err = s.Validate()
+	if err != nil {
+		log.Error().Err(err).Str("script", s.Name).Msg(semLogContext)
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// Validate checks the steps are well-formed operations and that conditions and references only point to previous steps.
+func (s *Script) Validate() error {
+	if len(s.Steps) == 0 {
+		return errors.New("script has no steps")
+	}
+
+	names := make(map[string]bool)
+	for i, step := range s.Steps {
+		if step.Name == "" {
+			return fmt.Errorf("script step #%d has no name", i)
+		}
+
+		if names[step.Name] {
+			return fmt.Errorf("script step %s is duplicated", step.Name)
+		}
+
+		if step.When != "" {
+			_, ref, err := parseScriptCondition(step.When)
+			if err != nil {
+				return err
+			}
+			if !names[ref] {
+				return fmt.Errorf("script step %s condition references unknown step %s", step.Name, ref)
+			}
+		}
+
+		switch step.OnNotFound {
+		case "", ScriptOnNotFoundContinue, ScriptOnNotFoundStop, ScriptOnNotFoundFail:
+		default:
+			return fmt.Errorf("script step %s has invalid on-not-found policy %s", step.Name, step.OnNotFound)
+		}
+
+		_, err := step.newOperation(func(ref string) ([]byte, error) {
+			stepName, _, _ := strings.Cut(ref, ".")
+			if !names[stepName] {
+				return nil, fmt.Errorf("script step %s references unknown step %s", step.Name, stepName)
+			}
+			return []byte("null"), nil
+		})
+		if err != nil {
+			return err
+		}
+
+		names[step.Name] = true
+	}
+
+	return nil
+}
+
+func (s *Script) Execute(lks *mongolks.LinkedService) ([]ScriptStepResult, error) {
+	return s.ExecuteContext(context.Background(), lks)
+}
+
+func (s *Script) ExecuteContext(ctx context.Context, lks *mongolks.LinkedService) ([]ScriptStepResult, error) {
+	const semLogContext = "json-ops::execute-script"
+
+	if !s.Transaction {
+		return s.executeSteps(ctx, lks)
+	}
+
+	var results []ScriptStepResult
+	err := withTransaction(ctx, lks, func(txCtx context.Context) error {
+		var txErr error
+		results, txErr = s.executeSteps(txCtx, lks)
+		return txErr
+	})
+	if err != nil {
+		log.Error().Err(err).Str("script", s.Name).Msg(semLogContext)
+	}
+
+	return results, err
+}
+
+func (s *Script) executeSteps(ctx context.Context, lks *mongolks.LinkedService) ([]ScriptStepResult, error) {
+	const semLogContext = "json-ops::execute-script-steps"
+
+	results := make([]ScriptStepResult, 0, len(s.Steps))
+	statusCodes := make(map[string]int)
+	docs := make(map[string]interface{})
+	for _, step := range s.Steps {
+		if step.When != "" {
+			cond, ref, _ := parseScriptCondition(step.When)
+			notFound := statusCodes[ref] == http.StatusNotFound
+			if _, executed := statusCodes[ref]; !executed || (cond == "found" && notFound) || (cond == "not-found" && !notFound) {
+				results = append(results, ScriptStepResult{Name: step.Name, Skipped: true})
+				continue
+			}
+		}
+
+		op, err := step.newOperation(func(ref string) ([]byte, error) {
+			return resolveScriptReference(ref, docs)
+		})
+		if err != nil {
+			log.Error().Err(err).Str("step", step.Name).Msg(semLogContext)
+			results = append(results, ScriptStepResult{Name: step.Name, Result: OperationResultFromStatementError(err)})
+			return results, err
+		}
+
+		res, body, err := op.ExecuteContext(ctx, lks, step.CollectionId)
+		results = append(results, ScriptStepResult{Name: step.Name, Result: res, Body: body})
+		if err != nil {
+			log.Error().Err(err).Str("step", step.Name).Msg(semLogContext)
+			return results, err
+		}
+
+		statusCodes[step.Name] = res.StatusCode
+		if res.StatusCode == http.StatusNotFound {
+			switch step.OnNotFound {
+			case ScriptOnNotFoundStop:
+				return results, nil
+			case ScriptOnNotFoundFail:
+				err = fmt.Errorf("%w: %s", ErrScriptStepNotFound, step.Name)
+				log.Error().Err(err).Msg(semLogContext)
+				return results, err
+			}
+		}
+
+		if len(body) > 0 {
+			var doc interface{}
+			dec := json.NewDecoder(bytes.NewReader(body))
+			dec.UseNumber()
+			if err = dec.Decode(&doc); err != nil {
+				log.Error().Err(err).Str("step", step.Name).Msg(semLogContext)
+				return results, err
+			}
+			docs[step.Name] = doc
+		}
+	}
+
+	return results, nil
+}
+
+// newOperation resolves the references of the statement. A user provided $output is rejected: the output of a step is always
+// canonical, the references to it would otherwise resolve to a different representation depending on the step.
+func (step *ScriptStep) newOperation(resolver func(ref string) ([]byte, error)) (Operation, error) {
+	if _, ok := step.Statement[scriptOutputProperty]; ok {
+		return nil, fmt.Errorf("%w: script step %s cannot set %s", ErrInvalidStatement, step.Name, scriptOutputProperty)
+	}
+
+	m := make(map[MongoJsonOperationStatementPart][]byte)
+	for part, data := range step.Statement {
+		resolved, err := rewriteReferences(data, ScriptReferenceProperty, resolver)
+		if err != nil {
+			return nil, err
+		}
+		m[part] = resolved
+	}
+	m[scriptOutputProperty] = scriptStepOutput
+
+	return NewOperation(step.OpType, m)
+}
+
+func parseScriptCondition(cond string) (string, string, error) {
+	matches := scriptConditionRegexp.FindStringSubmatch(strings.TrimSpace(cond))
+	if len(matches) != 3 {
+		return "", "", fmt.Errorf("invalid script condition %s", cond)
+	}
+
+	return matches[1], strings.TrimSpace(matches[2]), nil
+}
+
+// resolveScriptReference looks up a path in the parsed result of a previous step. Numeric segments index arrays.
+func resolveScriptReference(ref string, docs map[string]interface{}) ([]byte, error) {
+	segments := strings.Split(ref, ".")
+	v, ok := docs[segments[0]]
+	if !ok {
+		return nil, fmt.Errorf("script reference %s: step %s has no result", ref, segments[0])
+	}
+
+	for _, seg := range segments[1:] {
+		switch tv := v.(type) {
+		case map[string]interface{}:
+			if v, ok = tv[seg]; !ok {
+				return nil, fmt.Errorf("script reference %s: field %s not found", ref, seg)
+			}
+		case []interface{}:
+			ndx, err := strconv.Atoi(seg)
+			if err != nil || ndx < 0 || ndx >= len(tv) {
+				return nil, fmt.Errorf("script reference %s: invalid index %s", ref, seg)
+			}
+			v = tv[ndx]
+		default:
+			return nil, fmt.Errorf("script reference %s: cannot traverse %s", ref, seg)
+		}
+	}
+
+	return json.Marshal(v)
+}
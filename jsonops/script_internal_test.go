@@ -0,0 +1,69 @@
+package jsonops
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveScriptReference(t *testing.T) {
+	var doc interface{}
+	require.NoError(t, json.Unmarshal([]byte(`{ "_id": { "$oid": "65a0f0f0f0f0f0f0f0f0f0f0" }, "items": [ { "code": "a" }, { "code": "b" } ], "n": null }`), &doc))
+	docs := map[string]interface{}{"step1": doc}
+
+	var cases = []struct {
+		ref     string
+		want    string
+		wantErr bool
+	}{
+		{ref: "step1._id", want: `{"$oid":"65a0f0f0f0f0f0f0f0f0f0f0"}`},
+		{ref: "step1._id.$oid", want: `"65a0f0f0f0f0f0f0f0f0f0f0"`},
+		{ref: "step1.items.1.code", want: `"b"`},
+		{ref: "step1.n", want: `null`},
+		{ref: "step1.items.2", wantErr: true},
+		{ref: "step1.items.code", wantErr: true},
+		{ref: "step1.missing", wantErr: true},
+		{ref: "step1.n.missing", wantErr: true},
+		{ref: "step2._id", wantErr: true},
+	}
+
+	for _, c := range cases {
+		b, err := resolveScriptReference(c.ref, docs)
+		if c.wantErr {
+			require.Error(t, err, c.ref)
+			continue
+		}
+		require.NoError(t, err, c.ref)
+		require.JSONEq(t, c.want, string(b), c.ref)
+	}
+}
+
+func TestRewriteReferences(t *testing.T) {
+	resolver := func(ref string) ([]byte, error) {
+		return json.Marshal("resolved:" + ref)
+	}
+
+	var cases = []struct {
+		data string
+		want string
+	}{
+		{data: `{ "a": { "$ref": "s.x" } }`, want: `{ "a": "resolved:s.x" }`},
+		{data: `{ "a": { "b": [ 1, { "$ref": "s.y.0" } ] } }`, want: `{ "a": { "b": [ 1, "resolved:s.y.0" ] } }`},
+		{data: `[ { "$ref": "s.z" } ]`, want: `[ "resolved:s.z" ]`},
+		// a dbref-like document has more than the reference key and is left untouched.
+		{data: `{ "a": { "$ref": "coll", "$id": { "$oid": "65a0f0f0f0f0f0f0f0f0f0f0" } } }`, want: `{ "a": { "$ref": "coll", "$id": { "$oid": "65a0f0f0f0f0f0f0f0f0f0f0" } } }`},
+		// a non string reference is not a reference.
+		{data: `{ "a": { "$ref": 1 } }`, want: `{ "a": { "$ref": 1 } }`},
+		{data: `{}`, want: `{}`},
+	}
+
+	for _, c := range cases {
+		b, err := rewriteReferences([]byte(c.data), ScriptReferenceProperty, resolver)
+		require.NoError(t, err, c.data)
+		require.JSONEq(t, c.want, string(b), c.data)
+	}
+
+	_, err := rewriteReferences([]byte(`{ "a": 1 } { "b": 2 }`), ScriptReferenceProperty, resolver)
+	require.Error(t, err)
+}
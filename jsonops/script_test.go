@@ -0,0 +1,113 @@
+package jsonops_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/jsonops"
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/mongolks"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+const scriptExample = `{
+  "name": "upsert-and-audit",
+  "transaction": true,
+  "steps": [
+    { "name": "step1", "op-type": "find-one", "collection-id": "test", "statement": { "$query": { "code": "A01" } } },
+    { "name": "step2", "op-type": "update-one", "collection-id": "test", "when": "found(step1)",
+      "statement": { "$filter": { "_id": { "$ref": "step1._id" } }, "$update": { "$set": { "status": "done" } } } },
+    { "name": "step3", "op-type": "insert-one", "collection-id": "test", "when": "not-found(step1)",
+      "statement": { "$document": { "code": "A01", "status": "new" } } },
+    { "name": "audit", "op-type": "insert-one", "collection-id": "audit",
+      "statement": { "$document": { "ref": { "$ref": "step1._id" }, "items": [ { "$ref": "step1.items.0" } ] } } }
+  ]
+}`
+
+func TestScript(t *testing.T) {
+	s, err := jsonops.NewScriptFromJson([]byte(scriptExample))
+	require.NoError(t, err)
+	require.True(t, s.Transaction)
+	require.Len(t, s.Steps, 4)
+
+	_, err = jsonops.NewScriptFromJson([]byte(`{ "steps": [
+		{ "name": "step1", "op-type": "insert-one", "collection-id": "test", "statement": { "$document": { "ref": { "$ref": "step2._id" } } } },
+		{ "name": "step2", "op-type": "find-one", "collection-id": "test", "statement": { "$query": {} } } ] }`))
+	require.Error(t, err, "forward reference")
+
+	_, err = jsonops.NewScriptFromJson([]byte(`{ "steps": [
+		{ "name": "step1", "op-type": "find-one", "collection-id": "test", "when": "exists(step0)", "statement": { "$query": {} } } ] }`))
+	require.Error(t, err, "invalid condition")
+
+	_, err = jsonops.NewScriptFromJson([]byte(`{ "steps": [
+		{ "name": "step1", "op-type": "find-one", "collection-id": "test", "on-not-found": "retry", "statement": { "$query": {} } } ] }`))
+	require.Error(t, err, "invalid on-not-found policy")
+
+	_, err = jsonops.NewScriptFromJson([]byte(`{ "steps": [
+		{ "name": "step1", "op-type": "find-many", "collection-id": "test", "statement": { "$query": {} } } ] }`))
+	require.Error(t, err, "invalid op type")
+}
+
+func TestScriptRejectsOutput(t *testing.T) {
+	_, err := jsonops.NewScriptFromJson([]byte(`{ "steps": [
+		{ "name": "step1", "op-type": "find-one", "collection-id": "test", "statement": { "$query": {}, "$output": { "format": "relaxed" } } } ] }`))
+	require.ErrorIs(t, err, jsonops.ErrInvalidStatement)
+}
+
+const scriptConditionsExample = `{
+  "name": "find-or-insert",
+  "steps": [
+    { "name": "find", "op-type": "find-one", "collection-id": "%s", "statement": { "$query": { "code": "script-test" } } },
+    { "name": "insert", "op-type": "insert-one", "collection-id": "%s", "when": "not-found(find)",
+      "statement": { "$document": { "code": "script-test", "status": "new" } } },
+    { "name": "update", "op-type": "update-one", "collection-id": "%s", "when": "found(find)",
+      "statement": { "$filter": { "_id": { "$ref": "find._id" } }, "$update": { "$set": { "status": "done" } } } }
+  ]
+}`
+
+func TestScriptExecuteConditions(t *testing.T) {
+	lks, err := mongolks.GetLinkedService(context.Background(), "default")
+	require.NoError(t, err)
+
+	coll, err := mongolks.GetCollection(context.Background(), "default", CollectionId)
+	require.NoError(t, err)
+	_, err = coll.DeleteMany(context.Background(), bson.D{{Key: "code", Value: "script-test"}})
+	require.NoError(t, err)
+
+	s, err := jsonops.NewScriptFromJson([]byte(fmt.Sprintf(scriptConditionsExample, CollectionId, CollectionId, CollectionId)))
+	require.NoError(t, err)
+
+	// first run: not found, the insert is executed and the update skipped.
+	results, err := s.Execute(lks)
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	require.Equal(t, http.StatusNotFound, results[0].Result.StatusCode)
+	require.False(t, results[1].Skipped)
+	require.True(t, results[2].Skipped)
+
+	// second run: found, the insert is skipped and the update resolves the _id of the find.
+	results, err = s.Execute(lks)
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	require.Equal(t, http.StatusOK, results[0].Result.StatusCode)
+	require.True(t, results[1].Skipped)
+	require.False(t, results[2].Skipped)
+	require.Equal(t, int64(1), results[2].Result.ModifiedCount)
+
+	_, err = coll.DeleteMany(context.Background(), bson.D{{Key: "code", Value: "script-test"}})
+	require.NoError(t, err)
+
+	// on-not-found stop ends the script without errors, fail returns an ErrScriptStepNotFound.
+	for _, policy := range []string{jsonops.ScriptOnNotFoundStop, jsonops.ScriptOnNotFoundFail} {
+		s.Steps[0].OnNotFound = policy
+		results, err = s.Execute(lks)
+		require.Len(t, results, 1, policy)
+		if policy == jsonops.ScriptOnNotFoundFail {
+			require.ErrorIs(t, err, jsonops.ErrScriptStepNotFound)
+		} else {
+			require.NoError(t, err)
+		}
+	}
+}
@@ -0,0 +1,410 @@
+package jsonops
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/mongolks"
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/util"
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/util/mdboptions"
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+const (
+	MongoActivitySyncManyOpProperty            MongoJsonOperationStatementPart = "$op"
+	MongoActivitySyncManyDocumentsProperty     MongoJsonOperationStatementPart = "$documents"
+	MongoActivitySyncManyKeyProperty           MongoJsonOperationStatementPart = "$key"
+	MongoActivitySyncManyScopeProperty         MongoJsonOperationStatementPart = "$scope"
+	MongoActivitySyncManyDeleteMissingProperty MongoJsonOperationStatementPart = "$delete-missing"
+	MongoActivitySyncManyOptsProperty          MongoJsonOperationStatementPart = "$opts"
+	MongoActivitySyncManyOutputProperty        MongoJsonOperationStatementPart = "$output"
+)
+
+// SyncManyOperation upserts the documents by the value at the key path (i.e. "code" or "ref.code"). With delete-missing set to true
+// the documents of the scope filter whose key is not in the input are deleted: in that case both the documents and a non empty scope
+// are required. The bulk write and the deletion are two distinct commands and the sync is not atomic: a failure of the deletion
+// leaves the upserts in place, a concurrent writer may see the collection in between.
+type SyncManyOperation struct {
+	Documents     []byte `yaml:"documents,omitempty" json:"documents,omitempty" mapstructure:"documents,omitempty"`
+	Key           []byte `yaml:"key,omitempty" json:"key,omitempty" mapstructure:"key,omitempty"`
+	Scope         []byte `yaml:"scope,omitempty" json:"scope,omitempty" mapstructure:"scope,omitempty"`
+	DeleteMissing []byte `yaml:"delete-missing,omitempty" json:"delete-missing,omitempty" mapstructure:"delete-missing,omitempty"`
+	Options       []byte `yaml:"options,omitempty" json:"options,omitempty" mapstructure:"options,omitempty"`
+	Output        []byte `yaml:"output,omitempty" json:"output,omitempty" mapstructure:"output,omitempty"`
+}
+
+// SyncManyResult is the body returned by the sync-many operation.
+type SyncManyResult struct {
+	InsertedCount  int64
+	UpdatedCount   int64
+	UnchangedCount int64
+	DeletedCount   int64
+}
+
+func (op *SyncManyOperation) OpType() MongoJsonOperationType {
+	return SyncManyOperationType
+}
+
+func (op *SyncManyOperation) ToString() string {
+	var sb strings.Builder
+	numberOfElements := 0
+	sb.WriteString("{")
+	if len(op.Documents) > 0 {
+		numberOfElements++
+		sb.WriteString(fmt.Sprintf("\"%s\": ", MongoActivitySyncManyDocumentsProperty))
+		sb.WriteString(string(op.Documents))
+	}
+	if len(op.Key) > 0 {
+		if numberOfElements > 0 {
+			sb.WriteString(",")
+		}
+		numberOfElements++
+		sb.WriteString(fmt.Sprintf("\"%s\": ", MongoActivitySyncManyKeyProperty))
+		sb.WriteString(string(op.Key))
+	}
+	if len(op.Scope) > 0 {
+		if numberOfElements > 0 {
+			sb.WriteString(",")
+		}
+		numberOfElements++
+		sb.WriteString(fmt.Sprintf("\"%s\": ", MongoActivitySyncManyScopeProperty))
+		sb.WriteString(string(op.Scope))
+	}
+	if len(op.DeleteMissing) > 0 {
+		if numberOfElements > 0 {
+			sb.WriteString(",")
+		}
+		numberOfElements++
+		sb.WriteString(fmt.Sprintf("\"%s\": ", MongoActivitySyncManyDeleteMissingProperty))
+		sb.WriteString(string(op.DeleteMissing))
+	}
+	if len(op.Options) > 0 {
+		if numberOfElements > 0 {
+			sb.WriteString(",")
+		}
+		numberOfElements++
+		sb.WriteString(fmt.Sprintf("\"%s\": ", MongoActivitySyncManyOptsProperty))
+		sb.WriteString(string(op.Options))
+	}
+	if len(op.Output) > 0 {
+		if numberOfElements > 0 {
+			sb.WriteString(",")
+		}
+		numberOfElements++
+		sb.WriteString(fmt.Sprintf("\"%s\": ", MongoActivitySyncManyOutputProperty))
+		sb.WriteString(string(op.Output))
+	}
+
+	sb.WriteString("}")
+	return sb.String()
+}
+
+func NewSyncManyOperation(m map[MongoJsonOperationStatementPart][]byte) (*SyncManyOperation, error) {
+	foStmt, err := NewSyncManyStatementConfigFromJson(m[MongoActivitySyncManyOpProperty])
+	if err != nil {
+		return nil, err
+	}
+
+	if data, ok := m[MongoActivitySyncManyDocumentsProperty]; ok {
+		foStmt.Documents = data
+	}
+
+	if data, ok := m[MongoActivitySyncManyKeyProperty]; ok {
+		foStmt.Key = data
+	}
+
+	if data, ok := m[MongoActivitySyncManyScopeProperty]; ok {
+		foStmt.Scope = data
+	}
+
+	if data, ok := m[MongoActivitySyncManyDeleteMissingProperty]; ok {
+		foStmt.DeleteMissing = data
+	}
+
+	if data, ok := m[MongoActivitySyncManyOptsProperty]; ok {
+		foStmt.Options = data
+	}
+
+	if data, ok := m[MongoActivitySyncManyOutputProperty]; ok {
+		foStmt.Output = data
+	}
+
+	return &foStmt, nil
+}
+
+func NewSyncManyStatementConfigFromJson(data []byte) (SyncManyOperation, error) {
+
+	if len(data) == 0 {
+		return SyncManyOperation{}, nil
+	}
+
+	var m map[MongoJsonOperationStatementPart]json.RawMessage
+	err := json.Unmarshal(data, &m)
+	if err != nil {
+		return SyncManyOperation{}, err
+	}
+
+	fo := SyncManyOperation{
+		Documents:     m[MongoActivitySyncManyDocumentsProperty],
+		Key:           m[MongoActivitySyncManyKeyProperty],
+		Scope:         m[MongoActivitySyncManyScopeProperty],
+		DeleteMissing: m[MongoActivitySyncManyDeleteMissingProperty],
+		Options:       m[MongoActivitySyncManyOptsProperty],
+		Output:        m[MongoActivitySyncManyOutputProperty],
+	}
+
+	return fo, nil
+}
+
+func (op *SyncManyOperation) Execute(lks *mongolks.LinkedService, collectionId string) (OperationResult, []byte, error) {
+	return op.ExecuteContext(context.Background(), lks, collectionId)
+}
+
+func (op *SyncManyOperation) ExecuteContext(ctx context.Context, lks *mongolks.LinkedService, collectionId string) (res OperationResult, resp []byte, err error) {
+	ctx, endSpan := startOperationSpan(ctx, lks, op.OpType(), collectionId)
+	defer func() { endSpan(res, resp, err) }()
+
+	oo, err := NewOutputOptionsFromJson(op.Output)
+	if err != nil {
+		return OperationResultFromStatementError(err), nil, err
+	}
+
+	key, deleteMissing, err := op.syncParameters()
+	if err != nil {
+		return OperationResultFromStatementError(err), nil, err
+	}
+
+	sc, resp, err := SyncManyContext(ctx, lks, collectionId, op.Documents, key, op.Scope, deleteMissing, op.Options, oo)
+	return sc, resp, err
+}
+
+func (op *SyncManyOperation) syncParameters() (string, bool, error) {
+	var key string
+	if err := json.Unmarshal(op.Key, &key); err != nil {
+		return "", false, fmt.Errorf("sync-many key must be a string: %w", err)
+	}
+
+	var deleteMissing bool
+	if len(op.DeleteMissing) > 0 {
+		if err := json.Unmarshal(op.DeleteMissing, &deleteMissing); err != nil {
+			return "", false, fmt.Errorf("sync-many delete-missing must be a boolean: %w", err)
+		}
+	}
+
+	return key, deleteMissing, nil
+}
+
+// newSyncManyOperation rebuilds the statement of the parameters of SyncManyContext, the form checked by the policy.
+func newSyncManyOperation(documents []byte, key string, scope []byte, deleteMissing bool, opts []byte) *SyncManyOperation {
+	k, _ := json.Marshal(key)
+	return &SyncManyOperation{Documents: documents, Key: k, Scope: scope, DeleteMissing: []byte(strconv.FormatBool(deleteMissing)), Options: opts}
+}
+
+func SyncMany(lks *mongolks.LinkedService, collectionId string, documents []byte, key string, scope []byte, deleteMissing bool, opts []byte, output ...OutputOptions) (OperationResult, []byte, error) {
+	return SyncManyContext(context.Background(), lks, collectionId, documents, key, scope, deleteMissing, opts, output...)
+}
+
+func SyncManyContext(ctx context.Context, lks *mongolks.LinkedService, collectionId string, documents []byte, key string, scope []byte, deleteMissing bool, opts []byte, output ...OutputOptions) (OperationResult, []byte, error) {
+	const semLogContext = "json-ops::sync-many"
+	var err error
+
+	docs, err := util.UnmarshalJson2ArrayOfBsonD(documents, true)
+	if err != nil {
+		log.Error().Err(err).Msg(semLogContext)
+		return OperationResultFromStatementError(err), nil, err
+	}
+
+	models, keys, err := NewSyncManyWriteModels(docs, key)
+	if err != nil {
+		log.Error().Err(err).Msg(semLogContext)
+		return OperationResultFromStatementError(err), nil, err
+	}
+
+	statementScope, err := util.UnmarshalJson2BsonD(scope, true)
+	if err != nil {
+		log.Error().Err(err).Msg(semLogContext)
+		return OperationResultFromStatementError(err), nil, err
+	}
+
+	if deleteMissing {
+		if err = checkSyncManyDeleteMissing(docs, statementScope); err != nil {
+			log.Error().Err(err).Msg(semLogContext)
+			return OperationResultFromError(err), nil, err
+		}
+	}
+
+	if err := checkPolicy(lks, collectionId, newSyncManyOperation(documents, key, scope, deleteMissing, opts)); err != nil {
+		return OperationResultFromError(err), nil, err
+	}
+
+	c := lks.GetCollection(collectionId, "")
+	if c == nil {
+		err = errors.New("cannot find requested collection")
+		log.Error().Err(err).Str("collection", collectionId).Msg(semLogContext)
+		return OperationResultFromError(err), nil, err
+	}
+
+	bo, err := mdboptions.BulkWriteOptionsFromJson(opts)
+	if err != nil {
+		log.Error().Err(err).Msg(semLogContext)
+		return OperationResultFromStatementError(err), nil, err
+	}
+
+	ctx, cancel, err := newOperationContext(ctx, lks, collectionId, SyncManyOperationType, opts)
+	if err != nil {
+		log.Error().Err(err).Msg(semLogContext)
+		return OperationResultFromStatementError(err), nil, err
+	}
+	defer cancel()
+
+	var syncRes SyncManyResult
+	if len(models) > 0 {
+		br, err := c.BulkWrite(ctx, models, bo)
+		if err != nil {
+			res := OperationResultFromError(err)
+			log.Error().Err(err).Int("status-code", res.StatusCode).Msg(semLogContext)
+			return res, nil, err
+		}
+
+		syncRes.InsertedCount = br.UpsertedCount
+		syncRes.UpdatedCount = br.ModifiedCount
+		syncRes.UnchangedCount = br.MatchedCount - br.ModifiedCount
+	}
+
+	// the deletion runs only after all the upserts succeeded: a partial load must never remove documents. The two steps are not
+	// atomic, run the operation with ExecuteInTransaction when that matters.
+	if deleteMissing {
+		dr, err := c.DeleteMany(ctx, syncManyDeleteMissingFilter(statementScope, key, keys))
+		if err != nil {
+			res := OperationResultFromError(err)
+			log.Error().Err(err).Int("status-code", res.StatusCode).Msg(semLogContext)
+			return res, nil, err
+		}
+
+		syncRes.DeletedCount = dr.DeletedCount
+	}
+
+	var b []byte
+	b, err = outputOptionsOf(output).MarshalWriteResult(syncRes)
+	if err != nil {
+		log.Error().Err(err).Msg(semLogContext)
+		return OperationResultFromError(err), nil, err
+	}
+
+	return OperationResult{
+		StatusCode:    http.StatusOK,
+		MatchedCount:  syncRes.UpdatedCount + syncRes.UnchangedCount,
+		ModifiedCount: syncRes.UpdatedCount,
+		UpsertedCount: syncRes.InsertedCount,
+		DeletedCount:  syncRes.DeletedCount,
+	}, b, nil
+}
+
+// NewSyncManyWriteModels returns a replace-one upsert model for each document, filtered by the value at the key path, and the list of the key values.
+// Documents without the key and duplicated keys are rejected.
+func NewSyncManyWriteModels(docs []bson.D, key string) ([]mongo.WriteModel, bson.A, error) {
+	if key == "" {
+		return nil, nil, errors.New("sync-many requires a key path")
+	}
+
+	models := make([]mongo.WriteModel, 0, len(docs))
+	keys := make(bson.A, 0, len(docs))
+	seen := make(map[string]bool, len(docs))
+	for i, doc := range docs {
+		v, ok := util.LookupDocumentPath(doc, key)
+		if !ok {
+			return nil, nil, fmt.Errorf("sync-many document #%d has no key %s", i, key)
+		}
+
+		k, err := bson.MarshalExtJSON(bson.D{{Key: "k", Value: v}}, true, false)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if seen[string(k)] {
+			return nil, nil, fmt.Errorf("sync-many document #%d has duplicated key %s", i, string(k))
+		}
+		seen[string(k)] = true
+
+		keys = append(keys, v)
+		models = append(models, mongo.NewReplaceOneModel().SetFilter(bson.D{{Key: key, Value: v}}).SetReplacement(doc).SetUpsert(true))
+	}
+
+	return models, keys, nil
+}
+
+// checkSyncManyDeleteMissing rejects a delete-missing with no documents, the $nin clause would be empty and the deletion would hit the
+// whole scope, or with no scope.
+func checkSyncManyDeleteMissing(docs []bson.D, scope bson.D) error {
+	switch {
+	case len(docs) == 0:
+		return fmt.Errorf("%w: sync-many delete-missing requires at least one document", ErrInvalidStatement)
+	case len(scope) == 0:
+		return fmt.Errorf("%w: sync-many delete-missing requires a non empty scope", ErrInvalidStatement)
+	}
+
+	return nil
+}
+
+func syncManyDeleteMissingFilter(scope bson.D, key string, keys bson.A) bson.D {
+	return bson.D{{Key: "$and", Value: bson.A{scope, bson.D{{Key: key, Value: bson.D{{Key: "$nin", Value: keys}}}}}}}
+}
+
+func (op *SyncManyOperation) NewWriteModel() (mongo.WriteModel, error) {
+	panic("new write model not supported in sync-many operations")
+}
+
+// explainCommands returns an update command for each upsert of the bulk write, the same filter and replacement of the write models,
+// followed, with delete-missing, by the delete command of the documents not in the input.
+func (op *SyncManyOperation) explainCommands(collectionName string) ([]bson.D, []byte, error) {
+	key, deleteMissing, err := op.syncParameters()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	docs, err := util.UnmarshalJson2ArrayOfBsonD(op.Documents, true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	_, keys, err := NewSyncManyWriteModels(docs, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	statementScope, err := util.UnmarshalJson2BsonD(op.Scope, true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	jo, err := mdboptions.ParseJsonOptions(op.Options, mdboptions.BulkWriteOptionNames)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cmds := make([]bson.D, 0, len(docs)+1)
+	for i, doc := range docs {
+		stmt := bson.D{{Key: "q", Value: bson.D{{Key: key, Value: keys[i]}}}, {Key: "u", Value: doc}, {Key: "multi", Value: false}, {Key: "upsert", Value: true}}
+		cmd := bson.D{{Key: "update", Value: collectionName}, {Key: "updates", Value: bson.A{stmt}}}
+		cmds = append(cmds, explainCommandOptions(cmd, jo))
+	}
+
+	if deleteMissing {
+		if err = checkSyncManyDeleteMissing(docs, statementScope); err != nil {
+			return nil, nil, err
+		}
+
+		stmt := bson.D{{Key: "q", Value: syncManyDeleteMissingFilter(statementScope, key, keys)}, {Key: "limit", Value: int32(0)}}
+		cmd := bson.D{{Key: "delete", Value: collectionName}, {Key: "deletes", Value: bson.A{stmt}}}
+		cmds = append(cmds, explainCommandOptions(cmd, jo))
+	}
+
+	return cmds, op.Options, nil
+}
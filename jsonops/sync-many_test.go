@@ -0,0 +1,54 @@
+package jsonops_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/jsonops"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+func TestNewSyncManyWriteModels(t *testing.T) {
+	docs := []bson.D{
+		{{Key: "ref", Value: bson.D{{Key: "code", Value: "A01"}}}, {Key: "descr", Value: "first"}},
+		{{Key: "ref", Value: bson.D{{Key: "code", Value: "A02"}}}, {Key: "descr", Value: "second"}},
+	}
+
+	models, keys, err := jsonops.NewSyncManyWriteModels(docs, "ref.code")
+	require.NoError(t, err)
+	require.Len(t, models, 2)
+	require.Equal(t, bson.A{"A01", "A02"}, keys)
+
+	m, ok := models[1].(*mongo.ReplaceOneModel)
+	require.True(t, ok)
+	require.Equal(t, bson.D{{Key: "ref.code", Value: "A02"}}, m.Filter)
+	require.True(t, *m.Upsert)
+
+	_, _, err = jsonops.NewSyncManyWriteModels(append(docs, bson.D{{Key: "descr", Value: "no key"}}), "ref.code")
+	require.Error(t, err)
+
+	_, _, err = jsonops.NewSyncManyWriteModels(append(docs, docs[0]), "ref.code")
+	require.Error(t, err)
+}
+
+func TestSyncManyDeleteMissingRequiresInput(t *testing.T) {
+	cases := []struct {
+		documents []byte
+		scope     []byte
+	}{
+		{nil, []byte(`{ "status": "active" }`)},
+		{[]byte(`[]`), []byte(`{ "status": "active" }`)},
+		{[]byte(`[ { "code": "A01" } ]`), nil},
+		{[]byte(`[ { "code": "A01" } ]`), []byte(`{}`)},
+	}
+
+	// the statement is rejected before the linked service is used.
+	for i, c := range cases {
+		res, _, err := jsonops.SyncManyContext(context.Background(), nil, CollectionId, c.documents, "code", c.scope, true, nil)
+		require.ErrorIs(t, err, jsonops.ErrInvalidStatement, "case #%d", i)
+		require.Equal(t, http.StatusBadRequest, res.StatusCode, "case #%d", i)
+	}
+}
@@ -0,0 +1,115 @@
+package jsonops
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/mongolks"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	TelemetryInstrumentationName = "tpm-mongo-common/jsonops"
+
+	OperationDurationMetric = "jsonops.operation.duration"
+	OperationErrorsMetric   = "jsonops.operation.errors"
+
+	AttributeOpType        = "jsonops.op-type"
+	AttributeCollectionId  = "jsonops.collection-id"
+	AttributeLks           = "jsonops.lks"
+	AttributeStatusCode    = "jsonops.status-code"
+	AttributeMatchedCount  = "jsonops.matched-count"
+	AttributeModifiedCount = "jsonops.modified-count"
+	AttributeUpsertedCount = "jsonops.upserted-count"
+	AttributeDeletedCount  = "jsonops.deleted-count"
+	AttributeResultSize    = "jsonops.result-size"
+)
+
+var DefaultOperationDurationBuckets = []float64{1, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000}
+
+type operationMetrics struct {
+	Duration metric.Int64Histogram
+	Errors   metric.Int64Histogram
+}
+
+var (
+	theOperationMetrics     operationMetrics
+	theOperationMetricsOnce sync.Once
+)
+
+// getOperationMetrics creates the instruments on first use: the global meter provider delegates to the one set up by the application.
+func getOperationMetrics() operationMetrics {
+	theOperationMetricsOnce.Do(func() {
+		meter := otel.Meter(TelemetryInstrumentationName)
+
+		theOperationMetrics.Duration, _ = meter.Int64Histogram(
+			OperationDurationMetric,
+			metric.WithUnit("ms"),
+			metric.WithExplicitBucketBoundaries(DefaultOperationDurationBuckets...),
+			metric.WithDescription("Duration of the execution of a json operation"))
+
+		// every failed operation records a 1: the count of the histogram is the error count, its buckets are not meaningful.
+		theOperationMetrics.Errors, _ = meter.Int64Histogram(
+			OperationErrorsMetric,
+			metric.WithUnit("{error}"),
+			metric.WithExplicitBucketBoundaries(1),
+			metric.WithDescription("Json operations ended with an error"))
+	})
+
+	return theOperationMetrics
+}
+
+// startOperationSpan starts the span of a json operation, named after the op-type and the collection id. The span is internal: it is
+// the logical parent of the client spans of the driver instrumentation. The returned function ends the span with the counts of the
+// result and records the duration and error metrics in the context of the span.
+func startOperationSpan(ctx context.Context, lks *mongolks.LinkedService, opType MongoJsonOperationType, collectionId string) (context.Context, func(res OperationResult, body []byte, err error)) {
+	startTime := time.Now()
+
+	lksName := ""
+	if lks != nil {
+		lksName = lks.Name()
+	}
+
+	ctx, span := otel.Tracer(TelemetryInstrumentationName).Start(ctx, string(opType)+" "+collectionId,
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(
+			attribute.String(AttributeOpType, string(opType)),
+			attribute.String(AttributeCollectionId, collectionId),
+			attribute.String(AttributeLks, lksName),
+		))
+
+	return ctx, func(res OperationResult, body []byte, err error) {
+		span.SetAttributes(
+			attribute.Int(AttributeStatusCode, res.StatusCode),
+			attribute.Int64(AttributeMatchedCount, res.MatchedCount),
+			attribute.Int64(AttributeModifiedCount, res.ModifiedCount),
+			attribute.Int64(AttributeUpsertedCount, res.UpsertedCount),
+			attribute.Int64(AttributeDeletedCount, res.DeletedCount),
+			attribute.Int(AttributeResultSize, len(body)),
+		)
+
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+
+		attrs := metric.WithAttributeSet(attribute.NewSet(
+			attribute.String(AttributeOpType, string(opType)),
+			attribute.Int(AttributeStatusCode, res.StatusCode),
+		))
+
+		om := getOperationMetrics()
+		if om.Duration != nil {
+			om.Duration.Record(ctx, time.Since(startTime).Milliseconds(), attrs)
+		}
+		if err != nil && om.Errors != nil {
+			om.Errors.Record(ctx, 1, attrs)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package jsonops_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/jsonops"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestOperationTelemetry(t *testing.T) {
+	spans := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)))
+
+	reader := sdkmetric.NewManualReader()
+	otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "parent")
+
+	// an invalid output fails before reaching the linked service.
+	op := &jsonops.FindOperation{Query: []byte(`{ "year": 1939 }`), Output: []byte(`{ "format": "unknown" }`)}
+	res, _, err := op.ExecuteContext(ctx, nil, CollectionId)
+	require.Error(t, err)
+	require.Equal(t, http.StatusBadRequest, res.StatusCode)
+	parent.End()
+
+	ended := spans.Ended()
+	require.Len(t, ended, 2)
+	span := ended[0]
+	require.Equal(t, "find "+CollectionId, span.Name())
+	require.Equal(t, trace.SpanKindInternal, span.SpanKind())
+	require.Equal(t, parent.SpanContext().SpanID(), span.Parent().SpanID())
+	require.Contains(t, span.Attributes(), attribute.String(jsonops.AttributeOpType, "find"))
+	require.Contains(t, span.Attributes(), attribute.String(jsonops.AttributeCollectionId, CollectionId))
+	require.Contains(t, span.Attributes(), attribute.Int(jsonops.AttributeStatusCode, http.StatusBadRequest))
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+
+	metrics := map[string]metricdata.Metrics{}
+	for _, sm := range rm.ScopeMetrics {
+		if sm.Scope.Name == jsonops.TelemetryInstrumentationName {
+			for _, m := range sm.Metrics {
+				metrics[m.Name] = m
+			}
+		}
+	}
+
+	duration, ok := metrics[jsonops.OperationDurationMetric].Data.(metricdata.Histogram[int64])
+	require.True(t, ok)
+	require.Len(t, duration.DataPoints, 1)
+	require.Equal(t, uint64(1), duration.DataPoints[0].Count)
+	opType, _ := duration.DataPoints[0].Attributes.Value(jsonops.AttributeOpType)
+	require.Equal(t, "find", opType.AsString())
+
+	errs, ok := metrics[jsonops.OperationErrorsMetric].Data.(metricdata.Histogram[int64])
+	require.True(t, ok)
+	require.Len(t, errs.DataPoints, 1)
+	require.Equal(t, uint64(1), errs.DataPoints[0].Count)
+	sc, _ := errs.DataPoints[0].Attributes.Value(jsonops.AttributeStatusCode)
+	require.Equal(t, int64(http.StatusBadRequest), sc.AsInt64())
+}
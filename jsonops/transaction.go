@@ -0,0 +1,62 @@
+package jsonops
+
+import (
+	"context"
+
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/mongolks"
+	"github.com/rs/zerolog/log"
+)
+
+// OperationRef is an operation bound to the collection id it has to be executed on.
+type OperationRef struct {
+	CollectionId string    `yaml:"collection-id,omitempty" mapstructure:"collection-id,omitempty" json:"collection-id,omitempty"`
+	Op           Operation `yaml:"-" mapstructure:"-" json:"-"`
+}
+
+// ExecuteInTransaction runs the operations, in order, inside a single transaction of the linked service. The transaction is retried
+// by the driver on transient transaction errors and unknown commit results; the first failing operation aborts the whole transaction.
+func ExecuteInTransaction(lks *mongolks.LinkedService, ops []OperationRef) ([]OperationResult, error) {
+	return ExecuteInTransactionContext(context.Background(), lks, ops)
+}
+
+func ExecuteInTransactionContext(ctx context.Context, lks *mongolks.LinkedService, ops []OperationRef) ([]OperationResult, error) {
+	const semLogContext = "json-ops::execute-in-transaction"
+
+	var results []OperationResult
+	err := withTransaction(ctx, lks, func(txCtx context.Context) error {
+		// the function can be invoked more than once: results of previous attempts are discarded.
+		results = make([]OperationResult, 0, len(ops))
+		for i, ref := range ops {
+			res, _, err := ref.Op.ExecuteContext(txCtx, lks, ref.CollectionId)
+			results = append(results, res)
+			if err != nil {
+				log.Error().Err(err).Int("op-index", i).Str("op-type", string(ref.Op.OpType())).Str("collection-id", ref.CollectionId).Msg(semLogContext)
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	return results, err
+}
+
+func withTransaction(ctx context.Context, lks *mongolks.LinkedService, fn func(txCtx context.Context) error) error {
+	const semLogContext = "json-ops::with-transaction"
+
+	sess, err := lks.Db().Client().StartSession()
+	if err != nil {
+		log.Error().Err(err).Msg(semLogContext)
+		return err
+	}
+	defer sess.EndSession(ctx)
+
+	_, err = sess.WithTransaction(ctx, func(txCtx context.Context) (interface{}, error) {
+		return nil, fn(txCtx)
+	})
+	if err != nil {
+		log.Error().Err(err).Msg(semLogContext)
+	}
+
+	return err
+}
@@ -0,0 +1,57 @@
+package jsonops_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/jsonops"
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/mongolks"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestExecuteInTransaction(t *testing.T) {
+	lks, err := mongolks.GetLinkedService(context.Background(), "default")
+	require.NoError(t, err)
+
+	coll, err := mongolks.GetCollection(context.Background(), "default", CollectionId)
+	require.NoError(t, err)
+
+	filter := bson.D{{Key: "year", Value: 2040}}
+	_, err = coll.DeleteMany(context.Background(), filter)
+	require.NoError(t, err)
+
+	insertOp, err := jsonops.NewOperation(jsonops.InsertOneOperationType, map[jsonops.MongoJsonOperationStatementPart][]byte{
+		jsonops.MongoActivityInsertOneDocumentProperty: []byte(`{ "year": 2040, "title": "the 2040 movie" }`),
+	})
+	require.NoError(t, err)
+
+	updateOp, err := jsonops.NewOperation(jsonops.UpdateOneOperationType, map[jsonops.MongoJsonOperationStatementPart][]byte{
+		jsonops.MongoActivityUpdateOneFilterProperty: []byte(`{ "year": 2040 }`),
+		jsonops.MongoActivityUpdateOneUpdateProperty: []byte(`{ "$set": { "summary": "the 2040 movie summary" } }`),
+	})
+	require.NoError(t, err)
+
+	results, err := jsonops.ExecuteInTransaction(lks, []jsonops.OperationRef{{CollectionId: CollectionId, Op: insertOp}, {CollectionId: CollectionId, Op: updateOp}})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	require.Equal(t, int64(1), results[1].ModifiedCount)
+
+	// the invalid update aborts the transaction: the insert is rolled back.
+	badOp, err := jsonops.NewOperation(jsonops.UpdateOneOperationType, map[jsonops.MongoJsonOperationStatementPart][]byte{
+		jsonops.MongoActivityUpdateOneFilterProperty: []byte(`{ "year": 2040 }`),
+		jsonops.MongoActivityUpdateOneUpdateProperty: []byte(`{ "$unknownOperator": { "summary": 1 } }`),
+	})
+	require.NoError(t, err)
+
+	results, err = jsonops.ExecuteInTransaction(lks, []jsonops.OperationRef{{CollectionId: CollectionId, Op: insertOp}, {CollectionId: CollectionId, Op: badOp}})
+	require.Error(t, err)
+	require.Len(t, results, 2)
+
+	n, err := coll.CountDocuments(context.Background(), filter)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), n)
+
+	_, err = coll.DeleteMany(context.Background(), filter)
+	require.NoError(t, err)
+}
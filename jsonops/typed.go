@@ -0,0 +1,122 @@
+package jsonops
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/mongolks"
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/util"
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/util/mdboptions"
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// FindTyped executes a find statement and decodes the documents straight into T with the bson decoder: unlike Find there is no
+// round trip through json, so fields keep their bson types (ObjectID, DateTime, Decimal128, ...).
+func FindTyped[T any](ctx context.Context, lks *mongolks.LinkedService, collectionId string, query []byte, projection []byte, sort []byte, opts []byte) (items []T, res OperationResult, err error) {
+	const semLogContext = "json-ops::find-typed"
+
+	ctx, endSpan := startOperationSpan(ctx, lks, FindManyOperationType, collectionId)
+	defer func() { endSpan(res, nil, err) }()
+
+	if err = checkPolicy(lks, collectionId, &FindOperation{Query: query, Projection: projection, Sort: sort, Options: opts}); err != nil {
+		return nil, OperationResultFromError(err), err
+	}
+
+	c, statementQuery, res, err := typedFindPrologue(lks, collectionId, query)
+	if err != nil {
+		log.Error().Err(err).Str("collection", collectionId).Msg(semLogContext)
+		return nil, res, err
+	}
+
+	fo, err := mdboptions.FindOptionsFromJson(opts, sort, projection)
+	if err != nil {
+		log.Error().Err(err).Msg(semLogContext)
+		return nil, OperationResultFromStatementError(err), err
+	}
+
+	ctx, cancel, err := newOperationContext(ctx, lks, collectionId, FindManyOperationType, opts)
+	if err != nil {
+		log.Error().Err(err).Msg(semLogContext)
+		return nil, OperationResultFromStatementError(err), err
+	}
+	defer cancel()
+
+	crs, err := c.Find(ctx, statementQuery, fo)
+	if err != nil {
+		res = OperationResultFromError(err)
+		log.Error().Err(err).Int("status-code", res.StatusCode).Msg(semLogContext)
+		return nil, res, err
+	}
+
+	items = make([]T, 0)
+	if err = crs.All(ctx, &items); err != nil {
+		res = OperationResultFromError(err)
+		log.Error().Err(err).Int("status-code", res.StatusCode).Msg(semLogContext)
+		return nil, res, err
+	}
+
+	return items, OperationResult{StatusCode: http.StatusOK, MatchedCount: int64(len(items))}, nil
+}
+
+// FindOneTyped executes a find-one statement and decodes the document into T. A missing document is reported with a 404 status code
+// and no error, as FindOne does; in that case the returned value is the zero value of T.
+func FindOneTyped[T any](ctx context.Context, lks *mongolks.LinkedService, collectionId string, query []byte, projection []byte, sort []byte, opts []byte) (item T, res OperationResult, err error) {
+	const semLogContext = "json-ops::find-one-typed"
+
+	ctx, endSpan := startOperationSpan(ctx, lks, FindOneOperationType, collectionId)
+	defer func() { endSpan(res, nil, err) }()
+
+	if err = checkPolicy(lks, collectionId, &FindOneOperation{Query: query, Projection: projection, Sort: sort, Options: opts}); err != nil {
+		return item, OperationResultFromError(err), err
+	}
+
+	c, statementQuery, res, err := typedFindPrologue(lks, collectionId, query)
+	if err != nil {
+		log.Error().Err(err).Str("collection", collectionId).Msg(semLogContext)
+		return item, res, err
+	}
+
+	fo, err := mdboptions.FindOneOptionsFromJson(opts, sort, projection)
+	if err != nil {
+		log.Error().Err(err).Msg(semLogContext)
+		return item, OperationResultFromStatementError(err), err
+	}
+
+	ctx, cancel, err := newOperationContext(ctx, lks, collectionId, FindOneOperationType, opts)
+	if err != nil {
+		log.Error().Err(err).Msg(semLogContext)
+		return item, OperationResultFromStatementError(err), err
+	}
+	defer cancel()
+
+	err = c.FindOne(ctx, statementQuery, fo).Decode(&item)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return item, OperationResult{StatusCode: http.StatusNotFound}, nil
+	}
+
+	if err != nil {
+		res = OperationResultFromError(err)
+		log.Error().Err(err).Int("status-code", res.StatusCode).Msg(semLogContext)
+		return item, res, err
+	}
+
+	return item, OperationResult{StatusCode: http.StatusOK, MatchedCount: 1}, nil
+}
+
+func typedFindPrologue(lks *mongolks.LinkedService, collectionId string, query []byte) (*mongo.Collection, bson.D, OperationResult, error) {
+	c := lks.GetCollection(collectionId, "")
+	if c == nil {
+		err := errors.New("cannot find requested collection")
+		return nil, nil, OperationResultFromError(err), err
+	}
+
+	statementQuery, err := util.UnmarshalJson2BsonD(query, true)
+	if err != nil {
+		return nil, nil, OperationResultFromStatementError(err), err
+	}
+
+	return c, statementQuery, OperationResult{}, nil
+}
@@ -0,0 +1,53 @@
+package jsonops_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/jsonops"
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/mongolks"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type typedDocument struct {
+	Id    bson.ObjectID `bson:"_id"`
+	Title string        `bson:"title"`
+	Year  int32         `bson:"year"`
+}
+
+func TestFindTyped(t *testing.T) {
+	lks, err := mongolks.GetLinkedService(context.Background(), "default")
+	require.NoError(t, err)
+
+	docs, res, err := jsonops.FindTyped[typedDocument](context.Background(), lks, CollectionId, []byte(`{ "year": 1939 }`), nil, []byte(`{ "title": 1 }`), []byte(`{ "limit": 5 }`))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Equal(t, int64(len(docs)), res.MatchedCount)
+	for _, d := range docs {
+		require.False(t, d.Id.IsZero())
+		require.Equal(t, int32(1939), d.Year)
+	}
+
+	_, res, err = jsonops.FindOneTyped[typedDocument](context.Background(), lks, CollectionId, []byte(`{ "year": -1 }`), nil, nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNotFound, res.StatusCode)
+
+	_, res, err = jsonops.FindOneTyped[bson.M](context.Background(), lks, CollectionId, []byte(`{ "year": `), nil, nil, nil)
+	require.Error(t, err)
+	require.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+	// the typed variants are subject to the policy of the collection as Find and FindOne.
+	p := jsonops.DefaultPolicy()
+	p.MaxLimit = 10
+	jsonops.RegisterPolicy(lks.Name(), "typed-policy", p)
+
+	_, res, err = jsonops.FindTyped[typedDocument](context.Background(), lks, "typed-policy", []byte(`{ "year": 1939 }`), nil, nil, nil)
+	require.ErrorIs(t, err, jsonops.ErrPolicyViolation)
+	require.Equal(t, http.StatusForbidden, res.StatusCode)
+
+	_, res, err = jsonops.FindOneTyped[typedDocument](context.Background(), lks, "typed-policy", []byte(`{ "$where": "this.year > 1" }`), nil, nil, nil)
+	require.ErrorIs(t, err, jsonops.ErrPolicyViolation)
+	require.Equal(t, http.StatusForbidden, res.StatusCode)
+}
@@ -5,13 +5,13 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"net/http"
 	"strings"
 
 	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/mongolks"
 	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/util"
 	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/util/mdboptions"
 	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 )
 
@@ -20,12 +20,14 @@ const (
 	MongoActivityUpdateManyFilterProperty MongoJsonOperationStatementPart = "$filter"
 	MongoActivityUpdateManyUpdateProperty MongoJsonOperationStatementPart = "$update"
 	MongoActivityUpdateManyOptsProperty   MongoJsonOperationStatementPart = "$opts"
+	MongoActivityUpdateManyOutputProperty MongoJsonOperationStatementPart = "$output"
 )
 
 type UpdateManyOperation struct {
 	Filter  []byte `yaml:"filter,omitempty" json:"filter,omitempty" mapstructure:"filter,omitempty"`
 	Update  []byte `yaml:"update,omitempty" json:"update,omitempty" mapstructure:"update,omitempty"`
 	Options []byte `yaml:"options,omitempty" json:"options,omitempty" mapstructure:"options,omitempty"`
+	Output  []byte `yaml:"output,omitempty" json:"output,omitempty" mapstructure:"output,omitempty"`
 }
 
 func (op *UpdateManyOperation) OpType() MongoJsonOperationType {
@@ -57,6 +59,14 @@ func (op *UpdateManyOperation) ToString() string {
 		sb.WriteString(fmt.Sprintf("\"%s\": ", MongoActivityUpdateManyOptsProperty))
 		sb.WriteString(string(op.Options))
 	}
+	if len(op.Output) > 0 {
+		if numberOfElements > 0 {
+			sb.WriteString(",")
+		}
+		numberOfElements++
+		sb.WriteString(fmt.Sprintf("\"%s\": ", MongoActivityUpdateManyOutputProperty))
+		sb.WriteString(string(op.Output))
+	}
 
 	sb.WriteString("}")
 	return sb.String()
@@ -80,6 +90,10 @@ func NewUpdateManyOperation(m map[MongoJsonOperationStatementPart][]byte) (*Upda
 		foStmt.Options = data
 	}
 
+	if data, ok := m[MongoActivityUpdateManyOutputProperty]; ok {
+		foStmt.Output = data
+	}
+
 	return &foStmt, nil
 }
 
@@ -99,57 +113,85 @@ func NewUpdateManyStatementConfigFromJson(data []byte) (UpdateManyOperation, err
 		Filter:  m[MongoActivityUpdateManyFilterProperty],
 		Update:  m[MongoActivityUpdateManyUpdateProperty],
 		Options: m[MongoActivityUpdateManyOptsProperty],
+		Output:  m[MongoActivityUpdateManyOutputProperty],
 	}
 
 	return fo, nil
 }
 
 func (op *UpdateManyOperation) Execute(lks *mongolks.LinkedService, collectionId string) (OperationResult, []byte, error) {
-	sc, resp, err := UpdateMany(lks, collectionId, op.Filter, op.Update, op.Options)
+	return op.ExecuteContext(context.Background(), lks, collectionId)
+}
+
+func (op *UpdateManyOperation) ExecuteContext(ctx context.Context, lks *mongolks.LinkedService, collectionId string) (res OperationResult, resp []byte, err error) {
+	ctx, endSpan := startOperationSpan(ctx, lks, op.OpType(), collectionId)
+	defer func() { endSpan(res, resp, err) }()
+
+	oo, err := NewOutputOptionsFromJson(op.Output)
+	if err != nil {
+		return OperationResultFromStatementError(err), nil, err
+	}
+
+	sc, resp, err := UpdateManyContext(ctx, lks, collectionId, op.Filter, op.Update, op.Options, oo)
 	return sc, resp, err
 }
 
-func UpdateMany(lks *mongolks.LinkedService, collectionId string, filter []byte, update []byte, opts []byte) (OperationResult, []byte, error) {
+func UpdateMany(lks *mongolks.LinkedService, collectionId string, filter []byte, update []byte, opts []byte, output ...OutputOptions) (OperationResult, []byte, error) {
+	return UpdateManyContext(context.Background(), lks, collectionId, filter, update, opts, output...)
+}
+
+func UpdateManyContext(ctx context.Context, lks *mongolks.LinkedService, collectionId string, filter []byte, update []byte, opts []byte, output ...OutputOptions) (OperationResult, []byte, error) {
 	const semLogContext = "json-ops::update-many"
 	var err error
 
+	if err := checkPolicy(lks, collectionId, &UpdateManyOperation{Filter: filter, Update: update, Options: opts}); err != nil {
+		return OperationResultFromError(err), nil, err
+	}
+
 	c := lks.GetCollection(collectionId, "")
 	if c == nil {
 		err = errors.New("cannot find requested collection")
 		log.Error().Err(err).Str("collection", collectionId).Msg(semLogContext)
-		return OperationResult{StatusCode: http.StatusInternalServerError}, nil, err
+		return OperationResultFromError(err), nil, err
 	}
 
 	statementFilter, err := util.UnmarshalJson2BsonD(filter, true)
 	if err != nil {
 		log.Error().Err(err).Msg(semLogContext)
-		return OperationResult{StatusCode: http.StatusInternalServerError}, nil, err
+		return OperationResultFromStatementError(err), nil, err
 	}
 
 	statementUpdate, err := util.UnmarshalJson2Bson(update, true)
 	if err != nil {
 		log.Error().Err(err).Msg(semLogContext)
-		return OperationResult{StatusCode: http.StatusInternalServerError}, nil, err
+		return OperationResultFromStatementError(err), nil, err
 	}
 
 	uo, _, err := mdboptions.UpdateManyOptionsFromJson(opts)
 	if err != nil {
 		log.Error().Err(err).Msg(semLogContext)
-		return OperationResult{StatusCode: http.StatusInternalServerError}, nil, err
+		return OperationResultFromStatementError(err), nil, err
+	}
+
+	ctx, cancel, err := newOperationContext(ctx, lks, collectionId, UpdateManyOperationType, opts)
+	if err != nil {
+		log.Error().Err(err).Msg(semLogContext)
+		return OperationResultFromStatementError(err), nil, err
 	}
+	defer cancel()
 
-	res, err := c.UpdateMany(context.Background(), statementFilter, statementUpdate, uo)
+	res, err := c.UpdateMany(ctx, statementFilter, statementUpdate, uo)
 	if err != nil {
-		mongoErrorCode := util.MongoErrorCode(err, util.MongoDbVersion{})
-		log.Error().Err(err).Int32("mongo-error", mongoErrorCode).Msg(semLogContext)
-		return OperationResult{StatusCode: int(-mongoErrorCode)}, nil, err
+		res := OperationResultFromError(err)
+		log.Error().Err(err).Int("status-code", res.StatusCode).Msg(semLogContext)
+		return res, nil, err
 	}
 
 	var b []byte
-	b, err = json.Marshal(res)
+	b, err = outputOptionsOf(output).MarshalWriteResult(res)
 	if err != nil {
 		log.Error().Err(err).Msg(semLogContext)
-		return OperationResult{StatusCode: http.StatusInternalServerError}, nil, err
+		return OperationResultFromError(err), nil, err
 	}
 
 	return OperationResultFromUpdateResult(res), b, nil
@@ -170,12 +212,43 @@ func (op *UpdateManyOperation) NewWriteModel() (mongo.WriteModel, error) {
 		return nil, err
 	}
 
-	upsert := false
-	_, upsert, err = mdboptions.UpdateManyOptionsFromJson(op.Options)
+	jo, err := mdboptions.ParseJsonOptions(op.Options, mdboptions.UpdateOptionNames)
 	if err != nil {
 		log.Error().Err(err).Msg(semLogContext)
 		return nil, err
 	}
 
-	return mongo.NewUpdateManyModel().SetFilter(statementFilter).SetUpdate(statementUpdate).SetUpsert(upsert), nil
+	wm := mongo.NewUpdateManyModel().SetFilter(statementFilter).SetUpdate(statementUpdate).SetUpsert(jo.IsUpsert())
+	if jo.Hint != nil {
+		wm.SetHint(jo.Hint)
+	}
+	if jo.Collation != nil {
+		wm.SetCollation(jo.Collation)
+	}
+	if jo.ArrayFilters != nil {
+		wm.SetArrayFilters(jo.ArrayFilters)
+	}
+
+	return wm, nil
+}
+
+func (op *UpdateManyOperation) explainCommand(collectionName string) (bson.D, []byte, error) {
+	statementFilter, err := util.UnmarshalJson2BsonD(op.Filter, true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	statementUpdate, err := util.UnmarshalJson2Bson(op.Update, true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	jo, err := mdboptions.ParseJsonOptions(op.Options, mdboptions.UpdateOptionNames)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stmt := bson.D{{Key: "q", Value: statementFilter}, {Key: "u", Value: statementUpdate}, {Key: "multi", Value: true}, {Key: "upsert", Value: jo.IsUpsert()}}
+	cmd := bson.D{{Key: "update", Value: collectionName}, {Key: "updates", Value: bson.A{explainStatementOptions(stmt, jo)}}}
+	return explainCommandOptions(cmd, jo), op.Options, nil
 }
@@ -0,0 +1,325 @@
+package jsonops
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/changestream/checkpoint"
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/changestream/events"
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/mongolks"
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/util"
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/util/mdboptions"
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+const (
+	MongoActivityWatchOpProperty       MongoJsonOperationStatementPart = "$op"
+	MongoActivityWatchPipelineProperty MongoJsonOperationStatementPart = "$pipeline"
+	MongoActivityWatchOptsProperty     MongoJsonOperationStatementPart = "$opts"
+	MongoActivityWatchOutputProperty   MongoJsonOperationStatementPart = "$output"
+
+	DefaultWatchMaxEvents = 100
+	DefaultWatchMaxTime   = 5 * time.Second
+
+	watchOperationTypeInvalidate = "invalidate"
+)
+
+// WatchOperation opens a change stream on the collection and collects the events until maxEvents (default 100) have been received
+// or the maxTimeMS window (default the op-type timeout of the linked service or 5s) elapses. The last resume token is returned
+// so that a later watch can continue with the resumeAfter option. An invalidate event (i.e. the collection has been dropped or renamed)
+// ends the window: the stream cannot be resumed after it and the token returned must be passed as startAfter. The other events not
+// supported by the events package (i.e. drop) are skipped. A change stream has no query plan: watch is the op-type not supported by Explain.
+type WatchOperation struct {
+	Pipeline []byte `yaml:"pipeline,omitempty" json:"pipeline,omitempty" mapstructure:"pipeline,omitempty"`
+	Options  []byte `yaml:"options,omitempty" json:"options,omitempty" mapstructure:"options,omitempty"`
+	Output   []byte `yaml:"output,omitempty" json:"output,omitempty" mapstructure:"output,omitempty"`
+}
+
+// WatchResult holds the events of the window and the token to continue from, to be passed with the ResumeOption (resumeAfter or,
+// after an invalidate event, startAfter).
+type WatchResult struct {
+	Events       []events.ChangeEvent   `yaml:"events,omitempty" mapstructure:"events,omitempty" json:"events,omitempty"`
+	ResumeToken  checkpoint.ResumeToken `yaml:"resume-token,omitempty" mapstructure:"resume-token,omitempty" json:"resume-token,omitempty"`
+	ResumeOption string                 `yaml:"resume-option,omitempty" mapstructure:"resume-option,omitempty" json:"resume-option,omitempty"`
+	Invalidated  bool                   `yaml:"invalidated,omitempty" mapstructure:"invalidated,omitempty" json:"invalidated,omitempty"`
+}
+
+func (op *WatchOperation) OpType() MongoJsonOperationType {
+	return WatchOperationType
+}
+
+func (op *WatchOperation) ToString() string {
+	var sb strings.Builder
+	numberOfElements := 0
+	sb.WriteString("{")
+	if len(op.Pipeline) > 0 {
+		numberOfElements++
+		sb.WriteString(fmt.Sprintf("\"%s\": ", MongoActivityWatchPipelineProperty))
+		sb.WriteString(string(op.Pipeline))
+	}
+	if len(op.Options) > 0 {
+		if numberOfElements > 0 {
+			sb.WriteString(",")
+		}
+		numberOfElements++
+		sb.WriteString(fmt.Sprintf("\"%s\": ", MongoActivityWatchOptsProperty))
+		sb.WriteString(string(op.Options))
+	}
+	if len(op.Output) > 0 {
+		if numberOfElements > 0 {
+			sb.WriteString(",")
+		}
+		numberOfElements++
+		sb.WriteString(fmt.Sprintf("\"%s\": ", MongoActivityWatchOutputProperty))
+		sb.WriteString(string(op.Output))
+	}
+
+	sb.WriteString("}")
+	return sb.String()
+}
+
+func NewWatchOperation(m map[MongoJsonOperationStatementPart][]byte) (*WatchOperation, error) {
+	foStmt, err := NewWatchStatementConfigFromJson(m[MongoActivityWatchOpProperty])
+	if err != nil {
+		return nil, err
+	}
+
+	if data, ok := m[MongoActivityWatchPipelineProperty]; ok {
+		foStmt.Pipeline = data
+	}
+
+	if data, ok := m[MongoActivityWatchOptsProperty]; ok {
+		foStmt.Options = data
+	}
+
+	if data, ok := m[MongoActivityWatchOutputProperty]; ok {
+		foStmt.Output = data
+	}
+
+	return &foStmt, nil
+}
+
+func NewWatchStatementConfigFromJson(data []byte) (WatchOperation, error) {
+
+	if len(data) == 0 {
+		return WatchOperation{}, nil
+	}
+
+	var m map[MongoJsonOperationStatementPart]json.RawMessage
+	err := json.Unmarshal(data, &m)
+	if err != nil {
+		return WatchOperation{}, err
+	}
+
+	fo := WatchOperation{
+		Pipeline: m[MongoActivityWatchPipelineProperty],
+		Options:  m[MongoActivityWatchOptsProperty],
+		Output:   m[MongoActivityWatchOutputProperty],
+	}
+
+	return fo, nil
+}
+
+func (op *WatchOperation) Execute(lks *mongolks.LinkedService, collectionId string) (OperationResult, []byte, error) {
+	return op.ExecuteContext(context.Background(), lks, collectionId)
+}
+
+func (op *WatchOperation) ExecuteContext(ctx context.Context, lks *mongolks.LinkedService, collectionId string) (res OperationResult, resp []byte, err error) {
+	ctx, endSpan := startOperationSpan(ctx, lks, op.OpType(), collectionId)
+	defer func() { endSpan(res, resp, err) }()
+
+	oo, err := NewOutputOptionsFromJson(op.Output)
+	if err != nil {
+		return OperationResultFromStatementError(err), nil, err
+	}
+
+	sc, resp, err := WatchContext(ctx, lks, collectionId, op.Pipeline, op.Options, oo)
+	return sc, resp, err
+}
+
+func Watch(lks *mongolks.LinkedService, collectionId string, pipeline []byte, opts []byte, output ...OutputOptions) (OperationResult, []byte, error) {
+	return WatchContext(context.Background(), lks, collectionId, pipeline, opts, output...)
+}
+
+func WatchContext(ctx context.Context, lks *mongolks.LinkedService, collectionId string, pipeline []byte, opts []byte, output ...OutputOptions) (OperationResult, []byte, error) {
+	const semLogContext = "json-ops::watch"
+
+	wr, res, err := WatchEventsContext(ctx, lks, collectionId, pipeline, opts)
+	if err != nil {
+		return res, nil, err
+	}
+
+	b, err := wr.marshal(outputOptionsOf(output))
+	if err != nil {
+		log.Error().Err(err).Msg(semLogContext)
+		return OperationResultFromError(err), nil, err
+	}
+
+	return res, b, nil
+}
+
+// WatchEventsContext is the typed counterpart of WatchContext. The end of the time window is not an error: the events received so far
+// are returned with a 200 status code.
+func WatchEventsContext(ctx context.Context, lks *mongolks.LinkedService, collectionId string, pipeline []byte, opts []byte) (WatchResult, OperationResult, error) {
+	const semLogContext = "json-ops::watch-events"
+	var err error
+
+	if err := checkPolicy(lks, collectionId, &WatchOperation{Pipeline: pipeline, Options: opts}); err != nil {
+		return WatchResult{}, OperationResultFromError(err), err
+	}
+
+	c := lks.GetCollection(collectionId, "")
+	if c == nil {
+		err = errors.New("cannot find requested collection")
+		log.Error().Err(err).Str("collection", collectionId).Msg(semLogContext)
+		return WatchResult{}, OperationResultFromError(err), err
+	}
+
+	statementPipeline, err := util.UnmarshalJson2ArrayOfBsonD(pipeline, true)
+	if err != nil {
+		log.Error().Err(err).Msg(semLogContext)
+		return WatchResult{}, OperationResultFromStatementError(err), err
+	}
+
+	co, jo, err := mdboptions.ChangeStreamOptionsFromJson(opts)
+	if err != nil {
+		log.Error().Err(err).Msg(semLogContext)
+		return WatchResult{}, OperationResultFromStatementError(err), err
+	}
+
+	maxEvents := int64(DefaultWatchMaxEvents)
+	if jo.MaxEvents != nil && *jo.MaxEvents > 0 {
+		maxEvents = *jo.MaxEvents
+	}
+
+	watchCtx, cancel, err := newOperationContext(ctx, lks, collectionId, WatchOperationType, opts)
+	if err != nil {
+		log.Error().Err(err).Msg(semLogContext)
+		return WatchResult{}, OperationResultFromStatementError(err), err
+	}
+	defer cancel()
+
+	if _, ok := watchCtx.Deadline(); !ok {
+		var cancelDefault context.CancelFunc
+		watchCtx, cancelDefault = context.WithTimeout(watchCtx, DefaultWatchMaxTime)
+		defer cancelDefault()
+	}
+
+	cs, err := c.Watch(watchCtx, mongo.Pipeline(statementPipeline), co)
+	if err != nil {
+		res := OperationResultFromError(err)
+		log.Error().Err(err).Int("status-code", res.StatusCode).Msg(semLogContext)
+		return WatchResult{}, res, err
+	}
+	defer cs.Close(context.Background())
+
+	wr := WatchResult{Events: make([]events.ChangeEvent, 0)}
+	for int64(len(wr.Events)) < maxEvents && cs.Next(watchCtx) {
+		var data bson.M
+		if err = cs.Decode(&data); err != nil {
+			log.Error().Err(err).Msg(semLogContext)
+			return WatchResult{}, OperationResultFromError(err), err
+		}
+
+		if opType, _ := data["operationType"].(string); opType == watchOperationTypeInvalidate {
+			wr.Invalidated = true
+			break
+		}
+
+		evt, err := events.ParseEvent(data)
+		if err != nil {
+			if errors.Is(err, events.UnsupportedOperationType) {
+				continue
+			}
+
+			log.Error().Err(err).Msg(semLogContext)
+			return WatchResult{}, OperationResultFromError(err), err
+		}
+
+		wr.Events = append(wr.Events, evt)
+	}
+
+	// the window elapsing, either the deadline of the context or the maxTimeMS of the server, ends the watch. A cancellation of the
+	// caller and any other timeout (i.e. server selection, network) do not.
+	if err = cs.Err(); err != nil && ctx.Err() == nil && (errors.Is(err, context.DeadlineExceeded) || util.Classify(err).Code == util.MongoErrMaxTimeMSExpired) {
+		err = nil
+	}
+
+	if err == nil && ctx.Err() != nil {
+		err = ctx.Err()
+	}
+
+	if err != nil {
+		res := OperationResultFromError(err)
+		log.Error().Err(err).Int("status-code", res.StatusCode).Msg(semLogContext)
+		return WatchResult{}, res, err
+	}
+
+	if raw := cs.ResumeToken(); raw != nil {
+		wr.ResumeToken, err = checkpoint.DecodeResumeToken(raw)
+		if err != nil {
+			log.Error().Err(err).Msg(semLogContext)
+			return WatchResult{}, OperationResultFromError(err), err
+		}
+
+		wr.ResumeOption = mdboptions.OptionResumeAfter
+		if wr.Invalidated {
+			wr.ResumeOption = mdboptions.OptionStartAfter
+		}
+	}
+
+	return wr, OperationResult{StatusCode: http.StatusOK, MatchedCount: int64(len(wr.Events))}, nil
+}
+
+// marshal renders the events with encoding/json or, for the extended json formats, with the extended json of the events.
+func (wr WatchResult) marshal(oo OutputOptions) ([]byte, error) {
+	if oo.Format != OutputFormatCanonical && oo.Format != OutputFormatRelaxed {
+		return json.Marshal(wr)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(`{"events":[`)
+	for i := range wr.Events {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		b, err := bson.MarshalExtJSON(&wr.Events[i], oo.Format == OutputFormatCanonical, false)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(b)
+	}
+	buf.WriteString(`],"resume-token":`)
+
+	b, err := json.Marshal(wr.ResumeToken)
+	if err != nil {
+		return nil, err
+	}
+	buf.Write(b)
+
+	if wr.ResumeOption != "" {
+		buf.WriteString(`,"resume-option":`)
+		b, _ = json.Marshal(wr.ResumeOption)
+		buf.Write(b)
+	}
+
+	if wr.Invalidated {
+		buf.WriteString(`,"invalidated":true`)
+	}
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+func (op *WatchOperation) NewWriteModel() (mongo.WriteModel, error) {
+	panic("new write model not supported in watch operations")
+}
@@ -0,0 +1,92 @@
+package jsonops_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/changestream/events"
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/jsonops"
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/mongolks"
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/util/mdboptions"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestWatch(t *testing.T) {
+	lks, err := mongolks.GetLinkedService(context.Background(), "default")
+	require.NoError(t, err)
+
+	coll := lks.GetCollection(WatchCollectionId, "")
+	require.NoError(t, coll.Drop(context.Background()))
+
+	// an empty window still returns the token to resume from.
+	wr, res, err := jsonops.WatchEventsContext(context.Background(), lks, WatchCollectionId, nil, []byte(`{ "maxTimeMS": 500 }`))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Empty(t, wr.Events)
+	require.False(t, wr.ResumeToken.IsZero())
+
+	_, err = coll.InsertMany(context.Background(), []interface{}{
+		bson.D{{Key: "code", Value: "A01"}},
+		bson.D{{Key: "code", Value: "A02"}},
+		bson.D{{Key: "code", Value: "A03"}},
+	})
+	require.NoError(t, err)
+
+	// maxEvents ends the window before the time does.
+	wr, res, err = jsonops.WatchEventsContext(context.Background(), lks, WatchCollectionId, nil, watchOptions(wr.ResumeToken.Value, 2))
+	require.NoError(t, err)
+	require.Equal(t, int64(2), res.MatchedCount)
+	require.Len(t, wr.Events, 2)
+	require.Equal(t, events.OperationTypeInsert, wr.Events[0].OpType)
+	require.Equal(t, "A01", wr.Events[0].FullDocument["code"])
+	require.Equal(t, "A02", wr.Events[1].FullDocument["code"])
+
+	// resuming from the returned token delivers the remaining event only.
+	wr, _, err = jsonops.WatchEventsContext(context.Background(), lks, WatchCollectionId, nil, watchOptions(wr.ResumeToken.Value, 10))
+	require.NoError(t, err)
+	require.Len(t, wr.Events, 1)
+	require.Equal(t, "A03", wr.Events[0].FullDocument["code"])
+
+	// the drop event is skipped, the invalidate one ends the window: the stream can only be started again after its token.
+	_, err = coll.DeleteOne(context.Background(), bson.D{{Key: "code", Value: "A03"}})
+	require.NoError(t, err)
+	require.NoError(t, coll.Drop(context.Background()))
+
+	op, err := jsonops.NewOperation(jsonops.WatchOperationType, map[jsonops.MongoJsonOperationStatementPart][]byte{
+		jsonops.MongoActivityWatchOptsProperty: watchOptions(wr.ResumeToken.Value, 10),
+	})
+	require.NoError(t, err)
+
+	res, body, err := op.Execute(lks, WatchCollectionId)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	wr = jsonops.WatchResult{}
+	require.NoError(t, json.Unmarshal(body, &wr))
+	require.Len(t, wr.Events, 1)
+	require.Equal(t, events.OperationTypeDelete, wr.Events[0].OpType)
+	require.True(t, wr.Invalidated)
+	require.Equal(t, mdboptions.OptionStartAfter, wr.ResumeOption)
+	require.False(t, wr.ResumeToken.IsZero())
+
+	_, _, err = jsonops.WatchEventsContext(context.Background(), lks, WatchCollectionId, nil, watchOptions(wr.ResumeToken.Value, 10))
+	require.Error(t, err)
+
+	wr, _, err = jsonops.WatchEventsContext(context.Background(), lks, WatchCollectionId, nil, watchOptionsAfter(wr.ResumeOption, wr.ResumeToken.Value, 10))
+	require.NoError(t, err)
+	require.Empty(t, wr.Events)
+	require.False(t, wr.Invalidated)
+	require.Equal(t, mdboptions.OptionResumeAfter, wr.ResumeOption)
+}
+
+func watchOptions(resumeToken string, maxEvents int) []byte {
+	return watchOptionsAfter(mdboptions.OptionResumeAfter, resumeToken, maxEvents)
+}
+
+func watchOptionsAfter(resumeOption string, resumeToken string, maxEvents int) []byte {
+	return []byte(fmt.Sprintf(`{ %q: %q, "maxEvents": %d, "maxTimeMS": 1000 }`, resumeOption, resumeToken, maxEvents))
+}
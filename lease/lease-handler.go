@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/util"
 	"github.com/rs/zerolog/log"
 	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
@@ -226,6 +227,9 @@ func (lh *Handler) renewLoop() {
 	log.Info().Msg(semLogContext + " ended")
 }
 
+// RenewLeaseRetryPolicy bounds the attempts of a renewal on transient errors (network, primary step down, retryable writes).
+var RenewLeaseRetryPolicy = util.DefaultRetryPolicy("lease-renew")
+
 func (lh *Handler) RenewLease(withErrors bool) error {
 	const semLogContext = "lease-handler::renew"
 
@@ -241,26 +245,32 @@ func (lh *Handler) RenewLease(withErrors bool) error {
 
 	f := Filter{}
 	f.Or().AndBidEqTo(lh.Lease.Bid).AndGidEqTo(lh.Lease.Gid).AndEtagEqTo(lh.Lease.Etag).AndEtEqTo(EntityType)
-	lh.Lease = lh.Lease.Renewed()
+	renewed := lh.Lease.Renewed()
 
 	updOptions := []UpdateOption{
-		UpdateWithStatus(lh.Lease.Status),
-		UpdateWithEtag(lh.Lease.Etag),
-		UpdateWithTs(lh.Lease.Ts),
-		UpdateWithData(lh.Lease.Data),
+		UpdateWithStatus(renewed.Status),
+		UpdateWithEtag(renewed.Etag),
+		UpdateWithTs(renewed.Ts),
+		UpdateWithData(renewed.Data),
 	}
 	if withErrors {
 		updOptions = append(updOptions, UpdateWithIncErrors(1))
-		lh.Lease.Errors++
+		renewed.Errors++
 	}
 	ud := GetUpdateDocumentFromOptions(updOptions...)
 
-	res, err := lh.cli.UpdateOne(context.Background(), f.Build(), ud.Build())
+	var res *mongo.UpdateResult
+	err = util.Retry(context.Background(), RenewLeaseRetryPolicy, func(ctx context.Context) error {
+		var errUpd error
+		res, errUpd = lh.cli.UpdateOne(ctx, f.Build(), ud.Build())
+		return errUpd
+	})
 	if err != nil {
 		log.Error().Err(err).Msg(semLogContext)
 		return err
 	}
 
+	lh.Lease = renewed
 	if res.ModifiedCount == 0 {
 		log.Error().Msg(semLogContext + " lease already released")
 	}
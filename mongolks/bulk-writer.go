@@ -57,6 +57,10 @@ func NewBulkWriter(instanceName, collId string, opts ...BulkWriterOption) (*Bulk
 		return nil, err
 	}
 
+	return newBulkWriter(coll, opts...), nil
+}
+
+func newBulkWriter(coll *mongo.Collection, opts ...BulkWriterOption) *BulkWriter {
 	wrtOptions := BulkWriterOptions{Size: 100, Ordered: false}
 	for _, opt := range opts {
 		opt(&wrtOptions)
@@ -65,7 +69,7 @@ func NewBulkWriter(instanceName, collId string, opts ...BulkWriterOption) (*Bulk
 	return &BulkWriter{coll: coll,
 		opts:  wrtOptions,
 		batch: make([]mongo.WriteModel, 0, wrtOptions.Size),
-		stats: NewBulkWriterStatsInfo(wrtOptions.MetricsGid, wrtOptions.PrimaryLabel, wrtOptions.SecondaryLabel)}, nil
+		stats: NewBulkWriterStatsInfo(wrtOptions.MetricsGid, wrtOptions.PrimaryLabel, wrtOptions.SecondaryLabel)}
 }
 
 func (w *BulkWriter) String() string {
@@ -77,6 +81,11 @@ func (w *BulkWriter) Stats() *BulkWriterStatsInfo {
 }
 
 func (w *BulkWriter) Flush() (int, error) {
+	return w.FlushContext(context.Background())
+}
+
+// FlushContext writes the pending models with a bulk write bound to ctx, so that a cancellation of the caller stops the write.
+func (w *BulkWriter) FlushContext(ctx context.Context) (int, error) {
 	const semLogContext = "bulk-writer::flush"
 
 	sz := len(w.batch)
@@ -84,9 +93,13 @@ func (w *BulkWriter) Flush() (int, error) {
 		begin := time.Now()
 		blkOpts := options.BulkWrite()
 		blkOpts.SetOrdered(w.opts.Ordered)
-		resp, err := w.coll.BulkWrite(context.Background(), w.batch, blkOpts)
+		resp, err := w.coll.BulkWrite(ctx, w.batch, blkOpts)
 		if err != nil {
 			log.Error().Err(err).Msg(semLogContext)
+			// unordered writes go on after a failure: the result reports what has been applied anyway.
+			if resp != nil {
+				w.stats.Update(resp, time.Since(begin))
+			}
 			w.batch = w.batch[:0]
 			w.stats.IncErrors(1)
 			return sz, err
@@ -101,10 +114,15 @@ func (w *BulkWriter) Flush() (int, error) {
 }
 
 func (w *BulkWriter) Write(wm mongo.WriteModel) (int, error) {
+	return w.WriteContext(context.Background(), wm)
+}
+
+// WriteContext adds the model to the batch and, when the batch is full, flushes it with FlushContext.
+func (w *BulkWriter) WriteContext(ctx context.Context, wm mongo.WriteModel) (int, error) {
 	const semLogContext = "bulk-writer::write"
 	w.batch = append(w.batch, wm)
 	if w.opts.Size > 0 && len(w.batch) >= w.opts.Size {
-		return w.Flush()
+		return w.FlushContext(ctx)
 	}
 
 	return 0, nil
@@ -126,9 +144,9 @@ func (w *BulkWriter) Update(filter bson.D, updateDoc interface{}, withUpsert boo
 	const semLogContext = "bulk-writer::update"
 
 	if d, ok := updateDoc.(bson.D); ok {
-		log.Trace().Str("filter", util.MustToExtendedJsonString(filter, false, false)).Str("upd", util.MustToExtendedJsonString(d, false, false)).Msg(semLogContext)
+		log.Trace().Str("filter", util.RedactedExtendedJsonString(filter, false, false)).Str("upd", util.RedactedExtendedJsonString(d, false, false)).Msg(semLogContext)
 	} else {
-		log.Trace().Str("filter", util.MustToExtendedJsonString(filter, false, false)).Str("upd-type", fmt.Sprintf("%T", updateDoc)).Msg(semLogContext)
+		log.Trace().Str("filter", util.RedactedExtendedJsonString(filter, false, false)).Str("upd-type", fmt.Sprintf("%T", updateDoc)).Msg(semLogContext)
 	}
 
 	wm := mongo.NewUpdateOneModel().SetUpdate(updateDoc).SetUpsert(withUpsert).SetFilter(filter)
@@ -233,9 +251,9 @@ func (b *BulkWriterSet) Insert(nm string, item interface{}) (int, error) {
 func (b *BulkWriterSet) Update(nm string, filter bson.D, updateDoc interface{}, withUpsert bool) (int, error) {
 	const semLogContext = "bulk-writer-set::update"
 	if d, ok := updateDoc.(bson.D); ok {
-		log.Trace().Str("filter", util.MustToExtendedJsonString(filter, false, false)).Str("upd", util.MustToExtendedJsonString(d, false, false)).Msg(semLogContext)
+		log.Trace().Str("filter", util.RedactedExtendedJsonString(filter, false, false)).Str("upd", util.RedactedExtendedJsonString(d, false, false)).Msg(semLogContext)
 	} else {
-		log.Trace().Str("filter", util.MustToExtendedJsonString(filter, false, false)).Str("upd-type", fmt.Sprintf("%T", updateDoc)).Msg(semLogContext)
+		log.Trace().Str("filter", util.RedactedExtendedJsonString(filter, false, false)).Str("upd-type", fmt.Sprintf("%T", updateDoc)).Msg(semLogContext)
 	}
 	wm := mongo.NewUpdateOneModel().SetUpdate(updateDoc).SetUpsert(withUpsert).SetFilter(filter)
 	return b.Write(nm, wm)
@@ -5,6 +5,7 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/util"
 	"github.com/rs/zerolog/log"
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
 	"go.mongodb.org/mongo-driver/v2/mongo/readconcern"
@@ -54,6 +55,12 @@ type Config struct {
 	Collections            CollectionsCfg `mapstructure:"collections,omitempty" json:"collections,omitempty" yaml:"collections,omitempty"`
 	// WriteTimeout           string         `mapstructure:"write-timeout,omitempty" json:"write-timeout,omitempty" yaml:"write-timeout,omitempty"`
 	// BulkWriteOrdered bool           `mapstructure:"bulk-write-ordered,omitempty" json:"bulk-write-ordered,omitempty" yaml:"bulk-write-ordered,omitempty"`
+
+	// OperationTimeouts are the default timeouts of the json operations by op-type (i.e. find, update-many).
+	OperationTimeouts map[string]time.Duration `mapstructure:"operation-timeouts,omitempty" json:"operation-timeouts,omitempty" yaml:"operation-timeouts,omitempty"`
+
+	// Redaction is the policy applied to the documents logged by the library. It is process wide: at most one linked service can set it.
+	Redaction *util.RedactionPolicyConfig `mapstructure:"redaction,omitempty" json:"redaction,omitempty" yaml:"redaction,omitempty"`
 }
 
 func (cfg *Config) getOptions(opts *options.ClientOptions) *options.ClientOptions {
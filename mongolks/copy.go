@@ -0,0 +1,358 @@
+package mongolks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/util"
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+const (
+	DefaultCopyBatchSize     = 500
+	DefaultCopyProgressEvery = 10000
+)
+
+// CopyTransform rewrites a document on its way to the target. Returning a nil document skips it.
+type CopyTransform func(doc bson.D) (bson.D, error)
+
+type CopyResult struct {
+	Read    int64         `yaml:"read,omitempty" mapstructure:"read,omitempty" json:"read,omitempty"`
+	Written int64         `yaml:"written,omitempty" mapstructure:"written,omitempty" json:"written,omitempty"`
+	Skipped int64         `yaml:"skipped,omitempty" mapstructure:"skipped,omitempty" json:"skipped,omitempty"`
+	LastId  interface{}   `yaml:"last-id,omitempty" mapstructure:"last-id,omitempty" json:"last-id,omitempty"`
+	Elapsed time.Duration `yaml:"elapsed,omitempty" mapstructure:"elapsed,omitempty" json:"elapsed,omitempty"`
+}
+
+type CopyOptions struct {
+	Filter           bson.D
+	BatchSize        int
+	MaxDocsPerSecond int
+	Resume           bool
+	ResumeAfter      interface{}
+	Transforms       []CopyTransform
+	Masking          *util.RedactionPolicy
+	ProgressEvery    int
+	OnProgress       func(res CopyResult)
+}
+
+type CopyOption func(*CopyOptions)
+
+func CopyWithFilter(f bson.D) CopyOption {
+	return func(o *CopyOptions) {
+		o.Filter = f
+	}
+}
+
+func CopyWithBatchSize(sz int) CopyOption {
+	return func(o *CopyOptions) {
+		o.BatchSize = sz
+	}
+}
+
+// CopyWithMaxRate throttles the copy to the given number of documents per second.
+func CopyWithMaxRate(docsPerSecond int) CopyOption {
+	return func(o *CopyOptions) {
+		o.MaxDocsPerSecond = docsPerSecond
+	}
+}
+
+// CopyWithResume restarts an interrupted copy after the greatest _id found in the target collection. The target must hold only the
+// documents of the copy: an _id greater than the ones copied so far, written by someone else, makes the resumed copy skip the source
+// documents in between. CopyWithResumeAfter with the LastId of the interrupted run has no such limit and should be preferred.
+// Resuming relies on the order of the _id values: a source whose _ids are of different types (i.e. strings and ObjectIDs) is rejected,
+// the $gt on the last _id would not match the documents of the other types.
+func CopyWithResume(b bool) CopyOption {
+	return func(o *CopyOptions) {
+		o.Resume = b
+	}
+}
+
+// CopyWithResumeAfter restarts the copy after the given _id, the LastId of the result of a previous run. As for CopyWithResume, the
+// _ids of the source must all be of the type of the given one.
+func CopyWithResumeAfter(id interface{}) CopyOption {
+	return func(o *CopyOptions) {
+		o.ResumeAfter = id
+	}
+}
+
+func CopyWithTransforms(t ...CopyTransform) CopyOption {
+	return func(o *CopyOptions) {
+		o.Transforms = append(o.Transforms, t...)
+	}
+}
+
+// CopyWithMasking applies the redaction rules to the copied documents after the transforms.
+func CopyWithMasking(p *util.RedactionPolicy) CopyOption {
+	return func(o *CopyOptions) {
+		o.Masking = p
+	}
+}
+
+func CopyWithProgress(every int, f func(res CopyResult)) CopyOption {
+	return func(o *CopyOptions) {
+		o.ProgressEvery = every
+		o.OnProgress = f
+	}
+}
+
+// CopyCollection copies the documents of a collection of a linked service to a collection of another one, or the same. Documents are
+// read in _id order and replaced by _id in the target, so that an interrupted copy can be resumed and repeated safely.
+func CopyCollection(ctx context.Context, src *LinkedService, srcCollectionId string, dst *LinkedService, dstCollectionId string, opts ...CopyOption) (CopyResult, error) {
+	const semLogContext = "mongo-lks::copy-collection"
+
+	cpyOpts := CopyOptions{BatchSize: DefaultCopyBatchSize, ProgressEvery: DefaultCopyProgressEvery}
+	for _, o := range opts {
+		o(&cpyOpts)
+	}
+
+	var res CopyResult
+	srcColl := src.GetCollection(srcCollectionId, "")
+	if srcColl == nil {
+		err := fmt.Errorf("cannot find source collection by id %s", srcCollectionId)
+		log.Error().Err(err).Msg(semLogContext)
+		return res, err
+	}
+
+	dstColl := dst.GetCollection(dstCollectionId, "")
+	if dstColl == nil {
+		err := fmt.Errorf("cannot find target collection by id %s", dstCollectionId)
+		log.Error().Err(err).Msg(semLogContext)
+		return res, err
+	}
+
+	lastId := cpyOpts.ResumeAfter
+	if lastId == nil && cpyOpts.Resume {
+		var err error
+		lastId, err = lastCopiedId(ctx, dstColl)
+		if err != nil {
+			log.Error().Err(err).Msg(semLogContext)
+			return res, err
+		}
+	}
+
+	if lastId != nil || cpyOpts.Resume {
+		if err := checkCopyIdTypes(ctx, srcColl, cpyOpts.Filter, lastId); err != nil {
+			log.Error().Err(err).Msg(semLogContext)
+			return res, err
+		}
+	}
+
+	filter := cpyOpts.Filter
+	if lastId != nil {
+		log.Info().Interface("last-id", lastId).Msg(semLogContext + " - resuming copy")
+		idFilter := bson.D{{Key: "_id", Value: bson.D{{Key: "$gt", Value: lastId}}}}
+		if len(filter) > 0 {
+			filter = bson.D{{Key: "$and", Value: bson.A{filter, idFilter}}}
+		} else {
+			filter = idFilter
+		}
+		res.LastId = lastId
+	}
+
+	if filter == nil {
+		filter = bson.D{}
+	}
+
+	crs, err := srcColl.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		log.Error().Err(err).Str("filter", util.RedactedExtendedJsonString(filter, false, false)).Msg(semLogContext)
+		return res, err
+	}
+	defer crs.Close(context.Background())
+
+	wrt := newBulkWriter(dstColl, BulkWriterWithSize(cpyOpts.BatchSize), BulkWriterWithOrdered(true))
+	begin := time.Now()
+	throttle := newCopyThrottle(cpyOpts.MaxDocsPerSecond, begin)
+
+	var pendingLastId interface{}
+	pending := int64(0)
+	onFlush := func(sz int, err error) error {
+		if err != nil {
+			log.Error().Err(err).Interface("last-id", res.LastId).Msg(semLogContext)
+			return err
+		}
+
+		if sz > 0 {
+			res.Written += pending
+			res.LastId = pendingLastId
+			pending = 0
+		}
+
+		return nil
+	}
+
+	for crs.Next(ctx) {
+		var doc bson.D
+		if err = bson.Unmarshal(crs.Current, &doc); err != nil {
+			log.Error().Err(err).Msg(semLogContext)
+			return res, err
+		}
+		res.Read++
+
+		id := crs.Current.Lookup("_id")
+		doc, err = applyCopyTransforms(doc, cpyOpts)
+		if err != nil {
+			log.Error().Err(err).Str("_id", id.String()).Msg(semLogContext)
+			return res, err
+		}
+
+		if doc == nil {
+			res.Skipped++
+			continue
+		}
+
+		pending++
+		pendingLastId = id
+		wm := mongo.NewReplaceOneModel().SetFilter(bson.D{{Key: "_id", Value: id}}).SetReplacement(doc).SetUpsert(true)
+		if err = onFlush(wrt.WriteContext(ctx, wm)); err != nil {
+			return res, err
+		}
+
+		if cpyOpts.OnProgress != nil && cpyOpts.ProgressEvery > 0 && res.Read%int64(cpyOpts.ProgressEvery) == 0 {
+			res.Elapsed = time.Since(begin)
+			cpyOpts.OnProgress(res)
+		}
+
+		if err = throttle.wait(ctx, res.Read); err != nil {
+			return res, err
+		}
+	}
+
+	if err = crs.Err(); err != nil {
+		log.Error().Err(err).Msg(semLogContext)
+		return res, err
+	}
+
+	if err = onFlush(wrt.FlushContext(ctx)); err != nil {
+		return res, err
+	}
+
+	res.Elapsed = time.Since(begin)
+	if cpyOpts.OnProgress != nil {
+		cpyOpts.OnProgress(res)
+	}
+
+	log.Info().Int64("read", res.Read).Int64("written", res.Written).Int64("skipped", res.Skipped).Dur("elapsed", res.Elapsed).Msg(semLogContext)
+	return res, nil
+}
+
+func applyCopyTransforms(doc bson.D, opts CopyOptions) (bson.D, error) {
+	var err error
+	for _, t := range opts.Transforms {
+		doc, err = t(doc)
+		if err != nil || doc == nil {
+			return nil, err
+		}
+	}
+
+	if opts.Masking != nil {
+		doc = opts.Masking.Redact(doc)
+	}
+
+	return doc, nil
+}
+
+func lastCopiedId(ctx context.Context, coll *mongo.Collection) (interface{}, error) {
+	var doc bson.Raw
+	err := coll.FindOne(ctx, bson.D{}, options.FindOne().SetSort(bson.D{{Key: "_id", Value: -1}}).SetProjection(bson.D{{Key: "_id", Value: 1}})).Decode(&doc)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return doc.Lookup("_id"), nil
+}
+
+// checkCopyIdTypes verifies that the _ids of the source can be resumed with a $gt: the values of the different types are ordered
+// by type, so the smallest and the greatest _id share the type only if all of them do. Numbers compare with each other whatever their
+// type.
+func checkCopyIdTypes(ctx context.Context, coll *mongo.Collection, filter bson.D, lastId interface{}) error {
+	if filter == nil {
+		filter = bson.D{}
+	}
+
+	var ids []bson.RawValue
+	for _, dir := range []int{1, -1} {
+		var doc bson.Raw
+		err := coll.FindOne(ctx, filter, options.FindOne().SetSort(bson.D{{Key: "_id", Value: dir}}).SetProjection(bson.D{{Key: "_id", Value: 1}})).Decode(&doc)
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				return nil
+			}
+			return err
+		}
+
+		ids = append(ids, doc.Lookup("_id"))
+	}
+
+	if copyIdTypeClass(ids[0].Type) != copyIdTypeClass(ids[1].Type) {
+		return fmt.Errorf("cannot resume the copy: the source has _ids of type %s and %s", ids[0].Type, ids[1].Type)
+	}
+
+	if lastId == nil {
+		return nil
+	}
+
+	t, ok := lastId.(bson.RawValue)
+	if !ok {
+		var err error
+		if t.Type, t.Value, err = bson.MarshalValue(lastId); err != nil {
+			return err
+		}
+	}
+
+	if copyIdTypeClass(t.Type) != copyIdTypeClass(ids[0].Type) {
+		return fmt.Errorf("cannot resume the copy after an _id of type %s: the source has _ids of type %s", t.Type, ids[0].Type)
+	}
+
+	return nil
+}
+
+func copyIdTypeClass(t bson.Type) bson.Type {
+	switch t {
+	case bson.TypeInt32, bson.TypeInt64, bson.TypeDecimal128:
+		return bson.TypeDouble
+	case bson.TypeSymbol:
+		return bson.TypeString
+	}
+
+	return t
+}
+
+// copyThrottle paces the copy so that the average rate since the start does not exceed the configured one.
+type copyThrottle struct {
+	docsPerSecond int
+	begin         time.Time
+}
+
+func newCopyThrottle(docsPerSecond int, begin time.Time) copyThrottle {
+	return copyThrottle{docsPerSecond: docsPerSecond, begin: begin}
+}
+
+func (t copyThrottle) wait(ctx context.Context, docs int64) error {
+	if t.docsPerSecond <= 0 {
+		return nil
+	}
+
+	ahead := time.Duration(float64(docs)/float64(t.docsPerSecond)*float64(time.Second)) - time.Since(t.begin)
+	if ahead <= 0 {
+		return nil
+	}
+
+	tm := time.NewTimer(ahead)
+	defer tm.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-tm.C:
+		return nil
+	}
+}
@@ -0,0 +1,80 @@
+package mongolks_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/mongolks"
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/util"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestCopyCollection(t *testing.T) {
+	lks := testLinkedService(t)
+
+	src := lks.GetCollection(copySrcCollectionId, "")
+	dst := lks.GetCollection(copyDstCollectionId, "")
+	for _, c := range []string{copySrcCollectionId, copyDstCollectionId} {
+		_, err := lks.GetCollection(c, "").DeleteMany(context.Background(), bson.D{})
+		require.NoError(t, err)
+	}
+
+	docs := make([]interface{}, 0, 10)
+	for i := 0; i < 10; i++ {
+		docs = append(docs, bson.D{{Key: "_id", Value: i}, {Key: "customer", Value: bson.D{{Key: "fiscalCode", Value: "RSSMRA80A01H501U"}}}, {Key: "status", Value: "active"}})
+	}
+	_, err := src.InsertMany(context.Background(), docs)
+	require.NoError(t, err)
+
+	masking := util.MustNewRedactionPolicy(util.RedactionPolicyConfig{Rules: []util.RedactionRule{{Path: "**.fiscalCode", Mode: util.RedactionModeMask}}})
+	errStop := errors.New("stop")
+	stopAt := func(n int32) mongolks.CopyTransform {
+		return func(doc bson.D) (bson.D, error) {
+			if doc[0].Value.(int32) == n {
+				return nil, errStop
+			}
+			return doc, nil
+		}
+	}
+	skipOdd := func(doc bson.D) (bson.D, error) {
+		if doc[0].Value.(int32)%2 == 1 {
+			return nil, nil
+		}
+		return doc, nil
+	}
+
+	// an interrupted copy keeps what has been flushed.
+	res, err := mongolks.CopyCollection(context.Background(), lks, copySrcCollectionId, lks, copyDstCollectionId,
+		mongolks.CopyWithBatchSize(2), mongolks.CopyWithTransforms(stopAt(5)), mongolks.CopyWithMasking(masking))
+	require.ErrorIs(t, err, errStop)
+	require.Equal(t, int64(4), res.Written)
+
+	res, err = mongolks.CopyCollection(context.Background(), lks, copySrcCollectionId, lks, copyDstCollectionId,
+		mongolks.CopyWithResume(true), mongolks.CopyWithTransforms(skipOdd), mongolks.CopyWithMasking(masking), mongolks.CopyWithMaxRate(1000))
+	require.NoError(t, err)
+	require.Equal(t, int64(6), res.Read)
+	require.Equal(t, int64(3), res.Skipped)
+	require.Equal(t, int64(3), res.Written)
+
+	n, err := dst.CountDocuments(context.Background(), bson.D{{Key: "customer.fiscalCode", Value: util.RedactionMaskValue}})
+	require.NoError(t, err)
+	require.Equal(t, int64(7), n)
+
+	// resuming after the LastId of the previous run, the last written _id, reads the skipped documents after it only.
+	res, err = mongolks.CopyCollection(context.Background(), lks, copySrcCollectionId, lks, copyDstCollectionId,
+		mongolks.CopyWithResumeAfter(res.LastId), mongolks.CopyWithTransforms(skipOdd))
+	require.NoError(t, err)
+	require.Equal(t, int64(1), res.Read)
+	require.Equal(t, int64(0), res.Written)
+
+	// a resume after an _id of another type or on a source with mixed _id types is rejected.
+	_, err = mongolks.CopyCollection(context.Background(), lks, copySrcCollectionId, lks, copyDstCollectionId, mongolks.CopyWithResumeAfter("5"))
+	require.Error(t, err)
+
+	_, err = src.InsertOne(context.Background(), bson.D{{Key: "_id", Value: "a"}})
+	require.NoError(t, err)
+	_, err = mongolks.CopyCollection(context.Background(), lks, copySrcCollectionId, lks, copyDstCollectionId, mongolks.CopyWithResume(true))
+	require.Error(t, err)
+}
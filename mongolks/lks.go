@@ -49,6 +49,11 @@ func (lks *LinkedService) WriteTimeout() time.Duration {
 	return lks.writeTimeout
 }
 
+// OperationTimeoutOf returns the default timeout configured for the op-type, zero if none.
+func (lks *LinkedService) OperationTimeoutOf(opType string) time.Duration {
+	return lks.cfg.OperationTimeouts[opType]
+}
+
 func NewLinkedServiceWithConfig(cfg Config) (*LinkedService, error) {
 	lks := LinkedService{cfg: cfg}
 
@@ -3,7 +3,9 @@ package mongolks_test
 import (
 	"fmt"
 	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/mongolks"
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/util"
 	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/v2/bson"
 	"gopkg.in/yaml.v3"
 	"testing"
 	"time"
@@ -57,3 +59,27 @@ func TestConfig(t *testing.T) {
 
 	fmt.Println(string(b))
 }
+
+func TestInitializeRedaction(t *testing.T) {
+	redaction := &util.RedactionPolicyConfig{Rules: []util.RedactionRule{{Path: "**.fiscalCode"}}}
+
+	_, err := mongolks.Initialize([]mongolks.Config{
+		{Name: "default", Host: "mongodb://localhost:27017", Redaction: redaction},
+		{Name: "other", Host: "mongodb://localhost:27017", Redaction: redaction},
+	})
+	require.Error(t, err)
+
+	_, err = mongolks.Initialize([]mongolks.Config{{Name: "default", Host: "mongodb://localhost:27017", Redaction: &util.RedactionPolicyConfig{Rules: []util.RedactionRule{{Path: "a..b"}}}}})
+	require.Error(t, err)
+	require.Nil(t, util.DefaultRedactionPolicy())
+
+	// the registry is initialized again with the configuration of TestMain, the other tests are not affected.
+	cfg := testCfg
+	cfg.Redaction = redaction
+	_, err = mongolks.Initialize([]mongolks.Config{cfg})
+	require.NoError(t, err)
+	defer util.SetDefaultRedactionPolicy(nil)
+
+	require.NotNil(t, util.DefaultRedactionPolicy())
+	require.Equal(t, `{"fiscalCode":"***"}`, util.RedactedExtendedJsonString(bson.D{{Key: "fiscalCode", Value: "RSSMRA80A01H501U"}}, false, false))
+}
@@ -0,0 +1,46 @@
+package mongolks_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/mongolks"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	testLksName           = "default"
+	ndjsonCollectionId    = "ndjson"
+	ndjsonCollectionName  = "tpm_mongo_common_ndjson"
+	copySrcCollectionId   = "copy-src"
+	copySrcCollectionName = "tpm_mongo_common_copy_src"
+	copyDstCollectionId   = "copy-dst"
+	copyDstCollectionName = "tpm_mongo_common_copy_dst"
+)
+
+var testCfg = mongolks.Config{
+	Name:         testLksName,
+	Host:         "mongodb://localhost:27017",
+	DbName:       "tpm_morphia",
+	WriteConcern: "majority",
+	Pool:         mongolks.PoolConfig{MinConn: 1, MaxConn: 5, ConnectTimeout: 1000, MaxConnectionIdleTime: 30000},
+	Collections: []mongolks.CollectionCfg{
+		{Id: ndjsonCollectionId, Name: ndjsonCollectionName},
+		{Id: copySrcCollectionId, Name: copySrcCollectionName},
+		{Id: copyDstCollectionId, Name: copyDstCollectionName},
+	},
+}
+
+func TestMain(m *testing.M) {
+
+	_, err := mongolks.Initialize([]mongolks.Config{testCfg})
+	if err != nil {
+		panic(err)
+	}
+
+	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
+	zerolog.SetGlobalLevel(zerolog.InfoLevel)
+	exitVal := m.Run()
+	os.Exit(exitVal)
+}
@@ -0,0 +1,351 @@
+package mongolks
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/util"
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+const (
+	DefaultNDJsonProgressEvery = 10000
+	DefaultNDJsonBatchSize     = 100
+
+	// ndjsonMaxLineSize is above the 16MB limit of a document to account for the extended json overhead.
+	ndjsonMaxLineSize = 64 * 1024 * 1024
+)
+
+var ErrNDJsonTooManyErrors = errors.New("ndjson import: too many errors")
+
+// NDJsonProgress is reported every ProgressEvery documents and once at the end. Total is only known for exports.
+type NDJsonProgress struct {
+	CollectionId string        `yaml:"collection-id,omitempty" mapstructure:"collection-id,omitempty" json:"collection-id,omitempty"`
+	Documents    int64         `yaml:"documents,omitempty" mapstructure:"documents,omitempty" json:"documents,omitempty"`
+	Total        int64         `yaml:"total,omitempty" mapstructure:"total,omitempty" json:"total,omitempty"`
+	Bytes        int64         `yaml:"bytes,omitempty" mapstructure:"bytes,omitempty" json:"bytes,omitempty"`
+	Errors       int64         `yaml:"errors,omitempty" mapstructure:"errors,omitempty" json:"errors,omitempty"`
+	Elapsed      time.Duration `yaml:"elapsed,omitempty" mapstructure:"elapsed,omitempty" json:"elapsed,omitempty"`
+	Done         bool          `yaml:"done,omitempty" mapstructure:"done,omitempty" json:"done,omitempty"`
+}
+
+type NDJsonProgressFunc func(p NDJsonProgress)
+
+type NDJsonExportOptions struct {
+	Filter        bson.D
+	Projection    bson.D
+	Sort          bson.D
+	ProgressEvery int
+	OnProgress    NDJsonProgressFunc
+}
+
+type NDJsonExportOption func(*NDJsonExportOptions)
+
+func NDJsonExportWithFilter(f bson.D) NDJsonExportOption {
+	return func(o *NDJsonExportOptions) {
+		o.Filter = f
+	}
+}
+
+func NDJsonExportWithProjection(p bson.D) NDJsonExportOption {
+	return func(o *NDJsonExportOptions) {
+		o.Projection = p
+	}
+}
+
+func NDJsonExportWithSort(s bson.D) NDJsonExportOption {
+	return func(o *NDJsonExportOptions) {
+		o.Sort = s
+	}
+}
+
+func NDJsonExportWithProgress(every int, f NDJsonProgressFunc) NDJsonExportOption {
+	return func(o *NDJsonExportOptions) {
+		o.ProgressEvery = every
+		o.OnProgress = f
+	}
+}
+
+// ExportNDJson streams the documents of the collection to w, one canonical extended json document per line.
+func (lks *LinkedService) ExportNDJson(ctx context.Context, collectionId string, w io.Writer, opts ...NDJsonExportOption) (NDJsonProgress, error) {
+	const semLogContext = "mongo-lks::export-ndjson"
+
+	expOpts := NDJsonExportOptions{ProgressEvery: DefaultNDJsonProgressEvery}
+	for _, o := range opts {
+		o(&expOpts)
+	}
+
+	progress := NDJsonProgress{CollectionId: collectionId}
+	coll := lks.GetCollection(collectionId, "")
+	if coll == nil {
+		err := fmt.Errorf("cannot find collection by id %s", collectionId)
+		log.Error().Err(err).Msg(semLogContext)
+		return progress, err
+	}
+
+	filter := expOpts.Filter
+	if filter == nil {
+		filter = bson.D{}
+	}
+
+	if expOpts.OnProgress != nil {
+		var err error
+		if len(filter) == 0 {
+			progress.Total, err = coll.EstimatedDocumentCount(ctx)
+		} else {
+			progress.Total, err = coll.CountDocuments(ctx, filter)
+		}
+		if err != nil {
+			log.Warn().Err(err).Msg(semLogContext + " - cannot count documents")
+		}
+	}
+
+	findOpts := options.Find()
+	if len(expOpts.Projection) > 0 {
+		findOpts.SetProjection(expOpts.Projection)
+	}
+	if len(expOpts.Sort) > 0 {
+		findOpts.SetSort(expOpts.Sort)
+	}
+
+	crs, err := coll.Find(ctx, filter, findOpts)
+	if err != nil {
+		log.Error().Err(err).Str("filter", util.RedactedExtendedJsonString(filter, false, false)).Msg(semLogContext)
+		return progress, err
+	}
+	defer crs.Close(context.Background())
+
+	begin := time.Now()
+	bw := bufio.NewWriter(w)
+	for crs.Next(ctx) {
+		b, err := bson.MarshalExtJSON(crs.Current, true, false)
+		if err != nil {
+			log.Error().Err(err).Msg(semLogContext)
+			return progress, err
+		}
+
+		n, err := bw.Write(append(b, '\n'))
+		if err != nil {
+			log.Error().Err(err).Msg(semLogContext)
+			return progress, err
+		}
+
+		progress.Documents++
+		progress.Bytes += int64(n)
+		if expOpts.OnProgress != nil && expOpts.ProgressEvery > 0 && progress.Documents%int64(expOpts.ProgressEvery) == 0 {
+			progress.Elapsed = time.Since(begin)
+			expOpts.OnProgress(progress)
+		}
+	}
+
+	if err = crs.Err(); err != nil {
+		log.Error().Err(err).Msg(semLogContext)
+		return progress, err
+	}
+
+	if err = bw.Flush(); err != nil {
+		log.Error().Err(err).Msg(semLogContext)
+		return progress, err
+	}
+
+	progress.Elapsed = time.Since(begin)
+	progress.Done = true
+	if expOpts.OnProgress != nil {
+		expOpts.OnProgress(progress)
+	}
+
+	log.Info().Str("collection-id", collectionId).Int64("documents", progress.Documents).Int64("bytes", progress.Bytes).Dur("elapsed", progress.Elapsed).Msg(semLogContext)
+	return progress, nil
+}
+
+// NDJsonImportOptions: with upsert keys the documents replace the ones with the same key values, otherwise they get inserted.
+// MaxErrors is the number of tolerated errors, parse and write errors alike; a negative value tolerates any number of them.
+type NDJsonImportOptions struct {
+	BatchSize     int
+	Ordered       bool
+	UpsertKeys    []string
+	MaxErrors     int
+	ProgressEvery int
+	OnProgress    NDJsonProgressFunc
+}
+
+type NDJsonImportOption func(*NDJsonImportOptions)
+
+func NDJsonImportWithBatchSize(sz int) NDJsonImportOption {
+	return func(o *NDJsonImportOptions) {
+		o.BatchSize = sz
+	}
+}
+
+func NDJsonImportWithOrdered(b bool) NDJsonImportOption {
+	return func(o *NDJsonImportOptions) {
+		o.Ordered = b
+	}
+}
+
+func NDJsonImportWithUpsertKeys(keys ...string) NDJsonImportOption {
+	return func(o *NDJsonImportOptions) {
+		o.UpsertKeys = keys
+	}
+}
+
+func NDJsonImportWithMaxErrors(n int) NDJsonImportOption {
+	return func(o *NDJsonImportOptions) {
+		o.MaxErrors = n
+	}
+}
+
+func NDJsonImportWithProgress(every int, f NDJsonProgressFunc) NDJsonImportOption {
+	return func(o *NDJsonImportOptions) {
+		o.ProgressEvery = every
+		o.OnProgress = f
+	}
+}
+
+type NDJsonImportResult struct {
+	NDJsonProgress `yaml:",inline" mapstructure:",squash" json:",inline"`
+	InsertedCount  int64 `yaml:"inserted,omitempty" mapstructure:"inserted,omitempty" json:"inserted,omitempty"`
+	UpsertedCount  int64 `yaml:"upserted,omitempty" mapstructure:"upserted,omitempty" json:"upserted,omitempty"`
+	ModifiedCount  int64 `yaml:"modified,omitempty" mapstructure:"modified,omitempty" json:"modified,omitempty"`
+}
+
+// ImportNDJson loads the extended json documents read from r, one per line, through a BulkWriter. Blank lines are skipped.
+func (lks *LinkedService) ImportNDJson(ctx context.Context, collectionId string, r io.Reader, opts ...NDJsonImportOption) (NDJsonImportResult, error) {
+	const semLogContext = "mongo-lks::import-ndjson"
+
+	impOpts := NDJsonImportOptions{BatchSize: DefaultNDJsonBatchSize, ProgressEvery: DefaultNDJsonProgressEvery}
+	for _, o := range opts {
+		o(&impOpts)
+	}
+
+	res := NDJsonImportResult{NDJsonProgress: NDJsonProgress{CollectionId: collectionId}}
+	coll := lks.GetCollection(collectionId, "")
+	if coll == nil {
+		err := fmt.Errorf("cannot find collection by id %s", collectionId)
+		log.Error().Err(err).Msg(semLogContext)
+		return res, err
+	}
+
+	wrt := newBulkWriter(coll, BulkWriterWithSize(impOpts.BatchSize), BulkWriterWithOrdered(impOpts.Ordered))
+
+	begin := time.Now()
+	onWrite := func(sz int, err error) error {
+		// a cancelled import is not a write error to tolerate.
+		if err != nil && ctx.Err() != nil {
+			return err
+		}
+
+		if err != nil {
+			n := len(util.Classify(err).WriteErrors)
+			if n == 0 {
+				n = sz
+			}
+			res.Errors += int64(n)
+		}
+
+		return res.checkErrors(impOpts.MaxErrors, err)
+	}
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), ndjsonMaxLineSize)
+	lineNo := 0
+	for sc.Scan() {
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+
+		lineNo++
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		res.Documents++
+		res.Bytes += int64(len(line)) + 1
+
+		wm, err := newNDJsonWriteModel(line, impOpts.UpsertKeys)
+		if err != nil {
+			log.Warn().Err(err).Int("line", lineNo).Msg(semLogContext)
+			res.Errors++
+			if err = res.checkErrors(impOpts.MaxErrors, err); err != nil {
+				return res, err
+			}
+			continue
+		}
+
+		if err = onWrite(wrt.WriteContext(ctx, wm)); err != nil {
+			res.update(wrt.Stats())
+			return res, err
+		}
+
+		if impOpts.OnProgress != nil && impOpts.ProgressEvery > 0 && res.Documents%int64(impOpts.ProgressEvery) == 0 {
+			res.update(wrt.Stats())
+			res.Elapsed = time.Since(begin)
+			impOpts.OnProgress(res.NDJsonProgress)
+		}
+	}
+
+	if err := sc.Err(); err != nil {
+		log.Error().Err(err).Int("line", lineNo).Msg(semLogContext)
+		return res, err
+	}
+
+	err := onWrite(wrt.FlushContext(ctx))
+	res.update(wrt.Stats())
+	if err != nil {
+		return res, err
+	}
+
+	res.Elapsed = time.Since(begin)
+	res.Done = true
+	if impOpts.OnProgress != nil {
+		impOpts.OnProgress(res.NDJsonProgress)
+	}
+
+	log.Info().Str("collection-id", collectionId).Int64("documents", res.Documents).Int64("inserted", res.InsertedCount).Int64("upserted", res.UpsertedCount).Int64("errors", res.Errors).Dur("elapsed", res.Elapsed).Msg(semLogContext)
+	return res, nil
+}
+
+func (res *NDJsonImportResult) update(stats *BulkWriterStatsInfo) {
+	res.InsertedCount = stats.InsertedCount
+	res.UpsertedCount = stats.UpsertedCount
+	res.ModifiedCount = stats.ModifiedCount
+}
+
+func (res *NDJsonImportResult) checkErrors(maxErrors int, err error) error {
+	if err == nil || maxErrors < 0 || res.Errors <= int64(maxErrors) {
+		return nil
+	}
+
+	return fmt.Errorf("%w (%d): %w", ErrNDJsonTooManyErrors, res.Errors, err)
+}
+
+func newNDJsonWriteModel(line []byte, upsertKeys []string) (mongo.WriteModel, error) {
+	doc, err := util.UnmarshalJson2BsonD(line, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(upsertKeys) == 0 {
+		return mongo.NewInsertOneModel().SetDocument(doc), nil
+	}
+
+	filter := make(bson.D, 0, len(upsertKeys))
+	for _, k := range upsertKeys {
+		v, ok := util.LookupDocumentPath(doc, k)
+		if !ok {
+			return nil, fmt.Errorf("document has no value for the upsert key %s", k)
+		}
+		filter = append(filter, bson.E{Key: k, Value: v})
+	}
+
+	return mongo.NewReplaceOneModel().SetFilter(filter).SetReplacement(doc).SetUpsert(true), nil
+}
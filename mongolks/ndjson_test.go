@@ -0,0 +1,92 @@
+package mongolks_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/mongolks"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func testLinkedService(t *testing.T) *mongolks.LinkedService {
+	lks, err := mongolks.GetLinkedService(context.Background(), testLksName)
+	require.NoError(t, err)
+	return lks
+}
+
+func TestNDJsonExportImport(t *testing.T) {
+	lks := testLinkedService(t)
+
+	coll := lks.GetCollection(ndjsonCollectionId, "")
+	_, err := coll.DeleteMany(context.Background(), bson.D{})
+	require.NoError(t, err)
+
+	data := strings.Join([]string{
+		`{"_id": 1, "code": "a", "n": {"$numberLong": "1"}}`,
+		``,
+		`{"_id": 2, "code": "b", "n": {"$numberDecimal": "1.5"}}`,
+		`{"_id": 3, "code": "c", "n": 2`,
+		`{"_id": 4, "code": "d", "n": {"$date": "2024-01-01T00:00:00Z"}}`,
+	}, "\n")
+
+	// the malformed line exceeds the default tolerance.
+	_, err = lks.ImportNDJson(context.Background(), ndjsonCollectionId, strings.NewReader(data))
+	require.ErrorIs(t, err, mongolks.ErrNDJsonTooManyErrors)
+
+	res, err := lks.ImportNDJson(context.Background(), ndjsonCollectionId, strings.NewReader(data),
+		mongolks.NDJsonImportWithMaxErrors(1), mongolks.NDJsonImportWithUpsertKeys("code"), mongolks.NDJsonImportWithBatchSize(2))
+	require.NoError(t, err)
+	require.Equal(t, int64(4), res.Documents)
+	require.Equal(t, int64(1), res.Errors)
+	require.Equal(t, int64(3), res.UpsertedCount)
+
+	var progress []mongolks.NDJsonProgress
+	var buf bytes.Buffer
+	exp, err := lks.ExportNDJson(context.Background(), ndjsonCollectionId, &buf,
+		mongolks.NDJsonExportWithFilter(bson.D{{Key: "_id", Value: bson.D{{Key: "$lte", Value: 2}}}}),
+		mongolks.NDJsonExportWithProjection(bson.D{{Key: "code", Value: 0}}),
+		mongolks.NDJsonExportWithSort(bson.D{{Key: "_id", Value: 1}}),
+		mongolks.NDJsonExportWithProgress(1, func(p mongolks.NDJsonProgress) { progress = append(progress, p) }))
+	require.NoError(t, err)
+	require.Equal(t, int64(2), exp.Documents)
+	require.Equal(t, int64(2), exp.Total)
+	require.Len(t, progress, 3)
+	require.True(t, progress[2].Done)
+	require.Equal(t, `{"_id":{"$numberInt":"1"},"n":{"$numberLong":"1"}}`+"\n"+`{"_id":{"$numberInt":"2"},"n":{"$numberDecimal":"1.5"}}`+"\n", buf.String())
+}
+
+// cancelAtEOF cancels the import once the whole input has been read, so that only the last flush sees the cancellation.
+type cancelAtEOF struct {
+	r      io.Reader
+	cancel context.CancelFunc
+}
+
+func (c cancelAtEOF) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	if err == io.EOF {
+		c.cancel()
+	}
+	return n, err
+}
+
+func TestNDJsonImportCancelled(t *testing.T) {
+	lks := testLinkedService(t)
+
+	coll := lks.GetCollection(ndjsonCollectionId, "")
+	_, err := coll.DeleteMany(context.Background(), bson.D{})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	_, err = lks.ImportNDJson(ctx, ndjsonCollectionId, cancelAtEOF{r: strings.NewReader(`{"_id": 1, "code": "a"}`), cancel: cancel})
+	require.ErrorIs(t, err, context.Canceled)
+
+	n, err := coll.CountDocuments(context.Background(), bson.D{})
+	require.NoError(t, err)
+	require.Equal(t, int64(0), n)
+}
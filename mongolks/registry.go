@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/util"
 	"github.com/rs/zerolog/log"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 )
@@ -27,6 +28,11 @@ func Initialize(cfgs []Config) (LinkedServices, error) {
 
 	log.Info().Int("no-linked-services", len(cfgs)).Msg(semLogContext)
 
+	if err := initRedactionPolicy(cfgs); err != nil {
+		log.Error().Err(err).Msg(semLogContext)
+		return nil, err
+	}
+
 	var r LinkedServices
 	for _, kcfg := range cfgs {
 		lks, err := NewLinkedServiceWithConfig(kcfg)
@@ -43,6 +49,37 @@ func Initialize(cfgs []Config) (LinkedServices, error) {
 	return r, nil
 }
 
+// initRedactionPolicy sets the default redaction policy from the configuration. With no redaction configured the default policy is left
+// as it is: an application can still set it with util.SetDefaultRedactionPolicy.
+func initRedactionPolicy(cfgs []Config) error {
+	const semLogContext = "mongo-lks-registry::init-redaction-policy"
+
+	var rcfg *Config
+	for i := range cfgs {
+		if cfgs[i].Redaction == nil {
+			continue
+		}
+
+		if rcfg != nil {
+			return fmt.Errorf("redaction configured on both linked services %s and %s", rcfg.Name, cfgs[i].Name)
+		}
+		rcfg = &cfgs[i]
+	}
+
+	if rcfg == nil {
+		return nil
+	}
+
+	p, err := util.NewRedactionPolicy(*rcfg.Redaction)
+	if err != nil {
+		return err
+	}
+
+	log.Info().Str("name", rcfg.Name).Int("no-rules", len(rcfg.Redaction.Rules)).Msg(semLogContext)
+	util.SetDefaultRedactionPolicy(p)
+	return nil
+}
+
 func GetLinkedService(ctx context.Context, stgName string) (*LinkedService, error) {
 	const semLogContext = "mongo-lks-registry::get-lks"
 	for _, stg := range theRegistry {
@@ -0,0 +1,60 @@
+package mongolks
+
+import (
+	"context"
+	"fmt"
+
+	mongoUtil "github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/util"
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+const DefaultSchemaSampleSize = 1000
+
+// InferSchema samples sampleSize documents (DefaultSchemaSampleSize if not positive) of the collection with $sample and infers their
+// schema. The result can be rendered as a $jsonSchema validator or kept as documentation of the collection.
+func (lks *LinkedService) InferSchema(ctx context.Context, collectionId string, sampleSize int) (mongoUtil.InferredSchema, error) {
+	const semLogContext = "mongo-lks::infer-schema"
+
+	coll := lks.GetCollection(collectionId, "")
+	if coll == nil {
+		err := fmt.Errorf("cannot find collection by id %s", collectionId)
+		log.Error().Err(err).Msg(semLogContext)
+		return mongoUtil.InferredSchema{}, err
+	}
+
+	return InferCollectionSchema(ctx, coll, sampleSize)
+}
+
+func InferCollectionSchema(ctx context.Context, coll *mongo.Collection, sampleSize int) (mongoUtil.InferredSchema, error) {
+	const semLogContext = "mongo-lks::infer-collection-schema"
+
+	if sampleSize <= 0 {
+		sampleSize = DefaultSchemaSampleSize
+	}
+
+	crs, err := coll.Aggregate(ctx, mongo.Pipeline{{{Key: "$sample", Value: bson.D{{Key: "size", Value: sampleSize}}}}})
+	if err != nil {
+		log.Error().Err(err).Str("collection", coll.Name()).Msg(semLogContext)
+		return mongoUtil.InferredSchema{}, err
+	}
+	defer crs.Close(context.Background())
+
+	si := mongoUtil.NewSchemaInferrer()
+	for crs.Next(ctx) {
+		if err = si.Add(crs.Current); err != nil {
+			log.Error().Err(err).Str("collection", coll.Name()).Msg(semLogContext)
+			return mongoUtil.InferredSchema{}, err
+		}
+	}
+
+	if err = crs.Err(); err != nil {
+		log.Error().Err(err).Str("collection", coll.Name()).Msg(semLogContext)
+		return mongoUtil.InferredSchema{}, err
+	}
+
+	s := si.Schema()
+	log.Info().Str("collection", coll.Name()).Int("sample-size", s.SampleSize).Int("num-fields", len(s.Fields)).Msg(semLogContext)
+	return s, nil
+}
@@ -0,0 +1,33 @@
+package util
+
+import (
+	"strings"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// LookupDocumentPath returns the value at the dot separated path of the document (i.e. "customer.code"). Only embedded documents are
+// traversed: a segment is never an array index.
+func LookupDocumentPath(doc bson.D, path string) (interface{}, bool) {
+	var v interface{} = doc
+	for _, s := range strings.Split(path, ".") {
+		d, ok := v.(bson.D)
+		if !ok {
+			return nil, false
+		}
+
+		found := false
+		for _, e := range d {
+			if e.Key == s {
+				v, found = e.Value, true
+				break
+			}
+		}
+
+		if !found {
+			return nil, false
+		}
+	}
+
+	return v, true
+}
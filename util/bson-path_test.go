@@ -0,0 +1,26 @@
+package util_test
+
+import (
+	"testing"
+
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/util"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestLookupDocumentPath(t *testing.T) {
+	doc := bson.D{{Key: "code", Value: "A01"}, {Key: "ref", Value: bson.D{{Key: "code", Value: int32(1)}}}, {Key: "items", Value: bson.A{bson.D{{Key: "code", Value: "x"}}}}}
+
+	v, ok := util.LookupDocumentPath(doc, "code")
+	require.True(t, ok)
+	require.Equal(t, "A01", v)
+
+	v, ok = util.LookupDocumentPath(doc, "ref.code")
+	require.True(t, ok)
+	require.Equal(t, int32(1), v)
+
+	for _, path := range []string{"missing", "ref.missing", "code.sub", "items.0.code"} {
+		_, ok = util.LookupDocumentPath(doc, path)
+		require.False(t, ok, path)
+	}
+}
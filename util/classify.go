@@ -0,0 +1,297 @@
+package util
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+type ErrorCategory string
+
+const (
+	ErrorCategoryNone           ErrorCategory = ""
+	ErrorCategoryDuplicateKey   ErrorCategory = "duplicate-key"
+	ErrorCategoryValidation     ErrorCategory = "validation"
+	ErrorCategoryNetwork        ErrorCategory = "network"
+	ErrorCategoryTimeout        ErrorCategory = "timeout"
+	ErrorCategoryNotPrimary     ErrorCategory = "not-primary"
+	ErrorCategoryAuth           ErrorCategory = "auth"
+	ErrorCategoryHistoryLost    ErrorCategory = "history-lost"
+	ErrorCategoryWriteConcern   ErrorCategory = "write-concern"
+	ErrorCategoryCursorNotFound ErrorCategory = "cursor-not-found"
+	ErrorCategoryOther          ErrorCategory = "other"
+)
+
+const (
+	ErrorLabelRetryableWrite        = "RetryableWriteError"
+	ErrorLabelTransientTransaction  = "TransientTransactionError"
+	ErrorLabelResumableChangeStream = "ResumableChangeStreamError"
+	ErrorLabelNetwork               = "NetworkError"
+
+	// legacy duplicate key codes of updates and find and modify.
+	mongoErrDuplicateKeyOnUpdate        int32 = 11001
+	mongoErrDuplicateKeyOnFindAndModify int32 = 12582
+)
+
+// Sentinels of the error categories: errors.Is(util.Classify(err), util.ErrDuplicateKey) holds for a duplicate key error.
+var (
+	ErrDuplicateKey   = errors.New("mongo duplicate key error")
+	ErrValidation     = errors.New("mongo document validation error")
+	ErrNetwork        = errors.New("mongo network error")
+	ErrTimeout        = errors.New("mongo timeout error")
+	ErrNotPrimary     = errors.New("mongo not primary error")
+	ErrAuth           = errors.New("mongo authentication or authorization error")
+	ErrHistoryLost    = errors.New("mongo change stream history lost")
+	ErrWriteConcern   = errors.New("mongo write concern error")
+	ErrCursorNotFound = errors.New("mongo cursor not found")
+)
+
+var errorCategorySentinels = map[ErrorCategory]error{
+	ErrorCategoryDuplicateKey:   ErrDuplicateKey,
+	ErrorCategoryValidation:     ErrValidation,
+	ErrorCategoryNetwork:        ErrNetwork,
+	ErrorCategoryTimeout:        ErrTimeout,
+	ErrorCategoryNotPrimary:     ErrNotPrimary,
+	ErrorCategoryAuth:           ErrAuth,
+	ErrorCategoryHistoryLost:    ErrHistoryLost,
+	ErrorCategoryWriteConcern:   ErrWriteConcern,
+	ErrorCategoryCursorNotFound: ErrCursorNotFound,
+}
+
+var errorCodeCategories = map[int32]ErrorCategory{
+	MongoErrDuplicateKey:                      ErrorCategoryDuplicateKey,
+	mongoErrDuplicateKeyOnUpdate:              ErrorCategoryDuplicateKey,
+	mongoErrDuplicateKeyOnFindAndModify:       ErrorCategoryDuplicateKey,
+	MongoErrDocumentValidationFailure:         ErrorCategoryValidation,
+	MongoErrHostUnreachable:                   ErrorCategoryNetwork,
+	MongoErrHostNotFound:                      ErrorCategoryNetwork,
+	MongoErrSocketException:                   ErrorCategoryNetwork,
+	MongoErrNetworkTimeout:                    ErrorCategoryTimeout,
+	MongoErrMaxTimeMSExpired:                  ErrorCategoryTimeout,
+	MongoErrExceededTimeLimit:                 ErrorCategoryTimeout,
+	MongoErrNetworkInterfaceExceededTimeLimit: ErrorCategoryTimeout,
+	MongoErrLockTimeout:                       ErrorCategoryTimeout,
+	MongoErrNotWritablePrimary:                ErrorCategoryNotPrimary,
+	MongoErrNotPrimaryNoSecondaryOk:           ErrorCategoryNotPrimary,
+	MongoErrNotPrimaryOrSecondary:             ErrorCategoryNotPrimary,
+	MongoErrPrimarySteppedDown:                ErrorCategoryNotPrimary,
+	MongoErrInterruptedDueToReplStateChange:   ErrorCategoryNotPrimary,
+	MongoErrShutdownInProgress:                ErrorCategoryNotPrimary,
+	MongoErrInterruptedAtShutdown:             ErrorCategoryNotPrimary,
+	MongoErrUnauthorized:                      ErrorCategoryAuth,
+	MongoErrAuthenticationFailed:              ErrorCategoryAuth,
+	MongoErrAuthenticationRestrictionUnmet:    ErrorCategoryAuth,
+	MongoErrChangeStreamHistoryLost:           ErrorCategoryHistoryLost,
+	MongoErrWriteConcernFailed:                ErrorCategoryWriteConcern,
+	MongoErrUnsatisfiableWriteConcern:         ErrorCategoryWriteConcern,
+	MongoErrCursorNotFound:                    ErrorCategoryCursorNotFound,
+}
+
+// retryableErrorCodes are the codes the retryable writes specification considers retryable, plus the write conflicts of transactions.
+var retryableErrorCodes = map[int32]struct{}{
+	MongoErrHostUnreachable:                 {},
+	MongoErrHostNotFound:                    {},
+	MongoErrNetworkTimeout:                  {},
+	MongoErrShutdownInProgress:              {},
+	MongoErrPrimarySteppedDown:              {},
+	MongoErrExceededTimeLimit:               {},
+	MongoErrSocketException:                 {},
+	MongoErrNotWritablePrimary:              {},
+	MongoErrInterruptedAtShutdown:           {},
+	MongoErrInterruptedDueToReplStateChange: {},
+	MongoErrNotPrimaryNoSecondaryOk:         {},
+	MongoErrNotPrimaryOrSecondary:           {},
+	MongoErrWriteConflict:                   {},
+}
+
+type WriteErrorInfo struct {
+	Index    int    `yaml:"index" mapstructure:"index" json:"index"`
+	Code     int32  `yaml:"code,omitempty" mapstructure:"code,omitempty" json:"code,omitempty"`
+	CodeName string `yaml:"code-name,omitempty" mapstructure:"code-name,omitempty" json:"code-name,omitempty"`
+	Message  string `yaml:"message,omitempty" mapstructure:"message,omitempty" json:"message,omitempty"`
+}
+
+// Classification is the structured view of an error returned by the driver. The code is the one of the command error, of the first
+// write error or of the write concern error, in that order; all the write errors are reported with the index of the failed write.
+// A Classification is itself an error and matches the sentinel of its category with errors.Is.
+type Classification struct {
+	Category          ErrorCategory    `yaml:"category,omitempty" mapstructure:"category,omitempty" json:"category,omitempty"`
+	Code              int32            `yaml:"code,omitempty" mapstructure:"code,omitempty" json:"code,omitempty"`
+	CodeName          string           `yaml:"code-name,omitempty" mapstructure:"code-name,omitempty" json:"code-name,omitempty"`
+	Message           string           `yaml:"message,omitempty" mapstructure:"message,omitempty" json:"message,omitempty"`
+	Retryable         bool             `yaml:"retryable,omitempty" mapstructure:"retryable,omitempty" json:"retryable,omitempty"`
+	Labels            []string         `yaml:"labels,omitempty" mapstructure:"labels,omitempty" json:"labels,omitempty"`
+	WriteErrors       []WriteErrorInfo `yaml:"write-errors,omitempty" mapstructure:"write-errors,omitempty" json:"write-errors,omitempty"`
+	WriteConcernError *WriteErrorInfo  `yaml:"write-concern-error,omitempty" mapstructure:"write-concern-error,omitempty" json:"write-concern-error,omitempty"`
+	Err               error            `yaml:"-" mapstructure:"-" json:"-"`
+}
+
+func (c Classification) Error() string {
+	if c.Err != nil {
+		return c.Err.Error()
+	}
+
+	return string(c.Category)
+}
+
+func (c Classification) Unwrap() error {
+	return c.Err
+}
+
+func (c Classification) Is(target error) bool {
+	s, ok := errorCategorySentinels[c.Category]
+	return ok && s == target
+}
+
+func (c Classification) IsZero() bool {
+	return c.Err == nil
+}
+
+func (c Classification) HasLabel(label string) bool {
+	for _, l := range c.Labels {
+		if l == label {
+			return true
+		}
+	}
+
+	return false
+}
+
+type ClassifyOptions struct {
+	ServerVersion MongoDbVersion
+}
+
+type ClassifyOption func(opts *ClassifyOptions)
+
+// WithServerVersion enables the version specific mappings: on 4.x servers the ChangeStreamFatalError code reports a lost history.
+func WithServerVersion(v MongoDbVersion) ClassifyOption {
+	return func(opts *ClassifyOptions) {
+		opts.ServerVersion = v
+	}
+}
+
+// Classify maps an error of the driver to its category. It does not log: callers decide the level at which the outcome is reported.
+// A nil error gives the zero Classification.
+func Classify(err error, opts ...ClassifyOption) Classification {
+	if err == nil {
+		return Classification{}
+	}
+
+	clsOpts := ClassifyOptions{}
+	for _, o := range opts {
+		o(&clsOpts)
+	}
+
+	c := Classification{Err: err, Message: err.Error()}
+
+	var cmdErr mongo.CommandError
+	var writeExc mongo.WriteException
+	var bulkExc mongo.BulkWriteException
+	switch {
+	case errors.As(err, &cmdErr):
+		c.Code, c.CodeName = cmdErr.Code, cmdErr.Name
+		if cmdErr.Code == MongoErrChangeStreamFatalError && clsOpts.ServerVersion.IsVersion4() {
+			c.Code, c.CodeName = MongoErrChangeStreamHistoryLost, ""
+		}
+		c.Message = cmdErr.Message
+		c.Labels = cmdErr.Labels
+	case errors.As(err, &writeExc):
+		c.Labels = writeExc.Labels
+		for _, we := range writeExc.WriteErrors {
+			c.WriteErrors = append(c.WriteErrors, newWriteErrorInfo(we))
+		}
+		c.WriteConcernError = newWriteConcernErrorInfo(writeExc.WriteConcernError)
+	case errors.As(err, &bulkExc):
+		c.Labels = bulkExc.Labels
+		for _, we := range bulkExc.WriteErrors {
+			c.WriteErrors = append(c.WriteErrors, newWriteErrorInfo(we.WriteError))
+		}
+		c.WriteConcernError = newWriteConcernErrorInfo(bulkExc.WriteConcernError)
+	}
+
+	switch {
+	case len(c.WriteErrors) > 0:
+		c.Code, c.CodeName, c.Message = c.WriteErrors[0].Code, c.WriteErrors[0].CodeName, c.WriteErrors[0].Message
+	case c.WriteConcernError != nil:
+		c.Code, c.CodeName, c.Message = c.WriteConcernError.Code, c.WriteConcernError.CodeName, c.WriteConcernError.Message
+	}
+
+	if c.CodeName == "" {
+		c.CodeName = MongoErrorCodeName(c.Code)
+	}
+
+	c.Category = classifyCategory(err, c)
+	c.Retryable = isRetryable(c)
+	return c
+}
+
+func classifyCategory(err error, c Classification) ErrorCategory {
+	for _, we := range c.WriteErrors {
+		if cat, ok := errorCodeCategories[we.Code]; ok {
+			return cat
+		}
+	}
+
+	if c.WriteConcernError != nil {
+		return ErrorCategoryWriteConcern
+	}
+
+	if cat, ok := errorCodeCategories[c.Code]; ok {
+		return cat
+	}
+
+	switch {
+	case mongo.IsDuplicateKeyError(err):
+		return ErrorCategoryDuplicateKey
+	case errors.Is(err, context.DeadlineExceeded) || mongo.IsTimeout(err):
+		return ErrorCategoryTimeout
+	case mongo.IsNetworkError(err):
+		return ErrorCategoryNetwork
+	}
+
+	return ErrorCategoryOther
+}
+
+// isRetryable reports the errors a new attempt can fix: the ones labelled as retryable by the server or the driver, network and
+// state change errors. Write errors are never retryable, a duplicate key stays a duplicate key.
+func isRetryable(c Classification) bool {
+	if len(c.WriteErrors) > 0 {
+		return false
+	}
+
+	for _, l := range c.Labels {
+		switch l {
+		case ErrorLabelRetryableWrite, ErrorLabelTransientTransaction, ErrorLabelResumableChangeStream, ErrorLabelNetwork:
+			return true
+		}
+	}
+
+	if _, ok := retryableErrorCodes[c.Code]; ok {
+		return true
+	}
+
+	return c.Category == ErrorCategoryNetwork || c.Category == ErrorCategoryNotPrimary
+}
+
+func newWriteErrorInfo(we mongo.WriteError) WriteErrorInfo {
+	return WriteErrorInfo{
+		Index:    we.Index,
+		Code:     int32(we.Code),
+		CodeName: MongoErrorCodeName(int32(we.Code)),
+		Message:  we.Message,
+	}
+}
+
+func newWriteConcernErrorInfo(wce *mongo.WriteConcernError) *WriteErrorInfo {
+	if wce == nil {
+		return nil
+	}
+
+	name := wce.Name
+	if name == "" {
+		name = MongoErrorCodeName(int32(wce.Code))
+	}
+
+	return &WriteErrorInfo{Code: int32(wce.Code), CodeName: name, Message: wce.Message}
+}
@@ -0,0 +1,80 @@
+package util_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/util"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+func TestClassify(t *testing.T) {
+	c := util.Classify(nil)
+	require.True(t, c.IsZero())
+	require.Equal(t, util.ErrorCategoryNone, c.Category)
+
+	bulkErr := mongo.BulkWriteException{
+		WriteErrors: []mongo.BulkWriteError{
+			{WriteError: mongo.WriteError{Index: 1, Code: 11000, Message: "E11000 duplicate key error collection"}},
+			{WriteError: mongo.WriteError{Index: 4, Code: 121, Message: "Document failed validation"}},
+		},
+	}
+	c = util.Classify(fmt.Errorf("sync failed: %w", bulkErr))
+	require.Equal(t, util.ErrorCategoryDuplicateKey, c.Category)
+	require.Equal(t, int32(11000), c.Code)
+	require.Equal(t, "DuplicateKey", c.CodeName)
+	require.False(t, c.Retryable)
+	require.Len(t, c.WriteErrors, 2)
+	require.Equal(t, 4, c.WriteErrors[1].Index)
+	require.Equal(t, "DocumentValidationFailure", c.WriteErrors[1].CodeName)
+	require.True(t, errors.Is(c, util.ErrDuplicateKey))
+	require.False(t, errors.Is(c, util.ErrValidation))
+	require.True(t, errors.As(c, &bulkErr))
+
+	c = util.Classify(mongo.WriteException{WriteConcernError: &mongo.WriteConcernError{Code: 64, Message: "waiting for replication timed out"}, Labels: []string{util.ErrorLabelRetryableWrite}})
+	require.Equal(t, util.ErrorCategoryWriteConcern, c.Category)
+	require.Equal(t, "WriteConcernFailed", c.CodeName)
+	require.True(t, c.Retryable)
+	require.True(t, c.HasLabel(util.ErrorLabelRetryableWrite))
+	require.True(t, errors.Is(c, util.ErrWriteConcern))
+
+	// the classification reports the write errors first, MongoErrorCode keeps the write concern error first.
+	bothErr := mongo.WriteException{
+		WriteConcernError: &mongo.WriteConcernError{Code: 64, Message: "waiting for replication timed out"},
+		WriteErrors:       []mongo.WriteError{{Code: 11000, Message: "E11000 duplicate key error collection"}},
+	}
+	require.Equal(t, int32(11000), util.Classify(bothErr).Code)
+	require.Equal(t, int32(64), util.MongoErrorCode(bothErr, util.MongoDbVersion{}))
+	require.Equal(t, util.MongoErrInternalError, util.MongoErrorCode(errors.New("no code"), util.MongoDbVersion{}))
+
+	c = util.Classify(mongo.CommandError{Code: 189, Name: "PrimarySteppedDown"})
+	require.Equal(t, util.ErrorCategoryNotPrimary, c.Category)
+	require.True(t, c.Retryable)
+
+	c = util.Classify(mongo.CommandError{Code: 43, Message: "cursor id 1 not found"})
+	require.True(t, errors.Is(c, util.ErrCursorNotFound))
+	require.False(t, c.Retryable)
+
+	c = util.Classify(mongo.CommandError{Code: 18, Message: "Authentication failed."})
+	require.True(t, errors.Is(c, util.ErrAuth))
+
+	c = util.Classify(context.DeadlineExceeded)
+	require.True(t, errors.Is(c, util.ErrTimeout))
+	require.Equal(t, int32(0), c.Code)
+
+	c = util.Classify(mongo.CommandError{Code: 286, Message: "Resume of change stream was not possible"})
+	require.True(t, errors.Is(c, util.ErrHistoryLost))
+
+	fatalErr := mongo.CommandError{Code: 280, Message: "cannot resume stream"}
+	require.False(t, errors.Is(util.Classify(fatalErr), util.ErrHistoryLost))
+	c = util.Classify(fatalErr, util.WithServerVersion(util.NewMongoDbVersion("4.4.29")))
+	require.True(t, errors.Is(c, util.ErrHistoryLost))
+	require.Equal(t, "ChangeStreamHistoryLost", c.CodeName)
+
+	c = util.Classify(errors.New("cannot find requested collection"))
+	require.Equal(t, util.ErrorCategoryOther, c.Category)
+	require.Equal(t, "cannot find requested collection", c.Message)
+}
@@ -0,0 +1,346 @@
+package util
+
+// mongoErrorCodeNames maps the codes listed in errors.go to the code names reported by the server.
+var mongoErrorCodeNames = map[int32]string{
+	MongoErrInternalError:                                               "InternalError",
+	MongoErrBadValue:                                                    "BadValue",
+	MongoErrNoSuchKey:                                                   "NoSuchKey",
+	MongoErrGraphContainsCycle:                                          "GraphContainsCycle",
+	MongoErrHostUnreachable:                                             "HostUnreachable",
+	MongoErrHostNotFound:                                                "HostNotFound",
+	MongoErrUnknownError:                                                "UnknownError",
+	MongoErrFailedToParse:                                               "FailedToParse",
+	MongoErrCannotMutateObject:                                          "CannotMutateObject",
+	MongoErrUserNotFound:                                                "UserNotFound",
+	MongoErrUnsupportedFormat:                                           "UnsupportedFormat",
+	MongoErrUnauthorized:                                                "Unauthorized",
+	MongoErrTypeMismatch:                                                "TypeMismatch",
+	MongoErrOverflow:                                                    "Overflow",
+	MongoErrInvalidLength:                                               "InvalidLength",
+	MongoErrProtocolError:                                               "ProtocolError",
+	MongoErrAuthenticationFailed:                                        "AuthenticationFailed",
+	MongoErrCannotReuseObject:                                           "CannotReuseObject",
+	MongoErrIllegalOperation:                                            "IllegalOperation",
+	MongoErrEmptyArrayOperation:                                         "EmptyArrayOperation",
+	MongoErrInvalidBSON:                                                 "InvalidBSON",
+	MongoErrAlreadyInitialized:                                          "AlreadyInitialized",
+	MongoErrLockTimeout:                                                 "LockTimeout",
+	MongoErrRemoteValidationError:                                       "RemoteValidationError",
+	MongoErrNamespaceNotFound:                                           "NamespaceNotFound",
+	MongoErrIndexNotFound:                                               "IndexNotFound",
+	MongoErrPathNotViable:                                               "PathNotViable",
+	MongoErrNonExistentPath:                                             "NonExistentPath",
+	MongoErrInvalidPath:                                                 "InvalidPath",
+	MongoErrRoleNotFound:                                                "RoleNotFound",
+	MongoErrRolesNotRelated:                                             "RolesNotRelated",
+	MongoErrPrivilegeNotFound:                                           "PrivilegeNotFound",
+	MongoErrCannotBackfillArray:                                         "CannotBackfillArray",
+	MongoErrUserModificationFailed:                                      "UserModificationFailed",
+	MongoErrRemoteChangeDetected:                                        "RemoteChangeDetected",
+	MongoErrFileRenameFailed:                                            "FileRenameFailed",
+	MongoErrFileNotOpen:                                                 "FileNotOpen",
+	MongoErrFileStreamFailed:                                            "FileStreamFailed",
+	MongoErrConflictingUpdateOperators:                                  "ConflictingUpdateOperators",
+	MongoErrFileAlreadyOpen:                                             "FileAlreadyOpen",
+	MongoErrLogWriteFailed:                                              "LogWriteFailed",
+	MongoErrCursorNotFound:                                              "CursorNotFound",
+	MongoErrUserDataInconsistent:                                        "UserDataInconsistent",
+	MongoErrLockBusy:                                                    "LockBusy",
+	MongoErrNoMatchingDocument:                                          "NoMatchingDocument",
+	MongoErrNamespaceExists:                                             "NamespaceExists",
+	MongoErrInvalidRoleModification:                                     "InvalidRoleModification",
+	MongoErrMaxTimeMSExpired:                                            "MaxTimeMSExpired",
+	MongoErrManualInterventionRequired:                                  "ManualInterventionRequired",
+	MongoErrDollarPrefixedFieldName:                                     "DollarPrefixedFieldName",
+	MongoErrInvalidIdField:                                              "InvalidIdField",
+	MongoErrNotSingleValueField:                                         "NotSingleValueField",
+	MongoErrInvalidDBRef:                                                "InvalidDBRef",
+	MongoErrEmptyFieldName:                                              "EmptyFieldName",
+	MongoErrDottedFieldName:                                             "DottedFieldName",
+	MongoErrRoleModificationFailed:                                      "RoleModificationFailed",
+	MongoErrCommandNotFound:                                             "CommandNotFound",
+	MongoErrShardKeyNotFound:                                            "ShardKeyNotFound",
+	MongoErrOplogOperationUnsupported:                                   "OplogOperationUnsupported",
+	MongoErrStaleShardVersion:                                           "StaleShardVersion",
+	MongoErrWriteConcernFailed:                                          "WriteConcernFailed",
+	MongoErrMultipleErrorsOccurred:                                      "MultipleErrorsOccurred",
+	MongoErrImmutableField:                                              "ImmutableField",
+	MongoErrCannotCreateIndex:                                           "CannotCreateIndex",
+	MongoErrIndexAlreadyExists:                                          "IndexAlreadyExists",
+	MongoErrAuthSchemaIncompatible:                                      "AuthSchemaIncompatible",
+	MongoErrShardNotFound:                                               "ShardNotFound",
+	MongoErrReplicaSetNotFound:                                          "ReplicaSetNotFound",
+	MongoErrInvalidOptions:                                              "InvalidOptions",
+	MongoErrInvalidNamespace:                                            "InvalidNamespace",
+	MongoErrNodeNotFound:                                                "NodeNotFound",
+	MongoErrWriteConcernLegacyOK:                                        "WriteConcernLegacyOK",
+	MongoErrNoReplicationEnabled:                                        "NoReplicationEnabled",
+	MongoErrOperationIncomplete:                                         "OperationIncomplete",
+	MongoErrCommandResultSchemaViolation:                                "CommandResultSchemaViolation",
+	MongoErrUnknownReplWriteConcern:                                     "UnknownReplWriteConcern",
+	MongoErrRoleDataInconsistent:                                        "RoleDataInconsistent",
+	MongoErrNoMatchParseContext:                                         "NoMatchParseContext",
+	MongoErrNoProgressMade:                                              "NoProgressMade",
+	MongoErrRemoteResultsUnavailable:                                    "RemoteResultsUnavailable",
+	MongoErrIndexOptionsConflict:                                        "IndexOptionsConflict",
+	MongoErrIndexKeySpecsConflict:                                       "IndexKeySpecsConflict",
+	MongoErrCannotSplit:                                                 "CannotSplit",
+	MongoErrNetworkTimeout:                                              "NetworkTimeout",
+	MongoErrCallbackCanceled:                                            "CallbackCanceled",
+	MongoErrShutdownInProgress:                                          "ShutdownInProgress",
+	MongoErrSecondaryAheadOfPrimary:                                     "SecondaryAheadOfPrimary",
+	MongoErrInvalidReplicaSetConfig:                                     "InvalidReplicaSetConfig",
+	MongoErrNotYetInitialized:                                           "NotYetInitialized",
+	MongoErrNotSecondary:                                                "NotSecondary",
+	MongoErrOperationFailed:                                             "OperationFailed",
+	MongoErrNoProjectionFound:                                           "NoProjectionFound",
+	MongoErrDBPathInUse:                                                 "DBPathInUse",
+	MongoErrUnsatisfiableWriteConcern:                                   "UnsatisfiableWriteConcern",
+	MongoErrOutdatedClient:                                              "OutdatedClient",
+	MongoErrIncompatibleAuditMetadata:                                   "IncompatibleAuditMetadata",
+	MongoErrNewReplicaSetConfigurationIncompatible:                      "NewReplicaSetConfigurationIncompatible",
+	MongoErrNodeNotElectable:                                            "NodeNotElectable",
+	MongoErrIncompatibleShardingMetadata:                                "IncompatibleShardingMetadata",
+	MongoErrDistributedClockSkewed:                                      "DistributedClockSkewed",
+	MongoErrLockFailed:                                                  "LockFailed",
+	MongoErrInconsistentReplicaSetNames:                                 "InconsistentReplicaSetNames",
+	MongoErrConfigurationInProgress:                                     "ConfigurationInProgress",
+	MongoErrCannotInitializeNodeWithData:                                "CannotInitializeNodeWithData",
+	MongoErrNotExactValueField:                                          "NotExactValueField",
+	MongoErrWriteConflict:                                               "WriteConflict",
+	MongoErrInitialSyncFailure:                                          "InitialSyncFailure",
+	MongoErrInitialSyncOplogSourceMissing:                               "InitialSyncOplogSourceMissing",
+	MongoErrCommandNotSupported:                                         "CommandNotSupported",
+	MongoErrDocTooLargeForCapped:                                        "DocTooLargeForCapped",
+	MongoErrConflictingOperationInProgress:                              "ConflictingOperationInProgress",
+	MongoErrNamespaceNotSharded:                                         "NamespaceNotSharded",
+	MongoErrInvalidSyncSource:                                           "InvalidSyncSource",
+	MongoErrOplogStartMissing:                                           "OplogStartMissing",
+	MongoErrDocumentValidationFailure:                                   "DocumentValidationFailure",
+	MongoErrNotAReplicaSet:                                              "NotAReplicaSet",
+	MongoErrIncompatibleElectionProtocol:                                "IncompatibleElectionProtocol",
+	MongoErrCommandFailed:                                               "CommandFailed",
+	MongoErrRPCProtocolNegotiationFailed:                                "RPCProtocolNegotiationFailed",
+	MongoErrUnrecoverableRollbackError:                                  "UnrecoverableRollbackError",
+	MongoErrLockNotFound:                                                "LockNotFound",
+	MongoErrLockStateChangeFailed:                                       "LockStateChangeFailed",
+	MongoErrSymbolNotFound:                                              "SymbolNotFound",
+	MongoErrFailedToSatisfyReadPreference:                               "FailedToSatisfyReadPreference",
+	MongoErrReadConcernMajorityNotAvailableYet:                          "ReadConcernMajorityNotAvailableYet",
+	MongoErrStaleTerm:                                                   "StaleTerm",
+	MongoErrCappedPositionLost:                                          "CappedPositionLost",
+	MongoErrIncompatibleShardingConfigVersion:                           "IncompatibleShardingConfigVersion",
+	MongoErrRemoteOplogStale:                                            "RemoteOplogStale",
+	MongoErrJSInterpreterFailure:                                        "JSInterpreterFailure",
+	MongoErrInvalidSSLConfiguration:                                     "InvalidSSLConfiguration",
+	MongoErrSSLHandshakeFailed:                                          "SSLHandshakeFailed",
+	MongoErrJSUncatchableError:                                          "JSUncatchableError",
+	MongoErrCursorInUse:                                                 "CursorInUse",
+	MongoErrIncompatibleCatalogManager:                                  "IncompatibleCatalogManager",
+	MongoErrPooledConnectionsDropped:                                    "PooledConnectionsDropped",
+	MongoErrExceededMemoryLimit:                                         "ExceededMemoryLimit",
+	MongoErrZLibError:                                                   "ZLibError",
+	MongoErrReadConcernMajorityNotEnabled:                               "ReadConcernMajorityNotEnabled",
+	MongoErrNoConfigPrimary:                                             "NoConfigPrimary",
+	MongoErrStaleEpoch:                                                  "StaleEpoch",
+	MongoErrOperationCannotBeBatched:                                    "OperationCannotBeBatched",
+	MongoErrOplogOutOfOrder:                                             "OplogOutOfOrder",
+	MongoErrChunkTooBig:                                                 "ChunkTooBig",
+	MongoErrInconsistentShardIdentity:                                   "InconsistentShardIdentity",
+	MongoErrCannotApplyOplogWhilePrimary:                                "CannotApplyOplogWhilePrimary",
+	MongoErrCanRepairToDowngrade:                                        "CanRepairToDowngrade",
+	MongoErrMustUpgrade:                                                 "MustUpgrade",
+	MongoErrDurationOverflow:                                            "DurationOverflow",
+	MongoErrMaxStalenessOutOfRange:                                      "MaxStalenessOutOfRange",
+	MongoErrIncompatibleCollationVersion:                                "IncompatibleCollationVersion",
+	MongoErrCollectionIsEmpty:                                           "CollectionIsEmpty",
+	MongoErrZoneStillInUse:                                              "ZoneStillInUse",
+	MongoErrInitialSyncActive:                                           "InitialSyncActive",
+	MongoErrViewDepthLimitExceeded:                                      "ViewDepthLimitExceeded",
+	MongoErrCommandNotSupportedOnView:                                   "CommandNotSupportedOnView",
+	MongoErrOptionNotSupportedOnView:                                    "OptionNotSupportedOnView",
+	MongoErrInvalidPipelineOperator:                                     "InvalidPipelineOperator",
+	MongoErrCommandOnShardedViewNotSupportedOnMongod:                    "CommandOnShardedViewNotSupportedOnMongod",
+	MongoErrTooManyMatchingDocuments:                                    "TooManyMatchingDocuments",
+	MongoErrCannotIndexParallelArrays:                                   "CannotIndexParallelArrays",
+	MongoErrTransportSessionClosed:                                      "TransportSessionClosed",
+	MongoErrTransportSessionNotFound:                                    "TransportSessionNotFound",
+	MongoErrTransportSessionUnknown:                                     "TransportSessionUnknown",
+	MongoErrQueryPlanKilled:                                             "QueryPlanKilled",
+	MongoErrFileOpenFailed:                                              "FileOpenFailed",
+	MongoErrZoneNotFound:                                                "ZoneNotFound",
+	MongoErrRangeOverlapConflict:                                        "RangeOverlapConflict",
+	MongoErrWindowsPdhError:                                             "WindowsPdhError",
+	MongoErrBadPerfCounterPath:                                          "BadPerfCounterPath",
+	MongoErrAmbiguousIndexKeyPattern:                                    "AmbiguousIndexKeyPattern",
+	MongoErrInvalidViewDefinition:                                       "InvalidViewDefinition",
+	MongoErrClientMetadataMissingField:                                  "ClientMetadataMissingField",
+	MongoErrClientMetadataAppNameTooLarge:                               "ClientMetadataAppNameTooLarge",
+	MongoErrClientMetadataDocumentTooLarge:                              "ClientMetadataDocumentTooLarge",
+	MongoErrClientMetadataCannotBeMutated:                               "ClientMetadataCannotBeMutated",
+	MongoErrLinearizableReadConcernError:                                "LinearizableReadConcernError",
+	MongoErrIncompatibleServerVersion:                                   "IncompatibleServerVersion",
+	MongoErrPrimarySteppedDown:                                          "PrimarySteppedDown",
+	MongoErrMasterSlaveConnectionFailure:                                "MasterSlaveConnectionFailure",
+	MongoErrFailPointEnabled:                                            "FailPointEnabled",
+	MongoErrNoShardingEnabled:                                           "NoShardingEnabled",
+	MongoErrBalancerInterrupted:                                         "BalancerInterrupted",
+	MongoErrViewPipelineMaxSizeExceeded:                                 "ViewPipelineMaxSizeExceeded",
+	MongoErrInvalidIndexSpecificationOption:                             "InvalidIndexSpecificationOption",
+	MongoErrReplicaSetMonitorRemoved:                                    "ReplicaSetMonitorRemoved",
+	MongoErrChunkRangeCleanupPending:                                    "ChunkRangeCleanupPending",
+	MongoErrCannotBuildIndexKeys:                                        "CannotBuildIndexKeys",
+	MongoErrNetworkInterfaceExceededTimeLimit:                           "NetworkInterfaceExceededTimeLimit",
+	MongoErrShardingStateNotInitialized:                                 "ShardingStateNotInitialized",
+	MongoErrTimeProofMismatch:                                           "TimeProofMismatch",
+	MongoErrClusterTimeFailsRateLimiter:                                 "ClusterTimeFailsRateLimiter",
+	MongoErrNoSuchSession:                                               "NoSuchSession",
+	MongoErrInvalidUUID:                                                 "InvalidUUID",
+	MongoErrTooManyLocks:                                                "TooManyLocks",
+	MongoErrStaleClusterTime:                                            "StaleClusterTime",
+	MongoErrCannotVerifyAndSignLogicalTime:                              "CannotVerifyAndSignLogicalTime",
+	MongoErrKeyNotFound:                                                 "KeyNotFound",
+	MongoErrIncompatibleRollbackAlgorithm:                               "IncompatibleRollbackAlgorithm",
+	MongoErrDuplicateSession:                                            "DuplicateSession",
+	MongoErrAuthenticationRestrictionUnmet:                              "AuthenticationRestrictionUnmet",
+	MongoErrDatabaseDropPending:                                         "DatabaseDropPending",
+	MongoErrElectionInProgress:                                          "ElectionInProgress",
+	MongoErrIncompleteTransactionHistory:                                "IncompleteTransactionHistory",
+	MongoErrUpdateOperationFailed:                                       "UpdateOperationFailed",
+	MongoErrFTDCPathNotSet:                                              "FTDCPathNotSet",
+	MongoErrFTDCPathAlreadySet:                                          "FTDCPathAlreadySet",
+	MongoErrIndexModified:                                               "IndexModified",
+	MongoErrCloseChangeStream:                                           "CloseChangeStream",
+	MongoErrIllegalOpMsgFlag:                                            "IllegalOpMsgFlag",
+	MongoErrQueryFeatureNotAllowed:                                      "QueryFeatureNotAllowed",
+	MongoErrTransactionTooOld:                                           "TransactionTooOld",
+	MongoErrAtomicityFailure:                                            "AtomicityFailure",
+	MongoErrCannotImplicitlyCreateCollection:                            "CannotImplicitlyCreateCollection",
+	MongoErrSessionTransferIncomplete:                                   "SessionTransferIncomplete",
+	MongoErrMustDowngrade:                                               "MustDowngrade",
+	MongoErrDNSHostNotFound:                                             "DNSHostNotFound",
+	MongoErrDNSProtocolError:                                            "DNSProtocolError",
+	MongoErrMaxSubPipelineDepthExceeded:                                 "MaxSubPipelineDepthExceeded",
+	MongoErrTooManyDocumentSequences:                                    "TooManyDocumentSequences",
+	MongoErrRetryChangeStream:                                           "RetryChangeStream",
+	MongoErrInternalErrorNotSupported:                                   "InternalErrorNotSupported",
+	MongoErrForTestingErrorExtraInfo:                                    "ForTestingErrorExtraInfo",
+	MongoErrCursorKilled:                                                "CursorKilled",
+	MongoErrNotImplemented:                                              "NotImplemented",
+	MongoErrSnapshotTooOld:                                              "SnapshotTooOld",
+	MongoErrDNSRecordTypeMismatch:                                       "DNSRecordTypeMismatch",
+	MongoErrConversionFailure:                                           "ConversionFailure",
+	MongoErrCannotCreateCollection:                                      "CannotCreateCollection",
+	MongoErrIncompatibleWithUpgradedServer:                              "IncompatibleWithUpgradedServer",
+	MongoErrBrokenPromise:                                               "BrokenPromise",
+	MongoErrSnapshotUnavailable:                                         "SnapshotUnavailable",
+	MongoErrProducerConsumerQueueBatchTooLarge:                          "ProducerConsumerQueueBatchTooLarge",
+	MongoErrProducerConsumerQueueEndClosed:                              "ProducerConsumerQueueEndClosed",
+	MongoErrStaleDbVersion:                                              "StaleDbVersion",
+	MongoErrStaleChunkHistory:                                           "StaleChunkHistory",
+	MongoErrNoSuchTransaction:                                           "NoSuchTransaction",
+	MongoErrReentrancyNotAllowed:                                        "ReentrancyNotAllowed",
+	MongoErrFreeMonHttpInFlight:                                         "FreeMonHttpInFlight",
+	MongoErrFreeMonHttpTemporaryFailure:                                 "FreeMonHttpTemporaryFailure",
+	MongoErrFreeMonHttpPermanentFailure:                                 "FreeMonHttpPermanentFailure",
+	MongoErrTransactionCommitted:                                        "TransactionCommitted",
+	MongoErrTransactionTooLarge:                                         "TransactionTooLarge",
+	MongoErrUnknownFeatureCompatibilityVersion:                          "UnknownFeatureCompatibilityVersion",
+	MongoErrKeyedExecutorRetry:                                          "KeyedExecutorRetry",
+	MongoErrInvalidResumeToken:                                          "InvalidResumeToken",
+	MongoErrTooManyLogicalSessions:                                      "TooManyLogicalSessions",
+	MongoErrExceededTimeLimit:                                           "ExceededTimeLimit",
+	MongoErrOperationNotSupportedInTransaction:                          "OperationNotSupportedInTransaction",
+	MongoErrTooManyFilesOpen:                                            "TooManyFilesOpen",
+	MongoErrOrphanedRangeCleanUpFailed:                                  "OrphanedRangeCleanUpFailed",
+	MongoErrFailPointSetFailed:                                          "FailPointSetFailed",
+	MongoErrPreparedTransactionInProgress:                               "PreparedTransactionInProgress",
+	MongoErrCannotBackup:                                                "CannotBackup",
+	MongoErrDataModifiedByRepair:                                        "DataModifiedByRepair",
+	MongoErrRepairedReplicaSetNode:                                      "RepairedReplicaSetNode",
+	MongoErrJSInterpreterFailureWithStack:                               "JSInterpreterFailureWithStack",
+	MongoErrMigrationConflict:                                           "MigrationConflict",
+	MongoErrProducerConsumerQueueProducerQueueDepthExceeded:             "ProducerConsumerQueueProducerQueueDepthExceeded",
+	MongoErrProducerConsumerQueueConsumed:                               "ProducerConsumerQueueConsumed",
+	MongoErrExchangePassthrough:                                         "ExchangePassthrough",
+	MongoErrIndexBuildAborted:                                           "IndexBuildAborted",
+	MongoErrAlarmAlreadyFulfilled:                                       "AlarmAlreadyFulfilled",
+	MongoErrUnsatisfiableCommitQuorum:                                   "UnsatisfiableCommitQuorum",
+	MongoErrClientDisconnect:                                            "ClientDisconnect",
+	MongoErrChangeStreamFatalError:                                      "ChangeStreamFatalError",
+	MongoErrTransactionCoordinatorSteppingDown:                          "TransactionCoordinatorSteppingDown",
+	MongoErrTransactionCoordinatorReachedAbortDecision:                  "TransactionCoordinatorReachedAbortDecision",
+	MongoErrWouldChangeOwningShard:                                      "WouldChangeOwningShard",
+	MongoErrForTestingErrorExtraInfoWithExtraInfoInNamespace:            "ForTestingErrorExtraInfoWithExtraInfoInNamespace",
+	MongoErrIndexBuildAlreadyInProgress:                                 "IndexBuildAlreadyInProgress",
+	MongoErrChangeStreamHistoryLost:                                     "ChangeStreamHistoryLost",
+	MongoErrTransactionCoordinatorDeadlineTaskCanceled:                  "TransactionCoordinatorDeadlineTaskCanceled",
+	MongoErrChecksumMismatch:                                            "ChecksumMismatch",
+	MongoErrWaitForMajorityServiceEarlierOpTimeAvailable:                "WaitForMajorityServiceEarlierOpTimeAvailable",
+	MongoErrTransactionExceededLifetimeLimitSeconds:                     "TransactionExceededLifetimeLimitSeconds",
+	MongoErrNoQueryExecutionPlans:                                       "NoQueryExecutionPlans",
+	MongoErrQueryExceededMemoryLimitNoDiskUseAllowed:                    "QueryExceededMemoryLimitNoDiskUseAllowed",
+	MongoErrInvalidSeedList:                                             "InvalidSeedList",
+	MongoErrInvalidTopologyType:                                         "InvalidTopologyType",
+	MongoErrInvalidHeartBeatFrequency:                                   "InvalidHeartBeatFrequency",
+	MongoErrTopologySetNameRequired:                                     "TopologySetNameRequired",
+	MongoErrHierarchicalAcquisitionLevelViolation:                       "HierarchicalAcquisitionLevelViolation",
+	MongoErrInvalidServerType:                                           "InvalidServerType",
+	MongoErrOCSPCertificateStatusRevoked:                                "OCSPCertificateStatusRevoked",
+	MongoErrRangeDeletionAbandonedBecauseCollectionWithUUIDDoesNotExist: "RangeDeletionAbandonedBecauseCollectionWithUUIDDoesNotExist",
+	MongoErrDataCorruptionDetected:                                      "DataCorruptionDetected",
+	MongoErrOCSPCertificateStatusUnknown:                                "OCSPCertificateStatusUnknown",
+	MongoErrSplitHorizonChange:                                          "SplitHorizonChange",
+	MongoErrShardInvalidatedForTargeting:                                "ShardInvalidatedForTargeting",
+	MongoErrRangeDeletionAbandonedBecauseTaskDocumentDoesNotExist:       "RangeDeletionAbandonedBecauseTaskDocumentDoesNotExist",
+	MongoErrCurrentConfigNotCommittedYet:                                "CurrentConfigNotCommittedYet",
+	MongoErrExhaustCommandFinished:                                      "ExhaustCommandFinished",
+	MongoErrPeriodicJobIsStopped:                                        "PeriodicJobIsStopped",
+	MongoErrTransactionCoordinatorCanceled:                              "TransactionCoordinatorCanceled",
+	MongoErrOperationIsKilledAndDelisted:                                "OperationIsKilledAndDelisted",
+	MongoErrResumableRangeDeleterDisabled:                               "ResumableRangeDeleterDisabled",
+	MongoErrObjectIsBusy:                                                "ObjectIsBusy",
+	MongoErrTooStaleToSyncFromSource:                                    "TooStaleToSyncFromSource",
+	MongoErrQueryTrialRunCompleted:                                      "QueryTrialRunCompleted",
+	MongoErrConnectionPoolExpired:                                       "ConnectionPoolExpired",
+	MongoErrForTestingOptionalErrorExtraInfo:                            "ForTestingOptionalErrorExtraInfo",
+	MongoErrMovePrimaryInProgress:                                       "MovePrimaryInProgress",
+	MongoErrTenantMigrationConflict:                                     "TenantMigrationConflict",
+	MongoErrTenantMigrationCommitted:                                    "TenantMigrationCommitted",
+	MongoErrAPIVersionError:                                             "APIVersionError",
+	MongoErrAPIStrictError:                                              "APIStrictError",
+	MongoErrAPIDeprecationError:                                         "APIDeprecationError",
+	MongoErrTenantMigrationAborted:                                      "TenantMigrationAborted",
+	MongoErrOplogQueryMinTsMissing:                                      "OplogQueryMinTsMissing",
+	MongoErrNoSuchTenantMigration:                                       "NoSuchTenantMigration",
+	MongoErrTenantMigrationAccessBlockerShuttingDown:                    "TenantMigrationAccessBlockerShuttingDown",
+	MongoErrTenantMigrationInProgress:                                   "TenantMigrationInProgress",
+	MongoErrSkipCommandExecution:                                        "SkipCommandExecution",
+	MongoErrFailedToRunWithReplyBuilder:                                 "FailedToRunWithReplyBuilder",
+	MongoErrCannotDowngrade:                                             "CannotDowngrade",
+	MongoErrServiceExecutorInShutdown:                                   "ServiceExecutorInShutdown",
+	MongoErrMechanismUnavailable:                                        "MechanismUnavailable",
+	MongoErrTenantMigrationForgotten:                                    "TenantMigrationForgotten",
+	MongoErrSocketException:                                             "SocketException",
+	MongoErrCannotGrowDocumentInCappedNamespace:                         "CannotGrowDocumentInCappedNamespace",
+	MongoErrNotWritablePrimary:                                          "NotWritablePrimary",
+	MongoErrBSONObjectTooLarge:                                          "BSONObjectTooLarge",
+	MongoErrDuplicateKey:                                                "DuplicateKey",
+	MongoErrInterruptedAtShutdown:                                       "InterruptedAtShutdown",
+	MongoErrInterrupted:                                                 "Interrupted",
+	MongoErrInterruptedDueToReplStateChange:                             "InterruptedDueToReplStateChange",
+	MongoErrBackgroundOperationInProgressForDatabase:                    "BackgroundOperationInProgressForDatabase",
+	MongoErrBackgroundOperationInProgressForNamespace:                   "BackgroundOperationInProgressForNamespace",
+	MongoErrMergeStageNoMatchingDocument:                                "MergeStageNoMatchingDocument",
+	MongoErrDatabaseDifferCase:                                          "DatabaseDifferCase",
+	MongoErrStaleConfig:                                                 "StaleConfig",
+	MongoErrNotPrimaryNoSecondaryOk:                                     "NotPrimaryNoSecondaryOk",
+	MongoErrNotPrimaryOrSecondary:                                       "NotPrimaryOrSecondary",
+	MongoErrOutOfDiskSpace:                                              "OutOfDiskSpace",
+	MongoErrClientMarkedKilled:                                          "ClientMarkedKilled",
+}
+
+// MongoErrorCodeName returns the server code name of a mongo error code, empty if the code is not known.
+func MongoErrorCodeName(code int32) string {
+	return mongoErrorCodeNames[code]
+}
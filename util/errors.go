@@ -353,35 +353,27 @@ const (
 	MongoErrClientMarkedKilled                                          int32 = 46841
 )
 
+// MongoErrorCode returns the code of the error as classified by Classify, MongoErrInternalError if the error carries no code. As it
+// has always been, the write concern error of a write exception takes precedence over its write errors; the code of a bulk write
+// exception, that used to be reported as MongoErrInternalError, follows the same rule.
 func MongoErrorCode(err error, mongoDbVersion MongoDbVersion) int32 {
 	const semLogContext = "mongo::error-code"
-	mcode := MongoErrInternalError
 	if err == nil {
 		log.Warn().Msg(semLogContext + " - error is nil")
-		return mcode
+		return MongoErrInternalError
 	}
 
-	var mongoCmdErr mongo.CommandError
-	var writeExcErr mongo.WriteException
-	switch {
-	case errors.As(err, &mongoCmdErr):
-		log.Error().Err(mongoCmdErr).Str("server-version", mongoDbVersion.String()).Int32("error-code", mongoCmdErr.Code).Str("error-name", mongoCmdErr.Name).Str("error-msg", mongoCmdErr.Message).Msg(semLogContext + " - mongo.CommandError")
-		mcode = CommandErrorCode(mongoCmdErr, mongoDbVersion)
-	case errors.As(err, &writeExcErr):
-		log.Error().Msg(semLogContext + " - mongo.WriteException")
-		if writeExcErr.WriteConcernError != nil {
-			log.Error().Err(err).Int("code", writeExcErr.WriteConcernError.Code).Msg(semLogContext + " - mongo.WriteException with write concern error")
-			mcode = int32(writeExcErr.WriteConcernError.Code)
-		} else if len(writeExcErr.WriteErrors) > 0 {
-			log.Error().Err(err).Int("code", writeExcErr.WriteErrors[0].Code).Msg(semLogContext + " - mongo.WriteException with write errors error")
-			mcode = int32(writeExcErr.WriteErrors[0].Code)
-		}
-		// log.Info().Bool("code-11000", writeExcErr.HasErrorCode(11000)).Bool("msg-11000", writeExcErr.HasErrorMessage("E11000 duplicate key error collection")).Msg(semLogContext)
-	default:
-		log.Error().Err(err).Str("server-version", mongoDbVersion.String()).Str("error-type", fmt.Sprintf("%T", err)).Msg(semLogContext + " - !mongo.CommandError")
+	c := Classify(err, WithServerVersion(mongoDbVersion))
+	log.Debug().Err(err).Str("server-version", mongoDbVersion.String()).Int32("error-code", c.Code).Str("error-name", c.CodeName).Str("category", string(c.Category)).Msg(semLogContext)
+	if c.WriteConcernError != nil {
+		return c.WriteConcernError.Code
 	}
 
-	return mcode
+	if c.Code == 0 {
+		return MongoErrInternalError
+	}
+
+	return c.Code
 }
 
 func MongoError(err error, mongoDbVersion MongoDbVersion) (int32, mongo.CommandError) {
@@ -419,10 +411,7 @@ func MongoError(err error, mongoDbVersion MongoDbVersion) (int32, mongo.CommandE
 }
 
 func IsMongoErrorHistoryLost(err error, mongoDbVersion MongoDbVersion) bool {
-	const semLogContext = "mongo::is-history-lost-error"
-
-	c := MongoErrorCode(err, mongoDbVersion)
-	return c == MongoErrChangeStreamHistoryLost
+	return errors.Is(Classify(err, WithServerVersion(mongoDbVersion)), ErrHistoryLost)
 }
 
 var Version4ResumeTokenExtractionRegexp = regexp.MustCompile("{_data: \\\"([A-Z0-9]*)\\\"}")
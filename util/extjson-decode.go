@@ -0,0 +1,141 @@
+package util
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// extJsonArrayWrapperKey wraps top level arrays: the extended json reader only accepts documents at the top level.
+const extJsonArrayWrapperKey = "a"
+
+// decodeExtJsonDocument decodes an Extended JSON v2 document, relaxed and canonical forms alike, with the streaming value reader of the
+// driver. Key order is preserved and every value gets its bson type: numbers become int32, int64 or double by their magnitude and
+// format (or by the $numberInt, $numberLong and $numberDouble wrappers), embedded documents become bson.D and arrays bson.A.
+func decodeExtJsonDocument(b []byte) (bson.D, error) {
+	vr, err := bson.NewExtJSONValueReader(bytes.NewReader(b), false)
+	if err != nil {
+		return nil, err
+	}
+
+	var d bson.D
+	if err = bson.NewDecoder(vr).Decode(&d); err != nil {
+		return nil, err
+	}
+
+	if err = checkExtJsonTrailingData(b); err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// decodeExtJsonArray decodes an Extended JSON v2 array.
+func decodeExtJsonArray(b []byte) (bson.A, error) {
+	trimmed := bytes.TrimSpace(b)
+	if len(trimmed) == 0 || trimmed[0] != '[' {
+		return nil, errors.New("extended json is not an array")
+	}
+
+	var wrapper bytes.Buffer
+	wrapper.Grow(len(trimmed) + 8)
+	wrapper.WriteString(`{"` + extJsonArrayWrapperKey + `":`)
+	wrapper.Write(trimmed)
+	wrapper.WriteByte('}')
+
+	d, err := decodeExtJsonDocument(wrapper.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	a, ok := d[0].Value.(bson.A)
+	if !ok {
+		return nil, fmt.Errorf("extended json array decoded as %T", d[0].Value)
+	}
+
+	return a, nil
+}
+
+// checkExtJsonTrailingData rejects input with more than one top level value: the value reader stops at the end of the first document.
+func checkExtJsonTrailingData(b []byte) error {
+	dec := newJsonValueScanner(b)
+	if err := dec.skipValue(); err != nil {
+		return err
+	}
+
+	if dec.more() {
+		return errors.New("extended json has data after the top level value")
+	}
+
+	return nil
+}
+
+// jsonValueScanner finds the end of the first json value without decoding it.
+type jsonValueScanner struct {
+	data []byte
+	pos  int
+}
+
+func newJsonValueScanner(b []byte) *jsonValueScanner {
+	return &jsonValueScanner{data: b}
+}
+
+func (s *jsonValueScanner) skipSpaces() {
+	for s.pos < len(s.data) {
+		switch s.data[s.pos] {
+		case ' ', '\t', '\r', '\n':
+			s.pos++
+		default:
+			return
+		}
+	}
+}
+
+func (s *jsonValueScanner) more() bool {
+	s.skipSpaces()
+	return s.pos < len(s.data)
+}
+
+func (s *jsonValueScanner) skipValue() error {
+	s.skipSpaces()
+	depth := 0
+	inString := false
+	for ; s.pos < len(s.data); s.pos++ {
+		c := s.data[s.pos]
+		if inString {
+			switch c {
+			case '\\':
+				s.pos++
+			case '"':
+				inString = false
+				if depth == 0 {
+					s.pos++
+					return nil
+				}
+			}
+			continue
+		}
+
+		switch c {
+		case '"':
+			inString = true
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+			if depth == 0 {
+				s.pos++
+				return nil
+			}
+		}
+	}
+
+	if depth != 0 || inString {
+		return io.ErrUnexpectedEOF
+	}
+
+	return nil
+}
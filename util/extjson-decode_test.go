@@ -0,0 +1,132 @@
+package util_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/util"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// bsonCorpusFile is the layout of the bson-corpus tests of the mongodb specifications repository (source/bson-corpus/tests). The files
+// in testdata/bson-corpus are a subset of those cases, picked for the types a statement can hold, not the whole corpus: see the README
+// in that folder. The checks of the corpus not run on them are:
+//   - the canonical bson of the lossy cases, the corpus itself marks them as not round-tripping;
+//   - the degenerate extended json with a legacy $regex: it is kept as a document, in a statement it is the $regex query operator;
+//   - the parse errors of decimal128, they are number strings and not extended json documents;
+//   - the decodeErrors, they are invalid bson inputs and there is no bson decoding involved.
+type bsonCorpusFile struct {
+	Description string `json:"description"`
+	BsonType    string `json:"bson_type"`
+	Valid       []struct {
+		Description       string  `json:"description"`
+		CanonicalBson     string  `json:"canonical_bson"`
+		CanonicalExtJson  string  `json:"canonical_extjson"`
+		RelaxedExtJson    *string `json:"relaxed_extjson"`
+		DegenerateExtJson *string `json:"degenerate_extjson"`
+		Lossy             bool    `json:"lossy"`
+	} `json:"valid"`
+	ParseErrors []struct {
+		Description string `json:"description"`
+		String      string `json:"string"`
+	} `json:"parseErrors"`
+}
+
+func TestUnmarshalJson2BsonDCorpus(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "bson-corpus", "*.json"))
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+
+	for _, fn := range files {
+		data, err := os.ReadFile(fn)
+		require.NoError(t, err)
+
+		var cf bsonCorpusFile
+		require.NoError(t, json.Unmarshal(data, &cf), fn)
+
+		for _, v := range cf.Valid {
+			name := cf.Description + " - " + v.Description
+
+			// canonical extended json -> bson.D -> bson must give the canonical bson.
+			d, err := util.UnmarshalJson2BsonD([]byte(v.CanonicalExtJson), true)
+			require.NoError(t, err, name)
+			if !v.Lossy {
+				b, err := bson.Marshal(d)
+				require.NoError(t, err, name)
+				require.Equal(t, v.CanonicalBson, strings.ToUpper(hex.EncodeToString(b)), name)
+			}
+
+			ce, err := bson.MarshalExtJSON(d, true, false)
+			require.NoError(t, err, name)
+			require.Equal(t, compactJson(t, v.CanonicalExtJson), string(ce), name)
+
+			if v.RelaxedExtJson != nil {
+				d, err = util.UnmarshalJson2BsonD([]byte(*v.RelaxedExtJson), true)
+				require.NoError(t, err, name)
+				re, err := bson.MarshalExtJSON(d, false, false)
+				require.NoError(t, err, name)
+				require.Equal(t, compactJson(t, *v.RelaxedExtJson), string(re), name)
+			}
+
+			// a legacy { "$regex": ..., "$options": ... } is kept as a document: in a statement it is the $regex query operator.
+			if v.DegenerateExtJson != nil && !v.Lossy && !strings.Contains(*v.DegenerateExtJson, `"$regex"`) {
+				d, err = util.UnmarshalJson2BsonD([]byte(*v.DegenerateExtJson), true)
+				require.NoError(t, err, name)
+				b, err := bson.Marshal(d)
+				require.NoError(t, err, name)
+				require.Equal(t, v.CanonicalBson, strings.ToUpper(hex.EncodeToString(b)), name)
+			}
+		}
+
+		// the parse errors of decimal128 are number strings, not extended json documents.
+		if cf.BsonType == "0x13" {
+			continue
+		}
+
+		for _, pe := range cf.ParseErrors {
+			_, err = util.UnmarshalJson2BsonD([]byte(pe.String), true)
+			require.Error(t, err, cf.Description+" - "+pe.Description)
+		}
+	}
+}
+
+func TestUnmarshalJson2BsonDTypes(t *testing.T) {
+	d, err := util.UnmarshalJson2BsonD([]byte(`{ "i": 1, "l": 3000000000, "f": 1.5, "nl": { "$numberLong": "1" }, "a": [ 1, { "b": true } ], "s": "x" }`), true)
+	require.NoError(t, err)
+	require.Equal(t, bson.D{
+		{Key: "i", Value: int32(1)},
+		{Key: "l", Value: int64(3000000000)},
+		{Key: "f", Value: 1.5},
+		{Key: "nl", Value: int64(1)},
+		{Key: "a", Value: bson.A{int32(1), bson.D{{Key: "b", Value: true}}}},
+		{Key: "s", Value: "x"},
+	}, d)
+
+	docs, err := util.UnmarshalJson2ArrayOfBsonD([]byte(` [ { "$match": { "n": { "$numberDecimal": "1.10" } } }, { "$limit": 1 } ] `), true)
+	require.NoError(t, err)
+	require.Len(t, docs, 2)
+	_, ok := docs[0][0].Value.(bson.D)[0].Value.(bson.Decimal128)
+	require.True(t, ok)
+
+	_, err = util.UnmarshalJson2ArrayOfBsonD([]byte(`[ 1, 2 ]`), true)
+	require.Error(t, err)
+
+	_, err = util.UnmarshalJson2BsonD([]byte(`{ "a": 1 } { "b": 2 }`), true)
+	require.Error(t, err)
+
+	d, err = util.UnmarshalJson2BsonD([]byte(`{}`), false)
+	require.NoError(t, err)
+	require.Nil(t, d)
+}
+
+func compactJson(t *testing.T, s string) string {
+	var buf bytes.Buffer
+	require.NoError(t, json.Compact(&buf, []byte(s)))
+	return buf.String()
+}
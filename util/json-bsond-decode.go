@@ -50,16 +50,15 @@ func UnmarshalJson2Bson(b []byte, createIfEmpty bool) (any, error) {
 	}
 
 	if err != nil {
-		log.Error().Err(err).Str("json", string(b)).Msg("error unmarshalling bson")
+		log.Error().Err(err).Int("json-length", len(b)).Msg("error unmarshalling bson")
 	}
 	return res, err
 }
 
+// UnmarshalJson2BsonD decodes a json document, in relaxed or canonical Extended JSON v2, to a bson.D keeping the order of the keys and
+// the bson types of the values.
 func UnmarshalJson2BsonD(b []byte, createIfEmpty bool) (bson.D, error) {
 	const semLogContext = "mongo-json-util::unmarshal-json-2-bson-d"
-	var err error
-
-	o := New()
 
 	if len(b) == 0 {
 		if createIfEmpty {
@@ -68,41 +67,25 @@ func UnmarshalJson2BsonD(b []byte, createIfEmpty bool) (bson.D, error) {
 		return nil, nil
 	}
 
-	/*
-		doc := bson.M{}
-		err := json.Unmarshal(b, &doc)
-		if err != nil {
-			log.Error().Err(err).Msg(semLogContext)
-			log.Error().Str("json", string(b)).Msg(semLogContext)
-			return nil, err
-		}
-	*/
-
-	err = json.Unmarshal(b, &o)
-	// err := json.Unmarshal(b, &o)
+	d, err := decodeExtJsonDocument(b)
 	if err != nil {
-		log.Error().Err(err).Msg(semLogContext)
-		log.Error().Str("json", string(b)).Msg(semLogContext)
+		log.Error().Err(err).Int("json-length", len(b)).Msg(semLogContext)
 		return nil, err
 	}
 
-	d, err := o.ToBsonD()
-	if err != nil {
-		log.Error().Err(err).Msg(semLogContext)
-		log.Error().Str("json", string(b)).Msg(semLogContext)
-		return nil, err
-	}
-
-	if d == nil && createIfEmpty {
-		return bson.D{}, nil
+	if len(d) == 0 {
+		if createIfEmpty {
+			return bson.D{}, nil
+		}
+		return nil, nil
 	}
 
 	return d, nil
 }
 
+// UnmarshalJson2ArrayOfBsonD decodes a json array of documents (i.e. a pipeline) with the same rules of UnmarshalJson2BsonD.
 func UnmarshalJson2ArrayOfBsonD(b []byte, createIfEmpty bool) ([]bson.D, error) {
 	const semLogContext = "mongo-json-util::unmarshal-json-2-bson-d-array"
-	var err error
 
 	if len(b) == 0 {
 		if createIfEmpty {
@@ -111,18 +94,17 @@ func UnmarshalJson2ArrayOfBsonD(b []byte, createIfEmpty bool) ([]bson.D, error)
 		return nil, nil
 	}
 
-	var omaps []OrderedMap
-	err = json.Unmarshal(b, &omaps)
+	a, err := decodeExtJsonArray(b)
 	if err != nil {
-		log.Error().Err(err).Msg(semLogContext)
-		log.Error().Str("json", string(b)).Msg(semLogContext)
+		log.Error().Err(err).Int("json-length", len(b)).Msg(semLogContext)
 		return nil, err
 	}
 
 	var resp []bson.D
-	for _, o := range omaps {
-		d, err := o.ToBsonD()
-		if err != nil {
+	for i, v := range a {
+		d, ok := v.(bson.D)
+		if !ok {
+			err = fmt.Errorf("array element #%d is not a document but %T", i, v)
 			log.Error().Err(err).Msg(semLogContext)
 			return nil, err
 		}
@@ -135,7 +117,6 @@ func UnmarshalJson2ArrayOfBsonD(b []byte, createIfEmpty bool) ([]bson.D, error)
 	}
 
 	return resp, nil
-
 }
 
 type Pair struct {
@@ -235,7 +216,7 @@ func adaptSlice(a []interface{}) ([]interface{}, error) {
 				log.Error().Err(err).Msg(semLogContext)
 				return nil, err
 			}
-			a = append(a, val)
+			newA = append(newA, val)
 		default:
 			newA = append(newA, a[i])
 		}
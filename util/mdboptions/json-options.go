@@ -0,0 +1,354 @@
+package mdboptions
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/util"
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+const (
+	OptionLimit                    = "limit"
+	OptionSkip                     = "skip"
+	OptionHint                     = "hint"
+	OptionCollation                = "collation"
+	OptionMaxTimeMS                = "maxTimeMS"
+	OptionBatchSize                = "batchSize"
+	OptionComment                  = "comment"
+	OptionLet                      = "let"
+	OptionAllowDiskUse             = "allowDiskUse"
+	OptionArrayFilters             = "arrayFilters"
+	OptionBypassDocumentValidation = "bypassDocumentValidation"
+	OptionReturnDocument           = "returnDocument"
+	OptionUpsert                   = "upsert"
+	OptionMaxEvents                = "maxEvents"
+	OptionFullDocument             = "fullDocument"
+	OptionFullDocumentBeforeChange = "fullDocumentBeforeChange"
+	OptionResumeAfter              = "resumeAfter"
+	OptionStartAfter               = "startAfter"
+)
+
+var (
+	FindOptionNames             = []string{OptionLimit, OptionSkip, OptionHint, OptionCollation, OptionMaxTimeMS, OptionBatchSize, OptionComment, OptionLet, OptionAllowDiskUse}
+	FindOneOptionNames          = []string{OptionSkip, OptionHint, OptionCollation, OptionMaxTimeMS, OptionComment}
+	FindOneAndUpdateOptionNames = []string{OptionUpsert, OptionReturnDocument, OptionHint, OptionCollation, OptionMaxTimeMS, OptionComment, OptionLet, OptionArrayFilters, OptionBypassDocumentValidation}
+	AggregateOptionNames        = []string{OptionHint, OptionCollation, OptionMaxTimeMS, OptionBatchSize, OptionComment, OptionLet, OptionAllowDiskUse, OptionBypassDocumentValidation}
+	UpdateOptionNames           = []string{OptionUpsert, OptionHint, OptionCollation, OptionMaxTimeMS, OptionComment, OptionLet, OptionArrayFilters, OptionBypassDocumentValidation}
+	ReplaceOptionNames          = []string{OptionUpsert, OptionHint, OptionCollation, OptionMaxTimeMS, OptionComment, OptionLet, OptionBypassDocumentValidation}
+	InsertOneOptionNames        = []string{OptionMaxTimeMS, OptionComment, OptionBypassDocumentValidation}
+	DeleteOptionNames           = []string{OptionHint, OptionCollation, OptionMaxTimeMS, OptionComment, OptionLet}
+	BulkWriteOptionNames        = []string{OptionMaxTimeMS, OptionComment, OptionLet, OptionBypassDocumentValidation}
+	WatchOptionNames            = []string{OptionMaxEvents, OptionMaxTimeMS, OptionBatchSize, OptionCollation, OptionComment, OptionFullDocument, OptionFullDocumentBeforeChange, OptionResumeAfter, OptionStartAfter}
+)
+
+var ErrUnknownOption = errors.New("unknown option")
+var ErrUnsupportedOption = errors.New("option not supported by operation")
+
+// JsonOptions holds the options of a json operation as found in the $opts part of the statement. Only the fields actually
+// present in the json are set.
+type JsonOptions struct {
+	Limit                    *int64
+	Skip                     *int64
+	Hint                     interface{}
+	Collation                *options.Collation
+	MaxTime                  *time.Duration
+	BatchSize                *int32
+	Comment                  interface{}
+	Let                      interface{}
+	AllowDiskUse             *bool
+	ArrayFilters             []interface{}
+	BypassDocumentValidation *bool
+	ReturnDocument           *options.ReturnDocument
+	Upsert                   *bool
+	MaxEvents                *int64
+	FullDocument             *options.FullDocument
+	FullDocumentBeforeChange *options.FullDocument
+	ResumeAfter              interface{}
+	StartAfter               interface{}
+}
+
+func (jo JsonOptions) IsUpsert() bool {
+	return jo.Upsert != nil && *jo.Upsert
+}
+
+// ParseJsonOptions parses the opts (extended) json and checks every key against the list of option names supported by the operation.
+func ParseJsonOptions(opts []byte, supported []string) (JsonOptions, error) {
+	const semLogContext = "mongo-options::parse-json-options"
+
+	var jo JsonOptions
+	d, err := util.UnmarshalJson2BsonD(opts, false)
+	if err != nil {
+		log.Error().Err(err).Msg(semLogContext)
+		return jo, err
+	}
+
+	for _, e := range d {
+		if !isSupportedOption(e.Key, supported) {
+			if isKnownOption(e.Key) {
+				err = fmt.Errorf("%w: %s", ErrUnsupportedOption, e.Key)
+			} else {
+				err = fmt.Errorf("%w: %s", ErrUnknownOption, e.Key)
+			}
+			log.Error().Err(err).Msg(semLogContext)
+			return jo, err
+		}
+
+		switch e.Key {
+		case OptionLimit:
+			var n int64
+			if n, err = toInt64(e.Key, e.Value); err == nil {
+				jo.Limit = &n
+			}
+		case OptionSkip:
+			var n int64
+			if n, err = toNonNegativeInt64(e.Key, e.Value); err == nil {
+				jo.Skip = &n
+			}
+		case OptionBatchSize:
+			var n int64
+			if n, err = toNonNegativeInt64(e.Key, e.Value); err == nil {
+				if n > math.MaxInt32 {
+					err = fmt.Errorf("%s value %d out of range", e.Key, n)
+				} else {
+					bs := int32(n)
+					jo.BatchSize = &bs
+				}
+			}
+		case OptionMaxTimeMS:
+			var n int64
+			if n, err = toNonNegativeInt64(e.Key, e.Value); err == nil {
+				mt := time.Duration(n) * time.Millisecond
+				jo.MaxTime = &mt
+			}
+		case OptionAllowDiskUse:
+			var b bool
+			if b, err = toBool(e.Key, e.Value); err == nil {
+				jo.AllowDiskUse = &b
+			}
+		case OptionBypassDocumentValidation:
+			var b bool
+			if b, err = toBool(e.Key, e.Value); err == nil {
+				jo.BypassDocumentValidation = &b
+			}
+		case OptionUpsert:
+			var b bool
+			if b, err = toBool(e.Key, e.Value); err == nil {
+				jo.Upsert = &b
+			}
+		case OptionHint:
+			switch e.Value.(type) {
+			case string, bson.D:
+				jo.Hint = e.Value
+			default:
+				err = fmt.Errorf("unrecognized %s value of type %T", e.Key, e.Value)
+			}
+		case OptionLet:
+			if _, ok := e.Value.(bson.D); ok {
+				jo.Let = e.Value
+			} else {
+				err = fmt.Errorf("unrecognized %s value of type %T", e.Key, e.Value)
+			}
+		case OptionComment:
+			jo.Comment = e.Value
+		case OptionCollation:
+			var c *options.Collation
+			if c, err = toCollation(e.Value); err == nil {
+				jo.Collation = c
+			}
+		case OptionArrayFilters:
+			switch a := e.Value.(type) {
+			case bson.A:
+				jo.ArrayFilters = a
+			case []interface{}:
+				jo.ArrayFilters = a
+			default:
+				err = fmt.Errorf("unrecognized %s value of type %T", e.Key, e.Value)
+			}
+		case OptionMaxEvents:
+			var n int64
+			if n, err = toNonNegativeInt64(e.Key, e.Value); err == nil {
+				jo.MaxEvents = &n
+			}
+		case OptionFullDocument, OptionFullDocumentBeforeChange:
+			var s string
+			if s, err = toString(e.Key, e.Value); err == nil {
+				fd := options.FullDocument(s)
+				if e.Key == OptionFullDocument {
+					jo.FullDocument = &fd
+				} else {
+					jo.FullDocumentBeforeChange = &fd
+				}
+			}
+		case OptionResumeAfter, OptionStartAfter:
+			// a token is either the resume token document or the string of its _data field.
+			var tok interface{}
+			switch tv := e.Value.(type) {
+			case string:
+				tok = bson.D{{Key: "_data", Value: tv}}
+			case bson.D:
+				tok = tv
+			default:
+				err = fmt.Errorf("unrecognized %s value of type %T", e.Key, e.Value)
+			}
+			if e.Key == OptionResumeAfter {
+				jo.ResumeAfter = tok
+			} else {
+				jo.StartAfter = tok
+			}
+		case OptionReturnDocument:
+			s, ok := e.Value.(string)
+			switch {
+			case ok && s == "before":
+				rd := options.Before
+				jo.ReturnDocument = &rd
+			case ok && s == "after":
+				rd := options.After
+				jo.ReturnDocument = &rd
+			default:
+				err = fmt.Errorf("unrecognized %s value %v", e.Key, e.Value)
+			}
+		}
+
+		if err != nil {
+			log.Error().Err(err).Str("option", e.Key).Msg(semLogContext)
+			return jo, err
+		}
+	}
+
+	return jo, nil
+}
+
+// MaxTimeFromJson returns the maxTimeMS option, if any, as a duration. Since the v2 driver doesn't carry a maxTime in the
+// operation options, callers apply it as a timeout on the context of the operation.
+func MaxTimeFromJson(opts []byte) (time.Duration, error) {
+	const semLogContext = "mongo-options::max-time-from-json"
+
+	d, err := util.UnmarshalJson2BsonD(opts, false)
+	if err != nil {
+		log.Error().Err(err).Msg(semLogContext)
+		return 0, err
+	}
+
+	for _, e := range d {
+		if e.Key == OptionMaxTimeMS {
+			n, err := toInt64(e.Key, e.Value)
+			if err != nil {
+				log.Error().Err(err).Msg(semLogContext)
+				return 0, err
+			}
+			return time.Duration(n) * time.Millisecond, nil
+		}
+	}
+
+	return 0, nil
+}
+
+func isSupportedOption(n string, supported []string) bool {
+	for _, s := range supported {
+		if s == n {
+			return true
+		}
+	}
+	return false
+}
+
+func isKnownOption(n string) bool {
+	switch n {
+	case OptionLimit, OptionSkip, OptionHint, OptionCollation, OptionMaxTimeMS, OptionBatchSize, OptionComment, OptionLet,
+		OptionAllowDiskUse, OptionArrayFilters, OptionBypassDocumentValidation, OptionReturnDocument, OptionUpsert,
+		OptionMaxEvents, OptionFullDocument, OptionFullDocumentBeforeChange, OptionResumeAfter, OptionStartAfter:
+		return true
+	}
+	return false
+}
+
+func toInt64(n string, v interface{}) (int64, error) {
+	switch tv := v.(type) {
+	case int32:
+		return int64(tv), nil
+	case int64:
+		return tv, nil
+	case int:
+		return int64(tv), nil
+	case float64:
+		if tv != float64(int64(tv)) {
+			return 0, fmt.Errorf("unrecognized %s value %v", n, tv)
+		}
+		return int64(tv), nil
+	}
+
+	return 0, fmt.Errorf("unrecognized %s value of type %T", n, v)
+}
+
+// toNonNegativeInt64 is toInt64 for the options where a negative value has no meaning (i.e. an already expired maxTimeMS).
+func toNonNegativeInt64(n string, v interface{}) (int64, error) {
+	i, err := toInt64(n, v)
+	if err == nil && i < 0 {
+		err = fmt.Errorf("%s value %d cannot be negative", n, i)
+	}
+
+	return i, err
+}
+
+func toBool(n string, v interface{}) (bool, error) {
+	if b, ok := v.(bool); ok {
+		return b, nil
+	}
+
+	return false, fmt.Errorf("unrecognized %s value of type %T", n, v)
+}
+
+func toCollation(v interface{}) (*options.Collation, error) {
+	d, ok := v.(bson.D)
+	if !ok {
+		return nil, fmt.Errorf("unrecognized %s value of type %T", OptionCollation, v)
+	}
+
+	var err error
+	c := options.Collation{}
+	for _, e := range d {
+		switch e.Key {
+		case "locale":
+			c.Locale, err = toString(e.Key, e.Value)
+		case "caseLevel":
+			c.CaseLevel, err = toBool(e.Key, e.Value)
+		case "caseFirst":
+			c.CaseFirst, err = toString(e.Key, e.Value)
+		case "strength":
+			var n int64
+			n, err = toInt64(e.Key, e.Value)
+			c.Strength = int(n)
+		case "numericOrdering":
+			c.NumericOrdering, err = toBool(e.Key, e.Value)
+		case "alternate":
+			c.Alternate, err = toString(e.Key, e.Value)
+		case "maxVariable":
+			c.MaxVariable, err = toString(e.Key, e.Value)
+		case "normalization":
+			c.Normalization, err = toBool(e.Key, e.Value)
+		case "backwards":
+			c.Backwards, err = toBool(e.Key, e.Value)
+		default:
+			err = fmt.Errorf("%w: %s.%s", ErrUnknownOption, OptionCollation, e.Key)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &c, nil
+}
+
+func toString(n string, v interface{}) (string, error) {
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+
+	return "", fmt.Errorf("unrecognized %s value of type %T", n, v)
+}
@@ -1,10 +1,6 @@
 package mdboptions
 
 import (
-	"encoding/json"
-	"errors"
-	"fmt"
-
 	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/util"
 	"github.com/rs/zerolog/log"
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
@@ -13,27 +9,82 @@ import (
 func FindOptionsFromJson(opts []byte, sort, projection []byte) (*options.FindOptionsBuilder, error) {
 	const semLogContext = "mongo-options::new-find-options"
 	fo := options.Find()
-	if len(opts) > 0 {
-		var m map[string]interface{}
-		err := json.Unmarshal(opts, &m)
-		if err != nil {
-			log.Error().Err(err).Msg(semLogContext)
-			return fo, err
-		}
-
-		if limitOpt, ok := m["limit"]; ok {
-			switch limitValue := limitOpt.(type) {
-			case float64:
-				fo.SetLimit(int64(limitValue))
-				log.Trace().Float64("float-limit", limitValue).Msg(semLogContext)
-			case int:
-				fo.SetLimit(int64(limitValue))
-				log.Trace().Int("int-limit", limitValue).Msg(semLogContext)
-			default:
-				err = errors.New("unrecognized limit value")
-				log.Error().Err(err).Str("param-type", fmt.Sprintf("%T", limitOpt)).Msg(semLogContext)
-			}
-		}
+
+	jo, err := ParseJsonOptions(opts, FindOptionNames)
+	if err != nil {
+		log.Error().Err(err).Msg(semLogContext)
+		return nil, err
+	}
+
+	if jo.Limit != nil {
+		fo.SetLimit(*jo.Limit)
+	}
+	if jo.Skip != nil {
+		fo.SetSkip(*jo.Skip)
+	}
+	if jo.Hint != nil {
+		fo.SetHint(jo.Hint)
+	}
+	if jo.Collation != nil {
+		fo.SetCollation(jo.Collation)
+	}
+	if jo.BatchSize != nil {
+		fo.SetBatchSize(*jo.BatchSize)
+	}
+	if jo.Comment != nil {
+		fo.SetComment(jo.Comment)
+	}
+	if jo.Let != nil {
+		fo.SetLet(jo.Let)
+	}
+	if jo.AllowDiskUse != nil {
+		fo.SetAllowDiskUse(*jo.AllowDiskUse)
+	}
+
+	srt, err := util.UnmarshalJson2BsonD(sort, false)
+	if err != nil {
+		log.Error().Err(err).Msg(semLogContext)
+		return nil, err
+	}
+
+	if len(srt) > 0 {
+		fo.SetSort(srt)
+	}
+
+	prj, err := util.UnmarshalJson2BsonD(projection, false)
+	if err != nil {
+		log.Error().Err(err).Msg(semLogContext)
+		return nil, err
+	}
+
+	if len(prj) > 0 {
+		fo.SetProjection(prj)
+	}
+
+	return fo, nil
+}
+
+func FindOneOptionsFromJson(opts []byte, sort, projection []byte) (*options.FindOneOptionsBuilder, error) {
+	const semLogContext = "mongo-options::new-find-one-options"
+	fo := options.FindOne()
+
+	jo, err := ParseJsonOptions(opts, FindOneOptionNames)
+	if err != nil {
+		log.Error().Err(err).Msg(semLogContext)
+		return nil, err
+	}
+
+	if jo.Skip != nil {
+		fo.SetSkip(*jo.Skip)
+	}
+	if jo.Hint != nil {
+		fo.SetHint(jo.Hint)
+	}
+	if jo.Collation != nil {
+		fo.SetCollation(jo.Collation)
+	}
+	if jo.Comment != nil {
+		fo.SetComment(jo.Comment)
 	}
 
 	srt, err := util.UnmarshalJson2BsonD(sort, false)
@@ -61,47 +112,38 @@ func FindOptionsFromJson(opts []byte, sort, projection []byte) (*options.FindOpt
 
 func FindOneAndUpdateOptionsFromJson(opts []byte, sort, projection []byte) (*options.FindOneAndUpdateOptionsBuilder, bool, error) {
 	const semLogContext = "mongo-options::new-find-one-and-update-options"
-	var upsert bool
 
 	fo := options.FindOneAndUpdate()
-	if len(opts) > 0 {
-		var m map[string]interface{}
-		err := json.Unmarshal(opts, &m)
-		if err != nil {
-			log.Error().Err(err).Msg(semLogContext)
-			return fo, false, err
-		}
-
-		if upsert, ok := m["upsert"]; ok {
-			if b, ok := upsert.(bool); ok {
-				fo.SetUpsert(b)
-				upsert = b
-			} else {
-				err = errors.New("unrecognized upsert flag")
-				log.Error().Msg(semLogContext)
-			}
-		}
-
-		if rd, ok := m["returnDocument"]; ok {
-			if s, ok := rd.(string); ok {
-				switch s {
-				case "before":
-					fo.SetReturnDocument(options.Before)
-				case "after":
-					fo.SetReturnDocument(options.After)
-				default:
-					err = errors.New("unrecognized returnDocument")
-					log.Error().Err(err).Str("returnDocument", s).Msg(semLogContext)
-				}
-			}
-		}
-	}
-
-	//fo, err := newFindOneAndUpdateOptions(opts)
-	//if err != nil {
-	//	log.Error().Err(err).Msg(semLogContext)
-	//	return OperationResult{StatusCode: http.StatusInternalServerError}, nil, err
-	//}
+	jo, err := ParseJsonOptions(opts, FindOneAndUpdateOptionNames)
+	if err != nil {
+		log.Error().Err(err).Msg(semLogContext)
+		return nil, false, err
+	}
+
+	if jo.Upsert != nil {
+		fo.SetUpsert(*jo.Upsert)
+	}
+	if jo.ReturnDocument != nil {
+		fo.SetReturnDocument(*jo.ReturnDocument)
+	}
+	if jo.Hint != nil {
+		fo.SetHint(jo.Hint)
+	}
+	if jo.Collation != nil {
+		fo.SetCollation(jo.Collation)
+	}
+	if jo.Comment != nil {
+		fo.SetComment(jo.Comment)
+	}
+	if jo.Let != nil {
+		fo.SetLet(jo.Let)
+	}
+	if jo.ArrayFilters != nil {
+		fo.SetArrayFilters(jo.ArrayFilters)
+	}
+	if jo.BypassDocumentValidation != nil {
+		fo.SetBypassDocumentValidation(*jo.BypassDocumentValidation)
+	}
 
 	srt, err := util.UnmarshalJson2BsonD(sort, false)
 	if err != nil {
@@ -123,24 +165,71 @@ func FindOneAndUpdateOptionsFromJson(opts []byte, sort, projection []byte) (*opt
 		fo.SetProjection(prj)
 	}
 
-	return fo, upsert, nil
+	return fo, jo.IsUpsert(), nil
+}
+
+func AggregateOptionsFromJson(opts []byte) (*options.AggregateOptionsBuilder, error) {
+	const semLogContext = "mongo-options::aggregate-options-from-json"
+	ao := options.Aggregate()
+
+	jo, err := ParseJsonOptions(opts, AggregateOptionNames)
+	if err != nil {
+		log.Error().Err(err).Msg(semLogContext)
+		return nil, err
+	}
+
+	if jo.Hint != nil {
+		ao.SetHint(jo.Hint)
+	}
+	if jo.Collation != nil {
+		ao.SetCollation(jo.Collation)
+	}
+	if jo.BatchSize != nil {
+		ao.SetBatchSize(*jo.BatchSize)
+	}
+	if jo.Comment != nil {
+		ao.SetComment(jo.Comment)
+	}
+	if jo.Let != nil {
+		ao.SetLet(jo.Let)
+	}
+	if jo.AllowDiskUse != nil {
+		ao.SetAllowDiskUse(*jo.AllowDiskUse)
+	}
+	if jo.BypassDocumentValidation != nil {
+		ao.SetBypassDocumentValidation(*jo.BypassDocumentValidation)
+	}
+
+	return ao, nil
 }
 
 func ReplaceOptionsFromJson(opts []byte) (*options.ReplaceOptionsBuilder, error) {
 	const semLogContext = "mongo-options::replace-options-from-json"
-	var isUpsert bool
 	uo := options.Replace()
-	if len(opts) > 0 {
-		delOpts := options.ReplaceOptions{}
-		err := json.Unmarshal(opts, &delOpts)
-		if err != nil {
-			log.Error().Err(err).Msg(semLogContext)
-			return nil, err
-		}
-		if delOpts.Upsert != nil {
-			isUpsert = *delOpts.Upsert
-			uo.SetUpsert(isUpsert)
-		}
+
+	jo, err := ParseJsonOptions(opts, ReplaceOptionNames)
+	if err != nil {
+		log.Error().Err(err).Msg(semLogContext)
+		return nil, err
+	}
+
+	if jo.Upsert != nil {
+		uo.SetUpsert(*jo.Upsert)
+	}
+	if jo.Hint != nil {
+		uo.SetHint(jo.Hint)
+	}
+	if jo.Collation != nil {
+		uo.SetCollation(jo.Collation)
+	}
+	if jo.Comment != nil {
+		uo.SetComment(jo.Comment)
+	}
+	if jo.Let != nil {
+		uo.SetLet(jo.Let)
+	}
+	if jo.BypassDocumentValidation != nil {
+		uo.SetBypassDocumentValidation(*jo.BypassDocumentValidation)
 	}
 
 	return uo, nil
@@ -149,72 +238,175 @@ func ReplaceOptionsFromJson(opts []byte) (*options.ReplaceOptionsBuilder, error)
 func InsertOneOptionsFromJson(opts []byte) (*options.InsertOneOptionsBuilder, error) {
 	const semLogContext = "mongo-options::insert-one-options-from-json"
 	uo := options.InsertOne()
-	if len(opts) > 0 {
-		delOpts := options.InsertOneOptions{}
-		err := json.Unmarshal(opts, &delOpts)
-		if err != nil {
-			log.Error().Err(err).Msg(semLogContext)
-			return nil, err
-		}
+
+	jo, err := ParseJsonOptions(opts, InsertOneOptionNames)
+	if err != nil {
+		log.Error().Err(err).Msg(semLogContext)
+		return nil, err
+	}
+
+	if jo.Comment != nil {
+		uo.SetComment(jo.Comment)
+	}
+	if jo.BypassDocumentValidation != nil {
+		uo.SetBypassDocumentValidation(*jo.BypassDocumentValidation)
 	}
 
 	return uo, nil
 }
 
+// BulkWriteOptionsFromJson returns unordered bulk write options: the writes of a bulk are independent of each other.
+func BulkWriteOptionsFromJson(opts []byte) (*options.BulkWriteOptionsBuilder, error) {
+	const semLogContext = "mongo-options::bulk-write-options-from-json"
+	bo := options.BulkWrite().SetOrdered(false)
+
+	jo, err := ParseJsonOptions(opts, BulkWriteOptionNames)
+	if err != nil {
+		log.Error().Err(err).Msg(semLogContext)
+		return nil, err
+	}
+
+	if jo.Comment != nil {
+		bo.SetComment(jo.Comment)
+	}
+	if jo.Let != nil {
+		bo.SetLet(jo.Let)
+	}
+	if jo.BypassDocumentValidation != nil {
+		bo.SetBypassDocumentValidation(*jo.BypassDocumentValidation)
+	}
+
+	return bo, nil
+}
+
+// ChangeStreamOptionsFromJson returns the options of a change stream together with the parsed json: the maxEvents and maxTimeMS
+// options are not change stream options and bound the collection of the events by the caller.
+func ChangeStreamOptionsFromJson(opts []byte) (*options.ChangeStreamOptionsBuilder, JsonOptions, error) {
+	const semLogContext = "mongo-options::change-stream-options-from-json"
+	co := options.ChangeStream()
+
+	jo, err := ParseJsonOptions(opts, WatchOptionNames)
+	if err != nil {
+		log.Error().Err(err).Msg(semLogContext)
+		return nil, jo, err
+	}
+
+	if jo.BatchSize != nil {
+		co.SetBatchSize(*jo.BatchSize)
+	}
+	if jo.Collation != nil {
+		co.SetCollation(*jo.Collation)
+	}
+	if jo.Comment != nil {
+		co.SetComment(jo.Comment)
+	}
+	if jo.FullDocument != nil {
+		co.SetFullDocument(*jo.FullDocument)
+	}
+	if jo.FullDocumentBeforeChange != nil {
+		co.SetFullDocumentBeforeChange(*jo.FullDocumentBeforeChange)
+	}
+	if jo.ResumeAfter != nil {
+		co.SetResumeAfter(jo.ResumeAfter)
+	}
+	if jo.StartAfter != nil {
+		co.SetStartAfter(jo.StartAfter)
+	}
+
+	return co, jo, nil
+}
+
 func UpdateOneOptionsFromJson(opts []byte) (*options.UpdateOneOptionsBuilder, bool, error) {
 	const semLogContext = "mongo-options::update-one-options-from-json"
 	uo := options.UpdateOne()
 
-	var isUpsert bool
-	if len(opts) > 0 {
-		delOpts := options.UpdateOneOptions{}
-		err := json.Unmarshal(opts, &delOpts)
-		if err != nil {
-			log.Error().Err(err).Msg(semLogContext)
-			return nil, isUpsert, err
-		}
+	jo, err := ParseJsonOptions(opts, UpdateOptionNames)
+	if err != nil {
+		log.Error().Err(err).Msg(semLogContext)
+		return nil, false, err
+	}
 
-		if delOpts.Upsert != nil {
-			isUpsert = *delOpts.Upsert
-			uo.SetUpsert(isUpsert)
-		}
+	if jo.Upsert != nil {
+		uo.SetUpsert(*jo.Upsert)
+	}
+	if jo.Hint != nil {
+		uo.SetHint(jo.Hint)
+	}
+	if jo.Collation != nil {
+		uo.SetCollation(jo.Collation)
+	}
+	if jo.Comment != nil {
+		uo.SetComment(jo.Comment)
+	}
+	if jo.Let != nil {
+		uo.SetLet(jo.Let)
+	}
+	if jo.ArrayFilters != nil {
+		uo.SetArrayFilters(jo.ArrayFilters)
+	}
+	if jo.BypassDocumentValidation != nil {
+		uo.SetBypassDocumentValidation(*jo.BypassDocumentValidation)
 	}
 
-	return uo, isUpsert, nil
+	return uo, jo.IsUpsert(), nil
 }
 
 func UpdateManyOptionsFromJson(opts []byte) (*options.UpdateManyOptionsBuilder, bool, error) {
 	const semLogContext = "mongo-options::update-many-options-from-json"
 	uo := options.UpdateMany()
 
-	var isUpsert bool
-	if len(opts) > 0 {
-		delOpts := options.UpdateManyOptions{}
-		err := json.Unmarshal(opts, &delOpts)
-		if err != nil {
-			log.Error().Err(err).Msg(semLogContext)
-			return nil, isUpsert, err
-		}
+	jo, err := ParseJsonOptions(opts, UpdateOptionNames)
+	if err != nil {
+		log.Error().Err(err).Msg(semLogContext)
+		return nil, false, err
+	}
 
-		if delOpts.Upsert != nil {
-			isUpsert = *delOpts.Upsert
-			uo.SetUpsert(isUpsert)
-		}
+	if jo.Upsert != nil {
+		uo.SetUpsert(*jo.Upsert)
+	}
+	if jo.Hint != nil {
+		uo.SetHint(jo.Hint)
+	}
+	if jo.Collation != nil {
+		uo.SetCollation(jo.Collation)
+	}
+	if jo.Comment != nil {
+		uo.SetComment(jo.Comment)
+	}
+	if jo.Let != nil {
+		uo.SetLet(jo.Let)
+	}
+	if jo.ArrayFilters != nil {
+		uo.SetArrayFilters(jo.ArrayFilters)
+	}
+	if jo.BypassDocumentValidation != nil {
+		uo.SetBypassDocumentValidation(*jo.BypassDocumentValidation)
 	}
 
-	return uo, isUpsert, nil
+	return uo, jo.IsUpsert(), nil
 }
 
 func DeleteManyOptionsFromJson(opts []byte) (*options.DeleteManyOptionsBuilder, error) {
 	const semLogContext = "mongo-options::delete-many-options-from-json"
 	uo := options.DeleteMany()
-	if len(opts) > 0 {
-		delOpts := options.DeleteManyOptions{}
-		err := json.Unmarshal(opts, &delOpts)
-		if err != nil {
-			log.Error().Err(err).Msg(semLogContext)
-			return nil, err
-		}
+
+	jo, err := ParseJsonOptions(opts, DeleteOptionNames)
+	if err != nil {
+		log.Error().Err(err).Msg(semLogContext)
+		return nil, err
+	}
+
+	if jo.Hint != nil {
+		uo.SetHint(jo.Hint)
+	}
+	if jo.Collation != nil {
+		uo.SetCollation(jo.Collation)
+	}
+	if jo.Comment != nil {
+		uo.SetComment(jo.Comment)
+	}
+	if jo.Let != nil {
+		uo.SetLet(jo.Let)
 	}
 
 	return uo, nil
@@ -223,13 +415,24 @@ func DeleteManyOptionsFromJson(opts []byte) (*options.DeleteManyOptionsBuilder,
 func DeleteOneOptionsFromJson(opts []byte) (*options.DeleteOneOptionsBuilder, error) {
 	const semLogContext = "mongo-options::delete-one-options-from-json"
 	uo := options.DeleteOne()
-	if len(opts) > 0 {
-		delOpts := options.DeleteOneOptions{}
-		err := json.Unmarshal(opts, &delOpts)
-		if err != nil {
-			log.Error().Err(err).Msg(semLogContext)
-			return nil, err
-		}
+
+	jo, err := ParseJsonOptions(opts, DeleteOptionNames)
+	if err != nil {
+		log.Error().Err(err).Msg(semLogContext)
+		return nil, err
+	}
+
+	if jo.Hint != nil {
+		uo.SetHint(jo.Hint)
+	}
+	if jo.Collation != nil {
+		uo.SetCollation(jo.Collation)
+	}
+	if jo.Comment != nil {
+		uo.SetComment(jo.Comment)
+	}
+	if jo.Let != nil {
+		uo.SetLet(jo.Let)
 	}
 
 	return uo, nil
@@ -0,0 +1,93 @@
+package mdboptions_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/util/mdboptions"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+var findOptionsTest = []byte(`{ "limit": 10, "skip": {"$numberLong": "20"}, "hint": { "year": 1, "title": -1 }, "collation": { "locale": "it", "strength": 2 }, "maxTimeMS": 500, "batchSize": 5, "comment": "find test", "let": { "y": 1939 }, "allowDiskUse": true }`)
+
+func TestFindOptions(t *testing.T) {
+	jo, err := mdboptions.ParseJsonOptions(findOptionsTest, mdboptions.FindOptionNames)
+	require.NoError(t, err)
+	require.Equal(t, int64(10), *jo.Limit)
+	require.Equal(t, int64(20), *jo.Skip)
+	require.Equal(t, bson.D{{Key: "year", Value: int32(1)}, {Key: "title", Value: int32(-1)}}, jo.Hint)
+	require.Equal(t, "it", jo.Collation.Locale)
+	require.Equal(t, 2, jo.Collation.Strength)
+	require.Equal(t, 500*time.Millisecond, *jo.MaxTime)
+	require.Equal(t, int32(5), *jo.BatchSize)
+	require.Equal(t, "find test", jo.Comment)
+	require.True(t, *jo.AllowDiskUse)
+
+	_, err = mdboptions.FindOptionsFromJson(findOptionsTest, []byte(`{ "title": 1 }`), nil)
+	require.NoError(t, err)
+
+	mt, err := mdboptions.MaxTimeFromJson(findOptionsTest)
+	require.NoError(t, err)
+	require.Equal(t, 500*time.Millisecond, mt)
+}
+
+func TestUpdateOptions(t *testing.T) {
+	opts := []byte(`{ "upsert": true, "arrayFilters": [ { "elem.grade": { "$gte": 85 } } ], "bypassDocumentValidation": true, "hint": "year_1" }`)
+	jo, err := mdboptions.ParseJsonOptions(opts, mdboptions.UpdateOptionNames)
+	require.NoError(t, err)
+	require.True(t, jo.IsUpsert())
+	require.Len(t, jo.ArrayFilters, 1)
+	require.Equal(t, "year_1", jo.Hint)
+
+	_, upsert, err := mdboptions.UpdateManyOptionsFromJson(opts)
+	require.NoError(t, err)
+	require.True(t, upsert)
+
+	jo, err = mdboptions.ParseJsonOptions([]byte(`{ "returnDocument": "after" }`), mdboptions.FindOneAndUpdateOptionNames)
+	require.NoError(t, err)
+	require.Equal(t, options.After, *jo.ReturnDocument)
+}
+
+func TestInvalidOptions(t *testing.T) {
+	_, err := mdboptions.ParseJsonOptions([]byte(`{ "limitt": 10 }`), mdboptions.FindOptionNames)
+	require.True(t, errors.Is(err, mdboptions.ErrUnknownOption))
+
+	_, err = mdboptions.DeleteOneOptionsFromJson([]byte(`{ "upsert": true }`))
+	require.True(t, errors.Is(err, mdboptions.ErrUnsupportedOption))
+
+	_, err = mdboptions.ParseJsonOptions([]byte(`{ "limit": "ten" }`), mdboptions.FindOptionNames)
+	require.Error(t, err)
+
+	_, err = mdboptions.ParseJsonOptions([]byte(`{ "batchSize": {"$numberLong": "4294967296"} }`), mdboptions.FindOptionNames)
+	require.Error(t, err)
+
+	for _, opts := range []string{`{ "skip": -1 }`, `{ "maxTimeMS": -1000 }`, `{ "batchSize": -5 }`} {
+		_, err = mdboptions.ParseJsonOptions([]byte(opts), mdboptions.FindOptionNames)
+		require.Error(t, err, opts)
+	}
+
+	_, _, err = mdboptions.ChangeStreamOptionsFromJson([]byte(`{ "maxEvents": -1 }`))
+	require.Error(t, err)
+
+	_, err = mdboptions.ParseJsonOptions([]byte(`{ "returnDocument": "later" }`), mdboptions.FindOneAndUpdateOptionNames)
+	require.Error(t, err)
+
+	_, err = mdboptions.ParseJsonOptions([]byte(`{ "collation": { "language": "it" } }`), mdboptions.FindOptionNames)
+	require.True(t, errors.Is(err, mdboptions.ErrUnknownOption))
+}
+
+func TestChangeStreamOptions(t *testing.T) {
+	opts := []byte(`{ "maxEvents": 10, "maxTimeMS": 2000, "fullDocument": "updateLookup", "resumeAfter": "8264F0A1B2000000012B022C0100296E5A1004" }`)
+	_, jo, err := mdboptions.ChangeStreamOptionsFromJson(opts)
+	require.NoError(t, err)
+	require.Equal(t, int64(10), *jo.MaxEvents)
+	require.Equal(t, 2000*time.Millisecond, *jo.MaxTime)
+	require.Equal(t, options.UpdateLookup, *jo.FullDocument)
+	require.Equal(t, bson.D{{Key: "_data", Value: "8264F0A1B2000000012B022C0100296E5A1004"}}, jo.ResumeAfter)
+
+	_, _, err = mdboptions.ChangeStreamOptionsFromJson([]byte(`{ "limit": 10 }`))
+	require.True(t, errors.Is(err, mdboptions.ErrUnsupportedOption))
+}
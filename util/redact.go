@@ -0,0 +1,244 @@
+package util
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type RedactionMode string
+
+const (
+	RedactionModeMask RedactionMode = "mask"
+	RedactionModeHash RedactionMode = "hash"
+	RedactionModeDrop RedactionMode = "drop"
+
+	RedactionMaskValue  = "***"
+	RedactionHashPrefix = "sha256:"
+
+	redactionPathSeparator  = "."
+	redactionAnySegment     = "*"
+	redactionAnySegments    = "**"
+	redactionHashHexLength  = 16
+	redactionOperatorPrefix = "$"
+)
+
+// RedactionRule applies a mode to the fields whose path matches the pattern. Paths are dot separated: '*' matches exactly one segment,
+// '**' matches any number of segments, none included. So 'customer.**' matches customer and everything below it, '*.fiscalCode' the
+// fiscalCode of any top level object and '**.fiscalCode' a fiscalCode at any depth.
+type RedactionRule struct {
+	Path string        `yaml:"path,omitempty" mapstructure:"path,omitempty" json:"path,omitempty"`
+	Mode RedactionMode `yaml:"mode,omitempty" mapstructure:"mode,omitempty" json:"mode,omitempty"`
+
+	segments []string
+}
+
+type RedactionPolicyConfig struct {
+	Rules []RedactionRule `yaml:"rules,omitempty" mapstructure:"rules,omitempty" json:"rules,omitempty"`
+	Salt  string          `yaml:"salt,omitempty" mapstructure:"salt,omitempty" json:"salt,omitempty"`
+}
+
+// RedactionPolicy rewrites documents before they get logged. Operators ($and, $set, $in, ...) and array indexes are not part of the
+// paths, dotted keys are split in their segments: the fiscalCode of {"$set": {"customer.fiscalCode": "..."}} has path
+// customer.fiscalCode. The first matching rule wins.
+type RedactionPolicy struct {
+	rules []RedactionRule
+	salt  string
+}
+
+func NewRedactionPolicy(cfg RedactionPolicyConfig) (*RedactionPolicy, error) {
+	p := &RedactionPolicy{salt: cfg.Salt}
+	for _, r := range cfg.Rules {
+		switch r.Mode {
+		case RedactionModeMask, RedactionModeHash, RedactionModeDrop:
+		case "":
+			r.Mode = RedactionModeMask
+		default:
+			return nil, fmt.Errorf("invalid redaction mode %q for path %q", r.Mode, r.Path)
+		}
+
+		if r.Path == "" {
+			return nil, fmt.Errorf("empty redaction path")
+		}
+
+		r.segments = strings.Split(r.Path, redactionPathSeparator)
+		for _, s := range r.segments {
+			if s == "" {
+				return nil, fmt.Errorf("invalid redaction path %q", r.Path)
+			}
+		}
+
+		p.rules = append(p.rules, r)
+	}
+
+	return p, nil
+}
+
+func MustNewRedactionPolicy(cfg RedactionPolicyConfig) *RedactionPolicy {
+	p, err := NewRedactionPolicy(cfg)
+	if err != nil {
+		panic(err)
+	}
+
+	return p
+}
+
+// defaultRedactionPolicy is nil until a policy is configured: the redaction is opt-in and, with no policy, RedactedExtendedJsonString
+// logs the documents as they are.
+var defaultRedactionPolicy atomic.Pointer[RedactionPolicy]
+
+// SetDefaultRedactionPolicy sets the policy used by RedactedExtendedJsonString. A nil policy disables the redaction, the initial state.
+// mongolks.Initialize sets it from the redaction section of the linked service configuration, an application can set it directly.
+func SetDefaultRedactionPolicy(p *RedactionPolicy) {
+	defaultRedactionPolicy.Store(p)
+}
+
+func DefaultRedactionPolicy() *RedactionPolicy {
+	return defaultRedactionPolicy.Load()
+}
+
+// RedactedExtendedJsonString is the log counterpart of MustToExtendedJsonString: the document is redacted with the default policy
+// and a marshalling error is reported in the returned string instead of panicking.
+func RedactedExtendedJsonString(document bson.D, canonical, escapeHtml bool) string {
+	j, err := ToExtendedJsonString(DefaultRedactionPolicy().Redact(document), canonical, escapeHtml)
+	if err != nil {
+		return fmt.Sprintf("<unable to marshal document: %v>", err)
+	}
+
+	return j
+}
+
+// Redact returns a redacted copy of the document, the document itself if the policy is nil or has no rules.
+func (p *RedactionPolicy) Redact(document bson.D) bson.D {
+	if p == nil || len(p.rules) == 0 || document == nil {
+		return document
+	}
+
+	return p.redactD(document, nil)
+}
+
+func (p *RedactionPolicy) redactD(d bson.D, path []string) bson.D {
+	out := make(bson.D, 0, len(d))
+	for _, e := range d {
+		v, keep := p.redactElement(e.Key, e.Value, path)
+		if keep {
+			out = append(out, bson.E{Key: e.Key, Value: v})
+		}
+	}
+
+	return out
+}
+
+func (p *RedactionPolicy) redactM(m bson.M, path []string) bson.M {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	out := make(bson.M, len(m))
+	for _, k := range keys {
+		v, keep := p.redactElement(k, m[k], path)
+		if keep {
+			out[k] = v
+		}
+	}
+
+	return out
+}
+
+func (p *RedactionPolicy) redactElement(key string, value interface{}, path []string) (interface{}, bool) {
+	elemPath := path
+	if !strings.HasPrefix(key, redactionOperatorPrefix) {
+		elemPath = append(append(make([]string, 0, len(path)+1), path...), strings.Split(key, redactionPathSeparator)...)
+		if r, ok := p.match(elemPath); ok {
+			switch r.Mode {
+			case RedactionModeDrop:
+				return nil, false
+			case RedactionModeHash:
+				return p.hash(value), true
+			default:
+				return RedactionMaskValue, true
+			}
+		}
+	}
+
+	return p.redactValue(value, elemPath), true
+}
+
+func (p *RedactionPolicy) redactValue(value interface{}, path []string) interface{} {
+	switch v := value.(type) {
+	case bson.D:
+		return p.redactD(v, path)
+	case bson.M:
+		return p.redactM(v, path)
+	case map[string]interface{}:
+		return map[string]interface{}(p.redactM(v, path))
+	case bson.A:
+		return bson.A(p.redactSlice(v, path))
+	case []interface{}:
+		return p.redactSlice(v, path)
+	case []bson.D:
+		a := make([]bson.D, len(v))
+		for i := range v {
+			a[i] = p.redactD(v[i], path)
+		}
+		return a
+	}
+
+	return value
+}
+
+func (p *RedactionPolicy) redactSlice(values []interface{}, path []string) []interface{} {
+	a := make([]interface{}, len(values))
+	for i := range values {
+		a[i] = p.redactValue(values[i], path)
+	}
+
+	return a
+}
+
+func (p *RedactionPolicy) match(path []string) (RedactionRule, bool) {
+	for _, r := range p.rules {
+		if matchRedactionSegments(r.segments, path) {
+			return r, true
+		}
+	}
+
+	return RedactionRule{}, false
+}
+
+func matchRedactionSegments(pattern, path []string) bool {
+	if len(pattern) == 0 {
+		return len(path) == 0
+	}
+
+	switch pattern[0] {
+	case redactionAnySegments:
+		for i := 0; i <= len(path); i++ {
+			if matchRedactionSegments(pattern[1:], path[i:]) {
+				return true
+			}
+		}
+		return false
+	case redactionAnySegment:
+		return len(path) > 0 && matchRedactionSegments(pattern[1:], path[1:])
+	}
+
+	return len(path) > 0 && pattern[0] == path[0] && matchRedactionSegments(pattern[1:], path[1:])
+}
+
+// hash gives a stable token of the value: equal values can still be correlated across log lines without being disclosed.
+func (p *RedactionPolicy) hash(value interface{}) string {
+	b, _ := bson.MarshalExtJSON(bson.D{{Key: "v", Value: value}}, true, false)
+
+	h := sha256.New()
+	h.Write([]byte(p.salt))
+	h.Write(b)
+	return RedactionHashPrefix + hex.EncodeToString(h.Sum(nil))[:redactionHashHexLength]
+}
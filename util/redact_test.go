@@ -0,0 +1,66 @@
+package util_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/util"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestRedactionPolicy(t *testing.T) {
+	p, err := util.NewRedactionPolicy(util.RedactionPolicyConfig{
+		Rules: []util.RedactionRule{
+			{Path: "**.fiscalCode", Mode: util.RedactionModeHash},
+			{Path: "customer.**", Mode: util.RedactionModeMask},
+			{Path: "*.iban", Mode: util.RedactionModeDrop},
+		},
+	})
+	require.NoError(t, err)
+
+	filter := bson.D{
+		{Key: "$and", Value: bson.A{
+			bson.D{{Key: "fiscalCode", Value: "RSSMRA80A01H501U"}},
+			bson.D{{Key: "status", Value: "active"}},
+		}},
+		{Key: "account", Value: bson.D{{Key: "iban", Value: "IT60X0542811101000000123456"}, {Key: "bank", Value: "x"}}},
+	}
+	update := bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "customer.name", Value: "Mario"},
+			{Key: "order.fiscalCode", Value: "RSSMRA80A01H501U"},
+			{Key: "total", Value: 10},
+		}},
+	}
+
+	rf := p.Redact(filter)
+	cond := rf[0].Value.(bson.A)[0].(bson.D)
+	require.True(t, strings.HasPrefix(cond[0].Value.(string), util.RedactionHashPrefix))
+	require.Equal(t, bson.D{{Key: "bank", Value: "x"}}, rf[1].Value)
+	require.Equal(t, "active", rf[0].Value.(bson.A)[1].(bson.D)[0].Value)
+
+	// the original document is untouched.
+	require.Equal(t, "RSSMRA80A01H501U", filter[0].Value.(bson.A)[0].(bson.D)[0].Value)
+
+	ru := p.Redact(update)
+	set := ru[0].Value.(bson.D)
+	require.Equal(t, util.RedactionMaskValue, set[0].Value)
+	require.Equal(t, cond[0].Value, set[1].Value, "hashes of equal values must be equal")
+	require.Equal(t, 10, set[2].Value)
+
+	_, err = util.NewRedactionPolicy(util.RedactionPolicyConfig{Rules: []util.RedactionRule{{Path: "a", Mode: "scramble"}}})
+	require.Error(t, err)
+
+	_, err = util.NewRedactionPolicy(util.RedactionPolicyConfig{Rules: []util.RedactionRule{{Path: "a..b"}}})
+	require.Error(t, err)
+}
+
+func TestRedactedExtendedJsonString(t *testing.T) {
+	doc := bson.D{{Key: "customer", Value: bson.D{{Key: "name", Value: "Mario"}}}, {Key: "n", Value: 1}}
+	require.Equal(t, `{"customer":{"name":"Mario"},"n":1}`, util.RedactedExtendedJsonString(doc, false, false))
+
+	util.SetDefaultRedactionPolicy(util.MustNewRedactionPolicy(util.RedactionPolicyConfig{Rules: []util.RedactionRule{{Path: "customer.**"}}}))
+	defer util.SetDefaultRedactionPolicy(nil)
+	require.Equal(t, `{"customer":"***","n":1}`, util.RedactedExtendedJsonString(doc, false, false))
+}
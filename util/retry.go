@@ -0,0 +1,245 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"time"
+
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-common/util/promutil"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	DefaultRetryMaxAttempts     = 3
+	DefaultRetryInitialInterval = 100 * time.Millisecond
+	DefaultRetryMaxInterval     = 5 * time.Second
+	DefaultRetryMultiplier      = 2.0
+	DefaultRetryJitter          = 0.2
+
+	RetryOutcomeSuccess = "success"
+	RetryOutcomeRetry   = "retry"
+	RetryOutcomeGiveUp  = "give-up"
+
+	MetricRetryAttempts     = "mdb-retry-attempts"
+	MetricRetryLabelName    = "name"
+	MetricRetryLabelOutcome = "outcome"
+)
+
+// RetryPredicate tells if an error is worth a new attempt.
+type RetryPredicate func(err error) bool
+
+// RetryHook is invoked after every attempt.
+type RetryHook func(evt RetryEvent)
+
+type RetryEvent struct {
+	Name    string
+	Attempt int
+	Outcome string
+	Err     error
+	Delay   time.Duration
+	Elapsed time.Duration
+}
+
+// RetryPolicy drives Retry. Zero values of attempts, intervals and multiplier take the defaults, a zero max elapsed time does not
+// bound the retries. The delay of the n-th retry is initial-interval * multiplier^(n-1), capped at max-interval and spread by
+// +/- jitter. Errors are retried when the predicate says so; without one, when the labels of the error intersect retry-labels or,
+// with no labels configured, when Classify marks the error as retryable.
+type RetryPolicy struct {
+	Name            string                           `yaml:"name,omitempty" mapstructure:"name,omitempty" json:"name,omitempty"`
+	MaxAttempts     int                              `yaml:"max-attempts,omitempty" mapstructure:"max-attempts,omitempty" json:"max-attempts,omitempty"`
+	InitialInterval time.Duration                    `yaml:"initial-interval,omitempty" mapstructure:"initial-interval,omitempty" json:"initial-interval,omitempty"`
+	MaxInterval     time.Duration                    `yaml:"max-interval,omitempty" mapstructure:"max-interval,omitempty" json:"max-interval,omitempty"`
+	Multiplier      float64                          `yaml:"multiplier,omitempty" mapstructure:"multiplier,omitempty" json:"multiplier,omitempty"`
+	Jitter          float64                          `yaml:"jitter,omitempty" mapstructure:"jitter,omitempty" json:"jitter,omitempty"`
+	MaxElapsedTime  time.Duration                    `yaml:"max-elapsed-time,omitempty" mapstructure:"max-elapsed-time,omitempty" json:"max-elapsed-time,omitempty"`
+	RetryLabels     []string                         `yaml:"retry-labels,omitempty" mapstructure:"retry-labels,omitempty" json:"retry-labels,omitempty"`
+	RefMetrics      *promutil.MetricsConfigReference `yaml:"ref-metrics,omitempty" mapstructure:"ref-metrics,omitempty" json:"ref-metrics,omitempty"`
+	RetryIf         RetryPredicate                   `yaml:"-" mapstructure:"-" json:"-"`
+	OnAttempt       RetryHook                        `yaml:"-" mapstructure:"-" json:"-"`
+}
+
+func DefaultRetryPolicy(name string) RetryPolicy {
+	return RetryPolicy{
+		Name:            name,
+		MaxAttempts:     DefaultRetryMaxAttempts,
+		InitialInterval: DefaultRetryInitialInterval,
+		MaxInterval:     DefaultRetryMaxInterval,
+		Multiplier:      DefaultRetryMultiplier,
+		Jitter:          DefaultRetryJitter,
+	}
+}
+
+// IsRetryableError is the default predicate of a policy: the error is retryable according to Classify.
+func IsRetryableError(err error) bool {
+	return Classify(err).Retryable
+}
+
+// RetryOnLabels builds a predicate matching the errors carrying at least one of the labels, i.e. RetryableWriteError or
+// TransientTransactionError.
+func RetryOnLabels(labels ...string) RetryPredicate {
+	return func(err error) bool {
+		c := Classify(err)
+		for _, l := range labels {
+			if c.HasLabel(l) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = DefaultRetryMaxAttempts
+	}
+
+	if p.InitialInterval <= 0 {
+		p.InitialInterval = DefaultRetryInitialInterval
+	}
+
+	if p.MaxInterval <= 0 {
+		p.MaxInterval = DefaultRetryMaxInterval
+	}
+
+	if p.Multiplier < 1 {
+		p.Multiplier = DefaultRetryMultiplier
+	}
+
+	if p.Jitter < 0 || p.Jitter > 1 {
+		p.Jitter = DefaultRetryJitter
+	}
+
+	if p.RetryIf == nil {
+		if len(p.RetryLabels) > 0 {
+			p.RetryIf = RetryOnLabels(p.RetryLabels...)
+		} else {
+			p.RetryIf = IsRetryableError
+		}
+	}
+
+	return p
+}
+
+// Delay returns the wait before the attempt following the given one (1-based).
+func (p RetryPolicy) Delay(attempt int) time.Duration {
+	p = p.withDefaults()
+
+	d := float64(p.InitialInterval) * math.Pow(p.Multiplier, float64(attempt-1))
+	if d > float64(p.MaxInterval) {
+		d = float64(p.MaxInterval)
+	}
+
+	if p.Jitter > 0 {
+		d = d * (1 + p.Jitter*(2*rand.Float64()-1))
+	}
+
+	return time.Duration(d)
+}
+
+// Retry runs fn until it succeeds, the error is not retryable, the attempts or the elapsed time of the policy are exhausted or the
+// context is done. The error of the last attempt is returned, joined with the context error when the wait has been interrupted.
+func Retry(ctx context.Context, policy RetryPolicy, fn func(ctx context.Context) error) error {
+	p := policy.withDefaults()
+	metrics := newRetryMetrics(p)
+
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		evt := RetryEvent{Name: p.Name, Attempt: attempt, Err: err, Elapsed: time.Since(start)}
+		if err == nil {
+			evt.Outcome = RetryOutcomeSuccess
+			p.emit(evt, metrics)
+			return nil
+		}
+
+		evt.Delay = p.Delay(attempt)
+		switch {
+		case attempt >= p.MaxAttempts, !p.RetryIf(err), ctx.Err() != nil:
+			evt.Outcome = RetryOutcomeGiveUp
+		case p.MaxElapsedTime > 0 && evt.Elapsed+evt.Delay > p.MaxElapsedTime:
+			evt.Outcome = RetryOutcomeGiveUp
+		default:
+			evt.Outcome = RetryOutcomeRetry
+		}
+
+		p.emit(evt, metrics)
+		if evt.Outcome == RetryOutcomeGiveUp {
+			if ctx.Err() != nil {
+				return errors.Join(err, ctx.Err())
+			}
+			return err
+		}
+
+		t := time.NewTimer(evt.Delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return errors.Join(err, ctx.Err())
+		case <-t.C:
+		}
+	}
+}
+
+func (p RetryPolicy) emit(evt RetryEvent, metrics *retryMetrics) {
+	const semLogContext = "mongo::retry"
+
+	switch evt.Outcome {
+	case RetryOutcomeSuccess:
+		if evt.Attempt > 1 {
+			log.Info().Str("name", evt.Name).Int("attempt", evt.Attempt).Dur("elapsed", evt.Elapsed).Msg(semLogContext + " - succeeded after retries")
+		}
+	case RetryOutcomeRetry:
+		log.Warn().Err(evt.Err).Str("name", evt.Name).Int("attempt", evt.Attempt).Dur("delay", evt.Delay).Msg(semLogContext + " - retrying")
+	default:
+		// an error that is not retried on the first attempt is reported by the caller, there is nothing to give up on.
+		if evt.Attempt == 1 {
+			log.Debug().Err(evt.Err).Str("name", evt.Name).Msg(semLogContext + " - not retryable")
+		} else {
+			log.Error().Err(evt.Err).Str("name", evt.Name).Int("attempt", evt.Attempt).Dur("elapsed", evt.Elapsed).Msg(semLogContext + " - giving up")
+		}
+	}
+
+	metrics.inc(evt)
+
+	if p.OnAttempt != nil {
+		p.OnAttempt(evt)
+	}
+}
+
+// retryMetrics counts the attempts by outcome in the mdb-retry-attempts counter of the referenced group, if any.
+type retryMetrics struct {
+	mg   promutil.Group
+	name string
+}
+
+func newRetryMetrics(p RetryPolicy) *retryMetrics {
+	const semLogContext = "mongo::retry-metrics"
+	if p.RefMetrics == nil || p.RefMetrics.IsZero() {
+		return nil
+	}
+
+	mg, err := promutil.GetGroup(p.RefMetrics.GId)
+	if err != nil {
+		log.Warn().Err(err).Str("group-id", p.RefMetrics.GId).Msg(semLogContext)
+		return nil
+	}
+
+	return &retryMetrics{mg: mg, name: p.Name}
+}
+
+func (m *retryMetrics) inc(evt RetryEvent) {
+	if m == nil {
+		return
+	}
+
+	c, err := m.mg.CollectorByIdWithLabels(MetricRetryAttempts, map[string]string{
+		MetricRetryLabelName:    m.name,
+		MetricRetryLabelOutcome: evt.Outcome,
+	})
+	if err == nil {
+		c.SetMetric(1)
+	}
+}
@@ -0,0 +1,97 @@
+package util_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/util"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+func TestRetry(t *testing.T) {
+	var outcomes []string
+	policy := util.RetryPolicy{
+		Name:            "test",
+		MaxAttempts:     4,
+		InitialInterval: time.Millisecond,
+		MaxInterval:     4 * time.Millisecond,
+		OnAttempt: func(evt util.RetryEvent) {
+			outcomes = append(outcomes, evt.Outcome)
+		},
+	}
+
+	// retryable errors are retried until success.
+	steppedDown := mongo.CommandError{Code: 189, Name: "PrimarySteppedDown"}
+	attempts := 0
+	err := util.Retry(context.Background(), policy, func(ctx context.Context) error {
+		attempts++
+		if attempts < 3 {
+			return steppedDown
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 3, attempts)
+	require.Equal(t, []string{util.RetryOutcomeRetry, util.RetryOutcomeRetry, util.RetryOutcomeSuccess}, outcomes)
+
+	// max attempts.
+	outcomes, attempts = nil, 0
+	err = util.Retry(context.Background(), policy, func(ctx context.Context) error {
+		attempts++
+		return steppedDown
+	})
+	require.ErrorAs(t, err, &steppedDown)
+	require.Equal(t, 4, attempts)
+	require.Equal(t, util.RetryOutcomeGiveUp, outcomes[len(outcomes)-1])
+
+	// duplicate keys are not retryable.
+	attempts = 0
+	err = util.Retry(context.Background(), policy, func(ctx context.Context) error {
+		attempts++
+		return mongo.WriteException{WriteErrors: []mongo.WriteError{{Code: 11000}}}
+	})
+	require.Error(t, err)
+	require.Equal(t, 1, attempts)
+
+	// label predicates.
+	labelled := mongo.CommandError{Code: 112, Labels: []string{util.ErrorLabelTransientTransaction}}
+	policy.RetryLabels = []string{util.ErrorLabelRetryableWrite}
+	attempts = 0
+	_ = util.Retry(context.Background(), policy, func(ctx context.Context) error {
+		attempts++
+		return labelled
+	})
+	require.Equal(t, 1, attempts)
+
+	policy.RetryLabels = []string{util.ErrorLabelTransientTransaction}
+	attempts = 0
+	_ = util.Retry(context.Background(), policy, func(ctx context.Context) error {
+		attempts++
+		return labelled
+	})
+	require.Equal(t, 4, attempts)
+
+	// cancellation interrupts the wait.
+	ctx, cancel := context.WithCancel(context.Background())
+	policy.InitialInterval, policy.MaxInterval = time.Hour, time.Hour
+	err = util.Retry(ctx, policy, func(ctx context.Context) error {
+		cancel()
+		return labelled
+	})
+	require.True(t, errors.Is(err, context.Canceled))
+	require.ErrorAs(t, err, &labelled)
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	p := util.RetryPolicy{InitialInterval: 100 * time.Millisecond, MaxInterval: time.Second, Multiplier: 2, Jitter: 0.5}
+	for i := 0; i < 20; i++ {
+		d := p.Delay(2)
+		require.GreaterOrEqual(t, d, 100*time.Millisecond)
+		require.LessOrEqual(t, d, 300*time.Millisecond)
+	}
+
+	require.LessOrEqual(t, p.Delay(10), 1500*time.Millisecond)
+}
@@ -0,0 +1,277 @@
+package util
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// jsonSchemaBsonTypes are the bsonType aliases of the $jsonSchema operator.
+var jsonSchemaBsonTypes = map[bson.Type]string{
+	bson.TypeDouble:           "double",
+	bson.TypeString:           "string",
+	bson.TypeEmbeddedDocument: "object",
+	bson.TypeArray:            "array",
+	bson.TypeBinary:           "binData",
+	bson.TypeUndefined:        "undefined",
+	bson.TypeObjectID:         "objectId",
+	bson.TypeBoolean:          "bool",
+	bson.TypeDateTime:         "date",
+	bson.TypeNull:             "null",
+	bson.TypeRegex:            "regex",
+	bson.TypeDBPointer:        "dbPointer",
+	bson.TypeJavaScript:       "javascript",
+	bson.TypeSymbol:           "symbol",
+	bson.TypeCodeWithScope:    "javascriptWithScope",
+	bson.TypeInt32:            "int",
+	bson.TypeTimestamp:        "timestamp",
+	bson.TypeInt64:            "long",
+	bson.TypeDecimal128:       "decimal",
+	bson.TypeMinKey:           "minKey",
+	bson.TypeMaxKey:           "maxKey",
+}
+
+type InferredType struct {
+	BsonType  string  `yaml:"bson-type,omitempty" mapstructure:"bson-type,omitempty" json:"bson-type,omitempty"`
+	Count     int     `yaml:"count,omitempty" mapstructure:"count,omitempty" json:"count,omitempty"`
+	Frequency float64 `yaml:"frequency,omitempty" mapstructure:"frequency,omitempty" json:"frequency,omitempty"`
+}
+
+// InferredField describes a field observed in the sample. Count and Frequency are relative to the occurrences of the enclosing
+// object: a field is required when it has been found in every one of them. Items describes the elements of the arrays.
+type InferredField struct {
+	Name      string          `yaml:"name,omitempty" mapstructure:"name,omitempty" json:"name,omitempty"`
+	Path      string          `yaml:"path,omitempty" mapstructure:"path,omitempty" json:"path,omitempty"`
+	Count     int             `yaml:"count,omitempty" mapstructure:"count,omitempty" json:"count,omitempty"`
+	Frequency float64         `yaml:"frequency,omitempty" mapstructure:"frequency,omitempty" json:"frequency,omitempty"`
+	Required  bool            `yaml:"required,omitempty" mapstructure:"required,omitempty" json:"required,omitempty"`
+	Types     []InferredType  `yaml:"types,omitempty" mapstructure:"types,omitempty" json:"types,omitempty"`
+	Fields    []InferredField `yaml:"fields,omitempty" mapstructure:"fields,omitempty" json:"fields,omitempty"`
+	Items     *InferredField  `yaml:"items,omitempty" mapstructure:"items,omitempty" json:"items,omitempty"`
+}
+
+type InferredSchema struct {
+	SampleSize int             `yaml:"sample-size,omitempty" mapstructure:"sample-size,omitempty" json:"sample-size,omitempty"`
+	Fields     []InferredField `yaml:"fields,omitempty" mapstructure:"fields,omitempty" json:"fields,omitempty"`
+}
+
+// SchemaInferrer accumulates the documents of a sample. Fields are reported in the order they have been first seen.
+type SchemaInferrer struct {
+	root schemaNode
+}
+
+type schemaNode struct {
+	count   int
+	types   map[bson.Type]int
+	objects int
+	fields  map[string]*schemaNode
+	order   []string
+	items   *schemaNode
+}
+
+func NewSchemaInferrer() *SchemaInferrer {
+	return &SchemaInferrer{}
+}
+
+func (si *SchemaInferrer) Add(doc bson.Raw) error {
+	si.root.count++
+	return si.root.addObject(doc)
+}
+
+func (si *SchemaInferrer) AddDocument(doc bson.D) error {
+	b, err := bson.Marshal(doc)
+	if err != nil {
+		return err
+	}
+
+	return si.Add(b)
+}
+
+func (n *schemaNode) addObject(doc bson.Raw) error {
+	n.objects++
+	elems, err := doc.Elements()
+	if err != nil {
+		return err
+	}
+
+	for _, e := range elems {
+		k := e.Key()
+		child, ok := n.fields[k]
+		if !ok {
+			if n.fields == nil {
+				n.fields = make(map[string]*schemaNode)
+			}
+			child = &schemaNode{}
+			n.fields[k] = child
+			n.order = append(n.order, k)
+		}
+
+		if err = child.addValue(e.Value()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (n *schemaNode) addValue(v bson.RawValue) error {
+	n.count++
+	if n.types == nil {
+		n.types = make(map[bson.Type]int)
+	}
+	n.types[v.Type]++
+
+	switch v.Type {
+	case bson.TypeEmbeddedDocument:
+		return n.addObject(v.Document())
+	case bson.TypeArray:
+		values, err := v.Array().Values()
+		if err != nil {
+			return err
+		}
+
+		if n.items == nil {
+			n.items = &schemaNode{}
+		}
+
+		for _, item := range values {
+			if err = n.items.addValue(item); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (si *SchemaInferrer) Schema() InferredSchema {
+	return InferredSchema{SampleSize: si.root.count, Fields: si.root.inferFields("")}
+}
+
+func (n *schemaNode) inferFields(path string) []InferredField {
+	fields := make([]InferredField, 0, len(n.order))
+	for _, k := range n.order {
+		p := k
+		if path != "" {
+			p = path + "." + k
+		}
+
+		f := n.fields[k].infer(k, p)
+		f.Frequency = frequency(f.Count, n.objects)
+		f.Required = f.Count == n.objects
+		fields = append(fields, f)
+	}
+
+	return fields
+}
+
+func (n *schemaNode) infer(name, path string) InferredField {
+	f := InferredField{Name: name, Path: path, Count: n.count}
+	for t, c := range n.types {
+		f.Types = append(f.Types, InferredType{BsonType: jsonSchemaBsonTypes[t], Count: c, Frequency: frequency(c, n.count)})
+	}
+	sort.Slice(f.Types, func(i, j int) bool {
+		if f.Types[i].Count != f.Types[j].Count {
+			return f.Types[i].Count > f.Types[j].Count
+		}
+		return f.Types[i].BsonType < f.Types[j].BsonType
+	})
+
+	if n.objects > 0 {
+		f.Fields = n.inferFields(path)
+	}
+
+	if n.items != nil && n.items.count > 0 {
+		items := n.items.infer("", path)
+		items.Frequency = 1
+		items.Required = true
+		f.Items = &items
+	}
+
+	return f
+}
+
+func frequency(count, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+
+	return float64(count) / float64(total)
+}
+
+// JsonSchema renders the schema as the argument of a $jsonSchema validator. The observed frequencies are reported in the description
+// of every property, the validator keywords are limited to bsonType, required, properties and items.
+func (s InferredSchema) JsonSchema() bson.D {
+	d := bson.D{
+		{Key: "bsonType", Value: "object"},
+		{Key: "description", Value: fmt.Sprintf("inferred from %d sampled documents", s.SampleSize)},
+	}
+
+	// an empty required array is not a valid json schema.
+	if req := requiredFields(s.Fields); len(req) > 0 {
+		d = append(d, bson.E{Key: "required", Value: req})
+	}
+
+	return append(d, bson.E{Key: "properties", Value: jsonSchemaProperties(s.Fields)})
+}
+
+// Validator returns the validator of a collMod or createCollection command.
+func (s InferredSchema) Validator() bson.D {
+	return bson.D{{Key: "$jsonSchema", Value: s.JsonSchema()}}
+}
+
+func (s InferredSchema) MarshalJsonSchema() ([]byte, error) {
+	return bson.MarshalExtJSON(s.JsonSchema(), false, false)
+}
+
+func (f InferredField) jsonSchema() bson.D {
+	types := make(bson.A, 0, len(f.Types))
+	descr := make([]string, 0, len(f.Types))
+	for _, t := range f.Types {
+		types = append(types, t.BsonType)
+		descr = append(descr, fmt.Sprintf("%s %.1f%%", t.BsonType, t.Frequency*100))
+	}
+
+	d := bson.D{}
+	if len(types) == 1 {
+		d = append(d, bson.E{Key: "bsonType", Value: types[0]})
+	} else {
+		d = append(d, bson.E{Key: "bsonType", Value: types})
+	}
+
+	d = append(d, bson.E{Key: "description", Value: fmt.Sprintf("observed in %.1f%% (%d); %s", f.Frequency*100, f.Count, strings.Join(descr, ", "))})
+	if len(f.Fields) > 0 {
+		if req := requiredFields(f.Fields); len(req) > 0 {
+			d = append(d, bson.E{Key: "required", Value: req})
+		}
+		d = append(d, bson.E{Key: "properties", Value: jsonSchemaProperties(f.Fields)})
+	}
+
+	if f.Items != nil {
+		d = append(d, bson.E{Key: "items", Value: f.Items.jsonSchema()})
+	}
+
+	return d
+}
+
+func requiredFields(fields []InferredField) bson.A {
+	req := bson.A{}
+	for _, f := range fields {
+		if f.Required {
+			req = append(req, f.Name)
+		}
+	}
+
+	return req
+}
+
+func jsonSchemaProperties(fields []InferredField) bson.D {
+	props := make(bson.D, 0, len(fields))
+	for _, f := range fields {
+		props = append(props, bson.E{Key: f.Name, Value: f.jsonSchema()})
+	}
+
+	return props
+}
@@ -0,0 +1,71 @@
+package util_test
+
+import (
+	"testing"
+
+	"github.com/GPA-Gruppo-Progetti-Avanzati-SRL/tpm-mongo-common/util"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestSchemaInferrer(t *testing.T) {
+	docs := []string{
+		`{ "_id": { "$oid": "5f1b9b9b9b9b9b9b9b9b9b9b" }, "name": "a", "qty": 1, "tags": [ "x", "y" ], "customer": { "fiscalCode": "c1" } }`,
+		`{ "_id": { "$oid": "5f1b9b9b9b9b9b9b9b9b9b9c" }, "name": "b", "qty": 2.5, "tags": [], "items": [ { "sku": "s1", "n": 1 }, { "sku": "s2" } ] }`,
+		`{ "_id": { "$oid": "5f1b9b9b9b9b9b9b9b9b9b9d" }, "name": null, "qty": 3, "customer": { "fiscalCode": "c2", "email": "e" } }`,
+	}
+
+	si := util.NewSchemaInferrer()
+	for _, d := range docs {
+		doc, err := util.UnmarshalJson2BsonD([]byte(d), false)
+		require.NoError(t, err)
+		require.NoError(t, si.AddDocument(doc))
+	}
+
+	s := si.Schema()
+	require.Equal(t, 3, s.SampleSize)
+	require.Len(t, s.Fields, 6)
+
+	byName := map[string]util.InferredField{}
+	for _, f := range s.Fields {
+		byName[f.Name] = f
+	}
+
+	require.True(t, byName["_id"].Required)
+	require.Equal(t, "objectId", byName["_id"].Types[0].BsonType)
+
+	name := byName["name"]
+	require.True(t, name.Required)
+	require.Equal(t, []util.InferredType{{BsonType: "string", Count: 2, Frequency: 2.0 / 3}, {BsonType: "null", Count: 1, Frequency: 1.0 / 3}}, name.Types)
+
+	qty := byName["qty"]
+	require.Equal(t, "int", qty.Types[0].BsonType)
+	require.Equal(t, "double", qty.Types[1].BsonType)
+
+	customer := byName["customer"]
+	require.False(t, customer.Required)
+	require.InDelta(t, 2.0/3, customer.Frequency, 1e-9)
+	require.Len(t, customer.Fields, 2)
+	require.Equal(t, "customer.fiscalCode", customer.Fields[0].Path)
+	require.True(t, customer.Fields[0].Required)
+	require.False(t, customer.Fields[1].Required)
+
+	tags := byName["tags"]
+	require.NotNil(t, tags.Items)
+	require.Equal(t, "string", tags.Items.Types[0].BsonType)
+
+	items := byName["items"]
+	require.Equal(t, "items.sku", items.Items.Fields[0].Path)
+	require.True(t, items.Items.Fields[0].Required)
+	require.False(t, items.Items.Fields[1].Required)
+
+	js := s.JsonSchema()
+	require.Equal(t, bson.E{Key: "required", Value: bson.A{"_id", "name", "qty"}}, js[2])
+
+	b, err := s.MarshalJsonSchema()
+	require.NoError(t, err)
+	require.Contains(t, string(b), `"name":{"bsonType":["string","null"]`)
+	require.Contains(t, string(b), `"items":{"bsonType":"array","description":"observed in 33.3% (1); array 100.0%","items":{"bsonType":"object"`)
+
+	require.Equal(t, "$jsonSchema", s.Validator()[0].Key)
+}